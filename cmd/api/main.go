@@ -47,6 +47,7 @@ func main() {
 
 	// 4. Setup Router
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID) // Also the correlation ID of the events a request causes
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)                 // Captures panics from handlers to prevent server crash
 	r.Use(middleware.Timeout(60 * time.Second)) // Set a timeout for all requests
@@ -59,7 +60,10 @@ func main() {
 
 	// Mount v1 routes
 	// All v1.NewV1Router routes will be prefixed with /v1
-	r.Mount(v1Route, v1.NewV1Router(cfg, dbConn))
+	// Background workers (matching, scheduling) stop when workerCtx is cancelled
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	v1Router, waitForWorkers := v1.NewV1Router(workerCtx, cfg, dbConn)
+	r.Mount(v1Route, v1Router)
 
 	// --- End Routes ---
 
@@ -92,6 +96,14 @@ func main() {
 	if err := server.Shutdown(timeoutCtx); err != nil {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
+
+	// Stop taking new bookings off the queue and let the matches and background work in flight finish
+	stopWorkers()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.DispatchMatchTimeout+time.Second)
+	defer cancelDrain()
+	if err := waitForWorkers(drainCtx); err != nil {
+		log.Error().Err(err).Msg("Matches or background work still in flight at exit")
+	}
 	log.Info().Msg("Server exiting gracefully")
 
 	// TODO: Make sure to close any other resources like DB connections here
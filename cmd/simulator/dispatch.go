@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+	"CabBookingService/internal/services/queue"
+
+	"github.com/google/uuid"
+)
+
+// syncQueue delivers every message to the subscriber and only returns from Publish once the
+// subscriber acknowledged it, so a booking is matched before the simulation clock moves on.
+// The simulation has one subscriber per topic, so consumer groups make no difference.
+// Messages of topics nobody subscribed to are dropped.
+type syncQueue struct {
+	topics map[string]*syncSubscription
+	mu     sync.Mutex
+
+	// Publish returns once the subscriber took the message. The batch matcher holds messages until
+	// its next tick, waiting for the ack would put a single booking in every batch.
+	handOff bool
+}
+
+func newSyncQueue(handOff bool) *syncQueue {
+	return &syncQueue{topics: make(map[string]*syncSubscription), handOff: handOff}
+}
+
+func (q *syncQueue) Publish(ctx context.Context, topic string, envelope queue.Envelope) error {
+	q.mu.Lock()
+	sub, ok := q.topics[topic]
+	q.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	delivery := &syncDelivery{topic: topic, envelope: envelope, done: make(chan struct{})}
+	select {
+	case sub.in <- delivery:
+	case <-sub.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if q.handOff {
+		return nil
+	}
+	select {
+	case <-delivery.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *syncQueue) Subscribe(topic string, _ string) (queue.Subscription, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	sub, ok := q.topics[topic]
+	if !ok {
+		sub = &syncSubscription{
+			queue: q,
+			topic: topic,
+			in:    make(chan queue.Delivery),
+			out:   make(chan queue.Delivery),
+			done:  make(chan struct{}),
+		}
+		q.topics[topic] = sub
+		go sub.forward()
+	}
+	return sub, nil
+}
+
+func (q *syncQueue) Close() error {
+	q.mu.Lock()
+	subs := make([]*syncSubscription, 0, len(q.topics))
+	for _, sub := range q.topics {
+		subs = append(subs, sub)
+	}
+	q.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	return nil
+}
+
+// syncSubscription hands deliveries from Publish to the subscriber without buffering them
+type syncSubscription struct {
+	queue *syncQueue
+	topic string
+	in    chan queue.Delivery
+	out   chan queue.Delivery
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *syncSubscription) Deliveries() <-chan queue.Delivery {
+	return s.out
+}
+
+func (s *syncSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.queue.mu.Lock()
+		delete(s.queue.topics, s.topic)
+		s.queue.mu.Unlock()
+	})
+}
+
+func (s *syncSubscription) forward() {
+	defer close(s.out)
+	for {
+		select {
+		case <-s.done:
+			return
+		case delivery := <-s.in:
+			select {
+			case s.out <- delivery:
+			case <-s.done:
+				// Releases the publisher waiting for it
+				_ = delivery.DeadLetter(context.Background(), queue.ErrUnsubscribed)
+				return
+			}
+		}
+	}
+}
+
+// syncDelivery lets Publish return once the subscriber is done. Retries are not simulated,
+// failed messages are logged as dead letters.
+type syncDelivery struct {
+	topic    string
+	envelope queue.Envelope
+	done     chan struct{}
+}
+
+func (d *syncDelivery) Envelope() queue.Envelope {
+	return d.envelope
+}
+
+func (d *syncDelivery) Ack(context.Context) error {
+	close(d.done)
+	return nil
+}
+
+func (d *syncDelivery) Nack(ctx context.Context, reason error) error {
+	return d.DeadLetter(ctx, reason)
+}
+
+func (d *syncDelivery) DeadLetter(ctx context.Context, reason error) error {
+	defer close(d.done)
+	return queue.NewLogDeadLetterSink().DeadLetter(ctx, d.topic, d.envelope, reason)
+}
+
+// offer is a ride offered to a simulated driver
+type offer struct {
+	driverAccountID uuid.UUID
+	bookingID       uuid.UUID
+}
+
+// offerInbox collects the ride offers pushed to drivers, the simulation answers them on its next tick.
+// Offers may arrive from other goroutines, e.g. the batch matcher's timer.
+type offerInbox struct {
+	offers []offer
+	mu     sync.Mutex
+}
+
+func (i *offerInbox) NotifyDriver(_ context.Context, driver *models.Driver, notification services.DriverNotification) error {
+	// Simulated drivers keep track of their rides themselves, only offers need an answer
+	if notification.Type != services.DriverNotificationRideOffer {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.offers = append(i.offers, offer{driverAccountID: driver.AccountId, bookingID: notification.BookingID})
+	return nil
+}
+
+// NotifyPassenger drops the message, simulated passengers don't react to notifications
+func (i *offerInbox) NotifyPassenger(_ context.Context, _ *models.Passenger, _ services.PassengerNotification) error {
+	return nil
+}
+
+// take empties the inbox
+func (i *offerInbox) take() []offer {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	offers := i.offers
+	i.offers = nil
+	return offers
+}
@@ -0,0 +1,123 @@
+// Command simulator runs the real booking, matching and location services against in-memory
+// repositories, on a synthetic city grid or a replayed log, and reports how well dispatch did.
+//
+// The dispatch strategy is configured through the same environment variables as the API
+// (DISPATCH_MODE, DISPATCH_OFFER_MODE, DISPATCH_WEIGHT_*, ...), so two strategies are compared by
+// running the simulator twice with the same seed:
+//
+//	go run ./cmd/simulator -drivers 200 -bookings-per-hour 1500 -seed 7
+//	DISPATCH_MODE=batch DISPATCH_BATCH_WINDOW=20ms go run ./cmd/simulator -drivers 200 -bookings-per-hour 1500 -seed 7
+//
+// A replay log has one JSON object per line, either a driver location or a booking:
+//
+//	{"at":"2026-03-02T08:00:00Z","type":"location","driver":"d1","lat":12.97,"lon":77.59}
+//	{"at":"2026-03-02T08:00:05Z","type":"booking","passenger":"p1","lat":12.96,"lon":77.60,"dropoff_lat":12.93,"dropoff_lon":77.62}
+//
+// Offers are answered on the next step, but batch matching runs on a wall clock timer, so runs in
+// batch mode are not exactly reproducible.
+package main
+
+import (
+	"context"
+	"flag"
+	"math/rand"
+	"os"
+	"time"
+
+	"CabBookingService/internal/config"
+	"CabBookingService/internal/logger"
+	"CabBookingService/internal/models"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	var (
+		replayFile      = flag.String("replay", "", "JSON lines log of driver locations and bookings to replay instead of a synthetic city")
+		drivers         = flag.Int("drivers", 100, "Synthetic drivers")
+		bookingsPerHour = flag.Float64("bookings-per-hour", 600, "Synthetic ride requests per hour")
+		centerLat       = flag.Float64("center-lat", 12.9716, "Latitude of the synthetic city center")
+		centerLon       = flag.Float64("center-lon", 77.5946, "Longitude of the synthetic city center")
+		sizeKm          = flag.Float64("size-km", 10, "Side of the synthetic city square")
+		blockMeters     = flag.Float64("block-m", 200, "Distance between two streets of the synthetic city")
+		minTripKm       = flag.Float64("min-trip-km", 1, "Shortest synthetic ride")
+		duration        = flag.Duration("duration", 2*time.Hour, "Simulated time, a replay defaults to the length of the log")
+		tick            = flag.Duration("tick", 5*time.Second, "Simulated time between two steps")
+		acceptRate      = flag.Float64("accept-rate", 0.9, "Chance that a driver accepts an offer")
+		patience        = flag.Duration("patience", 5*time.Minute, "Passengers without a driver after this long cancel")
+		seed            = flag.Int64("seed", 1, "Random seed, the same seed gives the same city and demand")
+		jsonOutput      = flag.Bool("json", false, "Print the report as JSON")
+		logLevel        = flag.String("log-level", "error", "Log level of the services")
+	)
+	flag.Parse()
+
+	// 1. Load configuration, a .env file is optional here
+	_ = godotenv.Load()
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error loading config")
+	}
+	// Logs go to stderr, stdout is for the report
+	logger.Init(logger.Config{Environment: "simulation", LogLevel: *logLevel})
+
+	// 2. The city: recorded or synthetic
+	rng := rand.New(rand.NewSource(*seed))
+	var events []event
+	if *replayFile != "" {
+		if events, err = replayEvents(*replayFile); err != nil {
+			log.Fatal().Err(err).Str("file", *replayFile).Msg("Failed to read replay log")
+		}
+		if !isFlagSet("duration") {
+			*duration = events[len(events)-1].At
+		}
+	} else {
+		events = syntheticEvents(gridSettings{
+			Center:          models.ExactLocation{Latitude: *centerLat, Longitude: *centerLon},
+			SizeKm:          *sizeKm,
+			BlockMeters:     *blockMeters,
+			Drivers:         *drivers,
+			BookingsPerHour: *bookingsPerHour,
+			MinTripKm:       *minTripKm,
+			Duration:        *duration,
+		}, rng)
+	}
+
+	// 3. The services under test
+	inbox := &offerInbox{}
+	platform, err := newPlatform(cfg, inbox)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up services")
+	}
+
+	// 4. Run and report
+	settings := simulationSettings{
+		Tick:       *tick,
+		Duration:   *duration,
+		AcceptRate: *acceptRate,
+		Patience:   *patience,
+	}
+	if cfg.DispatchMode == config.DispatchModeBatch {
+		settings.BatchWindow = cfg.DispatchBatchWindow
+	}
+	result := newSimulation(platform, inbox, settings, rng).run(context.Background(), events)
+
+	if *jsonOutput {
+		err = result.writeJSON(os.Stdout)
+	} else {
+		err = result.writeText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to write report")
+	}
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
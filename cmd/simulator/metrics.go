@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// metrics are collected while the simulation runs
+type metrics struct {
+	requested int
+	failed    int // Rejected by CreateBooking, e.g. outside the service area
+	matched   int
+	completed int
+	cancelled int // Passengers who gave up waiting for a driver
+
+	offers         int
+	offersAccepted int
+	offersLost     int // Accepted by the driver, but another driver was faster or the offer expired
+
+	timeToMatch []time.Duration // Request to acceptance
+	pickupETA   []time.Duration // Acceptance to arrival at the pickup
+
+	onlineTime  time.Duration // Summed over every driver
+	busyTime    time.Duration // On the way to a pickup or with a passenger on board
+	onTripTime  time.Duration // With a passenger on board
+	driverCount int
+}
+
+// report is what a simulation run is judged by
+type report struct {
+	Requested int `json:"requested"`
+	Failed    int `json:"failed"`
+	Matched   int `json:"matched"`
+	Completed int `json:"completed"`
+	Cancelled int `json:"cancelled"`
+
+	MatchRate           float64 `json:"match_rate"`
+	CancellationRate    float64 `json:"cancellation_rate"`
+	OfferAcceptanceRate float64 `json:"offer_acceptance_rate"`
+	OffersLost          int     `json:"offers_lost"`
+
+	TimeToMatch durationStats `json:"time_to_match"`
+	PickupETA   durationStats `json:"pickup_eta"`
+
+	Drivers int `json:"drivers"`
+	// Share of the drivers' online time spent serving a ride, including the drive to the pickup
+	DriverUtilisation float64 `json:"driver_utilisation"`
+	// Share of the drivers' online time with a passenger on board
+	DriverOnTripShare float64 `json:"driver_on_trip_share"`
+}
+
+// durationStats summarise durations, in seconds so the JSON output is easy to plot
+type durationStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_seconds"`
+	P50   float64 `json:"p50_seconds"`
+	P90   float64 `json:"p90_seconds"`
+	P99   float64 `json:"p99_seconds"`
+	Max   float64 `json:"max_seconds"`
+}
+
+func (m *metrics) report() report {
+	return report{
+		Requested: m.requested,
+		Failed:    m.failed,
+		Matched:   m.matched,
+		Completed: m.completed,
+		Cancelled: m.cancelled,
+
+		MatchRate:           ratio(float64(m.matched), float64(m.requested)),
+		CancellationRate:    ratio(float64(m.cancelled), float64(m.requested)),
+		OfferAcceptanceRate: ratio(float64(m.offersAccepted), float64(m.offers)),
+		OffersLost:          m.offersLost,
+
+		TimeToMatch: summarise(m.timeToMatch),
+		PickupETA:   summarise(m.pickupETA),
+
+		Drivers:           m.driverCount,
+		DriverUtilisation: ratio(float64(m.busyTime), float64(m.onlineTime)),
+		DriverOnTripShare: ratio(float64(m.onTripTime), float64(m.onlineTime)),
+	}
+}
+
+func summarise(durations []time.Duration) durationStats {
+	if len(durations) == 0 {
+		return durationStats{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return durationStats{
+		Count: len(sorted),
+		Mean:  (total / time.Duration(len(sorted))).Seconds(),
+		P50:   percentile(sorted, 0.50).Seconds(),
+		P90:   percentile(sorted, 0.90).Seconds(),
+		P99:   percentile(sorted, 0.99).Seconds(),
+		Max:   sorted[len(sorted)-1].Seconds(),
+	}
+}
+
+// percentile of sorted durations, nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func ratio(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return part / total
+}
+
+func (r report) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r report) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Bookings requested\t%d\n", r.Requested)
+	fmt.Fprintf(tw, "Bookings rejected\t%d\n", r.Failed)
+	fmt.Fprintf(tw, "Matched\t%d\t(%.1f%%)\n", r.Matched, r.MatchRate*100)
+	fmt.Fprintf(tw, "Completed\t%d\n", r.Completed)
+	fmt.Fprintf(tw, "Cancelled by passenger\t%d\t(%.1f%%)\n", r.Cancelled, r.CancellationRate*100)
+	fmt.Fprintf(tw, "Offers accepted\t%.1f%%\t(%d lost to other drivers)\n", r.OfferAcceptanceRate*100, r.OffersLost)
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "\tmean\tp50\tp90\tp99\tmax\n")
+	writeDurationStats(tw, "Time to match", r.TimeToMatch)
+	writeDurationStats(tw, "Pickup ETA", r.PickupETA)
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "Drivers\t%d\n", r.Drivers)
+	fmt.Fprintf(tw, "Driver utilisation\t%.1f%%\n", r.DriverUtilisation*100)
+	fmt.Fprintf(tw, "Time with passenger\t%.1f%%\n", r.DriverOnTripShare*100)
+	return tw.Flush()
+}
+
+func writeDurationStats(w io.Writer, name string, d durationStats) {
+	seconds := func(s float64) string {
+		return time.Duration(s * float64(time.Second)).Round(time.Second).String()
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, seconds(d.Mean), seconds(d.P50), seconds(d.P90), seconds(d.P99), seconds(d.Max))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"CabBookingService/internal/models"
+)
+
+type eventKind string
+
+const (
+	// A driver reports their location. The first report brings the driver online.
+	eventLocation eventKind = "location"
+	// A passenger requests a ride
+	eventBooking eventKind = "booking"
+)
+
+// event is something that happens to the simulated city at a point in time
+type event struct {
+	At        time.Duration // Since the start of the simulation
+	Kind      eventKind
+	Driver    string // Driver of a location event
+	Passenger string // Passenger of a booking event
+	Location  models.ExactLocation
+	Dropoff   models.ExactLocation // Only for bookings
+	CarType   models.CarType       // Only for bookings, empty means economy
+}
+
+// gridSettings describe the synthetic city, a square of SizeKm around the center with streets every BlockMeters
+type gridSettings struct {
+	Center      models.ExactLocation
+	SizeKm      float64
+	BlockMeters float64
+	Drivers     int
+	// Ride requests per hour, spread evenly over the city and arriving at random (Poisson)
+	BookingsPerHour float64
+	// Shorter rides are re-drawn, nobody books a cab to the next block
+	MinTripKm float64
+	Duration  time.Duration
+}
+
+// syntheticEvents brings every driver online at the start and spreads the bookings over the duration
+func syntheticEvents(settings gridSettings, rng *rand.Rand) []event {
+	grid := newCityGrid(settings)
+
+	events := make([]event, 0, settings.Drivers)
+	for i := 0; i < settings.Drivers; i++ {
+		events = append(events, event{
+			Kind:     eventLocation,
+			Driver:   fmt.Sprintf("driver-%d", i+1),
+			Location: grid.randomPoint(rng),
+		})
+	}
+
+	if settings.BookingsPerHour <= 0 {
+		return events
+	}
+	meanGap := float64(time.Hour) / settings.BookingsPerHour
+	for at, i := time.Duration(0), 0; ; i++ {
+		at += time.Duration(rng.ExpFloat64() * meanGap)
+		if at > settings.Duration {
+			break
+		}
+		pickup, dropoff := grid.randomTrip(rng, settings.MinTripKm)
+		events = append(events, event{
+			At:        at,
+			Kind:      eventBooking,
+			Passenger: fmt.Sprintf("passenger-%d", i+1),
+			Location:  pickup,
+			Dropoff:   dropoff,
+		})
+	}
+	return events
+}
+
+type cityGrid struct {
+	minLat, minLon   float64
+	latStep, lonStep float64 // Degrees between two streets
+	blocks           int     // Streets per side
+}
+
+func newCityGrid(settings gridSettings) cityGrid {
+	const kmPerDegree = 6371 * math.Pi / 180
+	blockKm := settings.BlockMeters / 1000
+	latStep := blockKm / kmPerDegree
+	lonStep := latStep / math.Cos(settings.Center.Latitude*math.Pi/180)
+	blocks := max(int(settings.SizeKm/blockKm), 1)
+	return cityGrid{
+		minLat:  settings.Center.Latitude - latStep*float64(blocks)/2,
+		minLon:  settings.Center.Longitude - lonStep*float64(blocks)/2,
+		latStep: latStep,
+		lonStep: lonStep,
+		blocks:  blocks,
+	}
+}
+
+// randomPoint returns a random street intersection
+func (g cityGrid) randomPoint(rng *rand.Rand) models.ExactLocation {
+	return models.ExactLocation{
+		Latitude:  g.minLat + float64(rng.Intn(g.blocks+1))*g.latStep,
+		Longitude: g.minLon + float64(rng.Intn(g.blocks+1))*g.lonStep,
+	}
+}
+
+func (g cityGrid) randomTrip(rng *rand.Rand, minTripKm float64) (pickup, dropoff models.ExactLocation) {
+	pickup = g.randomPoint(rng)
+	// Bounded, a city smaller than the minimum trip would loop forever
+	for attempt := 0; attempt < 100; attempt++ {
+		dropoff = g.randomPoint(rng)
+		if distanceKm(pickup, dropoff) >= minTripKm {
+			break
+		}
+	}
+	return pickup, dropoff
+}
+
+// recordedEvent is a line of a replay log
+type recordedEvent struct {
+	At        time.Time `json:"at"`
+	Type      eventKind `json:"type"`
+	Driver    string    `json:"driver"`
+	Passenger string    `json:"passenger"`
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lon"`
+	// Only for bookings
+	DropoffLatitude  float64        `json:"dropoff_lat"`
+	DropoffLongitude float64        `json:"dropoff_lon"`
+	CarType          models.CarType `json:"car_type"`
+}
+
+// replayEvents reads a JSON lines log of driver locations and bookings. Times are made relative to
+// the first event, so recordings of any day can be replayed.
+func replayEvents(path string) ([]event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var recorded []recordedEvent
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e recordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch {
+		case e.Type == eventLocation && e.Driver == "":
+			return nil, fmt.Errorf("line %d: location without a driver", line)
+		case e.Type == eventBooking && e.Passenger == "":
+			return nil, fmt.Errorf("line %d: booking without a passenger", line)
+		case e.Type != eventLocation && e.Type != eventBooking:
+			return nil, fmt.Errorf("line %d: unknown event type %q", line, e.Type)
+		}
+		recorded = append(recorded, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("%s has no events", path)
+	}
+
+	sort.SliceStable(recorded, func(i, j int) bool {
+		return recorded[i].At.Before(recorded[j].At)
+	})
+	start := recorded[0].At
+	events := make([]event, 0, len(recorded))
+	for _, e := range recorded {
+		events = append(events, event{
+			At:        e.At.Sub(start),
+			Kind:      e.Type,
+			Driver:    e.Driver,
+			Passenger: e.Passenger,
+			Location:  models.ExactLocation{Latitude: e.Latitude, Longitude: e.Longitude},
+			Dropoff:   models.ExactLocation{Latitude: e.DropoffLatitude, Longitude: e.DropoffLongitude},
+			CarType:   e.CarType,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type simulationSettings struct {
+	Tick     time.Duration // Simulated time between two steps
+	Duration time.Duration
+	// Chance that a driver accepts an offer, the others decline it
+	AcceptRate float64
+	// Passengers without a driver after this long cancel their booking
+	Patience time.Duration
+	// Batch dispatch runs on a wall clock timer, each step waits this long for it while bookings are waiting
+	BatchWindow time.Duration
+}
+
+// simDriver is a driver whose decisions and movements are simulated
+type simDriver struct {
+	name      string
+	accountID uuid.UUID
+	location  models.ExactLocation
+	// Accepted rides in the order they are served, the first one is the current ride
+	rides []*simRide
+	leg   *leg // Nil while idle
+}
+
+type simRide struct {
+	bookingID  uuid.UUID
+	pickup     models.ExactLocation
+	dropoff    models.ExactLocation
+	acceptedAt time.Duration
+	pickedUp   bool
+}
+
+// leg is a drive between two points, the driver moves along the straight line at constant speed
+type leg struct {
+	from, to models.ExactLocation
+	duration time.Duration
+	elapsed  time.Duration
+}
+
+func (l *leg) position() models.ExactLocation {
+	if l.duration <= 0 || l.elapsed >= l.duration {
+		return l.to
+	}
+	progress := float64(l.elapsed) / float64(l.duration)
+	return models.ExactLocation{
+		Latitude:  l.from.Latitude + (l.to.Latitude-l.from.Latitude)*progress,
+		Longitude: l.from.Longitude + (l.to.Longitude-l.from.Longitude)*progress,
+	}
+}
+
+// waitingBooking is a booking no driver accepted yet
+type waitingBooking struct {
+	requestedAt time.Duration
+}
+
+type simulation struct {
+	platform *platform
+	inbox    *offerInbox
+	settings simulationSettings
+	rng      *rand.Rand
+
+	now             time.Duration
+	drivers         []*simDriver // In order of appearance, so every run steps through them the same way
+	driverByID      map[string]*simDriver
+	driverByAccount map[uuid.UUID]*simDriver
+	passengers      map[string]uuid.UUID // Account IDs
+	waiting         map[uuid.UUID]*waitingBooking
+
+	metrics metrics
+}
+
+func newSimulation(platform *platform, inbox *offerInbox, settings simulationSettings, rng *rand.Rand) *simulation {
+	return &simulation{
+		platform:        platform,
+		inbox:           inbox,
+		settings:        settings,
+		rng:             rng,
+		driverByID:      make(map[string]*simDriver),
+		driverByAccount: make(map[uuid.UUID]*simDriver),
+		passengers:      make(map[string]uuid.UUID),
+		waiting:         make(map[uuid.UUID]*waitingBooking),
+	}
+}
+
+// run plays the events, stepping the clock by one tick at a time until the end of the simulation
+func (s *simulation) run(ctx context.Context, events []event) report {
+	next := 0
+	for s.now = 0; s.now <= s.settings.Duration; s.now += s.settings.Tick {
+		// 1. What happened in the city since the last step
+		for next < len(events) && events[next].At <= s.now {
+			s.apply(ctx, events[next])
+			next++
+		}
+
+		// 2. Give batch dispatch the chance to match the waiting bookings
+		if s.settings.BatchWindow > 0 && len(s.waiting) > 0 {
+			time.Sleep(s.settings.BatchWindow)
+		}
+
+		// 3. Drivers answer their offers, passengers waiting too long give up
+		s.answerOffers(ctx)
+		s.giveUp(ctx)
+
+		// 4. Everyone on the road moves on
+		s.drive(ctx)
+	}
+
+	s.metrics.driverCount = len(s.drivers)
+	return s.metrics.report()
+}
+
+func (s *simulation) apply(ctx context.Context, e event) {
+	switch e.Kind {
+	case eventLocation:
+		s.driverLocation(ctx, e)
+	case eventBooking:
+		s.requestRide(ctx, e)
+	}
+}
+
+// driverLocation brings a new driver online. Recorded locations of known drivers only apply while
+// they are idle, the simulation decides where drivers serving a ride go.
+func (s *simulation) driverLocation(ctx context.Context, e event) {
+	driver, ok := s.driverByID[e.Driver]
+	if !ok {
+		var err error
+		if driver, err = s.newDriver(ctx, e.Driver); err != nil {
+			log.Error().Err(err).Str("driver", e.Driver).Msg("Failed to create simulated driver")
+			return
+		}
+	} else if len(driver.rides) > 0 {
+		return
+	}
+	s.moveDriver(ctx, driver, e.Location)
+}
+
+func (s *simulation) newDriver(ctx context.Context, name string) (*simDriver, error) {
+	accountID := uuid.New()
+	driver := &models.Driver{
+		AccountId:   accountID,
+		Name:        name,
+		IsAvailable: true,
+		// Ratings differ a little, so the ranking has something to go by
+		AverageRating: 4 + s.rng.Float64(),
+		RatingCount:   20,
+		Car: models.Car{
+			PlateNumber: fmt.Sprintf("SIM-%d", len(s.drivers)+1),
+			CarType:     models.CarTypeEconomy,
+		},
+	}
+	if err := s.platform.driverRepo.Create(ctx, driver); err != nil {
+		return nil, err
+	}
+
+	simulated := &simDriver{name: name, accountID: accountID}
+	s.drivers = append(s.drivers, simulated)
+	s.driverByID[name] = simulated
+	s.driverByAccount[accountID] = simulated
+	return simulated, nil
+}
+
+func (s *simulation) requestRide(ctx context.Context, e event) {
+	accountID, ok := s.passengers[e.Passenger]
+	if !ok {
+		accountID = uuid.New()
+		if err := s.platform.passengerRepo.Create(ctx, &models.Passenger{AccountId: accountID, Name: e.Passenger}); err != nil {
+			log.Error().Err(err).Str("passenger", e.Passenger).Msg("Failed to create simulated passenger")
+			return
+		}
+		s.passengers[e.Passenger] = accountID
+	}
+
+	s.metrics.requested++
+	booking, err := s.platform.bookingService.CreateBooking(ctx, services.CreateBookingParams{
+		PassengerAccountID: accountID,
+		PickupLatitude:     e.Location.Latitude,
+		PickupLongitude:    e.Location.Longitude,
+		DropoffLatitude:    e.Dropoff.Latitude,
+		DropoffLongitude:   e.Dropoff.Longitude,
+		CarType:            e.CarType,
+	})
+	if err != nil {
+		s.metrics.failed++
+		log.Warn().Err(err).Str("passenger", e.Passenger).Msg("Booking rejected")
+		return
+	}
+	s.waiting[booking.ID] = &waitingBooking{requestedAt: s.now}
+
+	// Match now rather than on the relay's wall clock timer. The lifecycle events of other bookings
+	// may be due as well, relay until the outbox is empty.
+	for s.platform.outboxRelay.RelayDue(ctx) > 0 {
+	}
+}
+
+func (s *simulation) answerOffers(ctx context.Context) {
+	for _, o := range s.inbox.take() {
+		driver, ok := s.driverByAccount[o.driverAccountID]
+		if !ok {
+			continue
+		}
+		s.metrics.offers++
+
+		if s.rng.Float64() >= s.settings.AcceptRate {
+			if err := s.platform.bookingService.DeclineBooking(ctx, driver.accountID, o.bookingID); err != nil {
+				log.Debug().Err(err).Str("booking_id", o.bookingID.String()).Msg("Decline failed")
+			}
+			continue
+		}
+		if err := s.platform.bookingService.AcceptBooking(ctx, driver.accountID, o.bookingID); err != nil {
+			// Another driver was faster, or the offer ran out
+			s.metrics.offersLost++
+			continue
+		}
+		s.metrics.offersAccepted++
+		s.accepted(ctx, driver, o.bookingID)
+	}
+}
+
+func (s *simulation) accepted(ctx context.Context, driver *simDriver, bookingID uuid.UUID) {
+	if waiting, ok := s.waiting[bookingID]; ok {
+		s.metrics.matched++
+		s.metrics.timeToMatch = append(s.metrics.timeToMatch, s.now-waiting.requestedAt)
+		delete(s.waiting, bookingID)
+	}
+
+	booking, err := s.platform.bookingRepo.GetByID(ctx, bookingID)
+	if err != nil {
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Failed to fetch accepted booking")
+		return
+	}
+	driver.rides = append(driver.rides, &simRide{
+		bookingID:  bookingID,
+		pickup:     models.ExactLocation{Latitude: booking.PickupLatitude, Longitude: booking.PickupLongitude},
+		dropoff:    models.ExactLocation{Latitude: booking.DropoffLatitude, Longitude: booking.DropoffLongitude},
+		acceptedAt: s.now,
+	})
+	// A chained ride waits until the current one is done
+	if len(driver.rides) == 1 {
+		s.startLeg(ctx, driver, driver.rides[0].pickup)
+	}
+}
+
+// giveUp cancels the bookings whose passengers waited longer than their patience
+func (s *simulation) giveUp(ctx context.Context) {
+	for bookingID, waiting := range s.waiting {
+		if s.now-waiting.requestedAt < s.settings.Patience {
+			continue
+		}
+		if err := s.platform.bookingRepo.UpdateStatus(ctx, bookingID, models.BookingStatusCancelled); err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Failed to cancel booking")
+			continue
+		}
+		s.metrics.cancelled++
+		delete(s.waiting, bookingID)
+	}
+}
+
+// drive moves every driver by one tick and books the time they spent
+func (s *simulation) drive(ctx context.Context) {
+	for _, driver := range s.drivers {
+		s.metrics.onlineTime += s.settings.Tick
+		if len(driver.rides) > 0 {
+			s.metrics.busyTime += s.settings.Tick
+			if driver.rides[0].pickedUp {
+				s.metrics.onTripTime += s.settings.Tick
+			}
+		}
+		if driver.leg == nil {
+			continue
+		}
+
+		driver.leg.elapsed += s.settings.Tick
+		s.moveDriver(ctx, driver, driver.leg.position())
+		if driver.leg.elapsed >= driver.leg.duration {
+			s.arrived(ctx, driver)
+		}
+	}
+}
+
+// arrived picks up or drops off the passenger of the current ride
+func (s *simulation) arrived(ctx context.Context, driver *simDriver) {
+	ride := driver.rides[0]
+	if !ride.pickedUp {
+		s.metrics.pickupETA = append(s.metrics.pickupETA, s.now-ride.acceptedAt)
+		if err := s.startRide(ctx, driver, ride); err != nil {
+			log.Error().Err(err).Str("booking_id", ride.bookingID.String()).Msg("Failed to start simulated ride")
+			s.nextRide(ctx, driver)
+			return
+		}
+		ride.pickedUp = true
+		s.startLeg(ctx, driver, ride.dropoff)
+		return
+	}
+
+	if err := s.platform.bookingService.EndRide(ctx, driver.accountID, ride.bookingID); err != nil {
+		log.Error().Err(err).Str("booking_id", ride.bookingID.String()).Msg("Failed to end simulated ride")
+	} else {
+		s.metrics.completed++
+	}
+	s.nextRide(ctx, driver)
+}
+
+// startRide starts the ride with the code the passenger was given
+func (s *simulation) startRide(ctx context.Context, driver *simDriver, ride *simRide) error {
+	booking, err := s.platform.bookingRepo.GetByID(ctx, ride.bookingID)
+	if err != nil {
+		return err
+	}
+	if booking.RideStartOTPId == nil {
+		return fmt.Errorf("booking %s has no OTP", booking.ID)
+	}
+	otp, err := s.platform.otpRepo.GetById(ctx, *booking.RideStartOTPId)
+	if err != nil {
+		return err
+	}
+	return s.platform.bookingService.StartRide(ctx, driver.accountID, ride.bookingID, otp.Code)
+}
+
+// nextRide drops the current ride and heads to the next pickup, if a ride was chained
+func (s *simulation) nextRide(ctx context.Context, driver *simDriver) {
+	driver.rides = driver.rides[1:]
+	driver.leg = nil
+	if len(driver.rides) > 0 {
+		s.startLeg(ctx, driver, driver.rides[0].pickup)
+	}
+}
+
+func (s *simulation) startLeg(ctx context.Context, driver *simDriver, to models.ExactLocation) {
+	l := &leg{from: driver.location, to: to}
+	route, err := s.platform.routingProvider.Route(ctx, driver.location, to)
+	if err != nil {
+		// Jump there on the next step rather than get stuck
+		log.Warn().Err(err).Str("driver", driver.name).Msg("Could not route simulated driver")
+	} else {
+		l.duration = route.Duration
+	}
+	driver.leg = l
+}
+
+// moveDriver reports the driver's location the way the driver app does
+func (s *simulation) moveDriver(ctx context.Context, driver *simDriver, location models.ExactLocation) {
+	driver.location = location
+	if err := s.platform.locationService.UpdateDriverLocation(ctx, driver.accountID, location.Latitude, location.Longitude); err != nil {
+		log.Error().Err(err).Str("driver", driver.name).Msg("Failed to update simulated driver location")
+		return
+	}
+	if err := s.platform.trackingService.OnDriverLocationUpdate(ctx, driver.accountID, location); err != nil {
+		log.Error().Err(err).Str("driver", driver.name).Msg("Failed to update ride tracking")
+	}
+}
+
+func distanceKm(a, b models.ExactLocation) float64 {
+	return util.DistanceKm(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+}
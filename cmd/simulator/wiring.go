@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"CabBookingService/internal/config"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/repositories/memory"
+	"CabBookingService/internal/services"
+	"CabBookingService/internal/services/geocoding"
+	"CabBookingService/internal/services/pooling"
+	"CabBookingService/internal/services/ranking"
+	"CabBookingService/internal/services/routing"
+	"CabBookingService/internal/services/scheduling"
+)
+
+// platform is the real service stack, running on in-memory repositories
+type platform struct {
+	bookingService  services.BookingService
+	locationService services.LocationService
+	trackingService services.RideTrackingService
+	routingProvider routing.RoutingProvider
+	// Not started, the simulation delivers the outbox right after every booking (see requestRide)
+	outboxRelay services.OutboxRelay
+
+	// The simulation seeds drivers and passengers, reads OTPs and gives up on bookings directly
+	bookingRepo   repositories.BookingRepository
+	driverRepo    repositories.DriverRepository
+	passengerRepo repositories.PassengerRepository
+	otpRepo       repositories.OTPRepository
+}
+
+// newPlatform wires the services the way the API does, with the dispatch strategy taken from cfg
+func newPlatform(cfg *config.Config, inbox *offerInbox) (*platform, error) {
+	// 1. Init Repositories
+	store := memory.NewStore()
+	passengerRepo := memory.NewPassengerRepository(store)
+	driverRepo := memory.NewDriverRepository(store)
+	bookingRepo := memory.NewBookingRepository(store)
+	otpRepo := memory.NewOTPRepository(store)
+	reviewRepo := memory.NewReviewRepository(store)
+	paymentRepo := memory.NewPaymentRepository(store)
+	geofenceRepo := memory.NewGeofenceRepository(store)
+	savedPlaceRepo := memory.NewSavedPlaceRepository(store)
+	tripRepo := memory.NewTripRepository(store)
+	outboxRepo := memory.NewOutboxRepository(store)
+
+	// 2. Init Core Services
+	routingProvider, err := newRoutingProvider(cfg.RoutingConfig)
+	if err != nil {
+		return nil, err
+	}
+	otpService := services.NewOTPService(otpRepo)
+	locationService := services.NewNaiveLocationService(driverRepo)
+	geofenceService := services.NewGeofenceService(geofenceRepo)
+	fareService := services.NewFareService(geofenceService, locationService, routingProvider, tripRepo)
+	transactor := memory.NewTransactor()
+	trackingService := services.NewRideTrackingService(bookingRepo, driverRepo, locationService, routingProvider, transactor, outboxRepo, cfg.PickupArrivalRadiusMeters)
+	paymentService := services.NewPaymentService(paymentRepo, fareService)
+
+	offerSettings, err := offerSettings(cfg.DispatchConfig)
+	if err != nil {
+		return nil, err
+	}
+	// Not started, simulated drivers answer every offer so sequential offers only move on through declines
+	offerService := services.NewOfferService(bookingRepo, driverRepo, inbox, offerSettings)
+	poolingService := services.NewPoolingService(tripRepo, bookingRepo, locationService, routingProvider, pooling.Limits{
+		Capacity:       cfg.PoolCapacity,
+		MaxDetourRatio: cfg.PoolMaxDetourRatio,
+		MaxDetour:      cfg.PoolMaxDetour,
+	})
+
+	// 3. Init Queue and the matching consumer
+	messageQueue := newSyncQueue(cfg.DispatchMode == config.DispatchModeBatch)
+	outboxRelay := services.NewOutboxRelay(outboxRepo, messageQueue, services.OutboxSettings{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		ClaimTimeout: cfg.OutboxClaimTimeout,
+		RetryBackoff: cfg.OutboxRetryBackoff,
+		MaxBackoff:   cfg.OutboxMaxBackoff,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+	})
+	settings := services.MatchingSettings{
+		RankingWeights: ranking.Weights{
+			ETA:            cfg.DispatchWeightETA,
+			Rating:         cfg.DispatchWeightRating,
+			AcceptanceRate: cfg.DispatchWeightAcceptanceRate,
+			IdleTime:       cfg.DispatchWeightIdleTime,
+			Heading:        cfg.DispatchWeightHeading,
+		},
+		DestinationMinProgressKm: cfg.DestinationModeMinProgressKm,
+		ChainMaxRemaining:        cfg.DispatchChainMaxRemaining,
+		Workers:                  cfg.DispatchWorkers,
+		MatchTimeout:             cfg.DispatchMatchTimeout,
+	}
+	var driverMatchingService services.DriverMatchingService
+	switch cfg.DispatchMode {
+	case config.DispatchModeBatch:
+		driverMatchingService = services.NewBatchDriverMatchingService(messageQueue, locationService, bookingRepo, driverRepo, geofenceService, routingProvider, offerService, poolingService, settings, cfg.DispatchBatchWindow)
+	case config.DispatchModeGreedy:
+		driverMatchingService = services.NewDriverMatchingService(messageQueue, locationService, bookingRepo, driverRepo, geofenceService, routingProvider, offerService, poolingService, settings)
+	default:
+		return nil, fmt.Errorf("unknown dispatch mode %q", cfg.DispatchMode)
+	}
+	if err := driverMatchingService.StartConsuming(context.Background()); err != nil {
+		return nil, err
+	}
+
+	// Simulated passengers book for now, scheduled bookings are only validated
+	schedulingRules := scheduling.Rules{
+		MinLead:               cfg.SchedulingMinLead,
+		MaxHorizon:            cfg.SchedulingMaxHorizon,
+		ActivationWindow:      cfg.SchedulingActivationWindow,
+		CityActivationWindows: cfg.SchedulingCityActivationWindows,
+	}
+	bookingService := services.NewBookingService(bookingRepo, driverRepo, passengerRepo, reviewRepo, savedPlaceRepo, otpService, locationService, paymentService, geofenceService, trackingService, geocoding.NewNoopGeocoder(), inbox, offerService, poolingService, transactor, outboxRepo, schedulingRules)
+
+	return &platform{
+		bookingService:  bookingService,
+		locationService: locationService,
+		trackingService: trackingService,
+		routingProvider: routingProvider,
+		outboxRelay:     outboxRelay,
+		bookingRepo:     bookingRepo,
+		driverRepo:      driverRepo,
+		passengerRepo:   passengerRepo,
+		otpRepo:         otpRepo,
+	}, nil
+}
+
+// newRoutingProvider uses the configured road graph if there is one, like the API
+func newRoutingProvider(cfg config.RoutingConfig) (routing.RoutingProvider, error) {
+	haversineProvider := routing.NewHaversineProvider(cfg.RoutingAverageSpeedKmh)
+	if cfg.RoutingGraphFile == "" {
+		return haversineProvider, nil
+	}
+	graph, err := routing.LoadOSMGraph(cfg.RoutingGraphFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading road graph %s: %w", cfg.RoutingGraphFile, err)
+	}
+	return routing.NewFallbackProvider(routing.NewGraphProvider(graph), haversineProvider), nil
+}
+
+func offerSettings(cfg config.DispatchConfig) (services.OfferSettings, error) {
+	settings := services.OfferSettings{
+		DefaultMode:   services.OfferMode(cfg.DispatchOfferMode),
+		CityModes:     make(map[string]services.OfferMode, len(cfg.DispatchCityOfferModes)),
+		Timeout:       cfg.DispatchOfferTimeout,
+		TopK:          cfg.DispatchTopK,
+		SweepInterval: cfg.DispatchOfferSweepInterval,
+		SweepBatch:    cfg.DispatchOfferSweepBatch,
+	}
+	if !settings.DefaultMode.IsValid() {
+		return settings, fmt.Errorf("unknown offer mode %q", cfg.DispatchOfferMode)
+	}
+	for city, mode := range cfg.DispatchCityOfferModes {
+		offerMode := services.OfferMode(mode)
+		if !offerMode.IsValid() {
+			return settings, fmt.Errorf("unknown offer mode %q for %s", mode, city)
+		}
+		settings.CityModes[city] = offerMode
+	}
+	return settings, nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"CabBookingService/internal/db"
 
@@ -10,6 +11,12 @@ import (
 
 const (
 	EnvDevelopment = "development"
+
+	DispatchModeGreedy = "greedy" // Match each booking as soon as it is requested
+	DispatchModeBatch  = "batch"  // Match all bookings of a time window at once
+
+	QueueBackendMemory   = "memory"   // Lost on restart, only for a single instance
+	QueueBackendPostgres = "postgres" // Durable and shared by every instance
 )
 
 type JWTConfig struct {
@@ -17,6 +24,132 @@ type JWTConfig struct {
 	JWTExpiresIn int64  `env:"JWT_EXPIRES_IN" envDefault:"3600"` // in seconds
 }
 
+type RoutingConfig struct {
+	// OSM XML extract used to build the offline road graph. Empty means straight line estimates only.
+	RoutingGraphFile       string  `env:"ROUTING_GRAPH_FILE"`
+	RoutingAverageSpeedKmh float64 `env:"ROUTING_AVERAGE_SPEED_KMH" envDefault:"25"` // Used by the haversine fallback
+}
+
+type TrackingConfig struct {
+	// A driver closer than this to the pickup is considered ARRIVED
+	PickupArrivalRadiusMeters float64 `env:"PICKUP_ARRIVAL_RADIUS_METERS" envDefault:"100"`
+}
+
+type GeocodingConfig struct {
+	// GeoNames style gazetteer (TSV) used for offline reverse geocoding. Empty disables geocoding.
+	GeocoderGazetteerFile string  `env:"GEOCODER_GAZETTEER_FILE"`
+	GeocoderMaxDistanceKm float64 `env:"GEOCODER_MAX_DISTANCE_KM" envDefault:"5"` // Places further away than this are ignored
+}
+
+type DispatchConfig struct {
+	DispatchMode        string        `env:"DISPATCH_MODE" envDefault:"greedy"`
+	DispatchBatchWindow time.Duration `env:"DISPATCH_BATCH_WINDOW" envDefault:"2s"` // Only used in batch mode
+	// Bookings matched at the same time, only used in greedy mode
+	DispatchWorkers int `env:"DISPATCH_WORKERS" envDefault:"8"`
+	// Matching a booking, or a whole batch, is given up after this long
+	DispatchMatchTimeout time.Duration `env:"DISPATCH_MATCH_TIMEOUT" envDefault:"10s"`
+
+	// broadcast: the best ranked drivers get the offer at once, sequential: one driver at a time
+	DispatchOfferMode string `env:"DISPATCH_OFFER_MODE" envDefault:"broadcast"`
+	// Per city overrides of the offer mode, e.g. "Bengaluru:sequential,Mumbai:broadcast"
+	DispatchCityOfferModes map[string]string `env:"DISPATCH_CITY_OFFER_MODES"`
+	// Time a driver has to accept a sequential offer before it moves on to the next driver
+	DispatchOfferTimeout time.Duration `env:"DISPATCH_OFFER_TIMEOUT" envDefault:"15s"`
+	// How often sequential offers that ran out are moved on to the next driver, and how many per query
+	DispatchOfferSweepInterval time.Duration `env:"DISPATCH_OFFER_SWEEP_INTERVAL" envDefault:"1s"`
+	DispatchOfferSweepBatch    int           `env:"DISPATCH_OFFER_SWEEP_BATCH" envDefault:"100"`
+	// Only the best ranked drivers get a broadcast offer
+	DispatchTopK int `env:"DISPATCH_TOP_K" envDefault:"3"`
+	// Drivers this close to finishing a solo ride near the pickup are offered it as their next ride, 0 disables chaining
+	DispatchChainMaxRemaining time.Duration `env:"DISPATCH_CHAIN_MAX_REMAINING" envDefault:"3m"`
+
+	// Ranking weights, relative to each other
+	DispatchWeightETA            float64 `env:"DISPATCH_WEIGHT_ETA" envDefault:"0.4"`
+	DispatchWeightRating         float64 `env:"DISPATCH_WEIGHT_RATING" envDefault:"0.2"`
+	DispatchWeightAcceptanceRate float64 `env:"DISPATCH_WEIGHT_ACCEPTANCE_RATE" envDefault:"0.15"`
+	DispatchWeightIdleTime       float64 `env:"DISPATCH_WEIGHT_IDLE_TIME" envDefault:"0.15"`
+	DispatchWeightHeading        float64 `env:"DISPATCH_WEIGHT_HEADING" envDefault:"0.1"`
+}
+
+type DestinationModeConfig struct {
+	// Times a driver can turn on destination mode per day
+	DestinationModeDailyLimit int `env:"DESTINATION_MODE_DAILY_LIMIT" envDefault:"2"`
+	// A ride must bring the driver at least this much closer to their destination to be offered
+	DestinationModeMinProgressKm float64 `env:"DESTINATION_MODE_MIN_PROGRESS_KM" envDefault:"1"`
+}
+
+type PoolingConfig struct {
+	PoolCapacity int `env:"POOL_CAPACITY" envDefault:"3"` // Riders in a shared car at once
+	// Extra in-vehicle time a shared rider accepts, relative to riding alone and capped at POOL_MAX_DETOUR
+	PoolMaxDetourRatio float64       `env:"POOL_MAX_DETOUR_RATIO" envDefault:"0.5"`
+	PoolMaxDetour      time.Duration `env:"POOL_MAX_DETOUR" envDefault:"10m"`
+}
+
+type OutboxConfig struct {
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"500ms"` // How often the relay looks for due events
+	OutboxBatchSize    int           `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`      // Events delivered per poll
+	// An event claimed by a relay that died is delivered again after this long
+	OutboxClaimTimeout time.Duration `env:"OUTBOX_CLAIM_TIMEOUT" envDefault:"30s"`
+	// Failed deliveries are retried after OUTBOX_RETRY_BACKOFF, doubling up to OUTBOX_MAX_BACKOFF
+	OutboxRetryBackoff time.Duration `env:"OUTBOX_RETRY_BACKOFF" envDefault:"1s"`
+	OutboxMaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"5m"`
+	// Events are given up on after OUTBOX_MAX_ATTEMPTS failed deliveries
+	OutboxMaxAttempts int `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"10"`
+}
+
+type SchedulingConfig struct {
+	// A ride can be scheduled from SCHEDULING_MIN_LEAD up to SCHEDULING_MAX_HORIZON ahead
+	SchedulingMinLead    time.Duration `env:"SCHEDULING_MIN_LEAD" envDefault:"20m"`
+	SchedulingMaxHorizon time.Duration `env:"SCHEDULING_MAX_HORIZON" envDefault:"720h"`
+	// Scheduled bookings are sent to driver matching this long before pickup
+	SchedulingActivationWindow time.Duration `env:"SCHEDULING_ACTIVATION_WINDOW" envDefault:"15m"`
+	// Per city overrides of the activation window, e.g. "Bengaluru:30m,Mysuru:10m"
+	SchedulingCityActivationWindows map[string]time.Duration `env:"SCHEDULING_CITY_ACTIVATION_WINDOWS"`
+	SchedulingTickInterval          time.Duration            `env:"SCHEDULING_TICK_INTERVAL" envDefault:"1m"` // How often due bookings are activated
+
+	SchedulingBatchSize int `env:"SCHEDULING_BATCH_SIZE" envDefault:"100"` // Scheduled bookings activated per transaction
+	// With several replicas, only the one holding a Postgres advisory lock runs the activation tick.
+	// Activation is safe without it, the lock saves the others the work.
+	SchedulingLeaderLock bool `env:"SCHEDULING_LEADER_LOCK" envDefault:"false"`
+	// Occurrences of recurring rides are booked this far ahead
+	SchedulingRecurringHorizon time.Duration `env:"SCHEDULING_RECURRING_HORIZON" envDefault:"48h"`
+}
+
+type ReservationConfig struct {
+	// Drivers can browse and reserve scheduled rides picking up within this long
+	ReservationBrowseHorizon time.Duration `env:"RESERVATION_BROWSE_HORIZON" envDefault:"168h"`
+	// A driver's reserved rides must be at least this far apart, on top of the estimated ride duration
+	ReservationBuffer time.Duration `env:"RESERVATION_BUFFER" envDefault:"30m"`
+	// Driver and passenger are reminded of a reserved ride this long before pickup
+	ReservationReminderLead time.Duration `env:"RESERVATION_REMINDER_LEAD" envDefault:"1h"`
+}
+
+type QueueConfig struct {
+	QueueBackend      string        `env:"QUEUE_BACKEND" envDefault:"memory"`
+	QueuePollInterval time.Duration `env:"QUEUE_POLL_INTERVAL" envDefault:"500ms"` // Only used by the postgres backend, like the rest
+	QueuePrefetch     int           `env:"QUEUE_PREFETCH" envDefault:"10"`         // Messages claimed per poll
+	// A message that is not acknowledged within this time is delivered again
+	QueueVisibilityTimeout time.Duration `env:"QUEUE_VISIBILITY_TIMEOUT" envDefault:"30s"`
+	// Failed messages are retried after QUEUE_RETRY_BACKOFF, doubling up to QUEUE_MAX_BACKOFF, and
+	// moved to the dead-letter table after QUEUE_MAX_ATTEMPTS deliveries
+	QueueRetryBackoff time.Duration `env:"QUEUE_RETRY_BACKOFF" envDefault:"1s"`
+	QueueMaxBackoff   time.Duration `env:"QUEUE_MAX_BACKOFF" envDefault:"1m"`
+	QueueMaxAttempts  int           `env:"QUEUE_MAX_ATTEMPTS" envDefault:"5"`
+}
+
+type WebhookConfig struct {
+	WebhookPollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" envDefault:"1s"` // How often the dispatcher looks for due deliveries
+	WebhookBatchSize    int           `env:"WEBHOOK_BATCH_SIZE" envDefault:"50"`    // Deliveries sent per poll
+	WebhookTimeout      time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`      // Per request to a partner
+	// A delivery claimed by a dispatcher that died is sent again after this long
+	WebhookClaimTimeout time.Duration `env:"WEBHOOK_CLAIM_TIMEOUT" envDefault:"1m"`
+	// Failed deliveries are retried after WEBHOOK_RETRY_BACKOFF, doubling up to WEBHOOK_MAX_BACKOFF, and
+	// marked failed after WEBHOOK_MAX_ATTEMPTS attempts
+	WebhookRetryBackoff time.Duration `env:"WEBHOOK_RETRY_BACKOFF" envDefault:"10s"`
+	WebhookMaxBackoff   time.Duration `env:"WEBHOOK_MAX_BACKOFF" envDefault:"1h"`
+	WebhookMaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"10"`
+}
+
 // Config holds all configuration for the application
 type Config struct {
 	Environment string `env:"APP_ENV" envDefault:"development"`
@@ -25,6 +158,17 @@ type Config struct {
 
 	db.PostgresConfig
 	JWTConfig
+	RoutingConfig
+	TrackingConfig
+	GeocodingConfig
+	DispatchConfig
+	DestinationModeConfig
+	PoolingConfig
+	OutboxConfig
+	SchedulingConfig
+	ReservationConfig
+	QueueConfig
+	WebhookConfig
 }
 
 // NewConfig creates a new Config instance by parsing environment variables
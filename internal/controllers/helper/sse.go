@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// ContentTypeEventStream is the MIME type for Server-Sent Events.
+	ContentTypeEventStream = "text/event-stream"
+)
+
+// StartEventStream prepares the response for Server-Sent Events.
+// Returns false if the response writer doesn't support streaming.
+func StartEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set(HeaderContentType, ContentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return flusher, true
+}
+
+// WriteEvent writes a single named event with a JSON payload and flushes it to the client.
+func WriteEvent(w http.ResponseWriter, flusher http.Flusher, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
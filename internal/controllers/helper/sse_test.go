@@ -0,0 +1,24 @@
+package helper
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEvent(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+
+	flusher, ok := StartEventStream(rr)
+	require.True(t, ok, "httptest.ResponseRecorder supports flushing")
+	require.Equal(t, ContentTypeEventStream, rr.Header().Get(HeaderContentType))
+
+	err := WriteEvent(rr, flusher, "progress", map[string]string{"status": "ARRIVED"})
+	require.NoError(t, err)
+
+	require.Equal(t, "event: progress\ndata: {\"status\":\"ARRIVED\"}\n\n", rr.Body.String())
+	require.True(t, rr.Flushed)
+}
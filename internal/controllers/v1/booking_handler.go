@@ -5,23 +5,35 @@ import (
 	"CabBookingService/internal/domain"
 	"CabBookingService/internal/models"
 	"CabBookingService/internal/services"
+	"CabBookingService/internal/services/scheduling"
+	"CabBookingService/internal/util"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // BookingHandler holds the dependencies for the bookings controllers
 type BookingHandler struct {
-	bookingService services.BookingService
+	bookingService  services.BookingService
+	fareService     services.FareService
+	trackingService services.RideTrackingService
 }
 
 // NewBookingHandler creates a new BookingHandler
-func NewBookingHandler(bookingService services.BookingService) *BookingHandler {
+func NewBookingHandler(
+	bookingService services.BookingService,
+	fareService services.FareService,
+	trackingService services.RideTrackingService,
+) *BookingHandler {
 	return &BookingHandler{
-		bookingService: bookingService,
+		bookingService:  bookingService,
+		fareService:     fareService,
+		trackingService: trackingService,
 	}
 }
 
@@ -32,18 +44,94 @@ type CreateBookingRequest struct {
 	DropoffLatitude  float64    `json:"dropoff_latitude"`
 	DropoffLongitude float64    `json:"dropoff_longitude"`
 	ScheduledTime    *time.Time `json:"scheduled_time"`
+	// Saved places can be used instead of the coordinates
+	PickupPlaceID  *uuid.UUID `json:"pickup_place_id"`
+	DropoffPlaceID *uuid.UUID `json:"dropoff_place_id"`
+	// Intermediate stops in visiting order
+	Stops []GeoPointDTO `json:"stops"`
+	// ECONOMY (default), PREMIUM, XL or ACCESSIBLE
+	CarType string `json:"car_type"`
+	// Preferences of this ride, the passenger's saved preferences are used when omitted
+	Preferences *RidePreferencesDTO `json:"preferences"`
+	// Offer the ride without the driver gender and quiet ride preferences if no driver meets them
+	AllowPreferenceFallback bool `json:"allow_preference_fallback"`
+	// Share the ride with other passengers going the same way, for a lower fare
+	Shared bool `json:"shared"`
 }
 
 // CreateBookingResponse defines the JSON response for a successful booking
 type CreateBookingResponse struct {
-	ID         string               `json:"id"`
-	Status     models.BookingStatus `json:"status"`
-	PickupLat  float64              `json:"pickup_lat"`
-	PickupLon  float64              `json:"pickup_lon"`
-	DropoffLat float64              `json:"dropoff_lat"`
-	DropoffLon float64              `json:"dropoff_lon"`
-	CreatedAt  time.Time            `json:"created_at"`
-	UpdatedAt  time.Time            `json:"updated_at"`
+	ID             string                `json:"id"`
+	Status         models.BookingStatus  `json:"status"`
+	PickupLat      float64               `json:"pickup_lat"`
+	PickupLon      float64               `json:"pickup_lon"`
+	PickupAddress  string                `json:"pickup_address"`
+	DropoffLat     float64               `json:"dropoff_lat"`
+	DropoffLon     float64               `json:"dropoff_lon"`
+	DropoffAddress string                `json:"dropoff_address"`
+	Stops          []BookingStopResponse `json:"stops"`
+	CarType        models.CarType        `json:"car_type"`
+	Shared         bool                  `json:"shared"`
+	TripID         *uuid.UUID            `json:"trip_id"` // Shared trip, once a driver accepted
+	ScheduledTime  *time.Time            `json:"scheduled_time,omitempty"`
+	Preferences    RidePreferencesDTO    `json:"preferences"`
+	// Driver who reserved the scheduled ride, they are assigned when it's due
+	ReservedDriverID *uuid.UUID `json:"reserved_driver_id,omitempty"`
+	// True once the ride was offered without the optional preferences
+	PreferencesRelaxed bool `json:"preferences_relaxed"`
+	// True while the assigned driver is still finishing their previous ride
+	DriverFinishingRide bool      `json:"driver_finishing_ride"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+type BookingStopResponse struct {
+	Sequence  int     `json:"sequence"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address"`
+}
+
+func newCreateBookingResponse(booking *models.Booking) CreateBookingResponse {
+	stops := make([]BookingStopResponse, 0, len(booking.Stops))
+	for _, stop := range booking.Stops {
+		stops = append(stops, BookingStopResponse{
+			Sequence:  stop.Sequence,
+			Latitude:  stop.Latitude,
+			Longitude: stop.Longitude,
+			Address:   stop.Address,
+		})
+	}
+	return CreateBookingResponse{
+		ID:             booking.ID.String(),
+		Status:         booking.Status,
+		PickupLat:      booking.PickupLatitude,
+		PickupLon:      booking.PickupLongitude,
+		PickupAddress:  booking.PickupAddress,
+		DropoffLat:     booking.DropoffLatitude,
+		DropoffLon:     booking.DropoffLongitude,
+		DropoffAddress: booking.DropoffAddress,
+		Stops:          stops,
+		CarType:        booking.CarType,
+		Shared:         booking.IsShared,
+		TripID:         booking.TripId,
+		ScheduledTime:  booking.ScheduledTime,
+		Preferences:    newRidePreferencesDTO(booking.Preferences),
+		CreatedAt:      booking.CreatedAt,
+
+		ReservedDriverID:    booking.ReservedDriverId,
+		PreferencesRelaxed:  booking.PreferencesRelaxed,
+		DriverFinishingRide: booking.QueuedBehindBookingId != nil,
+		UpdatedAt:           booking.UpdatedAt,
+	}
+}
+
+func toExactLocations(points []GeoPointDTO) []models.ExactLocation {
+	locations := make([]models.ExactLocation, 0, len(points))
+	for _, p := range points {
+		locations = append(locations, models.ExactLocation{Latitude: p.Latitude, Longitude: p.Longitude})
+	}
+	return locations
 }
 
 func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
@@ -63,11 +151,7 @@ func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Add Validations (e.g. validate lat/long range)
-	//	if req.PickupLat == 0 || req.PickupLon == 0 || req.DropoffLat == 0 || req.DropoffLon == 0 {
-	//		helper.RespondWithError(w, http.StatusBadRequest, "All location fields are required")
-	//		return
-	//	}
+	// Coordinates are validated (range and geofences) by the service
 
 	// 3. Construct Params
 	params := services.CreateBookingParams{
@@ -77,26 +161,374 @@ func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		DropoffLatitude:    req.DropoffLatitude,
 		DropoffLongitude:   req.DropoffLongitude,
 		ScheduledTime:      req.ScheduledTime,
+		PickupPlaceID:      req.PickupPlaceID,
+		DropoffPlaceID:     req.DropoffPlaceID,
+		Stops:              toExactLocations(req.Stops),
+		CarType:            models.CarType(req.CarType),
+
+		AllowPreferenceFallback: req.AllowPreferenceFallback,
+		Shared:                  req.Shared,
+	}
+	if req.Preferences != nil {
+		params.Preferences = util.Ptr(req.Preferences.model())
 	}
 
 	// 4. Call Service
 	booking, err := h.bookingService.CreateBooking(r.Context(), params)
+	if err != nil {
+		helper.RespondWithError(w, bookingErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, newCreateBookingResponse(booking))
+}
+
+// TripProgressResponse is the live driver progress shown to the passenger
+type TripProgressResponse struct {
+	Status             models.BookingStatus `json:"status"`
+	DriverLatitude     *float64             `json:"driver_latitude"`
+	DriverLongitude    *float64             `json:"driver_longitude"`
+	DistanceToPickupKm *float64             `json:"distance_to_pickup_km"`
+	PickupETASeconds   *int                 `json:"pickup_eta_seconds"`
+	DriverArrivedAt    *time.Time           `json:"driver_arrived_at"`
+	WaitingSeconds     int                  `json:"waiting_seconds"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+}
+
+// BookingDetailResponse defines the JSON response of a single booking
+type BookingDetailResponse struct {
+	CreateBookingResponse
+	DriverName    *string               `json:"driver_name"`
+	DriverPhone   *string               `json:"driver_phone"`
+	ScheduledTime *time.Time            `json:"scheduled_time"`
+	Progress      *TripProgressResponse `json:"progress"`
+}
+
+func newTripProgressResponse(progress *services.TripProgress) *TripProgressResponse {
+	resp := &TripProgressResponse{
+		Status:             progress.Status,
+		DistanceToPickupKm: progress.DistanceToPickupKm,
+		DriverArrivedAt:    progress.DriverArrivedAt,
+		WaitingSeconds:     int(progress.WaitingTime.Seconds()),
+		UpdatedAt:          progress.UpdatedAt,
+	}
+	if progress.DriverLocation != nil {
+		resp.DriverLatitude = &progress.DriverLocation.Latitude
+		resp.DriverLongitude = &progress.DriverLocation.Longitude
+	}
+	if progress.PickupETA != nil {
+		resp.PickupETASeconds = util.Ptr(int(progress.PickupETA.Seconds()))
+	}
+	return resp
+}
+
+// GetBooking GET /bookings/{bookingId}
+func (h *BookingHandler) GetBooking(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse bookingId from URL
+	bookingID, err := uuid.Parse(chi.URLParam(r, "bookingId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid booking ID")
+		return
+	}
+
+	// 3. Call Services
+	booking, err := h.bookingService.GetPassengerBooking(r.Context(), account.ID, bookingID)
+	if err != nil {
+		helper.RespondWithError(w, bookingErrorStatus(err), err.Error())
+		return
+	}
+	progress, err := h.trackingService.GetTripProgress(r.Context(), booking)
 	if err != nil {
 		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	resp := CreateBookingResponse{
-		ID:         booking.ID.String(),
-		Status:     booking.Status,
-		PickupLat:  booking.PickupLatitude,
-		PickupLon:  booking.PickupLongitude,
-		DropoffLat: booking.DropoffLatitude,
-		DropoffLon: booking.DropoffLongitude,
-		CreatedAt:  booking.CreatedAt,
-		UpdatedAt:  booking.UpdatedAt,
+	resp := BookingDetailResponse{
+		CreateBookingResponse: newCreateBookingResponse(booking),
+		ScheduledTime:         booking.ScheduledTime,
+		Progress:              newTripProgressResponse(progress),
+	}
+	if booking.Driver != nil {
+		resp.DriverName = &booking.Driver.Name
+		resp.DriverPhone = &booking.Driver.PhoneNumber
+	}
+	helper.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// ChangeDestinationRequest defines the expected JSON body for a mid-trip destination change
+type ChangeDestinationRequest struct {
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	PlaceID   *uuid.UUID `json:"place_id"` // Saved place, can be used instead of the coordinates
+}
+
+// ChangeDestination PATCH /bookings/{bookingId}/destination
+func (h *BookingHandler) ChangeDestination(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse bookingId from URL and the Request
+	bookingID, err := uuid.Parse(chi.URLParam(r, "bookingId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid booking ID")
+		return
+	}
+	var req ChangeDestinationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// 3. Call Service
+	booking, err := h.bookingService.ChangeDestination(r.Context(), account.ID, bookingID, services.ChangeDestinationParams{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		PlaceID:   req.PlaceID,
+	})
+	if err != nil {
+		helper.RespondWithError(w, bookingErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newCreateBookingResponse(booking))
+}
+
+// RescheduleRequest moves the pickup of a scheduled booking
+type RescheduleRequest struct {
+	ScheduledTime *time.Time `json:"scheduled_time"`
+}
+
+// RescheduleBooking PATCH /bookings/{bookingId}/schedule
+func (h *BookingHandler) RescheduleBooking(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse bookingId from URL and the Request
+	bookingID, err := uuid.Parse(chi.URLParam(r, "bookingId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid booking ID")
+		return
+	}
+	var req RescheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ScheduledTime == nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload, scheduled_time is required")
+		return
+	}
+
+	// 3. Call Service
+	booking, err := h.bookingService.RescheduleBooking(r.Context(), account.ID, bookingID, *req.ScheduledTime)
+	if err != nil {
+		helper.RespondWithError(w, bookingErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newCreateBookingResponse(booking))
+}
+
+// StreamBookingEvents GET /bookings/{bookingId}/events
+// Server-Sent Events stream of the driver's progress. The stream ends when the ride is over
+// or when the request times out, clients are expected to reconnect (EventSource does it by default).
+func (h *BookingHandler) StreamBookingEvents(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse bookingId from URL
+	bookingID, err := uuid.Parse(chi.URLParam(r, "bookingId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid booking ID")
+		return
+	}
+
+	// 3. Check Ownership
+	booking, err := h.bookingService.GetPassengerBooking(r.Context(), account.ID, bookingID)
+	if err != nil {
+		helper.RespondWithError(w, bookingErrorStatus(err), err.Error())
+		return
+	}
+
+	// 4. Subscribe before reading the current state so we don't miss updates in between
+	updates, cancel := h.trackingService.Subscribe(bookingID)
+	defer cancel()
+
+	progress, err := h.trackingService.GetTripProgress(r.Context(), booking)
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	flusher, ok := helper.StartEventStream(w)
+	if !ok {
+		helper.RespondWithError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// 5. Stream
+	for {
+		if err := helper.WriteEvent(w, flusher, "progress", newTripProgressResponse(progress)); err != nil {
+			return // Client went away
+		}
+		if progress.Status == models.BookingStatusCompleted || progress.Status == models.BookingStatusCancelled {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			progress = &update
+		}
+	}
+}
+
+// FareEstimateRequest defines the expected JSON body for a fare estimate
+type FareEstimateRequest struct {
+	PickupLatitude   float64 `json:"pickup_latitude"`
+	PickupLongitude  float64 `json:"pickup_longitude"`
+	DropoffLatitude  float64 `json:"dropoff_latitude"`
+	DropoffLongitude float64 `json:"dropoff_longitude"`
+	// Intermediate stops in visiting order
+	Stops []GeoPointDTO `json:"stops"`
+	// Class of the top level estimate, ECONOMY by default
+	CarType string `json:"car_type"`
+}
+
+// FareEstimateResponse defines the JSON response of a fare estimate.
+// The top level fields are the estimate of the requested class, Classes lists every class.
+type FareEstimateResponse struct {
+	ClassFareResponse
+	PickupETASeconds *int                `json:"pickup_eta_seconds"` // null when no driver is nearby
+	Classes          []ClassFareResponse `json:"classes"`
+}
+
+type ClassFareResponse struct {
+	CarType          models.CarType `json:"car_type"`
+	DistanceKm       float64        `json:"distance_km"`
+	DurationSeconds  int            `json:"duration_seconds"`
+	BaseAmount       float64        `json:"base_amount"`
+	DistanceAmount   float64        `json:"distance_amount"`
+	TimeAmount       float64        `json:"time_amount"`
+	ClassMultiplier  float64        `json:"class_multiplier"`
+	ZoneMultiplier   float64        `json:"zone_multiplier"`
+	AirportSurcharge float64        `json:"airport_surcharge"`
+	Amount           float64        `json:"amount"`
+	MaxSharedAmount  float64        `json:"max_shared_amount"` // Most the same ride costs when shared
+	Currency         string         `json:"currency"`
+}
+
+func newClassFareResponse(estimate *services.FareEstimate) ClassFareResponse {
+	return ClassFareResponse{
+		CarType:          estimate.CarType,
+		DistanceKm:       estimate.DistanceKm,
+		DurationSeconds:  int(estimate.Duration.Seconds()),
+		BaseAmount:       estimate.BaseAmount,
+		DistanceAmount:   estimate.DistanceAmount,
+		TimeAmount:       estimate.TimeAmount,
+		ClassMultiplier:  estimate.ClassMultiplier,
+		ZoneMultiplier:   estimate.ZoneMultiplier,
+		AirportSurcharge: estimate.AirportSurcharge,
+		Amount:           estimate.Amount,
+		MaxSharedAmount:  estimate.MaxSharedAmount,
+		Currency:         estimate.Currency,
+	}
+}
+
+// EstimateFare POST /bookings/estimate
+func (h *BookingHandler) EstimateFare(w http.ResponseWriter, r *http.Request) {
+	// 1. Parse Request
+	var req FareEstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	requested := models.CarType(req.CarType)
+	if requested == "" {
+		requested = models.CarTypeEconomy
+	}
+	if !requested.IsValid() {
+		helper.RespondWithError(w, http.StatusBadRequest, services.ErrInvalidCarType.Error())
+		return
+	}
+
+	// 2. Call Service
+	estimates, err := h.fareService.EstimateFares(r.Context(), services.FareEstimateParams{
+		PickupLatitude:   req.PickupLatitude,
+		PickupLongitude:  req.PickupLongitude,
+		DropoffLatitude:  req.DropoffLatitude,
+		DropoffLongitude: req.DropoffLongitude,
+		Stops:            toExactLocations(req.Stops),
+	})
+	if err != nil {
+		helper.RespondWithError(w, bookingErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := FareEstimateResponse{Classes: make([]ClassFareResponse, 0, len(estimates))}
+	for i := range estimates {
+		class := newClassFareResponse(&estimates[i])
+		if estimates[i].CarType == requested {
+			resp.ClassFareResponse = class
+			if estimates[i].PickupETA != nil {
+				resp.PickupETASeconds = util.Ptr(int(estimates[i].PickupETA.Seconds()))
+			}
+		}
+		resp.Classes = append(resp.Classes, class)
+	}
+	helper.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// bookingErrorStatus maps service errors to HTTP status codes.
+// Validation failures are the client's fault, everything else is treated as a server error.
+func bookingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidCoordinates),
+		errors.Is(err, services.ErrTooManyStops),
+		errors.Is(err, services.ErrInvalidCarType),
+		errors.Is(err, services.ErrInvalidPreferences),
+		errors.Is(err, services.ErrSharedRideWithStops),
+		errors.Is(err, scheduling.ErrTooSoon),
+		errors.Is(err, scheduling.ErrTooFar):
+		return http.StatusBadRequest
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSavedPlaceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrBookingNotOwned),
+		errors.Is(err, services.ErrSavedPlaceNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrPickupOutsideServiceArea),
+		errors.Is(err, services.ErrPickupInRestrictedZone),
+		errors.Is(err, services.ErrDropoffInRestrictedZone):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrDestinationChangeNotAllowed),
+		errors.Is(err, services.ErrRescheduleNotAllowed),
+		errors.Is(err, services.ErrBookingChanged),
+		errors.Is(err, services.ErrOccurrenceTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
 	}
-	helper.RespondWithJSON(w, http.StatusCreated, resp)
 }
 
 // RateRequest Struct
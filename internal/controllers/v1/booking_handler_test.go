@@ -0,0 +1,29 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"CabBookingService/internal/services"
+	"CabBookingService/internal/services/scheduling"
+)
+
+func TestBookingErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{scheduling.ErrTooSoon, http.StatusBadRequest},
+		{scheduling.ErrTooFar, http.StatusBadRequest},
+		{services.ErrBookingNotOwned, http.StatusForbidden},
+		{services.ErrRescheduleNotAllowed, http.StatusConflict},
+		{fmt.Errorf("rescheduling: %w", services.ErrOccurrenceTaken), http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		if got := bookingErrorStatus(tt.err); got != tt.want {
+			t.Errorf("bookingErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"CabBookingService/internal/controllers/helper"
@@ -13,13 +14,15 @@ import (
 )
 
 type DriverHandler struct {
-	bookingService services.BookingService
+	bookingService         services.BookingService
+	destinationModeService services.DestinationModeService
 }
 
 // NewDriverHandler creates a new DriverHandler
-func NewDriverHandler(bookingService services.BookingService) *DriverHandler {
+func NewDriverHandler(bookingService services.BookingService, destinationModeService services.DestinationModeService) *DriverHandler {
 	return &DriverHandler{
-		bookingService: bookingService,
+		bookingService:         bookingService,
+		destinationModeService: destinationModeService,
 	}
 }
 
@@ -51,7 +54,7 @@ func (h *DriverHandler) AcceptBooking(w http.ResponseWriter, r *http.Request) {
 	// 3. Call Service to accept booking
 	err = h.bookingService.AcceptBooking(r.Context(), account.ID, bookingID)
 	if err != nil {
-		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+		helper.RespondWithError(w, offerErrorStatus(err), err.Error())
 		return
 	}
 
@@ -62,6 +65,35 @@ func (h *DriverHandler) AcceptBooking(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// DeclineBooking - POST /v1/driver/bookings/{bookingId}/decline
+func (h *DriverHandler) DeclineBooking(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Get Booking ID from URL params
+	bookingID, err := uuid.Parse(chi.URLParam(r, "bookingId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid booking ID")
+		return
+	}
+
+	// 3. Call Service to decline the offer
+	if err := h.bookingService.DeclineBooking(r.Context(), account.ID, bookingID); err != nil {
+		helper.RespondWithError(w, offerErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, DriverActionResponse{
+		BookingID: bookingID.String(),
+		Status:    models.OfferStatusDeclined.String(),
+		Message:   "You have declined the ride",
+	})
+}
+
 // CancelBooking - POST /v1/driver/bookings/{bookingId}/cancel
 func (h *DriverHandler) CancelBooking(w http.ResponseWriter, r *http.Request) {
 	// 1. Get Account from context (set by AuthMiddleware)
@@ -238,3 +270,79 @@ func (h *DriverHandler) ToggleAvailability(w http.ResponseWriter, r *http.Reques
 		"message": "Driver is now " + statusMsg,
 	})
 }
+
+// SetDestination - PUT /v1/driver/destination
+func (h *DriverHandler) SetDestination(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Request
+	var req GeoPointDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	// 3. Call Service
+	driver, err := h.destinationModeService.SetDestination(r.Context(), account.ID, models.ExactLocation{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+	})
+	if err != nil {
+		helper.RespondWithError(w, destinationErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    "Destination mode is on",
+		"latitude":   req.Latitude,
+		"longitude":  req.Longitude,
+		"uses_today": driver.DestinationUses,
+	})
+}
+
+// ClearDestination - DELETE /v1/driver/destination
+func (h *DriverHandler) ClearDestination(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.destinationModeService.ClearDestination(r.Context(), account.ID); err != nil {
+		helper.RespondWithError(w, destinationErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Destination mode is off"})
+}
+
+func destinationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidCoordinates):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrDestinationModeLimitReached):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// offerErrorStatus maps errors of accepting or declining an offer to HTTP status codes
+func offerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotOfferedToDriver):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrOfferExpired),
+		errors.Is(err, services.ErrOfferDeclined):
+		return http.StatusGone
+	case errors.Is(err, services.ErrSharedRideNoLongerFits):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,158 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GeofenceHandler holds the dependencies for the admin geofence controllers
+type GeofenceHandler struct {
+	geofenceService services.GeofenceService
+}
+
+// NewGeofenceHandler creates a new GeofenceHandler
+func NewGeofenceHandler(geofenceService services.GeofenceService) *GeofenceHandler {
+	return &GeofenceHandler{
+		geofenceService: geofenceService,
+	}
+}
+
+// --- Requests / Responses ---
+
+type GeoPointDTO struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type CreateGeofenceRequest struct {
+	Name                string        `json:"name"`
+	City                string        `json:"city"`
+	Type                string        `json:"type"` // SERVICE_AREA, AIRPORT or RESTRICTED
+	Polygon             []GeoPointDTO `json:"polygon"`
+	NoPickup            bool          `json:"no_pickup"`
+	NoDropoff           bool          `json:"no_dropoff"`
+	FareMultiplier      float64       `json:"fare_multiplier"`
+	FlatSurcharge       float64       `json:"flat_surcharge"`
+	RequiresDriverQueue bool          `json:"requires_driver_queue"`
+}
+
+type GeofenceResponse struct {
+	ID                  string        `json:"id"`
+	Name                string        `json:"name"`
+	City                string        `json:"city"`
+	Type                string        `json:"type"`
+	IsActive            bool          `json:"is_active"`
+	Polygon             []GeoPointDTO `json:"polygon"`
+	NoPickup            bool          `json:"no_pickup"`
+	NoDropoff           bool          `json:"no_dropoff"`
+	FareMultiplier      float64       `json:"fare_multiplier"`
+	FlatSurcharge       float64       `json:"flat_surcharge"`
+	RequiresDriverQueue bool          `json:"requires_driver_queue"`
+	CreatedAt           time.Time     `json:"created_at"`
+}
+
+func newGeofenceResponse(g *models.Geofence) GeofenceResponse {
+	polygon := make([]GeoPointDTO, 0, len(g.Polygon))
+	for _, p := range g.Polygon {
+		polygon = append(polygon, GeoPointDTO{Latitude: p.Latitude, Longitude: p.Longitude})
+	}
+	return GeofenceResponse{
+		ID:                  g.ID.String(),
+		Name:                g.Name,
+		City:                g.City,
+		Type:                g.Type.String(),
+		IsActive:            g.IsActive,
+		Polygon:             polygon,
+		NoPickup:            g.NoPickup,
+		NoDropoff:           g.NoDropoff,
+		FareMultiplier:      g.FareMultiplier,
+		FlatSurcharge:       g.FlatSurcharge,
+		RequiresDriverQueue: g.RequiresDriverQueue,
+		CreatedAt:           g.CreatedAt,
+	}
+}
+
+// --- Handlers ---
+
+// CreateGeofence - POST /v1/admin/geofences
+func (h *GeofenceHandler) CreateGeofence(w http.ResponseWriter, r *http.Request) {
+	// 1. Parse Request
+	var req CreateGeofenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	polygon := make([]models.GeoPoint, 0, len(req.Polygon))
+	for _, p := range req.Polygon {
+		polygon = append(polygon, models.GeoPoint{Latitude: p.Latitude, Longitude: p.Longitude})
+	}
+
+	// 2. Call Service
+	geofence, err := h.geofenceService.CreateGeofence(r.Context(), services.CreateGeofenceParams{
+		Name:                req.Name,
+		City:                req.City,
+		Type:                models.GeofenceType(req.Type),
+		Polygon:             polygon,
+		NoPickup:            req.NoPickup,
+		NoDropoff:           req.NoDropoff,
+		FareMultiplier:      req.FareMultiplier,
+		FlatSurcharge:       req.FlatSurcharge,
+		RequiresDriverQueue: req.RequiresDriverQueue,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidGeofence) {
+			helper.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, newGeofenceResponse(geofence))
+}
+
+// ListGeofences - GET /v1/admin/geofences?city=
+func (h *GeofenceHandler) ListGeofences(w http.ResponseWriter, r *http.Request) {
+	geofences, err := h.geofenceService.ListGeofences(r.Context(), r.URL.Query().Get("city"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := make([]GeofenceResponse, 0, len(geofences))
+	for i := range geofences {
+		resp = append(resp, newGeofenceResponse(&geofences[i]))
+	}
+	helper.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// DeleteGeofence - DELETE /v1/admin/geofences/{geofenceId}
+func (h *GeofenceHandler) DeleteGeofence(w http.ResponseWriter, r *http.Request) {
+	geofenceID, err := uuid.Parse(chi.URLParam(r, "geofenceId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid geofence ID")
+		return
+	}
+
+	if err := h.geofenceService.DeleteGeofence(r.Context(), geofenceID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			helper.RespondWithError(w, http.StatusNotFound, "Geofence not found")
+			return
+		}
+		helper.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Geofence deleted"})
+}
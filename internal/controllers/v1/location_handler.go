@@ -2,18 +2,23 @@ package v1
 
 import (
 	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
 	"CabBookingService/internal/services"
 	"encoding/json"
 	"net/http"
+
+	"github.com/rs/zerolog/log"
 )
 
 type LocationHandler struct {
 	locationService services.LocationService
+	trackingService services.RideTrackingService
 }
 
-func NewLocationHandler(locationService services.LocationService) *LocationHandler {
+func NewLocationHandler(locationService services.LocationService, trackingService services.RideTrackingService) *LocationHandler {
 	return &LocationHandler{
 		locationService: locationService,
+		trackingService: trackingService,
 	}
 }
 
@@ -44,6 +49,13 @@ func (h *LocationHandler) UpdateDriverLocation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// 4. Respond with success
+	// 4. Refresh the passenger's view of an active ride (ETA, arrival)
+	// The location itself is saved already, so a tracking failure shouldn't fail the request
+	location := models.ExactLocation{Latitude: req.Latitude, Longitude: req.Longitude}
+	if err := h.trackingService.OnDriverLocationUpdate(r.Context(), account.ID, location); err != nil {
+		log.Error().Err(err).Str("account_id", account.ID.String()).Msg("Failed to update ride tracking")
+	}
+
+	// 5. Respond with success
 	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Location updated successfully"})
 }
@@ -9,7 +9,9 @@ import (
 	"CabBookingService/internal/models"
 	"CabBookingService/internal/repositories"
 	"CabBookingService/internal/services"
+	"CabBookingService/internal/services/queue"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang-jwt/jwt/v5/request"
 	"github.com/google/uuid"
@@ -81,6 +83,22 @@ func AuthMiddleware(jwtSecret string, accountRepo repositories.AccountRepository
 	}
 }
 
+// CorrelationIDMiddleware makes the events published while handling a request carry its request ID,
+// or the X-Correlation-ID header of the caller if there is one
+func CorrelationIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correlationID := r.Header.Get("X-Correlation-ID")
+		if correlationID == "" {
+			correlationID = middleware.GetReqID(r.Context())
+		}
+		if correlationID != "" {
+			w.Header().Set("X-Correlation-ID", correlationID)
+			r = r.WithContext(queue.WithCorrelationID(r.Context(), correlationID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // RequireRoleMiddleware checks if the user has the required role
 func RequireRoleMiddleware(requiredRole string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
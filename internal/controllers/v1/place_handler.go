@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PlaceHandler holds the dependencies for the passenger saved place controllers
+type PlaceHandler struct {
+	savedPlaceService services.SavedPlaceService
+}
+
+// NewPlaceHandler creates a new PlaceHandler
+func NewPlaceHandler(savedPlaceService services.SavedPlaceService) *PlaceHandler {
+	return &PlaceHandler{
+		savedPlaceService: savedPlaceService,
+	}
+}
+
+// --- Requests / Responses ---
+
+type SavedPlaceRequest struct {
+	Label     string  `json:"label"` // HOME, WORK or a custom label
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address"` // Optional, resolved from the coordinates when empty
+}
+
+type SavedPlaceResponse struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newSavedPlaceResponse(p *models.SavedPlace) SavedPlaceResponse {
+	return SavedPlaceResponse{
+		ID:        p.ID.String(),
+		Label:     p.Label,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Address:   p.Address,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+func (req SavedPlaceRequest) params() services.SavedPlaceParams {
+	return services.SavedPlaceParams{
+		Label:     req.Label,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Address:   req.Address,
+	}
+}
+
+// --- Handlers ---
+
+// CreatePlace - POST /v1/passenger/places
+func (h *PlaceHandler) CreatePlace(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Request
+	var req SavedPlaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// 3. Call Service
+	place, err := h.savedPlaceService.CreatePlace(r.Context(), account.ID, req.params())
+	if err != nil {
+		helper.RespondWithError(w, placeErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, newSavedPlaceResponse(place))
+}
+
+// ListPlaces - GET /v1/passenger/places
+func (h *PlaceHandler) ListPlaces(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	places, err := h.savedPlaceService.ListPlaces(r.Context(), account.ID)
+	if err != nil {
+		helper.RespondWithError(w, placeErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := make([]SavedPlaceResponse, 0, len(places))
+	for i := range places {
+		resp = append(resp, newSavedPlaceResponse(&places[i]))
+	}
+	helper.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// UpdatePlace - PUT /v1/passenger/places/{placeId}
+func (h *PlaceHandler) UpdatePlace(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Place ID and Request
+	placeID, err := uuid.Parse(chi.URLParam(r, "placeId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid place ID")
+		return
+	}
+	var req SavedPlaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// 3. Call Service
+	place, err := h.savedPlaceService.UpdatePlace(r.Context(), account.ID, placeID, req.params())
+	if err != nil {
+		helper.RespondWithError(w, placeErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newSavedPlaceResponse(place))
+}
+
+// DeletePlace - DELETE /v1/passenger/places/{placeId}
+func (h *PlaceHandler) DeletePlace(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	placeID, err := uuid.Parse(chi.URLParam(r, "placeId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid place ID")
+		return
+	}
+
+	if err := h.savedPlaceService.DeletePlace(r.Context(), account.ID, placeID); err != nil {
+		helper.RespondWithError(w, placeErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Place deleted"})
+}
+
+func placeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidSavedPlace):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrSavedPlaceNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSavedPlaceNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrPlaceLabelTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
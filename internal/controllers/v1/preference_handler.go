@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+
+	"gorm.io/gorm"
+)
+
+// PreferenceHandler holds the dependencies for the ride preference and driver capability controllers
+type PreferenceHandler struct {
+	preferenceService services.PreferenceService
+}
+
+// NewPreferenceHandler creates a new PreferenceHandler
+func NewPreferenceHandler(preferenceService services.PreferenceService) *PreferenceHandler {
+	return &PreferenceHandler{
+		preferenceService: preferenceService,
+	}
+}
+
+// --- Requests / Responses ---
+
+// RidePreferencesDTO is used both for requests and responses
+type RidePreferencesDTO struct {
+	PreferredDriverGender *models.Gender `json:"preferred_driver_gender"` // MALE, FEMALE, OTHER or null for no preference
+	WheelchairAccess      bool           `json:"wheelchair_access"`
+	PetFriendly           bool           `json:"pet_friendly"`
+	ChildSeat             bool           `json:"child_seat"`
+	QuietRide             bool           `json:"quiet_ride"`
+}
+
+func newRidePreferencesDTO(p models.RidePreferences) RidePreferencesDTO {
+	return RidePreferencesDTO{
+		PreferredDriverGender: p.PreferredDriverGender,
+		WheelchairAccess:      p.WheelchairAccess,
+		PetFriendly:           p.PetFriendly,
+		ChildSeat:             p.ChildSeat,
+		QuietRide:             p.QuietRide,
+	}
+}
+
+func (dto RidePreferencesDTO) model() models.RidePreferences {
+	return models.RidePreferences{
+		PreferredDriverGender: dto.PreferredDriverGender,
+		WheelchairAccess:      dto.WheelchairAccess,
+		PetFriendly:           dto.PetFriendly,
+		ChildSeat:             dto.ChildSeat,
+		QuietRide:             dto.QuietRide,
+	}
+}
+
+type DriverCapabilitiesRequest struct {
+	OffersQuietRides bool `json:"offers_quiet_rides"`
+	PetFriendly      bool `json:"pet_friendly"`
+	HasChildSeat     bool `json:"has_child_seat"`
+}
+
+func (req DriverCapabilitiesRequest) capabilities() services.DriverCapabilities {
+	return services.DriverCapabilities{
+		OffersQuietRides: req.OffersQuietRides,
+		PetFriendly:      req.PetFriendly,
+		HasChildSeat:     req.HasChildSeat,
+	}
+}
+
+type DriverCapabilitiesResponse struct {
+	DriverCapabilitiesRequest
+	WheelchairAccessible bool           `json:"wheelchair_accessible"` // Follows from the car type
+	CarType              models.CarType `json:"car_type"`
+}
+
+// --- Handlers ---
+
+// GetPreferences - GET /v1/passenger/preferences
+func (h *PreferenceHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	preferences, err := h.preferenceService.GetPassengerPreferences(r.Context(), account.ID)
+	if err != nil {
+		helper.RespondWithError(w, preferenceErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newRidePreferencesDTO(*preferences))
+}
+
+// UpdatePreferences - PUT /v1/passenger/preferences
+func (h *PreferenceHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Request
+	var req RidePreferencesDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// 3. Call Service
+	preferences, err := h.preferenceService.UpdatePassengerPreferences(r.Context(), account.ID, req.model())
+	if err != nil {
+		helper.RespondWithError(w, preferenceErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newRidePreferencesDTO(*preferences))
+}
+
+// UpdateCapabilities - PUT /v1/driver/capabilities
+func (h *PreferenceHandler) UpdateCapabilities(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Request
+	var req DriverCapabilitiesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// 3. Call Service
+	driver, err := h.preferenceService.UpdateDriverCapabilities(r.Context(), account.ID, req.capabilities())
+	if err != nil {
+		helper.RespondWithError(w, preferenceErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, DriverCapabilitiesResponse{
+		DriverCapabilitiesRequest: DriverCapabilitiesRequest{
+			OffersQuietRides: driver.OffersQuietRides,
+			PetFriendly:      driver.Car.PetFriendly,
+			HasChildSeat:     driver.Car.HasChildSeat,
+		},
+		WheelchairAccessible: driver.Car.IsWheelchairAccessible(),
+		CarType:              driver.Car.CarType,
+	})
+}
+
+func preferenceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidPreferences):
+		return http.StatusBadRequest
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,33 @@
+package v1
+
+import (
+	"net/http"
+
+	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/services/queue"
+)
+
+// QueueHandler holds the dependencies for the admin queue controllers
+type QueueHandler struct {
+	messageQueue queue.MessageQueue
+}
+
+// NewQueueHandler creates a new QueueHandler
+func NewQueueHandler(messageQueue queue.MessageQueue) *QueueHandler {
+	return &QueueHandler{
+		messageQueue: messageQueue,
+	}
+}
+
+// --- Handlers ---
+
+// GetQueueStats - GET /v1/admin/queue/stats
+// Backpressure metrics per topic, for queues that keep them
+func (h *QueueHandler) GetQueueStats(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := h.messageQueue.(queue.StatsReporter)
+	if !ok {
+		helper.RespondWithError(w, http.StatusNotImplemented, "The message queue doesn't report stats")
+		return
+	}
+	helper.RespondWithJSON(w, http.StatusOK, reporter.Stats())
+}
@@ -0,0 +1,295 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+	"CabBookingService/internal/services/recurrence"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// RecurringRideHandler holds the dependencies for the passenger recurring ride controllers
+type RecurringRideHandler struct {
+	recurringRideService services.RecurringRideService
+}
+
+// NewRecurringRideHandler creates a new RecurringRideHandler
+func NewRecurringRideHandler(recurringRideService services.RecurringRideService) *RecurringRideHandler {
+	return &RecurringRideHandler{
+		recurringRideService: recurringRideService,
+	}
+}
+
+// --- Requests / Responses ---
+
+type RecurringRideRequest struct {
+	PickupLatitude   float64 `json:"pickup_latitude"`
+	PickupLongitude  float64 `json:"pickup_longitude"`
+	DropoffLatitude  float64 `json:"dropoff_latitude"`
+	DropoffLongitude float64 `json:"dropoff_longitude"`
+	// Saved places can be used instead of the coordinates
+	PickupPlaceID  *uuid.UUID `json:"pickup_place_id"`
+	DropoffPlaceID *uuid.UUID `json:"dropoff_place_id"`
+	// ECONOMY (default), PREMIUM, XL or ACCESSIBLE
+	CarType                 string `json:"car_type"`
+	Shared                  bool   `json:"shared"`
+	AllowPreferenceFallback bool   `json:"allow_preference_fallback"`
+
+	TimeOfDay string   `json:"time_of_day"` // HH:MM, e.g. 08:15
+	Weekdays  []string `json:"weekdays"`    // MON to SUN
+	TimeZone  string   `json:"time_zone"`   // e.g. Europe/Berlin
+	StartDate string   `json:"start_date"`  // YYYY-MM-DD, today when omitted
+	EndDate   string   `json:"end_date"`    // YYYY-MM-DD, optional
+	SkipDates []string `json:"skip_dates"`  // YYYY-MM-DD
+}
+
+type RecurringRideResponse struct {
+	ID                      string    `json:"id"`
+	Status                  string    `json:"status"`
+	PickupLat               float64   `json:"pickup_lat"`
+	PickupLon               float64   `json:"pickup_lon"`
+	PickupAddress           string    `json:"pickup_address"`
+	DropoffLat              float64   `json:"dropoff_lat"`
+	DropoffLon              float64   `json:"dropoff_lon"`
+	DropoffAddress          string    `json:"dropoff_address"`
+	CarType                 string    `json:"car_type"`
+	Shared                  bool      `json:"shared"`
+	AllowPreferenceFallback bool      `json:"allow_preference_fallback"`
+	TimeOfDay               string    `json:"time_of_day"`
+	Weekdays                []string  `json:"weekdays"`
+	TimeZone                string    `json:"time_zone"`
+	StartDate               string    `json:"start_date"`
+	EndDate                 string    `json:"end_date,omitempty"`
+	SkipDates               []string  `json:"skip_dates"`
+	BookedUntil             time.Time `json:"booked_until"` // Occurrences up to here are bookings already
+	CreatedAt               time.Time `json:"created_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+func newRecurringRideResponse(ride *models.RecurringRide) RecurringRideResponse {
+	resp := RecurringRideResponse{
+		ID:                      ride.ID.String(),
+		Status:                  ride.Status.String(),
+		PickupLat:               ride.PickupLatitude,
+		PickupLon:               ride.PickupLongitude,
+		PickupAddress:           ride.PickupAddress,
+		DropoffLat:              ride.DropoffLatitude,
+		DropoffLon:              ride.DropoffLongitude,
+		DropoffAddress:          ride.DropoffAddress,
+		CarType:                 ride.CarType.String(),
+		Shared:                  ride.IsShared,
+		AllowPreferenceFallback: ride.AllowPreferenceFallback,
+		TimeOfDay:               ride.TimeOfDay,
+		Weekdays:                ride.Weekdays,
+		TimeZone:                ride.TimeZone,
+		StartDate:               recurrence.DateOf(ride.StartDate).String(),
+		SkipDates:               ride.SkipDates,
+		BookedUntil:             ride.MaterializedUntil,
+		CreatedAt:               ride.CreatedAt,
+		UpdatedAt:               ride.UpdatedAt,
+	}
+	if ride.EndDate != nil {
+		resp.EndDate = recurrence.DateOf(*ride.EndDate).String()
+	}
+	return resp
+}
+
+func (req RecurringRideRequest) params() services.RecurringRideParams {
+	return services.RecurringRideParams{
+		PickupLatitude:          req.PickupLatitude,
+		PickupLongitude:         req.PickupLongitude,
+		DropoffLatitude:         req.DropoffLatitude,
+		DropoffLongitude:        req.DropoffLongitude,
+		PickupPlaceID:           req.PickupPlaceID,
+		DropoffPlaceID:          req.DropoffPlaceID,
+		CarType:                 models.CarType(req.CarType),
+		Shared:                  req.Shared,
+		AllowPreferenceFallback: req.AllowPreferenceFallback,
+		TimeOfDay:               req.TimeOfDay,
+		Weekdays:                req.Weekdays,
+		TimeZone:                req.TimeZone,
+		StartDate:               req.StartDate,
+		EndDate:                 req.EndDate,
+		SkipDates:               req.SkipDates,
+	}
+}
+
+// --- Handlers ---
+
+// CreateRecurringRide - POST /v1/passenger/recurring-rides
+func (h *RecurringRideHandler) CreateRecurringRide(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Request
+	var req RecurringRideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// 3. Call Service
+	ride, err := h.recurringRideService.CreateRecurringRide(r.Context(), account.ID, req.params())
+	if err != nil {
+		helper.RespondWithError(w, recurringRideErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusCreated, newRecurringRideResponse(ride))
+}
+
+// ListRecurringRides - GET /v1/passenger/recurring-rides
+func (h *RecurringRideHandler) ListRecurringRides(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rides, err := h.recurringRideService.ListRecurringRides(r.Context(), account.ID)
+	if err != nil {
+		helper.RespondWithError(w, recurringRideErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := make([]RecurringRideResponse, 0, len(rides))
+	for i := range rides {
+		resp = append(resp, newRecurringRideResponse(&rides[i]))
+	}
+	helper.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// UpdateRecurringRide - PUT /v1/passenger/recurring-rides/{rideId}
+func (h *RecurringRideHandler) UpdateRecurringRide(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rideID, err := uuid.Parse(chi.URLParam(r, "rideId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid recurring ride ID")
+		return
+	}
+
+	var req RecurringRideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	ride, err := h.recurringRideService.UpdateRecurringRide(r.Context(), account.ID, rideID, req.params())
+	if err != nil {
+		helper.RespondWithError(w, recurringRideErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newRecurringRideResponse(ride))
+}
+
+// PauseRecurringRide - POST /v1/passenger/recurring-rides/{rideId}/pause
+func (h *RecurringRideHandler) PauseRecurringRide(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.recurringRideService.PauseRecurringRide)
+}
+
+// ResumeRecurringRide - POST /v1/passenger/recurring-rides/{rideId}/resume
+func (h *RecurringRideHandler) ResumeRecurringRide(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.recurringRideService.ResumeRecurringRide)
+}
+
+func (h *RecurringRideHandler) changeStatus(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, passengerAccountID, rideID uuid.UUID) (*models.RecurringRide, error)) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rideID, err := uuid.Parse(chi.URLParam(r, "rideId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid recurring ride ID")
+		return
+	}
+
+	ride, err := change(r.Context(), account.ID, rideID)
+	if err != nil {
+		helper.RespondWithError(w, recurringRideErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newRecurringRideResponse(ride))
+}
+
+// CancelRecurringRide - DELETE /v1/passenger/recurring-rides/{rideId}
+func (h *RecurringRideHandler) CancelRecurringRide(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rideID, err := uuid.Parse(chi.URLParam(r, "rideId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid recurring ride ID")
+		return
+	}
+
+	if err := h.recurringRideService.CancelRecurringRide(r.Context(), account.ID, rideID); err != nil {
+		helper.RespondWithError(w, recurringRideErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Recurring ride cancelled"})
+}
+
+// CancelOccurrence - DELETE /v1/passenger/recurring-rides/{rideId}/occurrences/{date}
+func (h *RecurringRideHandler) CancelOccurrence(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	rideID, err := uuid.Parse(chi.URLParam(r, "rideId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid recurring ride ID")
+		return
+	}
+
+	ride, err := h.recurringRideService.CancelOccurrence(r.Context(), account.ID, rideID, chi.URLParam(r, "date"))
+	if err != nil {
+		helper.RespondWithError(w, recurringRideErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newRecurringRideResponse(ride))
+}
+
+func recurringRideErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidRecurringRide):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrRecurringRideNotFound),
+		errors.Is(err, services.ErrNoOccurrence):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrRecurringRideNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrRecurringRideCancelled):
+		return http.StatusConflict
+	default:
+		// The route and ride options are validated like a booking
+		return bookingErrorStatus(err)
+	}
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ReservationHandler holds the dependencies for the driver's scheduled ride controllers
+type ReservationHandler struct {
+	reservationService services.ReservationService
+}
+
+// NewReservationHandler creates a new ReservationHandler
+func NewReservationHandler(reservationService services.ReservationService) *ReservationHandler {
+	return &ReservationHandler{
+		reservationService: reservationService,
+	}
+}
+
+// ListReservableRides - GET /v1/driver/scheduled-rides?page=1&page_size=10
+func (h *ReservationHandler) ListReservableRides(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Pagination
+	limit, offset := helper.GetPaginationParams(r)
+
+	// 3. Call Service
+	bookings, err := h.reservationService.ListReservableRides(r.Context(), account.ID, limit, offset)
+	if err != nil {
+		helper.RespondWithError(w, reservationErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newBookingResponses(bookings))
+}
+
+// ListReservedRides - GET /v1/driver/scheduled-rides/reserved
+func (h *ReservationHandler) ListReservedRides(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bookings, err := h.reservationService.ListReservedRides(r.Context(), account.ID)
+	if err != nil {
+		helper.RespondWithError(w, reservationErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newBookingResponses(bookings))
+}
+
+// ReserveRide - POST /v1/driver/scheduled-rides/{bookingId}/reserve
+func (h *ReservationHandler) ReserveRide(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bookingID, err := uuid.Parse(chi.URLParam(r, "bookingId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid booking ID")
+		return
+	}
+
+	booking, err := h.reservationService.ReserveRide(r.Context(), account.ID, bookingID)
+	if err != nil {
+		helper.RespondWithError(w, reservationErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, newCreateBookingResponse(booking))
+}
+
+// ReleaseRide - DELETE /v1/driver/scheduled-rides/{bookingId}/reserve
+func (h *ReservationHandler) ReleaseRide(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	bookingID, err := uuid.Parse(chi.URLParam(r, "bookingId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid booking ID")
+		return
+	}
+
+	if err := h.reservationService.ReleaseRide(r.Context(), account.ID, bookingID); err != nil {
+		helper.RespondWithError(w, reservationErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, DriverActionResponse{
+		BookingID: bookingID.String(),
+		Status:    models.BookingStatusScheduled.String(),
+		Message:   "You have released the ride",
+	})
+}
+
+func newBookingResponses(bookings []models.Booking) []CreateBookingResponse {
+	resp := make([]CreateBookingResponse, 0, len(bookings))
+	for i := range bookings {
+		resp = append(resp, newCreateBookingResponse(&bookings[i]))
+	}
+	return resp
+}
+
+func reservationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrRideNotReservable),
+		errors.Is(err, services.ErrReservationConflict),
+		errors.Is(err, services.ErrReservationDue):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrReservationNotFound):
+		return http.StatusNotFound
+	default:
+		return bookingErrorStatus(err)
+	}
+}
@@ -3,19 +3,27 @@ package v1
 import (
 	"context"
 	"net/http"
+	"sync"
 
 	"CabBookingService/internal/config"
 	"CabBookingService/internal/domain"
 	"CabBookingService/internal/repositories"
 	"CabBookingService/internal/services"
+	"CabBookingService/internal/services/geocoding"
+	"CabBookingService/internal/services/pooling"
 	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/services/ranking"
+	"CabBookingService/internal/services/routing"
+	"CabBookingService/internal/services/scheduling"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
-func NewV1Router(cfg *config.Config, db *gorm.DB) http.Handler {
+// NewV1Router wires the v1 API. The background workers run until ctx is cancelled, the returned func
+// waits for them to finish what they are doing.
+func NewV1Router(ctx context.Context, cfg *config.Config, db *gorm.DB) (http.Handler, func(context.Context) error) {
 	// 1. Init Repositories (Data Layer)
 	roleRepo := repositories.NewGormRoleRepository(db)
 	accountRepo := repositories.NewGormAccountRepository(db)
@@ -25,38 +33,99 @@ func NewV1Router(cfg *config.Config, db *gorm.DB) http.Handler {
 	otpRepo := repositories.NewGormOTPRepository(db)
 	reviewRepo := repositories.NewGormReviewRepository(db)
 	paymentRepo := repositories.NewGormPaymentRepository(db)
+	geofenceRepo := repositories.NewGormGeofenceRepository(db)
+	savedPlaceRepo := repositories.NewGormSavedPlaceRepository(db)
+	tripRepo := repositories.NewGormTripRepository(db)
+	outboxRepo := repositories.NewGormOutboxRepository(db)
+	recurringRideRepo := repositories.NewGormRecurringRideRepository(db)
+	transactor := repositories.NewGormTransactor(db)
 
 	// 2. Init Core Services
 	authService := services.NewAuthService(accountRepo, passengerRepo, driverRepo, roleRepo, db, cfg.JWTSecret, cfg.JWTExpiresIn)
 	otpService := services.NewOTPService(otpRepo)
 	locationService := services.NewNaiveLocationService(driverRepo)
-	paymentService := services.NewPaymentService(paymentRepo)
+	geofenceService := services.NewGeofenceService(geofenceRepo)
+	routingProvider := newRoutingProvider(cfg.RoutingConfig)
+	fareService := services.NewFareService(geofenceService, locationService, routingProvider, tripRepo)
+	trackingService := services.NewRideTrackingService(bookingRepo, driverRepo, locationService, routingProvider, transactor, outboxRepo, cfg.PickupArrivalRadiusMeters)
+	paymentService := services.NewPaymentService(paymentRepo, fareService)
+	geocoder := newGeocoder(cfg.GeocodingConfig)
+	savedPlaceService := services.NewSavedPlaceService(savedPlaceRepo, passengerRepo, geocoder)
+	preferenceService := services.NewPreferenceService(passengerRepo, driverRepo)
+	destinationModeService := services.NewDestinationModeService(driverRepo, cfg.DestinationModeDailyLimit)
 
-	// 3. Init Queue
-	messageQueue := queue.NewInMemoryQueue()
+	notificationService := services.NewLogNotificationService()
+	// Background loops and consumers are counted in background, the drain waits for them
+	var background sync.WaitGroup
+	offerService := services.NewOfferService(bookingRepo, driverRepo, notificationService, offerSettings(cfg.DispatchConfig))
+	offerService.Start(ctx, &background)
+	poolingService := services.NewPoolingService(tripRepo, bookingRepo, locationService, routingProvider, poolingLimits(cfg.PoolingConfig))
+
+	// 3. Init Queue, fed by the outbox relay
+	messageQueue := newMessageQueue(ctx, cfg, db)
+	outboxRelay := services.NewOutboxRelay(outboxRepo, messageQueue, outboxSettings(cfg.OutboxConfig))
+	outboxRelay.Start(ctx, &background)
 
 	// 4. Init Consumers (Workers)
-	driverMatchingService := services.NewDriverMatchingService(messageQueue, locationService, bookingRepo, driverRepo)
-	err := driverMatchingService.StartConsuming()
+	driverMatchingService := newDriverMatchingService(cfg, messageQueue, locationService, bookingRepo, driverRepo, geofenceService, routingProvider, offerService, poolingService)
+	err := driverMatchingService.StartConsuming(ctx)
 	if err != nil {
 		// We can use Fatal here because if the consumer fails, the app is broken.
 		log.Fatal().Err(err).Msg("Failed to start Driver Matching Consumer")
 	}
+	// Booking lifecycle events, downstream features subscribe to the same topics
+	if err := services.NewBookingEventLog(messageQueue).StartConsuming(ctx, &background); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start Booking Event Log")
+	}
+	webhookService := services.NewWebhookService(repositories.NewGormWebhookRepository(db), passengerRepo, messageQueue, webhookSettings(cfg.WebhookConfig))
+	if err := webhookService.StartConsuming(ctx, &background); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start Webhook Consumer")
+	}
+	webhookService.Start(ctx, &background)
 
-	schedulingService := services.NewSchedulingService(bookingRepo, messageQueue)
-	schedulingService.Start(context.Background())
+	// 5. Inject the outbox into Booking Service
+	rules := schedulingRules(cfg.SchedulingConfig)
+	bookingService := services.NewBookingService(bookingRepo, driverRepo, passengerRepo, reviewRepo, savedPlaceRepo, otpService, locationService, paymentService, geofenceService, trackingService, geocoder, notificationService, offerService, poolingService, transactor, outboxRepo, rules)
 
-	// 5. Inject Queue into Booking Service
-	bookingService := services.NewBookingService(bookingRepo, driverRepo, passengerRepo, reviewRepo, otpService, locationService, paymentService, messageQueue)
+	// 6. Scheduled and recurring rides, booked through the Booking Service. Drivers can reserve them.
+	recurringRideService := services.NewRecurringRideService(recurringRideRepo, bookingRepo, passengerRepo, savedPlaceRepo, geofenceService, geocoder, bookingService, transactor, outboxRepo, services.RecurringRideSettings{
+		Horizon:   cfg.SchedulingRecurringHorizon,
+		BatchSize: cfg.SchedulingBatchSize,
+		Rules:     rules,
+	})
+	var schedulingLock repositories.LeaderLock
+	if cfg.SchedulingLeaderLock {
+		schedulingLock = repositories.NewPostgresLeaderLock(db)
+	}
+	reservationService := services.NewReservationService(bookingRepo, driverRepo, passengerRepo, otpService, routingProvider, trackingService, notificationService, transactor, outboxRepo, services.ReservationSettings{
+		BrowseHorizon: cfg.ReservationBrowseHorizon,
+		Buffer:        cfg.ReservationBuffer,
+		ReminderLead:  cfg.ReservationReminderLead,
+		BatchSize:     cfg.SchedulingBatchSize,
+	})
+	schedulingService := services.NewSchedulingService(bookingRepo, transactor, outboxRepo, recurringRideService, reservationService, schedulingLock, services.SchedulingSettings{
+		TickInterval: cfg.SchedulingTickInterval,
+		BatchSize:    cfg.SchedulingBatchSize,
+		Rules:        rules,
+	})
+	schedulingService.Start(ctx, &background)
 
 	// 3. Init Handlers (Controller Layer)
 	userHandler := NewUserHandler(cfg, authService)
-	bookingHandler := NewBookingHandler(bookingService)
-	driverHandler := NewDriverHandler(bookingService)
-	locationHandler := NewLocationHandler(locationService)
+	bookingHandler := NewBookingHandler(bookingService, fareService, trackingService)
+	driverHandler := NewDriverHandler(bookingService, destinationModeService)
+	locationHandler := NewLocationHandler(locationService, trackingService)
+	geofenceHandler := NewGeofenceHandler(geofenceService)
+	placeHandler := NewPlaceHandler(savedPlaceService)
+	preferenceHandler := NewPreferenceHandler(preferenceService)
+	queueHandler := NewQueueHandler(messageQueue)
+	webhookHandler := NewWebhookHandler(webhookService)
+	recurringRideHandler := NewRecurringRideHandler(recurringRideService)
+	reservationHandler := NewReservationHandler(reservationService)
 
 	// 3. Create the v1 router
 	r := chi.NewRouter()
+	r.Use(CorrelationIDMiddleware)
 
 	// --- Routes ---
 
@@ -75,24 +144,287 @@ func NewV1Router(cfg *config.Config, db *gorm.DB) http.Handler {
 			r.Use(RequireRoleMiddleware(domain.RolePassenger)) // Only passengers can access these routes
 
 			r.Post("/", bookingHandler.CreateBooking)
+			r.Post("/estimate", bookingHandler.EstimateFare)
+			r.Get("/{bookingId}", bookingHandler.GetBooking)
+			r.Get("/{bookingId}/events", bookingHandler.StreamBookingEvents)
+			r.Patch("/{bookingId}/destination", bookingHandler.ChangeDestination)
+			r.Patch("/{bookingId}/schedule", bookingHandler.RescheduleBooking)
 			//r.Get("/", bookingHandler.ListMyBookings)
 		})
 
+		// Saved places of the passenger
+		r.Route("/passenger/places", func(r chi.Router) {
+			r.Use(RequireRoleMiddleware(domain.RolePassenger))
+
+			r.Get("/", placeHandler.ListPlaces)
+			r.Post("/", placeHandler.CreatePlace)
+			r.Put("/{placeId}", placeHandler.UpdatePlace)
+			r.Delete("/{placeId}", placeHandler.DeletePlace)
+		})
+
+		// Default ride preferences of the passenger
+		r.Route("/passenger/preferences", func(r chi.Router) {
+			r.Use(RequireRoleMiddleware(domain.RolePassenger))
+
+			r.Get("/", preferenceHandler.GetPreferences)
+			r.Put("/", preferenceHandler.UpdatePreferences)
+		})
+
+		// Rides the passenger takes every week
+		r.Route("/passenger/recurring-rides", func(r chi.Router) {
+			r.Use(RequireRoleMiddleware(domain.RolePassenger))
+
+			r.Get("/", recurringRideHandler.ListRecurringRides)
+			r.Post("/", recurringRideHandler.CreateRecurringRide)
+			r.Put("/{rideId}", recurringRideHandler.UpdateRecurringRide)
+			r.Delete("/{rideId}", recurringRideHandler.CancelRecurringRide)
+			r.Post("/{rideId}/pause", recurringRideHandler.PauseRecurringRide)
+			r.Post("/{rideId}/resume", recurringRideHandler.ResumeRecurringRide)
+			r.Delete("/{rideId}/occurrences/{date}", recurringRideHandler.CancelOccurrence)
+		})
+
+		// Partners book rides with a passenger account and hear about them through webhooks
+		r.Route("/partner/webhooks", func(r chi.Router) {
+			r.Use(RequireRoleMiddleware(domain.RolePartner)) // Granted by an admin on top of ROLE_PASSENGER
+
+			r.Get("/", webhookHandler.ListWebhooks)
+			r.Post("/", webhookHandler.RegisterWebhook)
+			r.Delete("/{webhookId}", webhookHandler.DeleteWebhook)
+			r.Get("/{webhookId}/deliveries", webhookHandler.ListDeliveries)
+			r.Post("/deliveries/{deliveryId}/replay", webhookHandler.ReplayDelivery)
+		})
+
 		// Driver routes
 		r.Route("/driver/bookings", func(r chi.Router) {
 			r.Use(RequireRoleMiddleware(domain.RoleDriver)) // Only drivers can access these routes
 
 			r.Get("/pending", driverHandler.ListPendingRides)
 			r.Post("/{bookingId}/accept", driverHandler.AcceptBooking)
+			r.Post("/{bookingId}/decline", driverHandler.DeclineBooking)
 			r.Post("/{bookingId}/cancel", driverHandler.CancelBooking)
 			r.Post("/{bookingId}/start", driverHandler.StartRide)
 			r.Post("/{bookingId}/end", driverHandler.EndRide)
 			r.Patch("/availability", driverHandler.ToggleAvailability)
 		})
 
+		// Scheduled rides drivers reserve in advance
+		r.Route("/driver/scheduled-rides", func(r chi.Router) {
+			r.Use(RequireRoleMiddleware(domain.RoleDriver))
+
+			r.Get("/", reservationHandler.ListReservableRides)
+			r.Get("/reserved", reservationHandler.ListReservedRides)
+			r.Post("/{bookingId}/reserve", reservationHandler.ReserveRide)
+			r.Delete("/{bookingId}/reserve", reservationHandler.ReleaseRide)
+		})
+
+		// What the driver and their car offer to passengers
+		r.With(RequireRoleMiddleware(domain.RoleDriver)).Put("/driver/capabilities", preferenceHandler.UpdateCapabilities)
+
+		// Destination mode ("heading home")
+		r.Route("/driver/destination", func(r chi.Router) {
+			r.Use(RequireRoleMiddleware(domain.RoleDriver))
+
+			r.Put("/", driverHandler.SetDestination)
+			r.Delete("/", driverHandler.ClearDestination)
+		})
+
 		r.Put("/location/update", locationHandler.UpdateDriverLocation)
 
+		// Admin routes
+		r.Route("/admin/geofences", func(r chi.Router) {
+			r.Use(RequireRoleMiddleware(domain.RoleAdmin)) // Only admins can manage geofences
+
+			r.Get("/", geofenceHandler.ListGeofences)
+			r.Post("/", geofenceHandler.CreateGeofence)
+			r.Delete("/{geofenceId}", geofenceHandler.DeleteGeofence)
+		})
+
+		r.With(RequireRoleMiddleware(domain.RoleAdmin)).Get("/admin/queue/stats", queueHandler.GetQueueStats)
+
 	})
 
-	return r
+	// Drain the matching workers and the background loops, then stop the subscriptions that are left
+	drain := func(ctx context.Context) error {
+		err := driverMatchingService.Wait(ctx)
+		if err == nil {
+			err = services.WaitFor(ctx, &background)
+		}
+		if closeErr := messageQueue.Close(); closeErr != nil {
+			log.Error().Err(closeErr).Msg("Failed to close message queue")
+		}
+		return err
+	}
+	return r, drain
+}
+
+// newRoutingProvider uses the offline road graph when one is configured, with straight line
+// estimates as a fallback for points outside of it.
+func newRoutingProvider(cfg config.RoutingConfig) routing.RoutingProvider {
+	haversineProvider := routing.NewHaversineProvider(cfg.RoutingAverageSpeedKmh)
+	if cfg.RoutingGraphFile == "" {
+		log.Info().Msg("No road graph configured, using straight line routing estimates")
+		return haversineProvider
+	}
+
+	graph, err := routing.LoadOSMGraph(cfg.RoutingGraphFile)
+	if err != nil {
+		log.Fatal().Err(err).Str("file", cfg.RoutingGraphFile).Msg("Failed to load road graph")
+	}
+	log.Info().Int("nodes", graph.NodeCount()).Msg("Road graph loaded")
+
+	return routing.NewFallbackProvider(routing.NewGraphProvider(graph), haversineProvider)
+}
+
+// newGeocoder uses the offline gazetteer when one is configured, otherwise bookings fall back to
+// showing coordinates as their address.
+func newGeocoder(cfg config.GeocodingConfig) geocoding.Geocoder {
+	if cfg.GeocoderGazetteerFile == "" {
+		log.Info().Msg("No gazetteer configured, reverse geocoding disabled")
+		return geocoding.NewNoopGeocoder()
+	}
+
+	geocoder, err := geocoding.LoadGazetteerGeocoder(cfg.GeocoderGazetteerFile, cfg.GeocoderMaxDistanceKm)
+	if err != nil {
+		log.Fatal().Err(err).Str("file", cfg.GeocoderGazetteerFile).Msg("Failed to load gazetteer")
+	}
+	log.Info().Str("file", cfg.GeocoderGazetteerFile).Msg("Gazetteer loaded")
+	return geocoder
+}
+
+// newDriverMatchingService picks the matcher for the configured dispatch mode
+func newDriverMatchingService(
+	cfg *config.Config,
+	messageQueue queue.MessageQueue,
+	locationService services.LocationService,
+	bookingRepo repositories.BookingRepository,
+	driverRepo repositories.DriverRepository,
+	geofenceService services.GeofenceService,
+	routingProvider routing.RoutingProvider,
+	offerService services.OfferService,
+	poolingService services.PoolingService,
+) services.DriverMatchingService {
+	switch cfg.DispatchMode {
+	case config.DispatchModeBatch:
+		log.Info().Dur("window", cfg.DispatchBatchWindow).Msg("Using batch driver matching")
+		return services.NewBatchDriverMatchingService(messageQueue, locationService, bookingRepo, driverRepo, geofenceService, routingProvider, offerService, poolingService, matchingSettings(cfg), cfg.DispatchBatchWindow)
+	case config.DispatchModeGreedy:
+		return services.NewDriverMatchingService(messageQueue, locationService, bookingRepo, driverRepo, geofenceService, routingProvider, offerService, poolingService, matchingSettings(cfg))
+	default:
+		log.Fatal().Str("mode", cfg.DispatchMode).Msg("Unknown dispatch mode")
+		return nil
+	}
+}
+
+func matchingSettings(cfg *config.Config) services.MatchingSettings {
+	return services.MatchingSettings{
+		RankingWeights: ranking.Weights{
+			ETA:            cfg.DispatchWeightETA,
+			Rating:         cfg.DispatchWeightRating,
+			AcceptanceRate: cfg.DispatchWeightAcceptanceRate,
+			IdleTime:       cfg.DispatchWeightIdleTime,
+			Heading:        cfg.DispatchWeightHeading,
+		},
+		DestinationMinProgressKm: cfg.DestinationModeMinProgressKm,
+		ChainMaxRemaining:        cfg.DispatchChainMaxRemaining,
+		Workers:                  cfg.DispatchWorkers,
+		MatchTimeout:             cfg.DispatchMatchTimeout,
+	}
+}
+
+// newMessageQueue returns the configured queue, the Postgres one is shared by every instance
+func newMessageQueue(ctx context.Context, cfg *config.Config, db *gorm.DB) queue.MessageQueue {
+	queueRepo := repositories.NewGormQueueMessageRepository(db)
+	switch cfg.QueueBackend {
+	case config.QueueBackendMemory:
+		return queue.NewInMemoryQueue(queue.NewStoredDeadLetterSink(queueRepo, queue.JSONCodec{}))
+	case config.QueueBackendPostgres:
+		return queue.NewPostgresQueue(ctx, queueRepo, queue.JSONCodec{}, queue.PostgresSettings{
+			PollInterval:      cfg.QueuePollInterval,
+			Prefetch:          cfg.QueuePrefetch,
+			VisibilityTimeout: cfg.QueueVisibilityTimeout,
+			RetryBackoff:      cfg.QueueRetryBackoff,
+			MaxBackoff:        cfg.QueueMaxBackoff,
+			MaxAttempts:       cfg.QueueMaxAttempts,
+		})
+	default:
+		log.Fatal().Str("backend", cfg.QueueBackend).Msg("Unknown queue backend")
+		return nil
+	}
+}
+
+func outboxSettings(cfg config.OutboxConfig) services.OutboxSettings {
+	return services.OutboxSettings{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		ClaimTimeout: cfg.OutboxClaimTimeout,
+		RetryBackoff: cfg.OutboxRetryBackoff,
+		MaxBackoff:   cfg.OutboxMaxBackoff,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+	}
+}
+
+func webhookSettings(cfg config.WebhookConfig) services.WebhookSettings {
+	return services.WebhookSettings{
+		PollInterval: cfg.WebhookPollInterval,
+		BatchSize:    cfg.WebhookBatchSize,
+		Timeout:      cfg.WebhookTimeout,
+		ClaimTimeout: cfg.WebhookClaimTimeout,
+		RetryBackoff: cfg.WebhookRetryBackoff,
+		MaxBackoff:   cfg.WebhookMaxBackoff,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+	}
+}
+
+// offerSettings validates the configured offer modes, an unknown mode is a deployment mistake
+func offerSettings(cfg config.DispatchConfig) services.OfferSettings {
+	settings := services.OfferSettings{
+		DefaultMode:   services.OfferMode(cfg.DispatchOfferMode),
+		CityModes:     make(map[string]services.OfferMode, len(cfg.DispatchCityOfferModes)),
+		Timeout:       cfg.DispatchOfferTimeout,
+		TopK:          cfg.DispatchTopK,
+		SweepInterval: cfg.DispatchOfferSweepInterval,
+		SweepBatch:    cfg.DispatchOfferSweepBatch,
+	}
+	if !settings.DefaultMode.IsValid() {
+		log.Fatal().Str("mode", cfg.DispatchOfferMode).Msg("Unknown offer mode")
+	}
+	if settings.SweepInterval <= 0 || settings.SweepBatch <= 0 {
+		log.Fatal().
+			Dur("sweep_interval", settings.SweepInterval).
+			Int("sweep_batch", settings.SweepBatch).
+			Msg("Invalid offer sweeper settings")
+	}
+	for city, mode := range cfg.DispatchCityOfferModes {
+		offerMode := services.OfferMode(mode)
+		if !offerMode.IsValid() {
+			log.Fatal().Str("city", city).Str("mode", mode).Msg("Unknown offer mode")
+		}
+		settings.CityModes[city] = offerMode
+	}
+	return settings
+}
+
+// schedulingRules validates the configured scheduling limits, like the offer modes
+func schedulingRules(cfg config.SchedulingConfig) scheduling.Rules {
+	if cfg.SchedulingMinLead < 0 || cfg.SchedulingMaxHorizon < cfg.SchedulingMinLead || cfg.SchedulingTickInterval <= 0 {
+		log.Fatal().
+			Dur("min_lead", cfg.SchedulingMinLead).
+			Dur("max_horizon", cfg.SchedulingMaxHorizon).
+			Dur("tick_interval", cfg.SchedulingTickInterval).
+			Msg("Invalid scheduling settings")
+	}
+	return scheduling.Rules{
+		MinLead:               cfg.SchedulingMinLead,
+		MaxHorizon:            cfg.SchedulingMaxHorizon,
+		ActivationWindow:      cfg.SchedulingActivationWindow,
+		CityActivationWindows: cfg.SchedulingCityActivationWindows,
+	}
+}
+
+func poolingLimits(cfg config.PoolingConfig) pooling.Limits {
+	return pooling.Limits{
+		Capacity:       cfg.PoolCapacity,
+		MaxDetourRatio: cfg.PoolMaxDetourRatio,
+		MaxDetour:      cfg.PoolMaxDetour,
+	}
 }
@@ -3,6 +3,7 @@ package v1
 import (
 	"CabBookingService/internal/config"
 	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
 	"CabBookingService/internal/services"
 	"encoding/json"
 	"net/http"
@@ -38,6 +39,10 @@ type RegisterDriverRequest struct {
 	PhoneNumber string `json:"phone_number"`
 	PlateNumber string `json:"plate_number"`
 	CarModel    string `json:"car_model"`
+	CarType     string `json:"car_type"` // ECONOMY (default), PREMIUM, XL or ACCESSIBLE
+	// Take economy rides as well when driving a higher class car
+	AcceptsLowerClass bool `json:"accepts_lower_class"`
+	DriverCapabilitiesRequest
 }
 
 // RegisterResponse defines the JSON response for a successful registration
@@ -99,7 +104,17 @@ func (h *UserHandler) RegisterDriver(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call Service
-	driver, err := h.authService.RegisterDriver(r.Context(), req.Username, req.Password, req.Name, req.PhoneNumber, req.PlateNumber, req.CarModel)
+	driver, err := h.authService.RegisterDriver(r.Context(), services.RegisterDriverParams{
+		Username:          req.Username,
+		Password:          req.Password,
+		Name:              req.Name,
+		PhoneNumber:       req.PhoneNumber,
+		PlateNumber:       req.PlateNumber,
+		CarModel:          req.CarModel,
+		CarType:           models.CarType(req.CarType),
+		AcceptsLowerClass: req.AcceptsLowerClass,
+		Capabilities:      req.DriverCapabilitiesRequest.capabilities(),
+	})
 	if err != nil {
 		helper.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
@@ -0,0 +1,227 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"CabBookingService/internal/controllers/helper"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebhookHandler holds the dependencies for the partner webhook controllers
+type WebhookHandler struct {
+	webhookService services.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(webhookService services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// --- Requests / Responses ---
+
+type RegisterWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"` // e.g. booking.created, booking.completed
+}
+
+type WebhookResponse struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	IsActive   bool      `json:"is_active"`
+	Secret     string    `json:"secret,omitempty"` // Only when registering, verifies the X-Webhook-Signature header
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             string     `json:"id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"` // Only while pending
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newWebhookResponse(e *models.WebhookEndpoint) WebhookResponse {
+	return WebhookResponse{
+		ID:         e.ID.String(),
+		URL:        e.URL,
+		EventTypes: e.EventTypes,
+		IsActive:   e.IsActive,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+func newWebhookDeliveryResponse(d *models.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:             d.ID.String(),
+		EventID:        d.EventID.String(),
+		EventType:      d.EventType,
+		Status:         d.Status.String(),
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		DeliveredAt:    d.DeliveredAt,
+		CreatedAt:      d.CreatedAt,
+	}
+	if d.Status == models.WebhookDeliveryStatusPending {
+		resp.NextAttemptAt = &d.NextAttemptAt
+	}
+	return resp
+}
+
+// --- Handlers ---
+
+// RegisterWebhook - POST /v1/partner/webhooks
+func (h *WebhookHandler) RegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	// 1. Get Account from context (set by AuthMiddleware)
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// 2. Parse Request
+	var req RegisterWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// 3. Call Service
+	endpoint, err := h.webhookService.RegisterWebhook(r.Context(), account.ID, services.RegisterWebhookParams{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+	})
+	if err != nil {
+		helper.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := newWebhookResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	helper.RespondWithJSON(w, http.StatusCreated, resp)
+}
+
+// ListWebhooks - GET /v1/partner/webhooks
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	endpoints, err := h.webhookService.ListWebhooks(r.Context(), account.ID)
+	if err != nil {
+		helper.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := make([]WebhookResponse, 0, len(endpoints))
+	for i := range endpoints {
+		resp = append(resp, newWebhookResponse(&endpoints[i]))
+	}
+	helper.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// DeleteWebhook - DELETE /v1/partner/webhooks/{webhookId}
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(r.Context(), account.ID, webhookID); err != nil {
+		helper.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+// ListDeliveries - GET /v1/partner/webhooks/{webhookId}/deliveries?page=&page_size=
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+	limit, offset := helper.GetPaginationParams(r)
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), account.ID, webhookID, limit, offset)
+	if err != nil {
+		helper.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	resp := make([]WebhookDeliveryResponse, 0, len(deliveries))
+	for i := range deliveries {
+		resp = append(resp, newWebhookDeliveryResponse(&deliveries[i]))
+	}
+	helper.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// ReplayDelivery - POST /v1/partner/webhooks/deliveries/{deliveryId}/replay
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	account, err := GetAccountFromContext(r.Context())
+	if err != nil {
+		helper.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	deliveryID, err := uuid.Parse(chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		helper.RespondWithError(w, http.StatusBadRequest, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(r.Context(), account.ID, deliveryID)
+	if err != nil {
+		helper.RespondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	helper.RespondWithJSON(w, http.StatusAccepted, newWebhookDeliveryResponse(delivery))
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhook):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrWebhookNotFound),
+		errors.Is(err, services.ErrDeliveryNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrWebhookNotOwned):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -44,8 +44,8 @@ func NewGormDbConn(config PostgresConfig) (*gorm.DB, error) {
 		config.Port,
 	)
 
-	// 1. Connect to Database
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// 1. Connect to Database. Constraint violations are translated, e.g. to gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -99,11 +99,24 @@ func applyPoolConfig(db *gorm.DB) (*gorm.DB, error) {
 	return db, nil
 }
 
-// NewGormTx creates a new GORM session with context and logger
+// txKey is the context key of the transaction started by WithTx
+type txKey struct{}
+
+// WithTx returns a context carrying the transaction tx. NewGormTx called with this context
+// returns tx, so repositories join the caller's transaction instead of running on their own.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// NewGormTx creates a new GORM session with context and logger.
+// If ctx carries a transaction (see WithTx) the session runs in that transaction.
 func NewGormTx(ctx context.Context, db *gorm.DB) *gorm.DB {
 	if db == nil {
 		return nil
 	}
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
 
 	// Use GORM's default logger, but control verbosity via Global Level
 	// In Production (Info Level), 'Warn' will show slow queries.
@@ -137,10 +150,12 @@ func withDatabaseTimeout(ctx context.Context, timeout time.Duration) context.Con
 	}
 
 	// No existing deadline - apply default timeout for safety
-	timeoutCtx, _ := context.WithTimeout(ctx, timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
 	// Note: We don't call cancel() immediately to allow the context to remain valid
 	// for operations like row iteration that happen after the query completes.
-	// The context will automatically cancel when the deadline is reached.
+	// The context will automatically cancel when the deadline is reached, cancel only releases
+	// its resources then.
+	context.AfterFunc(timeoutCtx, cancel)
 	return timeoutCtx
 }
 
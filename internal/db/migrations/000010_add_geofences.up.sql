@@ -0,0 +1,26 @@
+-- 1. Geofences Table (Service areas, airports and restricted zones)
+CREATE TABLE IF NOT EXISTS geofences (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ,
+
+    name VARCHAR(255) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    type VARCHAR(50) NOT NULL CHECK (type IN ('SERVICE_AREA', 'AIRPORT', 'RESTRICTED')),
+    is_active BOOLEAN DEFAULT true,
+
+    -- Ordered list of {"latitude": .., "longitude": ..} vertices
+    polygon JSONB NOT NULL,
+
+    no_pickup BOOLEAN DEFAULT false,
+    no_dropoff BOOLEAN DEFAULT false,
+
+    fare_multiplier DOUBLE PRECISION DEFAULT 1.0,
+    flat_surcharge DOUBLE PRECISION DEFAULT 0,
+    requires_driver_queue BOOLEAN DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS idx_geofences_city ON geofences(city);
+
+-- 2. Remember which city's service area a booking belongs to
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS city VARCHAR(100);
@@ -0,0 +1,6 @@
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS driver_arrived_at,
+    DROP COLUMN IF EXISTS ride_started_at;
+
+-- Postgres can't drop a single enum value, move ARRIVED rides back to ACCEPTED instead
+UPDATE bookings SET status = 'ACCEPTED' WHERE status = 'ARRIVED';
@@ -0,0 +1,5 @@
+ALTER TYPE booking_status ADD VALUE IF NOT EXISTS 'ARRIVED';
+
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS driver_arrived_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS ride_started_at TIMESTAMPTZ;
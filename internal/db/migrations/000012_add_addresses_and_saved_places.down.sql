@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS saved_places;
+
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS pickup_address,
+    DROP COLUMN IF EXISTS dropoff_address;
@@ -0,0 +1,23 @@
+-- 1. Human-readable addresses on bookings
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS pickup_address TEXT,
+    ADD COLUMN IF NOT EXISTS dropoff_address TEXT;
+
+-- 2. Saved Places Table (home, work, custom labels)
+CREATE TABLE IF NOT EXISTS saved_places (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ,
+
+    passenger_id UUID NOT NULL REFERENCES passengers(id) ON DELETE CASCADE,
+    label VARCHAR(50) NOT NULL,
+
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    address TEXT
+);
+
+-- A label can only be used once per passenger (ignoring deleted places)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_places_passenger_label
+    ON saved_places(passenger_id, label) WHERE deleted_at IS NULL;
@@ -0,0 +1,16 @@
+-- Intermediate stops of a booking, visited in sequence order before the drop-off
+CREATE TABLE IF NOT EXISTS booking_stops (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ,
+
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    sequence INT NOT NULL,
+
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    address TEXT,
+
+    UNIQUE (booking_id, sequence)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE drivers
+    DROP COLUMN IF EXISTS offers_received,
+    DROP COLUMN IF EXISTS offers_accepted,
+    DROP COLUMN IF EXISTS last_ride_ended_at;
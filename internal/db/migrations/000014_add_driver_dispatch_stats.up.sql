@@ -0,0 +1,5 @@
+-- Dispatch history used to rank drivers
+ALTER TABLE drivers
+    ADD COLUMN IF NOT EXISTS offers_received INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS offers_accepted INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_ride_ended_at TIMESTAMPTZ;
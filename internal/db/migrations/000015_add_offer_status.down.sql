@@ -0,0 +1,3 @@
+ALTER TABLE booking_notified_drivers
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,4 @@
+-- Offers can be declined or expire when they are made to one driver at a time
+ALTER TABLE booking_notified_drivers
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'OFFERED',
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
@@ -0,0 +1,6 @@
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS car_type;
+
+ALTER TABLE cars
+    DROP COLUMN IF EXISTS accepts_lower_class,
+    ALTER COLUMN car_type DROP DEFAULT;
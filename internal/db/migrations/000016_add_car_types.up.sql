@@ -0,0 +1,9 @@
+-- 1. Vehicle class of cars, existing cars are economy
+UPDATE cars SET car_type = 'ECONOMY' WHERE car_type IS NULL OR car_type = '';
+ALTER TABLE cars
+    ALTER COLUMN car_type SET DEFAULT 'ECONOMY',
+    ADD COLUMN IF NOT EXISTS accepts_lower_class BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 2. Vehicle class requested for a booking
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS car_type VARCHAR(50) NOT NULL DEFAULT 'ECONOMY';
@@ -0,0 +1,22 @@
+ALTER TABLE cars
+    DROP COLUMN IF EXISTS has_child_seat,
+    DROP COLUMN IF EXISTS pet_friendly;
+
+ALTER TABLE drivers
+    DROP COLUMN IF EXISTS offers_quiet_rides;
+
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS preferences_relaxed,
+    DROP COLUMN IF EXISTS allow_preference_fallback,
+    DROP COLUMN IF EXISTS pref_quiet_ride,
+    DROP COLUMN IF EXISTS pref_child_seat,
+    DROP COLUMN IF EXISTS pref_pet_friendly,
+    DROP COLUMN IF EXISTS pref_wheelchair_access,
+    DROP COLUMN IF EXISTS pref_preferred_driver_gender;
+
+ALTER TABLE passengers
+    DROP COLUMN IF EXISTS pref_quiet_ride,
+    DROP COLUMN IF EXISTS pref_child_seat,
+    DROP COLUMN IF EXISTS pref_pet_friendly,
+    DROP COLUMN IF EXISTS pref_wheelchair_access,
+    DROP COLUMN IF EXISTS pref_preferred_driver_gender;
@@ -0,0 +1,25 @@
+-- 1. Default preferences of passengers
+ALTER TABLE passengers
+    ADD COLUMN IF NOT EXISTS pref_preferred_driver_gender gender,
+    ADD COLUMN IF NOT EXISTS pref_wheelchair_access BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pref_pet_friendly BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pref_child_seat BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pref_quiet_ride BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 2. Preferences of a booking and whether they may be relaxed
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS pref_preferred_driver_gender gender,
+    ADD COLUMN IF NOT EXISTS pref_wheelchair_access BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pref_pet_friendly BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pref_child_seat BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS pref_quiet_ride BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS allow_preference_fallback BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS preferences_relaxed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 3. Capabilities of drivers and cars
+ALTER TABLE drivers
+    ADD COLUMN IF NOT EXISTS offers_quiet_rides BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE cars
+    ADD COLUMN IF NOT EXISTS pet_friendly BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS has_child_seat BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+ALTER TABLE drivers
+    DROP COLUMN IF EXISTS destination_uses_date,
+    DROP COLUMN IF EXISTS destination_uses,
+    DROP COLUMN IF EXISTS destination_longitude,
+    DROP COLUMN IF EXISTS destination_latitude;
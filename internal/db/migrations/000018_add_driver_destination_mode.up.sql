@@ -0,0 +1,6 @@
+-- Destination mode ("heading home") and its daily usage
+ALTER TABLE drivers
+    ADD COLUMN IF NOT EXISTS destination_latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS destination_longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS destination_uses INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS destination_uses_date DATE;
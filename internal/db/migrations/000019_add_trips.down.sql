@@ -0,0 +1,6 @@
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS trip_id,
+    DROP COLUMN IF EXISTS is_shared;
+
+DROP TABLE IF EXISTS trip_stops;
+DROP TABLE IF EXISTS trips;
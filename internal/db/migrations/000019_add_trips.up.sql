@@ -0,0 +1,35 @@
+-- 1. Trips group the shared bookings served by a driver at once
+CREATE TABLE IF NOT EXISTS trips (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ,
+
+    driver_id UUID NOT NULL REFERENCES drivers(id),
+    status VARCHAR(20) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_trips_driver_status ON trips(driver_id, status);
+
+-- 2. Ordered pickups and drop-offs of a trip
+CREATE TABLE IF NOT EXISTS trip_stops (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ,
+
+    trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    booking_id UUID NOT NULL REFERENCES bookings(id),
+    sequence INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_trip_stops_trip_sequence ON trip_stops(trip_id, sequence);
+
+-- 3. Shared bookings
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS is_shared BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS trip_id UUID REFERENCES trips(id);
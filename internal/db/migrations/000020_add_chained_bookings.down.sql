@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_bookings_queued_behind;
+
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS queued_behind_booking_id;
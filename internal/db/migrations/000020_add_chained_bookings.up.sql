@@ -0,0 +1,6 @@
+-- Bookings accepted by a driver who is still finishing another ride
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS queued_behind_booking_id UUID REFERENCES bookings(id);
+
+CREATE INDEX IF NOT EXISTS idx_bookings_queued_behind ON bookings(queued_behind_booking_id)
+    WHERE queued_behind_booking_id IS NOT NULL;
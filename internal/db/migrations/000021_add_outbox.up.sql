@@ -0,0 +1,16 @@
+-- Events written together with the state change they announce, delivered to the queue by the relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+
+    topic VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    sent_at TIMESTAMPTZ
+);
+
+-- The relay only looks at unsent events
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(next_attempt_at) WHERE sent_at IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_queue_dead_letters_topic;
+DROP TABLE IF EXISTS queue_dead_letters;
+
+DROP INDEX IF EXISTS idx_queue_messages_topic_visible;
+DROP TABLE IF EXISTS queue_messages;
@@ -0,0 +1,28 @@
+-- 1. Messages of the Postgres message queue, shared by every API instance
+CREATE TABLE IF NOT EXISTS queue_messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+
+    topic VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+
+    attempts INT NOT NULL DEFAULT 0,
+    visible_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_messages_topic_visible ON queue_messages(topic, visible_at);
+
+-- 2. Messages that ran out of attempts or couldn't be decoded
+CREATE TABLE IF NOT EXISTS queue_dead_letters (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+
+    topic VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT,
+    queued_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_queue_dead_letters_topic ON queue_dead_letters(topic, created_at);
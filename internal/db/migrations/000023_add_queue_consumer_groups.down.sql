@@ -0,0 +1,9 @@
+ALTER TABLE queue_dead_letters DROP COLUMN IF EXISTS consumer_group;
+
+DROP INDEX IF EXISTS idx_queue_messages_group_visible;
+-- Keep one copy of every message
+DELETE FROM queue_messages WHERE consumer_group <> 'driver-matching';
+ALTER TABLE queue_messages DROP COLUMN IF EXISTS consumer_group;
+CREATE INDEX IF NOT EXISTS idx_queue_messages_topic_visible ON queue_messages(topic, visible_at);
+
+DROP TABLE IF EXISTS queue_consumer_groups;
@@ -0,0 +1,20 @@
+-- 1. Consumer groups of the Postgres message queue, every group gets its own copy of a message
+CREATE TABLE IF NOT EXISTS queue_consumer_groups (
+    topic VARCHAR(100) NOT NULL,
+    consumer_group VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (topic, consumer_group)
+);
+
+-- Matching was the only consumer so far, it keeps the messages already queued
+INSERT INTO queue_consumer_groups (topic, consumer_group) VALUES ('DRIVER_MATCHING', 'driver-matching')
+ON CONFLICT DO NOTHING;
+
+-- 2. Messages belong to one group
+ALTER TABLE queue_messages ADD COLUMN IF NOT EXISTS consumer_group VARCHAR(100) NOT NULL DEFAULT 'driver-matching';
+ALTER TABLE queue_messages ALTER COLUMN consumer_group DROP DEFAULT;
+
+DROP INDEX IF EXISTS idx_queue_messages_topic_visible;
+CREATE INDEX IF NOT EXISTS idx_queue_messages_group_visible ON queue_messages(topic, consumer_group, visible_at);
+
+ALTER TABLE queue_dead_letters ADD COLUMN IF NOT EXISTS consumer_group VARCHAR(100);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_bookings_recurring_occurrence;
+ALTER TABLE bookings DROP COLUMN IF EXISTS recurring_ride_id;
+
+DROP INDEX IF EXISTS idx_recurring_rides_due;
+DROP INDEX IF EXISTS idx_recurring_rides_passenger;
+DROP TABLE IF EXISTS recurring_rides;
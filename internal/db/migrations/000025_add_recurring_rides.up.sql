@@ -0,0 +1,42 @@
+-- 1. Rides a passenger takes at the same time on some days of every week
+CREATE TABLE IF NOT EXISTS recurring_rides (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    deleted_at TIMESTAMPTZ,
+
+    passenger_id UUID NOT NULL REFERENCES passengers(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+
+    pickup_latitude DOUBLE PRECISION NOT NULL,
+    pickup_longitude DOUBLE PRECISION NOT NULL,
+    dropoff_latitude DOUBLE PRECISION NOT NULL,
+    dropoff_longitude DOUBLE PRECISION NOT NULL,
+    pickup_address TEXT,
+    dropoff_address TEXT,
+
+    car_type VARCHAR(20) NOT NULL DEFAULT 'ECONOMY',
+    is_shared BOOLEAN NOT NULL DEFAULT FALSE,
+    allow_preference_fallback BOOLEAN NOT NULL DEFAULT FALSE,
+
+    time_of_day VARCHAR(5) NOT NULL,
+    weekdays JSONB NOT NULL DEFAULT '[]',
+    time_zone VARCHAR(64) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    skip_dates JSONB NOT NULL DEFAULT '[]',
+
+    materialized_until TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_rides_passenger ON recurring_rides(passenger_id) WHERE deleted_at IS NULL;
+-- The scheduler only looks at active series
+CREATE INDEX IF NOT EXISTS idx_recurring_rides_due ON recurring_rides(materialized_until) WHERE status = 'ACTIVE';
+
+-- 2. Bookings of the occurrences
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS recurring_ride_id UUID REFERENCES recurring_rides(id);
+
+-- An occurrence is booked once, it can be booked again after it was cancelled
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookings_recurring_occurrence ON bookings(recurring_ride_id, scheduled_time)
+    WHERE recurring_ride_id IS NOT NULL AND status <> 'CANCELLED';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_bookings_reservable;
+DROP INDEX IF EXISTS idx_bookings_reserved_driver;
+
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS reminder_sent_at,
+    DROP COLUMN IF EXISTS reserved_at,
+    DROP COLUMN IF EXISTS reserved_driver_id;
@@ -0,0 +1,11 @@
+-- Drivers reserve scheduled rides in advance
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS reserved_driver_id UUID REFERENCES drivers(id),
+    ADD COLUMN IF NOT EXISTS reserved_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_reserved_driver ON bookings(reserved_driver_id, scheduled_time)
+    WHERE reserved_driver_id IS NOT NULL;
+-- Drivers browse the scheduled rides nobody reserved yet
+CREATE INDEX IF NOT EXISTS idx_bookings_reservable ON bookings(scheduled_time)
+    WHERE status = 'SCHEDULED' AND reserved_driver_id IS NULL AND NOT is_shared;
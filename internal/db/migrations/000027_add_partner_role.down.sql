@@ -0,0 +1,2 @@
+DELETE FROM account_roles WHERE role_id IN (SELECT id FROM roles WHERE name = 'ROLE_PARTNER');
+DELETE FROM roles WHERE name = 'ROLE_PARTNER';
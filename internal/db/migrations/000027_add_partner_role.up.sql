@@ -0,0 +1,5 @@
+-- Partners book rides with a passenger account, this role is granted on top of ROLE_PASSENGER
+-- and gates the partner APIs such as webhooks
+INSERT INTO roles (name, description) VALUES
+    ('ROLE_PARTNER', 'Booking partner')
+    ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_booking_offers_sequence;
+
+DELETE FROM booking_notified_drivers WHERE status = 'QUEUED';
+
+ALTER TABLE booking_notified_drivers
+    DROP COLUMN IF EXISTS sequence;
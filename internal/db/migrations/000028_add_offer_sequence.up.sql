@@ -0,0 +1,6 @@
+-- Sequential offers queue every ranked driver up front, the position keeps the order across restarts
+ALTER TABLE booking_notified_drivers
+    ADD COLUMN IF NOT EXISTS sequence INT;
+
+-- Lets the sweeper find sequences whose current offer ran out
+CREATE INDEX IF NOT EXISTS idx_booking_offers_sequence ON booking_notified_drivers(status, expires_at) WHERE sequence IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE bookings
+    DROP COLUMN IF EXISTS activation_attempts;
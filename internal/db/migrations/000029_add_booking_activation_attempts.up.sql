@@ -0,0 +1,3 @@
+-- Scheduled bookings whose activation failed go back to SCHEDULED, they are cancelled after a few attempts
+ALTER TABLE bookings
+    ADD COLUMN IF NOT EXISTS activation_attempts INT NOT NULL DEFAULT 0;
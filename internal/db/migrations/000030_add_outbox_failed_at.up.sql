@@ -0,0 +1,6 @@
+-- The relay gives up on events it can't decode or that failed too often, they stay for inspection
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(next_attempt_at) WHERE sent_at IS NULL AND failed_at IS NULL;
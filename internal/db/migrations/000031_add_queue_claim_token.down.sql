@@ -0,0 +1,2 @@
+ALTER TABLE queue_messages
+    DROP COLUMN IF EXISTS claim_token;
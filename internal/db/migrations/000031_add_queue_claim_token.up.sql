@@ -0,0 +1,4 @@
+-- Every claim of a message gets a new token, a consumer whose visibility timeout ran out can't
+-- acknowledge a message another consumer claimed since
+ALTER TABLE queue_messages
+    ADD COLUMN IF NOT EXISTS claim_token UUID;
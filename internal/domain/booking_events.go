@@ -0,0 +1,227 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Every booking lifecycle event is published to its own topic, subscribers pick the ones they need
+const (
+	TopicBookingCreated        = "BOOKING_CREATED"
+	TopicBookingScheduled      = "BOOKING_SCHEDULED"
+	TopicBookingRescheduled    = "BOOKING_RESCHEDULED"
+	TopicBookingReserved       = "BOOKING_RESERVED"
+	TopicBookingDriverReleased = "BOOKING_DRIVER_RELEASED"
+	TopicBookingActivated      = "BOOKING_ACTIVATED"
+	TopicBookingAccepted       = "BOOKING_ACCEPTED"
+	TopicBookingDeclined       = "BOOKING_DECLINED"
+	TopicBookingDriverArrived  = "BOOKING_DRIVER_ARRIVED"
+	TopicBookingStarted        = "BOOKING_STARTED"
+	TopicBookingCompleted      = "BOOKING_COMPLETED"
+	TopicBookingCancelled      = "BOOKING_CANCELLED"
+	TopicBookingRated          = "BOOKING_RATED"
+	TopicBookingPaid           = "BOOKING_PAID"
+)
+
+// BookingEventTopics are the topics of all booking lifecycle events
+var BookingEventTopics = []string{
+	TopicBookingCreated,
+	TopicBookingScheduled,
+	TopicBookingRescheduled,
+	TopicBookingReserved,
+	TopicBookingDriverReleased,
+	TopicBookingActivated,
+	TopicBookingAccepted,
+	TopicBookingDeclined,
+	TopicBookingDriverArrived,
+	TopicBookingStarted,
+	TopicBookingCompleted,
+	TopicBookingCancelled,
+	TopicBookingRated,
+	TopicBookingPaid,
+}
+
+// BookingEventTypes are the event types of all booking lifecycle events
+var BookingEventTypes = []string{
+	BookingCreated{}.EventType(),
+	BookingScheduled{}.EventType(),
+	BookingRescheduled{}.EventType(),
+	BookingReserved{}.EventType(),
+	BookingDriverReleased{}.EventType(),
+	BookingActivated{}.EventType(),
+	BookingAccepted{}.EventType(),
+	BookingDeclined{}.EventType(),
+	BookingDriverArrived{}.EventType(),
+	BookingStarted{}.EventType(),
+	BookingCompleted{}.EventType(),
+	BookingCancelled{}.EventType(),
+	BookingRated{}.EventType(),
+	BookingPaid{}.EventType(),
+}
+
+// BookingEvent is what every booking lifecycle event carries, the state of the booking after the change
+type BookingEvent struct {
+	BookingID   uuid.UUID  `json:"booking_id"`
+	PassengerID uuid.UUID  `json:"passenger_id"`
+	DriverID    *uuid.UUID `json:"driver_id,omitempty"`
+	Status      string     `json:"status"`
+}
+
+// BookingCreated is published for every new booking, also the ones scheduled for later
+type BookingCreated struct {
+	BookingEvent
+	City          string     `json:"city"`
+	CarType       string     `json:"car_type"`
+	Shared        bool       `json:"shared"`
+	ScheduledTime *time.Time `json:"scheduled_time,omitempty"`
+	// Set when the booking is an occurrence of a recurring ride
+	RecurringRideID *uuid.UUID `json:"recurring_ride_id,omitempty"`
+}
+
+func (BookingCreated) EventType() string { return "booking.created" }
+func (BookingCreated) EventVersion() int { return 1 }
+func (BookingCreated) Topic() string     { return TopicBookingCreated }
+
+// BookingScheduled follows BookingCreated for rides booked in advance
+type BookingScheduled struct {
+	BookingEvent
+	ScheduledTime time.Time `json:"scheduled_time"`
+}
+
+func (BookingScheduled) EventType() string { return "booking.scheduled" }
+func (BookingScheduled) EventVersion() int { return 1 }
+func (BookingScheduled) Topic() string     { return TopicBookingScheduled }
+
+// BookingRescheduled is published when the passenger moves the pickup of a scheduled ride
+type BookingRescheduled struct {
+	BookingEvent
+	PreviousScheduledTime time.Time `json:"previous_scheduled_time"`
+	ScheduledTime         time.Time `json:"scheduled_time"`
+}
+
+func (BookingRescheduled) EventType() string { return "booking.rescheduled" }
+func (BookingRescheduled) EventVersion() int { return 1 }
+func (BookingRescheduled) Topic() string     { return TopicBookingRescheduled }
+
+// BookingReserved is published when a driver reserves a scheduled ride in advance
+type BookingReserved struct {
+	BookingEvent
+	ReservedDriverID uuid.UUID `json:"reserved_driver_id"`
+	ScheduledTime    time.Time `json:"scheduled_time"`
+}
+
+func (BookingReserved) EventType() string { return "booking.reserved" }
+func (BookingReserved) EventVersion() int { return 1 }
+func (BookingReserved) Topic() string     { return TopicBookingReserved }
+
+// BookingDriverReleased is published when the driver who reserved a ride drops out. Close to the
+// pickup the ride goes back to open matching, otherwise other drivers can reserve it.
+type BookingDriverReleased struct {
+	BookingEvent
+	ReleasedDriverID uuid.UUID `json:"released_driver_id"`
+	Reason           string    `json:"reason"` // ReleaseReason*
+}
+
+const (
+	ReleaseReasonDriverReleased  = "DRIVER_RELEASED"  // The driver gave the reservation up
+	ReleaseReasonDriverOffline   = "DRIVER_OFFLINE"   // The driver was offline when the ride was due
+	ReleaseReasonDriverBusy      = "DRIVER_BUSY"      // The driver was still on a ride that couldn't be chained when the ride was due
+	ReleaseReasonDriverCancelled = "DRIVER_CANCELLED" // The driver cancelled after being assigned
+	ReleaseReasonRescheduled     = "RESCHEDULED"      // The passenger moved the pickup
+)
+
+func (BookingDriverReleased) EventType() string { return "booking.driver_released" }
+func (BookingDriverReleased) EventVersion() int { return 1 }
+func (BookingDriverReleased) Topic() string     { return TopicBookingDriverReleased }
+
+// BookingActivated is published when a scheduled ride is due and starts looking for a driver
+type BookingActivated struct {
+	BookingEvent
+}
+
+func (BookingActivated) EventType() string { return "booking.activated" }
+func (BookingActivated) EventVersion() int { return 1 }
+func (BookingActivated) Topic() string     { return TopicBookingActivated }
+
+type BookingAccepted struct {
+	BookingEvent
+	// Set when the driver takes the ride after the one they are on
+	QueuedBehindBookingID *uuid.UUID `json:"queued_behind_booking_id,omitempty"`
+}
+
+func (BookingAccepted) EventType() string { return "booking.accepted" }
+func (BookingAccepted) EventVersion() int { return 1 }
+func (BookingAccepted) Topic() string     { return TopicBookingAccepted }
+
+// BookingDeclined is published when a driver turns down the offer of a ride
+type BookingDeclined struct {
+	BookingEvent
+	DeclinedByDriverID uuid.UUID `json:"declined_by_driver_id"`
+}
+
+func (BookingDeclined) EventType() string { return "booking.declined" }
+func (BookingDeclined) EventVersion() int { return 1 }
+func (BookingDeclined) Topic() string     { return TopicBookingDeclined }
+
+type BookingDriverArrived struct {
+	BookingEvent
+	ArrivedAt time.Time `json:"arrived_at"`
+}
+
+func (BookingDriverArrived) EventType() string { return "booking.driver_arrived" }
+func (BookingDriverArrived) EventVersion() int { return 1 }
+func (BookingDriverArrived) Topic() string     { return TopicBookingDriverArrived }
+
+type BookingStarted struct {
+	BookingEvent
+	StartedAt time.Time `json:"started_at"`
+}
+
+func (BookingStarted) EventType() string { return "booking.started" }
+func (BookingStarted) EventVersion() int { return 1 }
+func (BookingStarted) Topic() string     { return TopicBookingStarted }
+
+type BookingCompleted struct {
+	BookingEvent
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+func (BookingCompleted) EventType() string { return "booking.completed" }
+func (BookingCompleted) EventVersion() int { return 1 }
+func (BookingCompleted) Topic() string     { return TopicBookingCompleted }
+
+// CancelledBySystem marks bookings the platform gave up on, e.g. a scheduled ride that couldn't be activated
+const CancelledBySystem = "SYSTEM"
+
+type BookingCancelled struct {
+	BookingEvent
+	CancelledBy string `json:"cancelled_by"` // RoleDriver, RolePassenger or CancelledBySystem
+	// The driver the ride was assigned to, the booking has none anymore
+	CancelledDriverID *uuid.UUID `json:"cancelled_driver_id,omitempty"`
+}
+
+func (BookingCancelled) EventType() string { return "booking.cancelled" }
+func (BookingCancelled) EventVersion() int { return 1 }
+func (BookingCancelled) Topic() string     { return TopicBookingCancelled }
+
+type BookingRated struct {
+	BookingEvent
+	Rating      int  `json:"rating"`
+	ByPassenger bool `json:"by_passenger"` // Otherwise the driver rated the passenger
+}
+
+func (BookingRated) EventType() string { return "booking.rated" }
+func (BookingRated) EventVersion() int { return 1 }
+func (BookingRated) Topic() string     { return TopicBookingRated }
+
+type BookingPaid struct {
+	BookingEvent
+	ReceiptID uuid.UUID `json:"receipt_id"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+}
+
+func (BookingPaid) EventType() string { return "booking.paid" }
+func (BookingPaid) EventVersion() int { return 1 }
+func (BookingPaid) Topic() string     { return TopicBookingPaid }
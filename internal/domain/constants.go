@@ -2,10 +2,13 @@ package domain
 
 const (
 	TopicDriverMatching = "DRIVER_MATCHING"
+	// Consumer group of the matching workers, every API instance joins it to share the work
+	GroupDriverMatching = "driver-matching"
 
 	RoleDriver    = "ROLE_DRIVER"
 	RolePassenger = "ROLE_PASSENGER"
 	RoleAdmin     = "ROLE_ADMIN"
+	RolePartner   = "ROLE_PARTNER"
 
 	PaymentGatewayStripe = "Stripe"
 )
@@ -0,0 +1,11 @@
+package domain
+
+import "github.com/google/uuid"
+
+// DriverMatchingRequested asks the matching consumers to find a driver for a booking
+type DriverMatchingRequested struct {
+	BookingID uuid.UUID `json:"booking_id"`
+}
+
+func (DriverMatchingRequested) EventType() string { return "driver_matching.requested" }
+func (DriverMatchingRequested) EventVersion() int { return 1 }
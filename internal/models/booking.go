@@ -31,6 +31,34 @@ type Booking struct {
 	DropoffLatitude  float64 `gorm:"not null"`
 	DropoffLongitude float64 `gorm:"not null"`
 
+	// Intermediate stops, ordered by Sequence. The drop-off is always the final destination.
+	Stops []BookingStop `gorm:"foreignKey:BookingId"`
+
+	// Human-readable addresses, resolved by reverse geocoding when the booking is created
+	PickupAddress  string
+	DropoffAddress string
+
+	// Shared ride: the passenger rides together with others going the same way, at a lower fare
+	IsShared bool       `gorm:"default:false"`
+	TripId   *uuid.UUID `gorm:"type:uuid"` // Trip the shared booking is on, once a driver accepted it
+
+	// Chained dispatch: the driver accepted this booking while finishing another ride.
+	// Set until that ride ends, the driver then heads to this pickup.
+	QueuedBehindBookingId *uuid.UUID `gorm:"type:uuid"`
+
+	// Vehicle class requested by the passenger
+	CarType CarType `gorm:"default:ECONOMY"`
+
+	// Preferences the driver and car must meet
+	Preferences RidePreferences `gorm:"embedded;embeddedPrefix:pref_"`
+	// When no driver meets every preference, the optional ones (driver gender, quiet ride) may be dropped
+	AllowPreferenceFallback bool `gorm:"default:false"`
+	// Set once the ride was offered without the optional preferences
+	PreferencesRelaxed bool `gorm:"default:false"`
+
+	// City of the service area the pickup falls in (empty if no service areas are configured)
+	City string
+
 	// Reviews
 	ReviewByPassengerId *uuid.UUID `gorm:"type:uuid"`
 	ReviewByPassenger   *Review    `gorm:"foreignKey:ReviewByPassengerId"`
@@ -39,6 +67,45 @@ type Booking struct {
 	ReviewByDriver   *Review    `gorm:"foreignKey:ReviewByDriverId"`
 
 	ScheduledTime *time.Time // Nullable for immediate rides
+	// Activations of the scheduled ride that failed, it is cancelled once too many did
+	ActivationAttempts int `gorm:"not null;default:0"`
+	// Set when the booking is an occurrence of a recurring ride
+	RecurringRideId *uuid.UUID `gorm:"type:uuid"`
+
+	// Driver who reserved the scheduled ride in advance. They are assigned when the ride is
+	// activated, or it goes to open matching if they are offline by then.
+	ReservedDriverId *uuid.UUID `gorm:"type:uuid"`
+	ReservedAt       *time.Time
+	ReminderSentAt   *time.Time // Both parties were reminded of the reserved ride
+
+	// Ride timeline
+	DriverArrivedAt *time.Time // Driver entered the pickup radius, waiting time counts from here
+	RideStartedAt   *time.Time
+}
+
+// WaitingTime returns how long the driver waited at the pickup (so far, if the ride hasn't started yet)
+func (b *Booking) WaitingTime(now time.Time) time.Duration {
+	if b.DriverArrivedAt == nil {
+		return 0
+	}
+	end := now
+	if b.RideStartedAt != nil {
+		end = *b.RideStartedAt
+	}
+	if end.Before(*b.DriverArrivedAt) {
+		return 0
+	}
+	return end.Sub(*b.DriverArrivedAt)
+}
+
+// Waypoints returns the pickup, every stop in order and the drop-off
+func (b *Booking) Waypoints() []ExactLocation {
+	waypoints := make([]ExactLocation, 0, len(b.Stops)+2)
+	waypoints = append(waypoints, ExactLocation{Latitude: b.PickupLatitude, Longitude: b.PickupLongitude})
+	for _, stop := range b.Stops {
+		waypoints = append(waypoints, ExactLocation{Latitude: stop.Latitude, Longitude: stop.Longitude})
+	}
+	return append(waypoints, ExactLocation{Latitude: b.DropoffLatitude, Longitude: b.DropoffLongitude})
 }
 
 func (*Booking) TableName() string {
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BookingOffer is a row of booking_notified_drivers, the offer of a booking to one driver
+type BookingOffer struct {
+	BookingId uuid.UUID `gorm:"type:uuid;primaryKey"`
+	DriverId  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time
+
+	Status    OfferStatus `gorm:"default:OFFERED"`
+	ExpiresAt *time.Time  // Nil for offers without an acceptance window
+	Sequence  *int        // Position of the driver in a sequential offer, nil for broadcast offers
+}
+
+// IsActive reports whether the driver can still accept the offer
+func (o *BookingOffer) IsActive(now time.Time) bool {
+	if o.Status != OfferStatusOffered {
+		return false
+	}
+	return o.ExpiresAt == nil || now.Before(*o.ExpiresAt)
+}
+
+func (*BookingOffer) TableName() string {
+	return "booking_notified_drivers"
+}
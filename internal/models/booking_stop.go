@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// BookingStop is an intermediate stop between the pickup and the drop-off of a booking
+type BookingStop struct {
+	BaseModel
+
+	BookingId uuid.UUID `gorm:"type:uuid;not null"`
+	Sequence  int       `gorm:"not null"` // Order in which the stops are visited, starting at 1
+
+	Latitude  float64 `gorm:"not null"`
+	Longitude float64 `gorm:"not null"`
+	Address   string
+}
+
+func (*BookingStop) TableName() string {
+	return "booking_stops"
+}
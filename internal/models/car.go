@@ -11,7 +11,19 @@ type Car struct {
 	PlateNumber   string    `gorm:"uniqueIndex"`
 	BrandAndModel string
 	Color         string
-	CarType       string
+	CarType       CarType `gorm:"default:ECONOMY"`
+
+	// The driver is willing to take rides of a lower class (e.g. a premium car taking economy rides)
+	AcceptsLowerClass bool `gorm:"default:false"`
+
+	// Capabilities matched against passenger preferences
+	PetFriendly  bool `gorm:"default:false"`
+	HasChildSeat bool `gorm:"default:false"`
+}
+
+// IsWheelchairAccessible reports whether the car can take a passenger in a wheelchair
+func (c *Car) IsWheelchairAccessible() bool {
+	return c.CarType == CarTypeAccessible
 }
 
 func (*Car) TableName() string {
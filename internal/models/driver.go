@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Driver struct {
@@ -20,6 +21,9 @@ type Driver struct {
 	IsAvailable    bool
 	ActiveCity     string
 
+	// The driver is happy to keep rides quiet when asked
+	OffersQuietRides bool `gorm:"default:false"`
+
 	// Has-One relationship with Car
 	Car Car `gorm:"foreignKey:DriverId"`
 
@@ -27,10 +31,27 @@ type Driver struct {
 	AverageRating float64 `gorm:"default:0.0"`
 	RatingCount   int     `gorm:"default:0"`
 
+	// Dispatch history, used to rank drivers
+	OffersReceived  int `gorm:"default:0"`
+	OffersAccepted  int `gorm:"default:0"`
+	LastRideEndedAt *time.Time
+
+	// Destination mode ("heading home"): while set, only rides towards the destination are offered
+	DestinationLatitude  *float64
+	DestinationLongitude *float64
+	// Times destination mode was turned on during DestinationUsesDate, limited per day
+	DestinationUses     int        `gorm:"default:0"`
+	DestinationUsesDate *time.Time `gorm:"type:date"`
+
 	LastKnownLatitude  *float64
 	LastKnownLongitude *float64
 	// Helper struct for Go logic, not GORM
 	LastKnownLocation *ExactLocation `gorm:"-"`
+	// Direction of travel in degrees from north, only known from live location updates
+	LastKnownHeading *float64 `gorm:"-"`
+	// Time until the driver finishes their current ride. Only set for drivers offered a chained ride,
+	// their LastKnownLocation is then the drop-off of the current ride.
+	BusyFor time.Duration `gorm:"-"`
 }
 
 type ExactLocation struct {
@@ -38,6 +59,31 @@ type ExactLocation struct {
 	Longitude float64
 }
 
+// AcceptanceRate is the share of offers the driver accepted. It is smoothed towards 50%
+// so a handful of offers doesn't make a new driver look perfect or terrible.
+func (d *Driver) AcceptanceRate() float64 {
+	return (float64(d.OffersAccepted) + 1) / (float64(d.OffersReceived) + 2)
+}
+
+// Destination returns where the driver is heading in destination mode, nil when the mode is off
+func (d *Driver) Destination() *ExactLocation {
+	if d.DestinationLatitude == nil || d.DestinationLongitude == nil {
+		return nil
+	}
+	return &ExactLocation{Latitude: *d.DestinationLatitude, Longitude: *d.DestinationLongitude}
+}
+
 func (*Driver) TableName() string {
 	return "drivers"
 }
+
+// AfterFind populates LastKnownLocation from the persisted coordinates
+func (d *Driver) AfterFind(_ *gorm.DB) error {
+	if d.LastKnownLatitude != nil && d.LastKnownLongitude != nil {
+		d.LastKnownLocation = &ExactLocation{
+			Latitude:  *d.LastKnownLatitude,
+			Longitude: *d.LastKnownLongitude,
+		}
+	}
+	return nil
+}
@@ -13,12 +13,17 @@ func (gender Gender) String() string {
 	return string(gender)
 }
 
+func (gender Gender) IsValid() bool {
+	return gender == GenderMale || gender == GenderFemale || gender == GenderOther
+}
+
 // BookingStatus defines the status of a booking
 type BookingStatus string
 
 const (
 	BookingStatusRequested BookingStatus = "REQUESTED"
 	BookingStatusAccepted  BookingStatus = "ACCEPTED"
+	BookingStatusArrived   BookingStatus = "ARRIVED" // Driver is waiting at the pickup
 	BookingStatusStarted   BookingStatus = "STARTED"
 	BookingStatusCompleted BookingStatus = "COMPLETED"
 	BookingStatusCancelled BookingStatus = "CANCELLED"
@@ -30,6 +35,123 @@ func (b BookingStatus) String() string {
 }
 
 func (b BookingStatus) IsCancellable() bool {
-	// Only REQUESTED and ACCEPTED bookings can be cancelled
-	return b == BookingStatusRequested || b == BookingStatusAccepted
+	// Only REQUESTED, ACCEPTED and ARRIVED bookings can be cancelled
+	return b == BookingStatusRequested || b == BookingStatusAccepted || b == BookingStatusArrived
+}
+
+// IsDriverEnRoute reports whether a driver is assigned and the passenger hasn't been picked up yet
+func (b BookingStatus) IsDriverEnRoute() bool {
+	return b == BookingStatusAccepted || b == BookingStatusArrived
+}
+
+// GeofenceType defines what kind of rules a geofence enforces
+type GeofenceType string
+
+const (
+	GeofenceTypeServiceArea GeofenceType = "SERVICE_AREA"
+	GeofenceTypeAirport     GeofenceType = "AIRPORT"
+	GeofenceTypeRestricted  GeofenceType = "RESTRICTED"
+)
+
+func (g GeofenceType) String() string {
+	return string(g)
+}
+
+func (g GeofenceType) IsValid() bool {
+	return g == GeofenceTypeServiceArea || g == GeofenceTypeAirport || g == GeofenceTypeRestricted
+}
+
+// OfferStatus is the state of a ride offer made to a driver
+type OfferStatus string
+
+const (
+	OfferStatusQueued   OfferStatus = "QUEUED" // Waiting for its turn in a sequential offer
+	OfferStatusOffered  OfferStatus = "OFFERED"
+	OfferStatusAccepted OfferStatus = "ACCEPTED"
+	OfferStatusDeclined OfferStatus = "DECLINED"
+	OfferStatusExpired  OfferStatus = "EXPIRED" // The driver let the acceptance window run out
+)
+
+func (o OfferStatus) String() string {
+	return string(o)
+}
+
+// CarType is the vehicle class a passenger can request
+type CarType string
+
+const (
+	CarTypeEconomy    CarType = "ECONOMY"
+	CarTypePremium    CarType = "PREMIUM"
+	CarTypeXL         CarType = "XL"
+	CarTypeAccessible CarType = "ACCESSIBLE" // Wheelchair accessible
+)
+
+// CarTypes lists every vehicle class, cheapest first
+var CarTypes = []CarType{CarTypeEconomy, CarTypeXL, CarTypeAccessible, CarTypePremium}
+
+func (c CarType) String() string {
+	return string(c)
+}
+
+func (c CarType) IsValid() bool {
+	return c == CarTypeEconomy || c == CarTypePremium || c == CarTypeXL || c == CarTypeAccessible
+}
+
+// CanServe reports whether a car of this class may take a ride requested for the given class.
+// Cars of a higher class may take economy rides when their driver opted in.
+func (c CarType) CanServe(requested CarType, acceptsLowerClass bool) bool {
+	if c == requested {
+		return true
+	}
+	return acceptsLowerClass && requested == CarTypeEconomy
+}
+
+// TripStatus is the state of a shared trip
+type TripStatus string
+
+const (
+	TripStatusActive    TripStatus = "ACTIVE"
+	TripStatusCompleted TripStatus = "COMPLETED" // Every rider was dropped off
+)
+
+func (t TripStatus) String() string {
+	return string(t)
+}
+
+// TripStopKind tells whether a rider gets in or out at a trip stop
+type TripStopKind string
+
+const (
+	TripStopPickup  TripStopKind = "PICKUP"
+	TripStopDropoff TripStopKind = "DROPOFF"
+)
+
+func (k TripStopKind) String() string {
+	return string(k)
+}
+
+// WebhookDeliveryStatus tracks a webhook delivery through its retries
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING" // Waiting for its first or next attempt
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED" // Out of attempts, can be replayed
+)
+
+func (s WebhookDeliveryStatus) String() string {
+	return string(s)
+}
+
+// RecurringRideStatus is the state of a series of recurring rides
+type RecurringRideStatus string
+
+const (
+	RecurringRideStatusActive    RecurringRideStatus = "ACTIVE"
+	RecurringRideStatusPaused    RecurringRideStatus = "PAUSED" // No rides until resumed
+	RecurringRideStatusCancelled RecurringRideStatus = "CANCELLED"
+)
+
+func (s RecurringRideStatus) String() string {
+	return string(s)
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookingStatusIsCancellable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status      BookingStatus
+		cancellable bool
+	}{
+		{BookingStatusRequested, true},
+		{BookingStatusAccepted, true},
+		{BookingStatusArrived, true},
+		{BookingStatusStarted, false},
+		{BookingStatusCompleted, false},
+		{BookingStatusCancelled, false},
+		{BookingStatusScheduled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status.String(), func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.cancellable, tt.status.IsCancellable())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"CabBookingService/internal/util"
+)
+
+// Geofence is an admin-managed polygon on the map with rules attached to it.
+// Service areas bound where pickups are accepted in a city, airports carry special
+// tariffs and queue rules, and restricted zones forbid pickups and/or drop-offs.
+type Geofence struct {
+	BaseModel
+
+	Name     string       `gorm:"size:255;not null"`
+	City     string       `gorm:"size:100;not null;index"`
+	Type     GeofenceType `gorm:"size:50;not null"`
+	IsActive bool         `gorm:"default:true"`
+
+	// Polygon vertices in order. The polygon is closed implicitly (last vertex connects to the first).
+	Polygon []GeoPoint `gorm:"type:jsonb;serializer:json;not null"`
+
+	// --- Restricted zone rules ---
+	NoPickup  bool
+	NoDropoff bool
+
+	// --- Airport zone rules ---
+	FareMultiplier float64 `gorm:"default:1.0"` // Applied to the distance based fare
+	FlatSurcharge  float64 `gorm:"default:0.0"` // Added once per trip touching the airport
+	// If set, pickups inside the airport are only offered to drivers already waiting inside the zone
+	RequiresDriverQueue bool
+}
+
+// GeoPoint is a single polygon vertex
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (*Geofence) TableName() string {
+	return "geofences"
+}
+
+// Contains reports whether the given coordinate lies inside the geofence polygon
+func (g *Geofence) Contains(lat, lon float64) bool {
+	vertices := make([][2]float64, 0, len(g.Polygon))
+	for _, p := range g.Polygon {
+		vertices = append(vertices, [2]float64{p.Latitude, p.Longitude})
+	}
+	return util.PointInPolygon(lat, lon, vertices)
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is a message for the queue, written in the same transaction as the state change it
+// announces. The outbox relay delivers it and marks it sent.
+type OutboxEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	CreatedAt time.Time
+
+	Topic   string `gorm:"not null"`
+	Payload string `gorm:"type:jsonb;not null"` // The message, JSON encoded
+
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null"` // Claimed events are pushed back by the claim timeout
+	LastError     string
+	SentAt        *time.Time // Nil until the relay delivered the event
+	FailedAt      *time.Time // Set when the relay gave up on the event, it is kept for inspection
+}
+
+func (*OutboxEvent) TableName() string {
+	return "outbox"
+}
@@ -17,6 +17,9 @@ type Passenger struct {
 	Gender      *Gender
 	DateOfBirth *time.Time `gorm:"type:date"` // 'type:date' forces Postgres to use the DATE column, ignoring the time part of time.Time
 
+	// Default preferences, used for bookings that don't specify their own
+	Preferences RidePreferences `gorm:"embedded;embeddedPrefix:pref_"`
+
 	// Rating
 	AverageRating float64 `gorm:"default:0.0"`
 	RatingCount   int     `gorm:"default:0"`
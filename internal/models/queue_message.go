@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueueMessage is a message waiting in the Postgres message queue
+type QueueMessage struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	CreatedAt time.Time
+
+	Topic         string `gorm:"not null"`
+	ConsumerGroup string `gorm:"not null"`            // Every group of the topic gets its own copy
+	Payload       string `gorm:"type:jsonb;not null"` // The message, JSON encoded
+
+	Attempts int `gorm:"not null;default:0"` // Deliveries so far, counted when the message is claimed
+	// Consumers don't see the message before this, while it is claimed or waiting for a retry
+	VisibleAt time.Time `gorm:"not null"`
+	// Set anew by every claim, only the consumer holding the current claim can settle the message
+	ClaimToken *uuid.UUID `gorm:"type:uuid"`
+	LastError  string
+}
+
+func (*QueueMessage) TableName() string {
+	return "queue_messages"
+}
+
+// DeadLetter is a queue message that ran out of attempts or couldn't be decoded, kept for inspection
+type DeadLetter struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;"` // Same as the queue message
+	CreatedAt time.Time // When the message was dead-lettered
+
+	Topic         string `gorm:"not null"`
+	ConsumerGroup string // Empty when the message never reached a group
+	Payload       string `gorm:"type:jsonb;not null"`
+	Attempts      int    `gorm:"not null"`
+	LastError     string
+	QueuedAt      time.Time // When the message was published
+}
+
+func (*DeadLetter) TableName() string {
+	return "queue_dead_letters"
+}
+
+// QueueConsumerGroup is a consumer group of a topic. It stays registered while nobody is
+// subscribed, so its messages wait for the next subscriber.
+type QueueConsumerGroup struct {
+	Topic         string `gorm:"primaryKey"`
+	ConsumerGroup string `gorm:"primaryKey"`
+	CreatedAt     time.Time
+}
+
+func (*QueueConsumerGroup) TableName() string {
+	return "queue_consumer_groups"
+}
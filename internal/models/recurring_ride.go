@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecurringRide is a ride the passenger takes at the same time on some days of every week. The
+// scheduler books each occurrence ahead of time as a SCHEDULED booking.
+type RecurringRide struct {
+	BaseModel
+
+	PassengerId uuid.UUID `gorm:"type:uuid;not null"`
+	Status      RecurringRideStatus
+
+	PickupLatitude   float64 `gorm:"not null"`
+	PickupLongitude  float64 `gorm:"not null"`
+	DropoffLatitude  float64 `gorm:"not null"`
+	DropoffLongitude float64 `gorm:"not null"`
+	PickupAddress    string
+	DropoffAddress   string
+
+	CarType                 CarType `gorm:"default:ECONOMY"`
+	IsShared                bool    `gorm:"default:false"`
+	AllowPreferenceFallback bool    `gorm:"default:false"`
+
+	// Pickup at TimeOfDay (HH:MM) in TimeZone on the Weekdays (MON to SUN), from StartDate until
+	// EndDate, both inclusive. SkipDates (YYYY-MM-DD) have no ride.
+	TimeOfDay string     `gorm:"size:5;not null"`
+	Weekdays  []string   `gorm:"type:jsonb;serializer:json;not null"`
+	TimeZone  string     `gorm:"size:64;not null"`
+	StartDate time.Time  `gorm:"type:date;not null"`
+	EndDate   *time.Time `gorm:"type:date"`
+	SkipDates []string   `gorm:"type:jsonb;serializer:json;not null"`
+
+	// Occurrences up to here are booked
+	MaterializedUntil time.Time `gorm:"not null"`
+}
+
+func (*RecurringRide) TableName() string {
+	return "recurring_rides"
+}
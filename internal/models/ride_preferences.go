@@ -0,0 +1,25 @@
+package models
+
+// RidePreferences are what a passenger asks of the driver and car.
+// Passengers keep default preferences on their profile, bookings carry the ones used for that ride.
+type RidePreferences struct {
+	PreferredDriverGender *Gender // Nil when the passenger has no preference
+	WheelchairAccess      bool    `gorm:"default:false"`
+	PetFriendly           bool    `gorm:"default:false"` // Travelling with a pet
+	ChildSeat             bool    `gorm:"default:false"`
+	QuietRide             bool    `gorm:"default:false"`
+}
+
+// Essential preferences are never relaxed, the ride can't happen safely without them
+func (p RidePreferences) Essential() RidePreferences {
+	return RidePreferences{
+		WheelchairAccess: p.WheelchairAccess,
+		PetFriendly:      p.PetFriendly,
+		ChildSeat:        p.ChildSeat,
+	}
+}
+
+// HasOptional reports whether some preferences could be dropped to find a driver
+func (p RidePreferences) HasOptional() bool {
+	return p.PreferredDriverGender != nil || p.QuietRide
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+const (
+	PlaceLabelHome = "HOME"
+	PlaceLabelWork = "WORK"
+)
+
+// SavedPlace is a location a passenger stored for reuse (home, work or a custom label)
+type SavedPlace struct {
+	BaseModel
+
+	PassengerId uuid.UUID `gorm:"type:uuid;not null"`
+	Label       string    `gorm:"size:50;not null"` // Unique per passenger
+
+	Latitude  float64 `gorm:"not null"`
+	Longitude float64 `gorm:"not null"`
+	Address   string
+}
+
+func (*SavedPlace) TableName() string {
+	return "saved_places"
+}
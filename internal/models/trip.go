@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trip is the drive of one driver serving several shared bookings at once
+type Trip struct {
+	BaseModel
+
+	DriverId uuid.UUID `gorm:"type:uuid;not null"`
+	Status   TripStatus
+
+	// Pickups and drop-offs of every booking on the trip, ordered by Sequence
+	Stops []TripStop `gorm:"foreignKey:TripId"`
+}
+
+// RemainingStops returns the stops still to be visited, in order
+func (t *Trip) RemainingStops() []TripStop {
+	remaining := make([]TripStop, 0, len(t.Stops))
+	for _, stop := range t.Stops {
+		if stop.CompletedAt == nil {
+			remaining = append(remaining, stop)
+		}
+	}
+	return remaining
+}
+
+// CompletedStops returns the stops already visited, in order
+func (t *Trip) CompletedStops() []TripStop {
+	completed := make([]TripStop, 0, len(t.Stops))
+	for _, stop := range t.Stops {
+		if stop.CompletedAt != nil {
+			completed = append(completed, stop)
+		}
+	}
+	return completed
+}
+
+func (*Trip) TableName() string {
+	return "trips"
+}
+
+// TripStop is the pickup or drop-off of a booking on a trip
+type TripStop struct {
+	BaseModel
+
+	TripId    uuid.UUID    `gorm:"type:uuid;not null"`
+	BookingId uuid.UUID    `gorm:"type:uuid;not null"`
+	Sequence  int          `gorm:"not null"` // Order in which the stops are visited, starting at 1
+	Kind      TripStopKind `gorm:"not null"`
+
+	Latitude    float64 `gorm:"not null"`
+	Longitude   float64 `gorm:"not null"`
+	CompletedAt *time.Time
+}
+
+func (s *TripStop) Location() ExactLocation {
+	return ExactLocation{Latitude: s.Latitude, Longitude: s.Longitude}
+}
+
+func (*TripStop) TableName() string {
+	return "trip_stops"
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEndpoint is a URL a partner registered to hear about the lifecycle of their bookings
+type WebhookEndpoint struct {
+	BaseModel
+
+	// The partner's account books the rides, only events of its bookings are sent
+	PassengerId uuid.UUID `gorm:"type:uuid;not null"`
+	URL         string    `gorm:"not null"`
+	Secret      string    `gorm:"not null"` // Signs the requests, only shown when the endpoint is registered
+	EventTypes  []string  `gorm:"type:jsonb;serializer:json;not null"`
+	IsActive    bool      `gorm:"not null;default:true"`
+}
+
+// Subscribes reports whether the endpoint wants events of this type
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (*WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookDelivery is an event on its way to an endpoint, it stays as the delivery log
+type WebhookDelivery struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	EndpointId uuid.UUID `gorm:"type:uuid;not null"`
+	EventID    uuid.UUID `gorm:"type:uuid;not null"` // Unique per endpoint, the event is delivered once
+	EventType  string    `gorm:"not null"`
+	Payload    string    `gorm:"type:jsonb;not null"` // The event envelope, sent as the request body
+
+	Status         WebhookDeliveryStatus `gorm:"not null;default:PENDING"`
+	Attempts       int                   `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time             `gorm:"not null"` // Claimed deliveries are pushed back by the claim timeout
+	LastStatusCode int                   // Status of the last answer, 0 if the receiver didn't answer
+	LastError      string
+	DeliveredAt    *time.Time
+}
+
+func (*WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
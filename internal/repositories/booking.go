@@ -5,10 +5,12 @@ import (
 	"CabBookingService/internal/models"
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // BookingRepository defines the methods for interacting with booking data.
@@ -21,17 +23,88 @@ type BookingRepository interface {
 	UpdateStatus(ctx context.Context, id uuid.UUID, status models.BookingStatus) error
 
 	// New Security Methods
+	// AddNotifiedDrivers offers the booking to the drivers, offers made before are kept
 	AddNotifiedDrivers(ctx context.Context, bookingID uuid.UUID, drivers []models.Driver) error
 	IsDriverNotified(ctx context.Context, bookingID uuid.UUID, driverID uuid.UUID) (bool, error)
 
+	// CreateOffer offers the booking to a single driver
+	CreateOffer(ctx context.Context, offer *models.BookingOffer) error
+	GetOffer(ctx context.Context, bookingID, driverID uuid.UUID) (*models.BookingOffer, error)
+	// UpdateOfferStatus moves an offer from one status to another. Returns false if the offer was not in the from status.
+	UpdateOfferStatus(ctx context.Context, bookingID, driverID uuid.UUID, from, to models.OfferStatus) (bool, error)
+	// AcceptOffer marks an offer accepted if it is still live at now. Returns false if it was not.
+	AcceptOffer(ctx context.Context, bookingID, driverID uuid.UUID, now time.Time) (bool, error)
+	// CreateOfferSequence queues the booking for the drivers in order, they get the offer one at a time.
+	// Drivers who already had an offer for the booking are skipped, a re-match is queued after the last match.
+	CreateOfferSequence(ctx context.Context, bookingID uuid.UUID, driverIDs []uuid.UUID) error
+	// AdvanceOfferSequence expires the current sequential offer once its window ran out and offers the booking
+	// to the next queued driver until expiresAt, with the booking row locked. Returns nil if the booking is no
+	// longer requested or its current offer is still live, and gorm.ErrRecordNotFound if no driver is left.
+	AdvanceOfferSequence(ctx context.Context, bookingID uuid.UUID, now, expiresAt time.Time) (*models.BookingOffer, error)
+	// ListStalledOfferSequences returns requested bookings whose sequential offer has to move on,
+	// because the current offer ran out or was declined without the next one being made
+	ListStalledOfferSequences(ctx context.Context, now time.Time, limit int) ([]uuid.UUID, error)
+
 	SaveReviewAndRecalculateDriverRating(ctx context.Context, bookingID uuid.UUID, review *models.Review) error
 	SaveReviewAndRecalculatePassengerRating(ctx context.Context, bookingID uuid.UUID, review *models.Review) error
 
 	GetPendingBookingsForDriver(ctx context.Context, driverID uuid.UUID, limit, offset int) ([]models.Booking, error)
 
-	GetDueScheduledBookings(ctx context.Context, cutoff time.Time) ([]models.Booking, error)
-
-	AcceptBookingTransaction(ctx context.Context, bookingID, driverID uuid.UUID, otpID uuid.UUID) error
+	// ClaimDueScheduledBookings moves up to limit SCHEDULED bookings due by the cutoff of their city
+	// to REQUESTED, earliest first, and returns them. Cities without a cityCutoffs entry use cutoff.
+	// Bookings another instance is claiming are skipped, so each one is activated once. Called in a
+	// transaction, the activation commits or rolls back with it.
+	ClaimDueScheduledBookings(ctx context.Context, cityCutoffs map[string]time.Time, cutoff time.Time, limit int) ([]models.Booking, error)
+	// FailActivation moves a claimed booking whose activation failed from REQUESTED to status, SCHEDULED
+	// to try again on a later tick or CANCELLED to give up, and counts the attempt.
+	// Returns false if the booking is not REQUESTED anymore.
+	FailActivation(ctx context.Context, bookingID uuid.UUID, status models.BookingStatus) (bool, error)
+	// RescheduleBooking moves the pickup of a SCHEDULED booking and takes off its reservation, if any.
+	// Returns false if the booking is not SCHEDULED anymore, and gorm.ErrDuplicatedKey if another
+	// occurrence of its recurring ride is booked at scheduledTime.
+	RescheduleBooking(ctx context.Context, bookingID uuid.UUID, scheduledTime time.Time) (bool, error)
+	// CancelScheduledOccurrences cancels the SCHEDULED bookings of a recurring ride with a scheduled
+	// time in [from, to) and returns them. A zero to cancels every one from on.
+	CancelScheduledOccurrences(ctx context.Context, recurringRideID uuid.UUID, from, to time.Time) ([]models.Booking, error)
+
+	// ListReservableBookings returns SCHEDULED solo bookings nobody reserved yet with a pickup in
+	// (from, to], earliest first. An empty city lists every city.
+	ListReservableBookings(ctx context.Context, city string, from, to time.Time, limit, offset int) ([]models.Booking, error)
+	// ListDriverReservations returns the SCHEDULED bookings the driver reserved, earliest first
+	ListDriverReservations(ctx context.Context, driverID uuid.UUID) ([]models.Booking, error)
+	// ReserveScheduledBooking reserves a SCHEDULED booking for the driver. Returns false if it isn't
+	// SCHEDULED anymore or another driver reserved it first.
+	ReserveScheduledBooking(ctx context.Context, bookingID, driverID uuid.UUID, reservedAt time.Time) (bool, error)
+	// ReleaseReservation takes the driver's reservation off a booking no driver is assigned to yet.
+	// Returns false if the driver doesn't hold it.
+	ReleaseReservation(ctx context.Context, bookingID, driverID uuid.UUID) (bool, error)
+	// ReturnReservedToMatching takes the driver who reserved the booking and was assigned to it off it,
+	// moving it from the from status back to REQUESTED. Returns false if the booking is not in the from
+	// status with that driver anymore.
+	ReturnReservedToMatching(ctx context.Context, bookingID, driverID uuid.UUID, from models.BookingStatus) (bool, error)
+	// ClaimReservationReminders marks up to limit reserved SCHEDULED bookings with a pickup before the
+	// cutoff as reminded and returns them, so each reminder goes out once.
+	ClaimReservationReminders(ctx context.Context, cutoff, now time.Time, limit int) ([]models.Booking, error)
+
+	// AcceptBookingTransaction assigns the driver. With queuedBehind set, the booking is chained after
+	// that ride of the driver, as long as it is still in progress. Returns whether it was chained.
+	AcceptBookingTransaction(ctx context.Context, bookingID, driverID uuid.UUID, otpID uuid.UUID, queuedBehind *uuid.UUID) (bool, error)
+
+	// GetActiveBookingForDriver returns the booking the driver is currently serving (ACCEPTED, ARRIVED or STARTED)
+	GetActiveBookingForDriver(ctx context.Context, driverID uuid.UUID) (*models.Booking, error)
+	// MarkDriverArrived moves an ACCEPTED booking to ARRIVED. Returns false if the booking was not in ACCEPTED status.
+	MarkDriverArrived(ctx context.Context, bookingID uuid.UUID, arrivedAt time.Time) (bool, error)
+	// ChangeDestination moves the drop-off of a STARTED booking. Returns false if the booking was not in STARTED status.
+	ChangeDestination(ctx context.Context, bookingID uuid.UUID, latitude, longitude float64, address string) (bool, error)
+	// MarkPreferencesRelaxed records that the booking is offered without its optional preferences
+	MarkPreferencesRelaxed(ctx context.Context, bookingID uuid.UUID) error
+
+	// GetChainableBookings returns solo STARTED bookings with a drop-off inside the box whose driver
+	// hasn't queued a next ride yet. The driver and their car are preloaded.
+	GetChainableBookings(ctx context.Context, minLat, maxLat, minLon, maxLon float64) ([]models.Booking, error)
+	// ReleaseQueuedBooking un-queues the booking chained after the given one.
+	// Returns gorm.ErrRecordNotFound if nothing was queued.
+	ReleaseQueuedBooking(ctx context.Context, bookingID uuid.UUID) (*models.Booking, error)
 }
 
 type gormBookingRepository struct {
@@ -61,6 +134,9 @@ func (r *gormBookingRepository) GetByID(ctx context.Context, id uuid.UUID) (*mod
 		Preload("RideStartOTP").
 		Preload("ReviewByPassenger").
 		Preload("ReviewByDriver").
+		Preload("Stops", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sequence ASC")
+		}).
 		First(&booking, "id = ?", id).Error
 	if err != nil {
 		return nil, err
@@ -83,9 +159,22 @@ func (r *gormBookingRepository) UpdateStatus(ctx context.Context, id uuid.UUID,
 
 func (r *gormBookingRepository) AddNotifiedDrivers(ctx context.Context, bookingID uuid.UUID, drivers []models.Driver) error {
 	tx := db.NewGormTx(ctx, r.db)
-	booking := models.Booking{BaseModel: models.BaseModel{ID: bookingID}}
-	// GORM's Association Mode handles the INSERT into booking_notified_drivers
-	return tx.Model(&booking).Association("NotifiedDrivers").Replace(drivers)
+	if len(drivers) == 0 {
+		return nil
+	}
+
+	// Offers made before stay as they are, a driver who declined the booking isn't offered it again
+	now := time.Now()
+	offers := make([]models.BookingOffer, 0, len(drivers))
+	for _, driver := range drivers {
+		offers = append(offers, models.BookingOffer{
+			BookingId: bookingID,
+			DriverId:  driver.ID,
+			CreatedAt: now,
+			Status:    models.OfferStatusOffered,
+		})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&offers).Error
 }
 
 func (r *gormBookingRepository) IsDriverNotified(ctx context.Context, bookingID uuid.UUID, driverID uuid.UUID) (bool, error) {
@@ -97,6 +186,169 @@ func (r *gormBookingRepository) IsDriverNotified(ctx context.Context, bookingID
 	return count > 0, err
 }
 
+func (r *gormBookingRepository) CreateOffer(ctx context.Context, offer *models.BookingOffer) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(offer).Error
+}
+
+func (r *gormBookingRepository) GetOffer(ctx context.Context, bookingID, driverID uuid.UUID) (*models.BookingOffer, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var offer models.BookingOffer
+	err := tx.Where("booking_id = ? AND driver_id = ?", bookingID, driverID).
+		First(&offer).Error
+	if err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+func (r *gormBookingRepository) UpdateOfferStatus(ctx context.Context, bookingID, driverID uuid.UUID, from, to models.OfferStatus) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	res := tx.Model(&models.BookingOffer{}).
+		Where("booking_id = ? AND driver_id = ? AND status = ?", bookingID, driverID, from).
+		Update("status", to)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *gormBookingRepository) AcceptOffer(ctx context.Context, bookingID, driverID uuid.UUID, now time.Time) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	// SQL: UPDATE booking_notified_drivers SET status='ACCEPTED' WHERE ... AND status='OFFERED' AND (expires_at IS NULL OR expires_at > now)
+	res := tx.Model(&models.BookingOffer{}).
+		Where("booking_id = ? AND driver_id = ? AND status = ?", bookingID, driverID, models.OfferStatusOffered).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Update("status", models.OfferStatusAccepted)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *gormBookingRepository) CreateOfferSequence(ctx context.Context, bookingID uuid.UUID, driverIDs []uuid.UUID) error {
+	if len(driverIDs) == 0 {
+		return nil
+	}
+	tx := db.NewGormTx(ctx, r.db)
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the booking, a re-match queues its drivers after the ones of the last match
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&models.Booking{}, "id = ?", bookingID).Error; err != nil {
+			return err
+		}
+		var last int
+		if err := tx.Model(&models.BookingOffer{}).
+			Where("booking_id = ?", bookingID).
+			Select("COALESCE(MAX(sequence), -1)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+
+		// 2. Queue the drivers, the ones offered the booking before keep their offer
+		now := time.Now()
+		offers := make([]models.BookingOffer, 0, len(driverIDs))
+		for i, driverID := range driverIDs {
+			sequence := last + 1 + i
+			offers = append(offers, models.BookingOffer{
+				BookingId: bookingID,
+				DriverId:  driverID,
+				CreatedAt: now,
+				Status:    models.OfferStatusQueued,
+				Sequence:  &sequence,
+			})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&offers).Error
+	})
+}
+
+func (r *gormBookingRepository) AdvanceOfferSequence(ctx context.Context, bookingID uuid.UUID, now, expiresAt time.Time) (*models.BookingOffer, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var next *models.BookingOffer
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the booking, a decline and the sweeper advancing the same sequence run one after the other
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status").
+			First(&booking, "id = ?", bookingID).Error; err != nil {
+			return err
+		}
+		if booking.Status != models.BookingStatusRequested {
+			return nil
+		}
+
+		// 2. Expire the current offer once its window ran out
+		if err := tx.Model(&models.BookingOffer{}).
+			Where("booking_id = ? AND sequence IS NOT NULL AND status = ? AND expires_at <= ?", bookingID, models.OfferStatusOffered, now).
+			Update("status", models.OfferStatusExpired).Error; err != nil {
+			return err
+		}
+
+		// 3. Leave a live offer alone
+		var live int64
+		if err := tx.Model(&models.BookingOffer{}).
+			Where("booking_id = ? AND sequence IS NOT NULL AND status = ?", bookingID, models.OfferStatusOffered).
+			Count(&live).Error; err != nil {
+			return err
+		}
+		if live > 0 {
+			return nil
+		}
+
+		// 4. Offer to the next driver in line
+		var offer models.BookingOffer
+		if err := tx.Where("booking_id = ? AND status = ?", bookingID, models.OfferStatusQueued).
+			Order("sequence").
+			First(&offer).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.BookingOffer{}).
+			Where("booking_id = ? AND driver_id = ?", bookingID, offer.DriverId).
+			Updates(map[string]interface{}{
+				"status":     models.OfferStatusOffered,
+				"expires_at": expiresAt,
+			}).Error; err != nil {
+			return err
+		}
+		offer.Status = models.OfferStatusOffered
+		offer.ExpiresAt = &expiresAt
+		next = &offer
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+func (r *gormBookingRepository) ListStalledOfferSequences(ctx context.Context, now time.Time, limit int) ([]uuid.UUID, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var ids []uuid.UUID
+	err := tx.Model(&models.Booking{}).
+		Where("status = ?", models.BookingStatusRequested).
+		// An offer ran out, or drivers are still waiting for their turn
+		Where(`EXISTS (SELECT 1 FROM booking_notified_drivers o WHERE o.booking_id = bookings.id AND o.sequence IS NOT NULL
+			AND ((o.status = ? AND o.expires_at <= ?) OR o.status = ?))`,
+			models.OfferStatusOffered, now, models.OfferStatusQueued).
+		// ... and nobody holds a live offer
+		Where(`NOT EXISTS (SELECT 1 FROM booking_notified_drivers o WHERE o.booking_id = bookings.id AND o.sequence IS NOT NULL
+			AND o.status = ? AND o.expires_at > ?)`,
+			models.OfferStatusOffered, now).
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func (r *gormBookingRepository) SaveReviewAndRecalculateDriverRating(ctx context.Context, bookingID uuid.UUID, review *models.Review) error {
 	tx := db.NewGormTx(ctx, r.db)
 
@@ -176,6 +428,8 @@ func (r *gormBookingRepository) GetPendingBookingsForDriver(ctx context.Context,
 	err := tx.Table("bookings").
 		Joins("JOIN booking_notified_drivers ON bookings.id = booking_notified_drivers.booking_id").
 		Where("booking_notified_drivers.driver_id = ?", driverID).
+		Where("booking_notified_drivers.status = ?", models.OfferStatusOffered).
+		Where("(booking_notified_drivers.expires_at IS NULL OR booking_notified_drivers.expires_at > ?)", time.Now()).
 		Where("bookings.status = ?", models.BookingStatusRequested).
 		Limit(limit).
 		Offset(offset).
@@ -188,29 +442,241 @@ func (r *gormBookingRepository) GetPendingBookingsForDriver(ctx context.Context,
 	return bookings, nil
 }
 
-// GetDueScheduledBookings finds bookings that are in SCHEDULED status and ready to be processed
-func (r *gormBookingRepository) GetDueScheduledBookings(ctx context.Context, cutoff time.Time) ([]models.Booking, error) {
+func (r *gormBookingRepository) ClaimDueScheduledBookings(ctx context.Context, cityCutoffs map[string]time.Time, cutoff time.Time, limit int) ([]models.Booking, error) {
 	tx := db.NewGormTx(ctx, r.db)
 
+	// SQL: (city = ? AND scheduled_time <= ?) OR ... OR (city NOT IN ? AND scheduled_time <= ?)
+	due, args := "scheduled_time <= ?", []interface{}{cutoff}
+	if len(cityCutoffs) > 0 {
+		conditions := make([]string, 0, len(cityCutoffs)+1)
+		args = make([]interface{}, 0, 2*len(cityCutoffs)+2)
+		cities := make([]string, 0, len(cityCutoffs))
+		for city, cityCutoff := range cityCutoffs {
+			conditions = append(conditions, "(city = ? AND scheduled_time <= ?)")
+			args = append(args, city, cityCutoff)
+			cities = append(cities, city)
+		}
+		conditions = append(conditions, "(city NOT IN ? AND scheduled_time <= ?)")
+		args = append(args, cities, cutoff)
+		due = "(" + strings.Join(conditions, " OR ") + ")"
+	}
+
 	var bookings []models.Booking
-	// We check for:
-	// 1. Status is 'SCHEDULED'
-	// 2. ScheduledTime is before or equal to the cutoff (e.g., Now + 15 mins)
-	// TODO: Take care of pagination if needed
-	err := tx.Model(&models.Booking{}).
-		Where("status = ? AND scheduled_time <= ?", models.BookingStatusScheduled, cutoff).
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the due bookings, skipping the ones another instance is activating right now
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", models.BookingStatusScheduled).
+			Where(due, args...).
+			Order("scheduled_time, created_at").
+			Limit(limit).
+			Find(&bookings).Error
+		if err != nil || len(bookings) == 0 {
+			return err
+		}
+
+		// 2. Activate them
+		// SQL: UPDATE bookings SET status='REQUESTED' WHERE id IN (...) AND status='SCHEDULED'
+		ids := make([]uuid.UUID, len(bookings))
+		for i := range bookings {
+			ids[i] = bookings[i].ID
+			bookings[i].Status = models.BookingStatusRequested
+		}
+		return tx.Model(&models.Booking{}).
+			Where("id IN ? AND status = ?", ids, models.BookingStatusScheduled).
+			Update("status", models.BookingStatusRequested).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (r *gormBookingRepository) FailActivation(ctx context.Context, bookingID uuid.UUID, status models.BookingStatus) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	res := tx.Model(&models.Booking{}).
+		Where("id = ? AND status = ?", bookingID, models.BookingStatusRequested).
+		Updates(map[string]interface{}{
+			"status":              status,
+			"activation_attempts": gorm.Expr("activation_attempts + 1"),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *gormBookingRepository) RescheduleBooking(ctx context.Context, bookingID uuid.UUID, scheduledTime time.Time) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	res := tx.Model(&models.Booking{}).
+		Where("id = ? AND status = ?", bookingID, models.BookingStatusScheduled).
+		Updates(map[string]interface{}{
+			"scheduled_time":     scheduledTime,
+			"reserved_driver_id": nil,
+			"reserved_at":        nil,
+			"reminder_sent_at":   nil,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *gormBookingRepository) CancelScheduledOccurrences(ctx context.Context, recurringRideID uuid.UUID, from, to time.Time) ([]models.Booking, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var bookings []models.Booking
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the occurrences, the scheduler may be activating them
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("recurring_ride_id = ? AND status = ? AND scheduled_time >= ?", recurringRideID, models.BookingStatusScheduled, from)
+		if !to.IsZero() {
+			query = query.Where("scheduled_time < ?", to)
+		}
+		err := query.Find(&bookings).Error
+		if err != nil || len(bookings) == 0 {
+			return err
+		}
+
+		// 2. Cancel the ones still SCHEDULED
+		ids := make([]uuid.UUID, len(bookings))
+		for i := range bookings {
+			ids[i] = bookings[i].ID
+			bookings[i].Status = models.BookingStatusCancelled
+		}
+		return tx.Model(&models.Booking{}).
+			Where("id IN ? AND status = ?", ids, models.BookingStatusScheduled).
+			Update("status", models.BookingStatusCancelled).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (r *gormBookingRepository) ListReservableBookings(ctx context.Context, city string, from, to time.Time, limit, offset int) ([]models.Booking, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	// Shared rides are matched into trips when they are due, they can't be reserved
+	query := tx.Where("status = ? AND reserved_driver_id IS NULL AND NOT is_shared AND scheduled_time > ? AND scheduled_time <= ?",
+		models.BookingStatusScheduled, from, to)
+	if city != "" {
+		query = query.Where("city = ?", city)
+	}
+
+	var bookings []models.Booking
+	err := query.Order("scheduled_time").
+		Limit(limit).
+		Offset(offset).
 		Find(&bookings).Error
 	if err != nil {
 		return nil, err
 	}
+	return bookings, nil
+}
 
+func (r *gormBookingRepository) ListDriverReservations(ctx context.Context, driverID uuid.UUID) ([]models.Booking, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var bookings []models.Booking
+	err := tx.Where("status = ? AND reserved_driver_id = ?", models.BookingStatusScheduled, driverID).
+		Order("scheduled_time").
+		Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
 	return bookings, nil
 }
 
-func (r *gormBookingRepository) AcceptBookingTransaction(ctx context.Context, bookingID, driverID uuid.UUID, otpID uuid.UUID) error {
+func (r *gormBookingRepository) ReserveScheduledBooking(ctx context.Context, bookingID, driverID uuid.UUID, reservedAt time.Time) (bool, error) {
 	tx := db.NewGormTx(ctx, r.db)
 
-	return tx.Transaction(func(tx *gorm.DB) error {
+	// SQL: UPDATE bookings SET reserved_driver_id=? WHERE id=? AND status='SCHEDULED' AND reserved_driver_id IS NULL
+	res := tx.Model(&models.Booking{}).
+		Where("id = ? AND status = ? AND reserved_driver_id IS NULL", bookingID, models.BookingStatusScheduled).
+		Updates(map[string]interface{}{
+			"reserved_driver_id": driverID,
+			"reserved_at":        reservedAt,
+			"reminder_sent_at":   nil,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *gormBookingRepository) ReleaseReservation(ctx context.Context, bookingID, driverID uuid.UUID) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	res := tx.Model(&models.Booking{}).
+		Where("id = ? AND reserved_driver_id = ? AND driver_id IS NULL", bookingID, driverID).
+		Updates(map[string]interface{}{
+			"reserved_driver_id": nil,
+			"reserved_at":        nil,
+			"reminder_sent_at":   nil,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *gormBookingRepository) ReturnReservedToMatching(ctx context.Context, bookingID, driverID uuid.UUID, from models.BookingStatus) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	res := tx.Model(&models.Booking{}).
+		Where("id = ? AND status = ? AND driver_id = ? AND reserved_driver_id = ?", bookingID, from, driverID, driverID).
+		Updates(map[string]interface{}{
+			"status":             models.BookingStatusRequested,
+			"driver_id":          nil,
+			"reserved_driver_id": nil,
+			"reserved_at":        nil,
+			"driver_arrived_at":  nil,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *gormBookingRepository) ClaimReservationReminders(ctx context.Context, cutoff, now time.Time, limit int) ([]models.Booking, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var bookings []models.Booking
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the reservations that are coming up, skipping the ones another instance is reminding
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND reserved_driver_id IS NOT NULL AND reminder_sent_at IS NULL AND scheduled_time <= ?",
+				models.BookingStatusScheduled, cutoff).
+			Order("scheduled_time").
+			Limit(limit).
+			Find(&bookings).Error
+		if err != nil || len(bookings) == 0 {
+			return err
+		}
+
+		// 2. Mark them reminded
+		ids := make([]uuid.UUID, len(bookings))
+		for i := range bookings {
+			ids[i] = bookings[i].ID
+			bookings[i].ReminderSentAt = &now
+		}
+		return tx.Model(&models.Booking{}).
+			Where("id IN ?", ids).
+			Update("reminder_sent_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (r *gormBookingRepository) AcceptBookingTransaction(ctx context.Context, bookingID, driverID uuid.UUID, otpID uuid.UUID, queuedBehind *uuid.UUID) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	chained := false
+	err := tx.Transaction(func(tx *gorm.DB) error {
 		// 1. Assign Driver (Updates Booking Table)
 		// SQL: UPDATE bookings SET status='ACCEPTED', driver_id=? WHERE id=? AND status='REQUESTED'
 		res := tx.Model(&models.Booking{}).
@@ -229,13 +695,132 @@ func (r *gormBookingRepository) AcceptBookingTransaction(ctx context.Context, bo
 			return errors.New("booking is no longer available")
 		}
 
-		// 2. Mark Driver Unavailable (Updates Driver Table)
+		// Chain after the current ride, unless it ended in the meantime
+		if queuedBehind != nil {
+			res := tx.Model(&models.Booking{}).
+				Where("id = ? AND EXISTS (SELECT 1 FROM bookings current WHERE current.id = ? AND current.status = ?)",
+					bookingID, *queuedBehind, models.BookingStatusStarted).
+				Update("queued_behind_booking_id", *queuedBehind)
+			if res.Error != nil {
+				return res.Error
+			}
+			chained = res.RowsAffected > 0
+		}
+
+		// 2. Mark Driver Unavailable and count the acceptance (Updates Driver Table)
 		if err := tx.Model(&models.Driver{}).
 			Where("id = ?", driverID).
-			Update("is_available", false).Error; err != nil {
+			Updates(map[string]interface{}{
+				"is_available":    false,
+				"offers_accepted": gorm.Expr("offers_accepted + 1"),
+			}).Error; err != nil {
 			return err
 		}
 
 		return nil
 	})
+	return chained, err
+}
+
+func (r *gormBookingRepository) GetActiveBookingForDriver(ctx context.Context, driverID uuid.UUID) (*models.Booking, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var booking models.Booking
+	err := tx.Model(&models.Booking{}).
+		Where("driver_id = ? AND status IN ?", driverID, []models.BookingStatus{
+			models.BookingStatusAccepted,
+			models.BookingStatusArrived,
+			models.BookingStatusStarted,
+		}).
+		// A chained booking only becomes active once the current ride ends
+		Where("queued_behind_booking_id IS NULL").
+		Order("updated_at DESC").
+		First(&booking).Error
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+func (r *gormBookingRepository) MarkDriverArrived(ctx context.Context, bookingID uuid.UUID, arrivedAt time.Time) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	// Conditional update, so concurrent location updates can only mark the arrival once
+	res := tx.Model(&models.Booking{}).
+		Where("id = ? AND status = ?", bookingID, models.BookingStatusAccepted).
+		Updates(map[string]interface{}{
+			"status":            models.BookingStatusArrived,
+			"driver_arrived_at": arrivedAt,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *gormBookingRepository) ChangeDestination(ctx context.Context, bookingID uuid.UUID, latitude, longitude float64, address string) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	// Conditional update, the ride may have ended while the passenger was picking the new destination
+	res := tx.Model(&models.Booking{}).
+		Where("id = ? AND status = ?", bookingID, models.BookingStatusStarted).
+		Updates(map[string]interface{}{
+			"dropoff_latitude":  latitude,
+			"dropoff_longitude": longitude,
+			"dropoff_address":   address,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *gormBookingRepository) MarkPreferencesRelaxed(ctx context.Context, bookingID uuid.UUID) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.Booking{}).
+		Where("id = ?", bookingID).
+		Updates(map[string]interface{}{
+			"preferences_relaxed": true,
+			"updated_at":          time.Now(),
+		}).Error
+}
+
+func (r *gormBookingRepository) GetChainableBookings(ctx context.Context, minLat, maxLat, minLon, maxLon float64) ([]models.Booking, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var bookings []models.Booking
+	err := tx.Model(&models.Booking{}).
+		Preload("Driver").
+		Preload("Driver.Car").
+		Where("status = ? AND is_shared = ?", models.BookingStatusStarted, false).
+		Where("dropoff_latitude BETWEEN ? AND ? AND dropoff_longitude BETWEEN ? AND ?", minLat, maxLat, minLon, maxLon).
+		Where("NOT EXISTS (SELECT 1 FROM bookings queued WHERE queued.queued_behind_booking_id = bookings.id AND queued.status = ?)",
+			models.BookingStatusAccepted).
+		Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (r *gormBookingRepository) ReleaseQueuedBooking(ctx context.Context, bookingID uuid.UUID) (*models.Booking, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var queued models.Booking
+	err := tx.Where("queued_behind_booking_id = ? AND status = ?", bookingID, models.BookingStatusAccepted).
+		First(&queued).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(&models.Booking{}).
+		Where("id = ?", queued.ID).
+		Updates(map[string]interface{}{
+			"queued_behind_booking_id": nil,
+			"updated_at":               time.Now(),
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(ctx, queued.ID)
 }
@@ -4,9 +4,11 @@ import (
 	"CabBookingService/internal/db"
 	"CabBookingService/internal/models"
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DriverRepository interface {
@@ -15,7 +17,21 @@ type DriverRepository interface {
 	GetByAccountID(ctx context.Context, accountID uuid.UUID) (*models.Driver, error)
 	GetByAccountIDs(ctx context.Context, accountIDs []uuid.UUID) ([]models.Driver, error)
 	UpdateAvailability(ctx context.Context, driverID uuid.UUID, isAvailable bool) error
-	UpdateLocation(ctx context.Context, driverID uuid.UUID, lat, lon float64) error
+	UpdateLocationByAccountID(ctx context.Context, accountID uuid.UUID, lat, lon float64) error
+	// IncrementOffersReceived counts a ride offer for each of the drivers
+	IncrementOffersReceived(ctx context.Context, driverIDs []uuid.UUID) error
+	// MarkRideEnded records when the last ride ended and makes the driver available again,
+	// unless they already have a next ride
+	MarkRideEnded(ctx context.Context, driverID uuid.UUID, endedAt time.Time, available bool) error
+	// UpdateCapabilities saves what the driver and their car offer to passengers
+	UpdateCapabilities(ctx context.Context, driverID uuid.UUID, offersQuietRides, petFriendly, hasChildSeat bool) error
+	// SetDestination turns on destination mode and counts a use for the day.
+	// Returns false if the driver already used it dailyLimit times that day.
+	SetDestination(ctx context.Context, driverID uuid.UUID, lat, lon float64, day time.Time, dailyLimit int) (bool, error)
+	ClearDestination(ctx context.Context, driverID uuid.UUID) error
+	// LockForUpdate locks the driver until the transaction ends, so changes made for the driver
+	// in transactions that take the lock happen one at a time
+	LockForUpdate(ctx context.Context, driverID uuid.UUID) error
 }
 
 type gormDriverRepository struct {
@@ -80,12 +96,90 @@ func (r *gormDriverRepository) UpdateAvailability(ctx context.Context, id uuid.U
 		Update("is_available", isAvailable).Error
 }
 
-func (r *gormDriverRepository) UpdateLocation(ctx context.Context, driverID uuid.UUID, lat, lon float64) error {
+// UpdateLocationByAccountID persists the location. Location updates come straight from the
+// authenticated driver, so they are keyed by account ID rather than driver ID.
+func (r *gormDriverRepository) UpdateLocationByAccountID(ctx context.Context, accountID uuid.UUID, lat, lon float64) error {
 	tx := db.NewGormTx(ctx, r.db)
 	return tx.Model(&models.Driver{}).
-		Where("id = ?", driverID).
+		Where("account_id = ?", accountID).
 		Updates(map[string]interface{}{
 			"last_known_latitude":  lat,
 			"last_known_longitude": lon,
 		}).Error
 }
+
+func (r *gormDriverRepository) IncrementOffersReceived(ctx context.Context, driverIDs []uuid.UUID) error {
+	if len(driverIDs) == 0 {
+		return nil
+	}
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.Driver{}).
+		Where("id IN ?", driverIDs).
+		Update("offers_received", gorm.Expr("offers_received + 1")).Error
+}
+
+func (r *gormDriverRepository) MarkRideEnded(ctx context.Context, driverID uuid.UUID, endedAt time.Time, available bool) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.Driver{}).
+		Where("id = ?", driverID).
+		Updates(map[string]interface{}{
+			"is_available":       available,
+			"last_ride_ended_at": endedAt,
+		}).Error
+}
+
+func (r *gormDriverRepository) UpdateCapabilities(ctx context.Context, driverID uuid.UUID, offersQuietRides, petFriendly, hasChildSeat bool) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Driver{}).
+			Where("id = ?", driverID).
+			Update("offers_quiet_rides", offersQuietRides).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Car{}).
+			Where("driver_id = ?", driverID).
+			Updates(map[string]interface{}{
+				"pet_friendly":   petFriendly,
+				"has_child_seat": hasChildSeat,
+			}).Error
+	})
+}
+
+func (r *gormDriverRepository) SetDestination(ctx context.Context, driverID uuid.UUID, lat, lon float64, day time.Time, dailyLimit int) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+	date := day.Format(time.DateOnly)
+
+	// Uses of a previous day don't count, the counter restarts on the first use of the day
+	result := tx.Model(&models.Driver{}).
+		Where("id = ? AND (destination_uses_date IS DISTINCT FROM ? OR destination_uses < ?)", driverID, date, dailyLimit).
+		Updates(map[string]interface{}{
+			"destination_latitude":  lat,
+			"destination_longitude": lon,
+			"destination_uses":      gorm.Expr("CASE WHEN destination_uses_date = ? THEN destination_uses + 1 ELSE 1 END", date),
+			"destination_uses_date": date,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormDriverRepository) ClearDestination(ctx context.Context, driverID uuid.UUID) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.Driver{}).
+		Where("id = ?", driverID).
+		Updates(map[string]interface{}{
+			"destination_latitude":  nil,
+			"destination_longitude": nil,
+		}).Error
+}
+
+func (r *gormDriverRepository) LockForUpdate(ctx context.Context, driverID uuid.UUID) error {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var driver models.Driver
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&driver, "id = ?", driverID).Error
+}
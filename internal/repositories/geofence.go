@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"CabBookingService/internal/models"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GeofenceRepository interface {
+	Create(ctx context.Context, geofence *models.Geofence) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Geofence, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// List returns all geofences, optionally narrowed down to a city (empty city means all cities)
+	List(ctx context.Context, city string) ([]models.Geofence, error)
+	// ListActive returns every active geofence, used for point-in-polygon checks
+	ListActive(ctx context.Context) ([]models.Geofence, error)
+}
+
+type gormGeofenceRepository struct {
+	db *gorm.DB
+}
+
+func NewGormGeofenceRepository(db *gorm.DB) GeofenceRepository {
+	return &gormGeofenceRepository{db: db}
+}
+
+func (r *gormGeofenceRepository) Create(ctx context.Context, geofence *models.Geofence) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(geofence).Error
+}
+
+func (r *gormGeofenceRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Geofence, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var geofence models.Geofence
+	if err := tx.First(&geofence, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &geofence, nil
+}
+
+func (r *gormGeofenceRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tx := db.NewGormTx(ctx, r.db)
+	res := tx.Delete(&models.Geofence{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *gormGeofenceRepository) List(ctx context.Context, city string) ([]models.Geofence, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	query := tx.Model(&models.Geofence{})
+	if city != "" {
+		query = query.Where("city = ?", city)
+	}
+
+	var geofences []models.Geofence
+	if err := query.Order("city, name").Find(&geofences).Error; err != nil {
+		return nil, err
+	}
+	return geofences, nil
+}
+
+func (r *gormGeofenceRepository) ListActive(ctx context.Context) ([]models.Geofence, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var geofences []models.Geofence
+	if err := tx.Where("is_active = ?", true).Find(&geofences).Error; err != nil {
+		return nil, err
+	}
+	return geofences, nil
+}
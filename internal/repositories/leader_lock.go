@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// LeaderLock elects one instance to run a periodic job when several replicas share the database
+type LeaderLock interface {
+	// TryLock takes the named lock unless another instance holds it. The caller is the leader until
+	// it calls unlock, or its connection to the database drops.
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+type postgresLeaderLock struct {
+	db *gorm.DB
+}
+
+// NewPostgresLeaderLock elects the leader with a session-level advisory lock
+func NewPostgresLeaderLock(db *gorm.DB) LeaderLock {
+	return &postgresLeaderLock{db: db}
+}
+
+func (l *postgresLeaderLock) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	// 1. Advisory locks belong to a session, so hold on to one connection of the pool
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("getting a connection for lock %s: %w", name, err)
+	}
+
+	// 2. Try the lock, without waiting for the leader to release it
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("taking lock %s: %w", name, err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// Closing the connection returns it to the pool with the lock held, so unlock first, even
+		// when ctx is done. If that fails, drop the connection, which ends the session and the lock.
+		_, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock(hashtext($1))", name)
+		if err != nil {
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,744 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type bookingRepository struct {
+	store *Store
+}
+
+func NewBookingRepository(store *Store) repositories.BookingRepository {
+	return &bookingRepository{store: store}
+}
+
+func (r *bookingRepository) Create(_ context.Context, booking *models.Booking) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&booking.BaseModel)
+	for i := range booking.Stops {
+		newID(&booking.Stops[i].BaseModel)
+		booking.Stops[i].BookingId = booking.ID
+	}
+	r.store.bookings[booking.ID] = storedBooking(booking)
+	return nil
+}
+
+func (r *bookingRepository) GetByID(_ context.Context, id uuid.UUID) (*models.Booking, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	booking, ok := r.store.bookings[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.store.readBooking(booking), nil
+}
+
+func (r *bookingRepository) Update(_ context.Context, booking *models.Booking) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking.UpdatedAt = time.Now()
+	r.store.bookings[booking.ID] = storedBooking(booking)
+	return nil
+}
+
+func (r *bookingRepository) UpdateStatus(_ context.Context, id uuid.UUID, status models.BookingStatus) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if booking, ok := r.store.bookings[id]; ok {
+		booking.Status = status
+		booking.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *bookingRepository) AddNotifiedDrivers(_ context.Context, bookingID uuid.UUID, drivers []models.Driver) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	offers, ok := r.store.offers[bookingID]
+	if !ok {
+		offers = make(map[uuid.UUID]*models.BookingOffer, len(drivers))
+		r.store.offers[bookingID] = offers
+	}
+	now := time.Now()
+	for _, driver := range drivers {
+		// Offers made before stay as they are
+		if _, exists := offers[driver.ID]; exists {
+			continue
+		}
+		offers[driver.ID] = &models.BookingOffer{
+			BookingId: bookingID,
+			DriverId:  driver.ID,
+			CreatedAt: now,
+			Status:    models.OfferStatusOffered,
+		}
+	}
+	return nil
+}
+
+func (r *bookingRepository) IsDriverNotified(_ context.Context, bookingID uuid.UUID, driverID uuid.UUID) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	_, ok := r.store.offers[bookingID][driverID]
+	return ok, nil
+}
+
+func (r *bookingRepository) CreateOffer(_ context.Context, offer *models.BookingOffer) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	offers, ok := r.store.offers[offer.BookingId]
+	if !ok {
+		offers = make(map[uuid.UUID]*models.BookingOffer)
+		r.store.offers[offer.BookingId] = offers
+	}
+	if _, exists := offers[offer.DriverId]; exists {
+		return gorm.ErrDuplicatedKey
+	}
+	stored := *offer
+	offers[offer.DriverId] = &stored
+	return nil
+}
+
+func (r *bookingRepository) GetOffer(_ context.Context, bookingID, driverID uuid.UUID) (*models.BookingOffer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offer, ok := r.store.offers[bookingID][driverID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *offer
+	return &found, nil
+}
+
+func (r *bookingRepository) UpdateOfferStatus(_ context.Context, bookingID, driverID uuid.UUID, from, to models.OfferStatus) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	offer, ok := r.store.offers[bookingID][driverID]
+	if !ok || offer.Status != from {
+		return false, nil
+	}
+	offer.Status = to
+	return true, nil
+}
+
+func (r *bookingRepository) AcceptOffer(_ context.Context, bookingID, driverID uuid.UUID, now time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	offer, ok := r.store.offers[bookingID][driverID]
+	if !ok || !offer.IsActive(now) {
+		return false, nil
+	}
+	offer.Status = models.OfferStatusAccepted
+	return true, nil
+}
+
+func (r *bookingRepository) CreateOfferSequence(_ context.Context, bookingID uuid.UUID, driverIDs []uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	offers, ok := r.store.offers[bookingID]
+	if !ok {
+		offers = make(map[uuid.UUID]*models.BookingOffer)
+		r.store.offers[bookingID] = offers
+	}
+	// A re-match queues its drivers after the ones of the last match
+	last := -1
+	for _, offer := range offers {
+		if offer.Sequence != nil && *offer.Sequence > last {
+			last = *offer.Sequence
+		}
+	}
+	now := time.Now()
+	for i, driverID := range driverIDs {
+		if _, exists := offers[driverID]; exists {
+			continue
+		}
+		sequence := last + 1 + i
+		offers[driverID] = &models.BookingOffer{
+			BookingId: bookingID,
+			DriverId:  driverID,
+			CreatedAt: now,
+			Status:    models.OfferStatusQueued,
+			Sequence:  &sequence,
+		}
+	}
+	return nil
+}
+
+func (r *bookingRepository) AdvanceOfferSequence(_ context.Context, bookingID uuid.UUID, now, expiresAt time.Time) (*models.BookingOffer, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if booking.Status != models.BookingStatusRequested {
+		return nil, nil
+	}
+
+	var next *models.BookingOffer
+	for _, offer := range r.store.offers[bookingID] {
+		if offer.Sequence == nil {
+			continue
+		}
+		if offer.Status == models.OfferStatusOffered && !now.Before(*offer.ExpiresAt) {
+			offer.Status = models.OfferStatusExpired
+		}
+		switch {
+		case offer.Status == models.OfferStatusOffered:
+			return nil, nil
+		case offer.Status == models.OfferStatusQueued && (next == nil || *offer.Sequence < *next.Sequence):
+			next = offer
+		}
+	}
+	if next == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	next.Status = models.OfferStatusOffered
+	next.ExpiresAt = &expiresAt
+	offer := *next
+	return &offer, nil
+}
+
+func (r *bookingRepository) ListStalledOfferSequences(_ context.Context, now time.Time, limit int) ([]uuid.UUID, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var ids []uuid.UUID
+	for bookingID, offers := range r.store.offers {
+		if booking, ok := r.store.bookings[bookingID]; !ok || booking.Status != models.BookingStatusRequested {
+			continue
+		}
+		stalled, live := false, false
+		for _, offer := range offers {
+			if offer.Sequence == nil {
+				continue
+			}
+			switch {
+			case offer.Status == models.OfferStatusQueued:
+				stalled = true
+			case offer.Status == models.OfferStatusOffered && now.Before(*offer.ExpiresAt):
+				live = true
+			case offer.Status == models.OfferStatusOffered:
+				stalled = true
+			}
+		}
+		if stalled && !live {
+			ids = append(ids, bookingID)
+		}
+		if len(ids) == limit {
+			break
+		}
+	}
+	return ids, nil
+}
+
+func (r *bookingRepository) SaveReviewAndRecalculateDriverRating(_ context.Context, bookingID uuid.UUID, review *models.Review) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// The rated driver is the one of the booking
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.DriverId == nil {
+		return gorm.ErrRecordNotFound
+	}
+	driver, ok := r.store.drivers[*booking.DriverId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	r.store.saveReview(review)
+	booking.ReviewByPassengerId = &review.ID
+	driver.AverageRating = newAverage(driver.AverageRating, driver.RatingCount, review.Rating)
+	driver.RatingCount++
+	return nil
+}
+
+func (r *bookingRepository) SaveReviewAndRecalculatePassengerRating(_ context.Context, bookingID uuid.UUID, review *models.Review) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// The rated passenger is the one of the booking
+	booking, ok := r.store.bookings[bookingID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	passenger, ok := r.store.passengers[booking.PassengerId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	r.store.saveReview(review)
+	booking.ReviewByDriverId = &review.ID
+	passenger.AverageRating = newAverage(passenger.AverageRating, passenger.RatingCount, review.Rating)
+	passenger.RatingCount++
+	return nil
+}
+
+func (r *bookingRepository) GetPendingBookingsForDriver(_ context.Context, driverID uuid.UUID, limit, offset int) ([]models.Booking, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	now := time.Now()
+	var bookings []models.Booking
+	for bookingID, offers := range r.store.offers {
+		offer, ok := offers[driverID]
+		if !ok || !offer.IsActive(now) {
+			continue
+		}
+		booking, ok := r.store.bookings[bookingID]
+		if !ok || booking.Status != models.BookingStatusRequested {
+			continue
+		}
+		bookings = append(bookings, *r.store.readBooking(booking))
+	}
+	sortByCreatedAt(bookings)
+	return page(bookings, limit, offset), nil
+}
+
+func (r *bookingRepository) ClaimDueScheduledBookings(_ context.Context, cityCutoffs map[string]time.Time, cutoff time.Time, limit int) ([]models.Booking, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var due []*models.Booking
+	for _, booking := range r.store.bookings {
+		if booking.Status != models.BookingStatusScheduled || booking.ScheduledTime == nil {
+			continue
+		}
+		bookingCutoff, ok := cityCutoffs[booking.City]
+		if !ok {
+			bookingCutoff = cutoff
+		}
+		if !booking.ScheduledTime.After(bookingCutoff) {
+			due = append(due, booking)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if !due[i].ScheduledTime.Equal(*due[j].ScheduledTime) {
+			return due[i].ScheduledTime.Before(*due[j].ScheduledTime)
+		}
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	now := time.Now()
+	bookings := make([]models.Booking, 0, len(due))
+	for _, booking := range due {
+		booking.Status = models.BookingStatusRequested
+		booking.UpdatedAt = now
+		bookings = append(bookings, *r.store.readBooking(booking))
+	}
+	return bookings, nil
+}
+
+func (r *bookingRepository) FailActivation(_ context.Context, bookingID uuid.UUID, status models.BookingStatus) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.Status != models.BookingStatusRequested {
+		return false, nil
+	}
+	booking.Status = status
+	booking.ActivationAttempts++
+	booking.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *bookingRepository) RescheduleBooking(_ context.Context, bookingID uuid.UUID, scheduledTime time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.Status != models.BookingStatusScheduled {
+		return false, nil
+	}
+	// Mirrors idx_bookings_recurring_occurrence
+	if booking.RecurringRideId != nil {
+		for _, other := range r.store.bookings {
+			if other.ID != booking.ID && other.RecurringRideId != nil && *other.RecurringRideId == *booking.RecurringRideId &&
+				other.Status != models.BookingStatusCancelled && other.ScheduledTime != nil && other.ScheduledTime.Equal(scheduledTime) {
+				return false, gorm.ErrDuplicatedKey
+			}
+		}
+	}
+	booking.ScheduledTime = &scheduledTime
+	booking.ReservedDriverId = nil
+	booking.ReservedAt = nil
+	booking.ReminderSentAt = nil
+	booking.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *bookingRepository) CancelScheduledOccurrences(_ context.Context, recurringRideID uuid.UUID, from, to time.Time) ([]models.Booking, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	var bookings []models.Booking
+	for _, booking := range r.store.bookings {
+		if booking.RecurringRideId == nil || *booking.RecurringRideId != recurringRideID ||
+			booking.Status != models.BookingStatusScheduled || booking.ScheduledTime == nil ||
+			booking.ScheduledTime.Before(from) || (!to.IsZero() && !booking.ScheduledTime.Before(to)) {
+			continue
+		}
+		booking.Status = models.BookingStatusCancelled
+		booking.UpdatedAt = now
+		bookings = append(bookings, *r.store.readBooking(booking))
+	}
+	sortByCreatedAt(bookings)
+	return bookings, nil
+}
+
+func (r *bookingRepository) ListReservableBookings(_ context.Context, city string, from, to time.Time, limit, offset int) ([]models.Booking, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var bookings []models.Booking
+	for _, booking := range r.store.bookings {
+		if booking.Status != models.BookingStatusScheduled || booking.ReservedDriverId != nil || booking.IsShared || booking.ScheduledTime == nil ||
+			!booking.ScheduledTime.After(from) || booking.ScheduledTime.After(to) || (city != "" && booking.City != city) {
+			continue
+		}
+		bookings = append(bookings, *r.store.readBooking(booking))
+	}
+	sortByScheduledTime(bookings)
+	return page(bookings, limit, offset), nil
+}
+
+func (r *bookingRepository) ListDriverReservations(_ context.Context, driverID uuid.UUID) ([]models.Booking, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var bookings []models.Booking
+	for _, booking := range r.store.bookings {
+		if booking.Status == models.BookingStatusScheduled && booking.ReservedDriverId != nil && *booking.ReservedDriverId == driverID {
+			bookings = append(bookings, *r.store.readBooking(booking))
+		}
+	}
+	sortByScheduledTime(bookings)
+	return bookings, nil
+}
+
+func (r *bookingRepository) ReserveScheduledBooking(_ context.Context, bookingID, driverID uuid.UUID, reservedAt time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.Status != models.BookingStatusScheduled || booking.ReservedDriverId != nil {
+		return false, nil
+	}
+	booking.ReservedDriverId = &driverID
+	booking.ReservedAt = &reservedAt
+	booking.ReminderSentAt = nil
+	booking.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *bookingRepository) ReleaseReservation(_ context.Context, bookingID, driverID uuid.UUID) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.ReservedDriverId == nil || *booking.ReservedDriverId != driverID || booking.DriverId != nil {
+		return false, nil
+	}
+	booking.ReservedDriverId = nil
+	booking.ReservedAt = nil
+	booking.ReminderSentAt = nil
+	booking.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *bookingRepository) ReturnReservedToMatching(_ context.Context, bookingID, driverID uuid.UUID, from models.BookingStatus) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.Status != from ||
+		booking.DriverId == nil || *booking.DriverId != driverID ||
+		booking.ReservedDriverId == nil || *booking.ReservedDriverId != driverID {
+		return false, nil
+	}
+	booking.Status = models.BookingStatusRequested
+	booking.DriverId = nil
+	booking.ReservedDriverId = nil
+	booking.ReservedAt = nil
+	booking.DriverArrivedAt = nil
+	booking.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *bookingRepository) ClaimReservationReminders(_ context.Context, cutoff, now time.Time, limit int) ([]models.Booking, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var due []*models.Booking
+	for _, booking := range r.store.bookings {
+		if booking.Status == models.BookingStatusScheduled && booking.ReservedDriverId != nil &&
+			booking.ReminderSentAt == nil && booking.ScheduledTime != nil && !booking.ScheduledTime.After(cutoff) {
+			due = append(due, booking)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].ScheduledTime.Before(*due[j].ScheduledTime)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	bookings := make([]models.Booking, 0, len(due))
+	for _, booking := range due {
+		booking.ReminderSentAt = &now
+		bookings = append(bookings, *r.store.readBooking(booking))
+	}
+	return bookings, nil
+}
+
+func (r *bookingRepository) AcceptBookingTransaction(_ context.Context, bookingID, driverID uuid.UUID, otpID uuid.UUID, queuedBehind *uuid.UUID) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// 1. Assign Driver, only if the booking is still up for grabs
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.Status != models.BookingStatusRequested {
+		return false, errors.New("booking is no longer available")
+	}
+	booking.Status = models.BookingStatusAccepted
+	booking.DriverId = &driverID
+	booking.RideStartOTPId = &otpID
+	booking.UpdatedAt = time.Now()
+
+	// Chain after the current ride, unless it ended in the meantime
+	chained := false
+	if queuedBehind != nil {
+		if current, ok := r.store.bookings[*queuedBehind]; ok && current.Status == models.BookingStatusStarted {
+			booking.QueuedBehindBookingId = queuedBehind
+			chained = true
+		}
+	}
+
+	// 2. Mark Driver Unavailable and count the acceptance
+	if driver, ok := r.store.drivers[driverID]; ok {
+		driver.IsAvailable = false
+		driver.OffersAccepted++
+	}
+	return chained, nil
+}
+
+func (r *bookingRepository) GetActiveBookingForDriver(_ context.Context, driverID uuid.UUID) (*models.Booking, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var active *models.Booking
+	for _, booking := range r.store.bookings {
+		if booking.DriverId == nil || *booking.DriverId != driverID {
+			continue
+		}
+		if !booking.Status.IsDriverEnRoute() && booking.Status != models.BookingStatusStarted {
+			continue
+		}
+		// A chained booking only becomes active once the current ride ends
+		if booking.QueuedBehindBookingId != nil {
+			continue
+		}
+		if active == nil || booking.UpdatedAt.After(active.UpdatedAt) {
+			active = booking
+		}
+	}
+	if active == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return storedBooking(active), nil
+}
+
+func (r *bookingRepository) MarkDriverArrived(_ context.Context, bookingID uuid.UUID, arrivedAt time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.Status != models.BookingStatusAccepted {
+		return false, nil
+	}
+	booking.Status = models.BookingStatusArrived
+	booking.DriverArrivedAt = &arrivedAt
+	booking.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *bookingRepository) ChangeDestination(_ context.Context, bookingID uuid.UUID, latitude, longitude float64, address string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	booking, ok := r.store.bookings[bookingID]
+	if !ok || booking.Status != models.BookingStatusStarted {
+		return false, nil
+	}
+	booking.DropoffLatitude, booking.DropoffLongitude = latitude, longitude
+	booking.DropoffAddress = address
+	booking.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (r *bookingRepository) MarkPreferencesRelaxed(_ context.Context, bookingID uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if booking, ok := r.store.bookings[bookingID]; ok {
+		booking.PreferencesRelaxed = true
+		booking.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *bookingRepository) GetChainableBookings(_ context.Context, minLat, maxLat, minLon, maxLon float64) ([]models.Booking, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// Rides that already have a next ride queued
+	queued := make(map[uuid.UUID]bool)
+	for _, booking := range r.store.bookings {
+		if booking.QueuedBehindBookingId != nil && booking.Status == models.BookingStatusAccepted {
+			queued[*booking.QueuedBehindBookingId] = true
+		}
+	}
+
+	var bookings []models.Booking
+	for _, booking := range r.store.bookings {
+		if booking.Status != models.BookingStatusStarted || booking.IsShared || queued[booking.ID] {
+			continue
+		}
+		if booking.DropoffLatitude < minLat || booking.DropoffLatitude > maxLat ||
+			booking.DropoffLongitude < minLon || booking.DropoffLongitude > maxLon {
+			continue
+		}
+		found := storedBooking(booking)
+		if booking.DriverId != nil {
+			if driver, ok := r.store.drivers[*booking.DriverId]; ok {
+				found.Driver = r.store.readDriver(driver)
+			}
+		}
+		bookings = append(bookings, *found)
+	}
+	return bookings, nil
+}
+
+func (r *bookingRepository) ReleaseQueuedBooking(_ context.Context, bookingID uuid.UUID) (*models.Booking, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, booking := range r.store.bookings {
+		if booking.QueuedBehindBookingId != nil && *booking.QueuedBehindBookingId == bookingID &&
+			booking.Status == models.BookingStatusAccepted {
+			booking.QueuedBehindBookingId = nil
+			booking.UpdatedAt = time.Now()
+			return r.store.readBooking(booking), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// readBooking copies a stored booking with its relations preloaded. The caller must hold the store lock.
+func (s *Store) readBooking(stored *models.Booking) *models.Booking {
+	booking := storedBooking(stored)
+	if passenger, ok := s.passengers[booking.PassengerId]; ok {
+		booking.Passenger = *passenger
+	}
+	if booking.DriverId != nil {
+		if driver, ok := s.drivers[*booking.DriverId]; ok {
+			booking.Driver = s.readDriver(driver)
+		}
+	}
+	if booking.RideStartOTPId != nil {
+		if otp, ok := s.otps[*booking.RideStartOTPId]; ok {
+			found := *otp
+			booking.RideStartOTP = &found
+		}
+	}
+	if booking.ReviewByPassengerId != nil {
+		if review, ok := s.reviews[*booking.ReviewByPassengerId]; ok {
+			found := *review
+			booking.ReviewByPassenger = &found
+		}
+	}
+	if booking.ReviewByDriverId != nil {
+		if review, ok := s.reviews[*booking.ReviewByDriverId]; ok {
+			found := *review
+			booking.ReviewByDriver = &found
+		}
+	}
+	return booking
+}
+
+// saveReview stores a review. The caller must hold the store lock.
+func (s *Store) saveReview(review *models.Review) {
+	newID(&review.BaseModel)
+	stored := *review
+	s.reviews[review.ID] = &stored
+}
+
+// storedBooking copies the booking's own columns and its stops, relations are left out
+func storedBooking(booking *models.Booking) *models.Booking {
+	stored := *booking
+	stored.Passenger = models.Passenger{}
+	stored.Driver = nil
+	stored.RideStartOTP = nil
+	stored.ReviewByPassenger = nil
+	stored.ReviewByDriver = nil
+	stored.NotifiedDrivers = nil
+	stored.Stops = append([]models.BookingStop(nil), booking.Stops...)
+	sort.SliceStable(stored.Stops, func(i, j int) bool {
+		return stored.Stops[i].Sequence < stored.Stops[j].Sequence
+	})
+	return &stored
+}
+
+// newAverage adds a rating to an average of count ratings
+func newAverage(average float64, count, rating int) float64 {
+	return (average*float64(count) + float64(rating)) / float64(count+1)
+}
+
+func sortByCreatedAt(bookings []models.Booking) {
+	sort.SliceStable(bookings, func(i, j int) bool {
+		return bookings[i].CreatedAt.Before(bookings[j].CreatedAt)
+	})
+}
+
+func sortByScheduledTime(bookings []models.Booking) {
+	sort.SliceStable(bookings, func(i, j int) bool {
+		return bookings[i].ScheduledTime.Before(*bookings[j].ScheduledTime)
+	})
+}
+
+// page applies LIMIT and OFFSET, a limit of zero or less means no limit
+func page(bookings []models.Booking, limit, offset int) []models.Booking {
+	if offset >= len(bookings) {
+		return nil
+	}
+	bookings = bookings[offset:]
+	if limit > 0 && limit < len(bookings) {
+		bookings = bookings[:limit]
+	}
+	return bookings
+}
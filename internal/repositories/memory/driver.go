@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type driverRepository struct {
+	store *Store
+}
+
+func NewDriverRepository(store *Store) repositories.DriverRepository {
+	return &driverRepository{store: store}
+}
+
+func (r *driverRepository) Create(_ context.Context, driver *models.Driver) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&driver.BaseModel)
+	// The car is saved with its driver, like GORM does for a has-one relation
+	newID(&driver.Car.BaseModel)
+	driver.Car.DriverId = driver.ID
+	stored := *driver
+	r.store.drivers[driver.ID] = &stored
+	return nil
+}
+
+func (r *driverRepository) GetByID(_ context.Context, id uuid.UUID) (*models.Driver, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	driver, ok := r.store.drivers[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.store.readDriver(driver), nil
+}
+
+func (r *driverRepository) GetByAccountID(_ context.Context, accountID uuid.UUID) (*models.Driver, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, driver := range r.store.drivers {
+		if driver.AccountId == accountID {
+			return r.store.readDriver(driver), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *driverRepository) GetByAccountIDs(_ context.Context, accountIDs []uuid.UUID) ([]models.Driver, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	wanted := make(map[uuid.UUID]bool, len(accountIDs))
+	for _, id := range accountIDs {
+		wanted[id] = true
+	}
+	var drivers []models.Driver
+	for _, driver := range r.store.drivers {
+		if wanted[driver.AccountId] {
+			drivers = append(drivers, *r.store.readDriver(driver))
+		}
+	}
+	return drivers, nil
+}
+
+func (r *driverRepository) UpdateAvailability(_ context.Context, driverID uuid.UUID, isAvailable bool) error {
+	return r.store.updateDriver(driverID, func(driver *models.Driver) {
+		driver.IsAvailable = isAvailable
+	})
+}
+
+func (r *driverRepository) UpdateLocationByAccountID(_ context.Context, accountID uuid.UUID, lat, lon float64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, driver := range r.store.drivers {
+		if driver.AccountId == accountID {
+			driver.LastKnownLatitude, driver.LastKnownLongitude = &lat, &lon
+		}
+	}
+	return nil
+}
+
+func (r *driverRepository) IncrementOffersReceived(_ context.Context, driverIDs []uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, id := range driverIDs {
+		if driver, ok := r.store.drivers[id]; ok {
+			driver.OffersReceived++
+		}
+	}
+	return nil
+}
+
+func (r *driverRepository) MarkRideEnded(_ context.Context, driverID uuid.UUID, endedAt time.Time, available bool) error {
+	return r.store.updateDriver(driverID, func(driver *models.Driver) {
+		driver.IsAvailable = available
+		driver.LastRideEndedAt = &endedAt
+	})
+}
+
+func (r *driverRepository) UpdateCapabilities(_ context.Context, driverID uuid.UUID, offersQuietRides, petFriendly, hasChildSeat bool) error {
+	return r.store.updateDriver(driverID, func(driver *models.Driver) {
+		driver.OffersQuietRides = offersQuietRides
+		driver.Car.PetFriendly = petFriendly
+		driver.Car.HasChildSeat = hasChildSeat
+	})
+}
+
+func (r *driverRepository) SetDestination(_ context.Context, driverID uuid.UUID, lat, lon float64, day time.Time, dailyLimit int) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	driver, ok := r.store.drivers[driverID]
+	if !ok {
+		return false, nil
+	}
+	date := day.Format(time.DateOnly)
+	sameDay := driver.DestinationUsesDate != nil && driver.DestinationUsesDate.Format(time.DateOnly) == date
+	if sameDay && driver.DestinationUses >= dailyLimit {
+		return false, nil
+	}
+
+	// Uses of a previous day don't count, the counter restarts on the first use of the day
+	if sameDay {
+		driver.DestinationUses++
+	} else {
+		driver.DestinationUses = 1
+	}
+	usesDate, _ := time.Parse(time.DateOnly, date)
+	driver.DestinationUsesDate = &usesDate
+	driver.DestinationLatitude, driver.DestinationLongitude = &lat, &lon
+	return true, nil
+}
+
+func (r *driverRepository) ClearDestination(_ context.Context, driverID uuid.UUID) error {
+	return r.store.updateDriver(driverID, func(driver *models.Driver) {
+		driver.DestinationLatitude, driver.DestinationLongitude = nil, nil
+	})
+}
+
+// LockForUpdate is a no-op, the store serializes every change itself
+func (r *driverRepository) LockForUpdate(_ context.Context, _ uuid.UUID) error {
+	return nil
+}
+
+// readDriver copies a stored driver and fills in LastKnownLocation, like the GORM AfterFind hook.
+// The caller must hold the store lock.
+func (s *Store) readDriver(stored *models.Driver) *models.Driver {
+	driver := *stored
+	driver.LastKnownLocation = nil
+	_ = driver.AfterFind(nil)
+	return &driver
+}
+
+// updateDriver applies the change to a stored driver, missing drivers are ignored like an UPDATE without matching rows
+func (s *Store) updateDriver(driverID uuid.UUID, change func(driver *models.Driver)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if driver, ok := s.drivers[driverID]; ok {
+		change(driver)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type geofenceRepository struct {
+	store *Store
+}
+
+func NewGeofenceRepository(store *Store) repositories.GeofenceRepository {
+	return &geofenceRepository{store: store}
+}
+
+func (r *geofenceRepository) Create(_ context.Context, geofence *models.Geofence) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&geofence.BaseModel)
+	stored := *geofence
+	r.store.geofences[geofence.ID] = &stored
+	return nil
+}
+
+func (r *geofenceRepository) GetByID(_ context.Context, id uuid.UUID) (*models.Geofence, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	geofence, ok := r.store.geofences[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *geofence
+	return &found, nil
+}
+
+func (r *geofenceRepository) Delete(_ context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.geofences[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.store.geofences, id)
+	return nil
+}
+
+func (r *geofenceRepository) List(_ context.Context, city string) ([]models.Geofence, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var geofences []models.Geofence
+	for _, geofence := range r.store.geofences {
+		if city == "" || geofence.City == city {
+			geofences = append(geofences, *geofence)
+		}
+	}
+	sort.Slice(geofences, func(i, j int) bool {
+		if geofences[i].City != geofences[j].City {
+			return geofences[i].City < geofences[j].City
+		}
+		return geofences[i].Name < geofences[j].Name
+	})
+	return geofences, nil
+}
+
+func (r *geofenceRepository) ListActive(_ context.Context) ([]models.Geofence, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var geofences []models.Geofence
+	for _, geofence := range r.store.geofences {
+		if geofence.IsActive {
+			geofences = append(geofences, *geofence)
+		}
+	}
+	return geofences, nil
+}
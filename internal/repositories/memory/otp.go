@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type otpRepository struct {
+	store *Store
+}
+
+func NewOTPRepository(store *Store) repositories.OTPRepository {
+	return &otpRepository{store: store}
+}
+
+func (r *otpRepository) Create(_ context.Context, otp *models.OTP) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&otp.BaseModel)
+	stored := *otp
+	r.store.otps[otp.ID] = &stored
+	return nil
+}
+
+func (r *otpRepository) GetById(_ context.Context, id uuid.UUID) (*models.OTP, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	otp, ok := r.store.otps[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *otp
+	return &found, nil
+}
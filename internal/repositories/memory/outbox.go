@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type outboxRepository struct {
+	store *Store
+}
+
+func NewOutboxRepository(store *Store) repositories.OutboxRepository {
+	return &outboxRepository{store: store}
+}
+
+func (r *outboxRepository) Add(_ context.Context, event *models.OutboxEvent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	stored := *event
+	r.store.outbox[event.ID] = &stored
+	return nil
+}
+
+func (r *outboxRepository) ClaimDue(_ context.Context, now time.Time, claimTimeout time.Duration, limit int) ([]models.OutboxEvent, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var due []*models.OutboxEvent
+	for _, event := range r.store.outbox {
+		if event.SentAt == nil && event.FailedAt == nil && !event.NextAttemptAt.After(now) {
+			due = append(due, event)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	events := make([]models.OutboxEvent, len(due))
+	for i, event := range due {
+		events[i] = *event
+		event.NextAttemptAt = now.Add(claimTimeout)
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) MarkSent(_ context.Context, id uuid.UUID, sentAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if event, ok := r.store.outbox[id]; ok {
+		event.SentAt = &sentAt
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkFailed(_ context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if event, ok := r.store.outbox[id]; ok {
+		event.Attempts++
+		event.NextAttemptAt = nextAttemptAt
+		event.LastError = lastError
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkDead(_ context.Context, id uuid.UUID, failedAt time.Time, lastError string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if event, ok := r.store.outbox[id]; ok {
+		event.Attempts++
+		event.FailedAt = &failedAt
+		event.LastError = lastError
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type passengerRepository struct {
+	store *Store
+}
+
+func NewPassengerRepository(store *Store) repositories.PassengerRepository {
+	return &passengerRepository{store: store}
+}
+
+func (r *passengerRepository) Create(_ context.Context, passenger *models.Passenger) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&passenger.BaseModel)
+	stored := *passenger
+	r.store.passengers[passenger.ID] = &stored
+	return nil
+}
+
+func (r *passengerRepository) GetByID(_ context.Context, id uuid.UUID) (*models.Passenger, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	passenger, ok := r.store.passengers[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *passenger
+	return &found, nil
+}
+
+func (r *passengerRepository) GetByAccountID(_ context.Context, accountID uuid.UUID) (*models.Passenger, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, passenger := range r.store.passengers {
+		if passenger.AccountId == accountID {
+			found := *passenger
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *passengerRepository) UpdatePreferences(_ context.Context, id uuid.UUID, preferences models.RidePreferences) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if passenger, ok := r.store.passengers[id]; ok {
+		passenger.Preferences = preferences
+	}
+	return nil
+}
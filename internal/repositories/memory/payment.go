@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type paymentRepository struct {
+	store *Store
+}
+
+// NewPaymentRepository comes with the gateway payments are processed through, which is seed data in a real database
+func NewPaymentRepository(store *Store) repositories.PaymentRepository {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.gateways[domain.PaymentGatewayStripe]; !ok {
+		store.gateways[domain.PaymentGatewayStripe] = &models.PaymentGateway{
+			BaseModel: models.BaseModel{ID: uuid.New()},
+			Name:      domain.PaymentGatewayStripe,
+		}
+	}
+	return &paymentRepository{store: store}
+}
+
+func (r *paymentRepository) GetGatewayByName(_ context.Context, name string) (*models.PaymentGateway, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	gateway, ok := r.store.gateways[name]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *gateway
+	return &found, nil
+}
+
+func (r *paymentRepository) CreateReceipt(_ context.Context, receipt *models.PaymentReceipt) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&receipt.BaseModel)
+	stored := *receipt
+	r.store.receipts[receipt.ID] = &stored
+	return nil
+}
@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type queueMessageRepository struct {
+	store *Store
+}
+
+func NewQueueMessageRepository(store *Store) repositories.QueueMessageRepository {
+	return &queueMessageRepository{store: store}
+}
+
+func (r *queueMessageRepository) RegisterGroup(_ context.Context, topic string, group string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	groups, ok := r.store.queueGroups[topic]
+	if !ok {
+		groups = make(map[string]struct{})
+		r.store.queueGroups[topic] = groups
+	}
+	groups[group] = struct{}{}
+	return nil
+}
+
+func (r *queueMessageRepository) Groups(_ context.Context, topic string) ([]string, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	groups := make([]string, 0, len(r.store.queueGroups[topic]))
+	for group := range r.store.queueGroups[topic] {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+func (r *queueMessageRepository) Enqueue(_ context.Context, messages []models.QueueMessage) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i := range messages {
+		stored := messages[i]
+		r.store.queueMessages[stored.ID] = &stored
+	}
+	return nil
+}
+
+func (r *queueMessageRepository) Claim(_ context.Context, topic string, group string, now time.Time, visibilityTimeout time.Duration, limit int) ([]models.QueueMessage, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var visible []*models.QueueMessage
+	for _, message := range r.store.queueMessages {
+		if message.Topic == topic && message.ConsumerGroup == group && !message.VisibleAt.After(now) {
+			visible = append(visible, message)
+		}
+	}
+	sort.Slice(visible, func(i, j int) bool {
+		if !visible[i].VisibleAt.Equal(visible[j].VisibleAt) {
+			return visible[i].VisibleAt.Before(visible[j].VisibleAt)
+		}
+		return visible[i].CreatedAt.Before(visible[j].CreatedAt)
+	})
+	if len(visible) > limit {
+		visible = visible[:limit]
+	}
+
+	claimToken := uuid.New()
+	messages := make([]models.QueueMessage, len(visible))
+	for i, message := range visible {
+		message.Attempts++
+		message.VisibleAt = now.Add(visibilityTimeout)
+		token := claimToken
+		message.ClaimToken = &token
+		messages[i] = *message
+	}
+	return messages, nil
+}
+
+// claimed returns the message if it still carries the claim token. Callers hold the lock.
+func (r *queueMessageRepository) claimed(id, claimToken uuid.UUID) *models.QueueMessage {
+	message, ok := r.store.queueMessages[id]
+	if !ok || message.ClaimToken == nil || *message.ClaimToken != claimToken {
+		return nil
+	}
+	return message
+}
+
+func (r *queueMessageRepository) ExtendClaim(_ context.Context, id, claimToken uuid.UUID, visibleAt time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	message := r.claimed(id, claimToken)
+	if message == nil {
+		return false, nil
+	}
+	message.VisibleAt = visibleAt
+	return true, nil
+}
+
+func (r *queueMessageRepository) Release(_ context.Context, claimToken uuid.UUID, ids []uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if message := r.claimed(id, claimToken); message != nil {
+			message.Attempts--
+			message.VisibleAt = now
+			message.ClaimToken = nil
+		}
+	}
+	return nil
+}
+
+func (r *queueMessageRepository) Delete(_ context.Context, id, claimToken uuid.UUID) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.claimed(id, claimToken) == nil {
+		return false, nil
+	}
+	delete(r.store.queueMessages, id)
+	return true, nil
+}
+
+func (r *queueMessageRepository) Retry(_ context.Context, id, claimToken uuid.UUID, visibleAt time.Time, lastError string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	message := r.claimed(id, claimToken)
+	if message == nil {
+		return false, nil
+	}
+	message.VisibleAt = visibleAt
+	message.LastError = lastError
+	message.ClaimToken = nil
+	return true, nil
+}
+
+func (r *queueMessageRepository) DeadLetter(_ context.Context, id, claimToken uuid.UUID, lastError string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	message := r.claimed(id, claimToken)
+	if message == nil {
+		return false, nil
+	}
+	r.store.deadLetters[id] = &models.DeadLetter{
+		ID:            message.ID,
+		CreatedAt:     time.Now(),
+		Topic:         message.Topic,
+		ConsumerGroup: message.ConsumerGroup,
+		Payload:       message.Payload,
+		Attempts:      message.Attempts,
+		LastError:     lastError,
+		QueuedAt:      message.CreatedAt,
+	}
+	delete(r.store.queueMessages, id)
+	return true, nil
+}
+
+func (r *queueMessageRepository) AddDeadLetter(_ context.Context, deadLetter *models.DeadLetter) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Storing the same message again is a no-op, like ON CONFLICT DO NOTHING
+	if _, exists := r.store.deadLetters[deadLetter.ID]; !exists {
+		stored := *deadLetter
+		r.store.deadLetters[deadLetter.ID] = &stored
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type recurringRideRepository struct {
+	store *Store
+}
+
+func NewRecurringRideRepository(store *Store) repositories.RecurringRideRepository {
+	return &recurringRideRepository{store: store}
+}
+
+func (r *recurringRideRepository) Create(_ context.Context, ride *models.RecurringRide) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&ride.BaseModel)
+	r.store.recurringRides[ride.ID] = copyRecurringRide(ride)
+	return nil
+}
+
+func (r *recurringRideRepository) GetByID(_ context.Context, id uuid.UUID) (*models.RecurringRide, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	ride, ok := r.store.recurringRides[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return copyRecurringRide(ride), nil
+}
+
+func (r *recurringRideRepository) ListByPassenger(_ context.Context, passengerID uuid.UUID) ([]models.RecurringRide, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var rides []models.RecurringRide
+	for _, ride := range r.store.recurringRides {
+		if ride.PassengerId == passengerID {
+			rides = append(rides, *copyRecurringRide(ride))
+		}
+	}
+	sort.Slice(rides, func(i, j int) bool {
+		return rides[i].CreatedAt.Before(rides[j].CreatedAt)
+	})
+	return rides, nil
+}
+
+func (r *recurringRideRepository) Update(_ context.Context, ride *models.RecurringRide) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored := copyRecurringRide(ride)
+	if existing, ok := r.store.recurringRides[ride.ID]; ok {
+		stored.MaterializedUntil = existing.MaterializedUntil
+	}
+	r.store.recurringRides[ride.ID] = stored
+	return nil
+}
+
+// ClaimDue returns the due series, there is no other instance to skip them for
+func (r *recurringRideRepository) ClaimDue(_ context.Context, cutoff time.Time, limit int) ([]models.RecurringRide, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var rides []models.RecurringRide
+	for _, ride := range r.store.recurringRides {
+		if ride.Status == models.RecurringRideStatusActive && ride.MaterializedUntil.Before(cutoff) {
+			rides = append(rides, *copyRecurringRide(ride))
+		}
+	}
+	sort.Slice(rides, func(i, j int) bool {
+		return rides[i].MaterializedUntil.Before(rides[j].MaterializedUntil)
+	})
+	if len(rides) > limit {
+		rides = rides[:limit]
+	}
+	return rides, nil
+}
+
+func (r *recurringRideRepository) SetMaterializedUntil(_ context.Context, id uuid.UUID, until time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if ride, ok := r.store.recurringRides[id]; ok {
+		ride.MaterializedUntil = until
+	}
+	return nil
+}
+
+// copyRecurringRide copies the series and its days
+func copyRecurringRide(ride *models.RecurringRide) *models.RecurringRide {
+	copied := *ride
+	copied.Weekdays = append([]string(nil), ride.Weekdays...)
+	copied.SkipDates = append([]string(nil), ride.SkipDates...)
+	return &copied
+}
@@ -0,0 +1,25 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+)
+
+type reviewRepository struct {
+	store *Store
+}
+
+func NewReviewRepository(store *Store) repositories.ReviewRepository {
+	return &reviewRepository{store: store}
+}
+
+func (r *reviewRepository) Create(_ context.Context, review *models.Review) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&review.BaseModel)
+	stored := *review
+	r.store.reviews[review.ID] = &stored
+	return nil
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type savedPlaceRepository struct {
+	store *Store
+}
+
+func NewSavedPlaceRepository(store *Store) repositories.SavedPlaceRepository {
+	return &savedPlaceRepository{store: store}
+}
+
+func (r *savedPlaceRepository) Create(_ context.Context, place *models.SavedPlace) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&place.BaseModel)
+	stored := *place
+	r.store.savedPlaces[place.ID] = &stored
+	return nil
+}
+
+func (r *savedPlaceRepository) GetByID(_ context.Context, id uuid.UUID) (*models.SavedPlace, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	place, ok := r.store.savedPlaces[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *place
+	return &found, nil
+}
+
+func (r *savedPlaceRepository) GetByPassengerAndLabel(_ context.Context, passengerID uuid.UUID, label string) (*models.SavedPlace, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, place := range r.store.savedPlaces {
+		if place.PassengerId == passengerID && place.Label == label {
+			found := *place
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *savedPlaceRepository) ListByPassenger(_ context.Context, passengerID uuid.UUID) ([]models.SavedPlace, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var places []models.SavedPlace
+	for _, place := range r.store.savedPlaces {
+		if place.PassengerId == passengerID {
+			places = append(places, *place)
+		}
+	}
+	sort.Slice(places, func(i, j int) bool {
+		return places[i].Label < places[j].Label
+	})
+	return places, nil
+}
+
+func (r *savedPlaceRepository) Update(_ context.Context, place *models.SavedPlace) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored := *place
+	r.store.savedPlaces[place.ID] = &stored
+	return nil
+}
+
+func (r *savedPlaceRepository) Delete(_ context.Context, id uuid.UUID) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.savedPlaces, id)
+	return nil
+}
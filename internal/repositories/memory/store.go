@@ -0,0 +1,69 @@
+// Package memory implements the repositories on top of plain maps, for the simulator and other
+// offline tools that drive the real services without a database.
+package memory
+
+import (
+	"sync"
+
+	"CabBookingService/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Store holds the rows of every in-memory repository. Repositories created from the same store
+// see each other's writes, the way the GORM repositories share a database.
+//
+// Rows are copied in and out, so callers can't change stored rows behind the repository's back.
+// Relations are not stored with a row, they are filled in on read like a GORM Preload.
+type Store struct {
+	mu sync.RWMutex
+
+	bookings    map[uuid.UUID]*models.Booking
+	offers      map[uuid.UUID]map[uuid.UUID]*models.BookingOffer // Booking ID -> driver ID -> offer
+	drivers     map[uuid.UUID]*models.Driver
+	passengers  map[uuid.UUID]*models.Passenger
+	otps        map[uuid.UUID]*models.OTP
+	reviews     map[uuid.UUID]*models.Review
+	gateways    map[string]*models.PaymentGateway // By name
+	receipts    map[uuid.UUID]*models.PaymentReceipt
+	geofences   map[uuid.UUID]*models.Geofence
+	savedPlaces map[uuid.UUID]*models.SavedPlace
+	trips       map[uuid.UUID]*models.Trip
+	outbox      map[uuid.UUID]*models.OutboxEvent
+
+	recurringRides map[uuid.UUID]*models.RecurringRide
+
+	queueGroups   map[string]map[string]struct{} // Topic -> consumer groups
+	queueMessages map[uuid.UUID]*models.QueueMessage
+	deadLetters   map[uuid.UUID]*models.DeadLetter
+}
+
+func NewStore() *Store {
+	return &Store{
+		bookings:    make(map[uuid.UUID]*models.Booking),
+		offers:      make(map[uuid.UUID]map[uuid.UUID]*models.BookingOffer),
+		drivers:     make(map[uuid.UUID]*models.Driver),
+		passengers:  make(map[uuid.UUID]*models.Passenger),
+		otps:        make(map[uuid.UUID]*models.OTP),
+		reviews:     make(map[uuid.UUID]*models.Review),
+		gateways:    make(map[string]*models.PaymentGateway),
+		receipts:    make(map[uuid.UUID]*models.PaymentReceipt),
+		geofences:   make(map[uuid.UUID]*models.Geofence),
+		savedPlaces: make(map[uuid.UUID]*models.SavedPlace),
+		trips:       make(map[uuid.UUID]*models.Trip),
+		outbox:      make(map[uuid.UUID]*models.OutboxEvent),
+
+		recurringRides: make(map[uuid.UUID]*models.RecurringRide),
+
+		queueGroups:   make(map[string]map[string]struct{}),
+		queueMessages: make(map[uuid.UUID]*models.QueueMessage),
+		deadLetters:   make(map[uuid.UUID]*models.DeadLetter),
+	}
+}
+
+// newID gives rows created without an ID one, like the database default does
+func newID(base *models.BaseModel) {
+	if base.ID == uuid.Nil {
+		base.ID = uuid.New()
+	}
+}
@@ -0,0 +1,18 @@
+package memory
+
+import (
+	"CabBookingService/internal/repositories"
+	"context"
+)
+
+type transactor struct{}
+
+// NewTransactor runs fn without a transaction. Every repository call is atomic on its own, but
+// writes made before fn fails are not rolled back.
+func NewTransactor() repositories.Transactor {
+	return transactor{}
+}
+
+func (transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
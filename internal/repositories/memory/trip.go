@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type tripRepository struct {
+	store *Store
+}
+
+func NewTripRepository(store *Store) repositories.TripRepository {
+	return &tripRepository{store: store}
+}
+
+func (r *tripRepository) Create(_ context.Context, trip *models.Trip) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	newID(&trip.BaseModel)
+	for i := range trip.Stops {
+		newID(&trip.Stops[i].BaseModel)
+		trip.Stops[i].TripId = trip.ID
+	}
+	r.store.trips[trip.ID] = copyTrip(trip)
+	return nil
+}
+
+func (r *tripRepository) GetByID(_ context.Context, id uuid.UUID) (*models.Trip, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	trip, ok := r.store.trips[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return copyTrip(trip), nil
+}
+
+func (r *tripRepository) GetActiveByDriver(_ context.Context, driverID uuid.UUID) (*models.Trip, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, trip := range r.store.trips {
+		if trip.DriverId == driverID && trip.Status == models.TripStatusActive {
+			return copyTrip(trip), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *tripRepository) GetActiveByDrivers(_ context.Context, driverIDs []uuid.UUID) ([]models.Trip, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	wanted := make(map[uuid.UUID]bool, len(driverIDs))
+	for _, id := range driverIDs {
+		wanted[id] = true
+	}
+	var trips []models.Trip
+	for _, trip := range r.store.trips {
+		if wanted[trip.DriverId] && trip.Status == models.TripStatusActive {
+			trips = append(trips, *copyTrip(trip))
+		}
+	}
+	return trips, nil
+}
+
+func (r *tripRepository) AddBooking(_ context.Context, tripID, bookingID uuid.UUID, remaining []models.TripStop) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if booking, ok := r.store.bookings[bookingID]; ok {
+		booking.TripId = &tripID
+	}
+	r.store.replaceRemainingStops(tripID, remaining)
+	return nil
+}
+
+func (r *tripRepository) ReplaceRemainingStops(_ context.Context, tripID uuid.UUID, remaining []models.TripStop) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.replaceRemainingStops(tripID, remaining)
+	return nil
+}
+
+func (r *tripRepository) CompleteStop(_ context.Context, tripID, bookingID uuid.UUID, kind models.TripStopKind, at time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	trip, ok := r.store.trips[tripID]
+	if !ok {
+		return false, nil
+	}
+	for i := range trip.Stops {
+		stop := &trip.Stops[i]
+		if stop.BookingId == bookingID && stop.Kind == kind && stop.CompletedAt == nil {
+			stop.CompletedAt = &at
+			stop.UpdatedAt = at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *tripRepository) UpdateStatus(_ context.Context, id uuid.UUID, status models.TripStatus) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if trip, ok := r.store.trips[id]; ok {
+		trip.Status = status
+		trip.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// replaceRemainingStops numbers the new stops after the completed ones. The caller must hold the store lock.
+func (s *Store) replaceRemainingStops(tripID uuid.UUID, remaining []models.TripStop) {
+	trip, ok := s.trips[tripID]
+	if !ok {
+		return
+	}
+	stops := trip.CompletedStops()
+	completed := len(stops)
+	for i := range remaining {
+		newID(&remaining[i].BaseModel)
+		remaining[i].TripId = tripID
+		remaining[i].Sequence = completed + i + 1
+	}
+	trip.Stops = append(stops, remaining...)
+}
+
+// copyTrip copies the trip and its stops, ordered by sequence
+func copyTrip(trip *models.Trip) *models.Trip {
+	copied := *trip
+	copied.Stops = append([]models.TripStop(nil), trip.Stops...)
+	sort.SliceStable(copied.Stops, func(i, j int) bool {
+		return copied.Stops[i].Sequence < copied.Stops[j].Sequence
+	})
+	return &copied
+}
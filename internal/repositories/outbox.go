@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"CabBookingService/internal/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	// Add stores an event, in the caller's transaction if ctx carries one
+	Add(ctx context.Context, event *models.OutboxEvent) error
+	// ClaimDue returns up to limit unsent events that are due, oldest first. Their next attempt is
+	// pushed back by claimTimeout, so other relays skip them while this one delivers them.
+	ClaimDue(ctx context.Context, now time.Time, claimTimeout time.Duration, limit int) ([]models.OutboxEvent, error)
+	MarkSent(ctx context.Context, id uuid.UUID, sentAt time.Time) error
+	// MarkFailed records a failed delivery, the event is tried again at nextAttemptAt
+	MarkFailed(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error
+	// MarkDead records a failed delivery the relay gives up on, the event is not claimed again
+	MarkDead(ctx context.Context, id uuid.UUID, failedAt time.Time, lastError string) error
+}
+
+type gormOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewGormOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &gormOutboxRepository{db: db}
+}
+
+func (r *gormOutboxRepository) Add(ctx context.Context, event *models.OutboxEvent) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(event).Error
+}
+
+func (r *gormOutboxRepository) ClaimDue(ctx context.Context, now time.Time, claimTimeout time.Duration, limit int) ([]models.OutboxEvent, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var events []models.OutboxEvent
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the due events, skipping the ones another relay is claiming right now
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+			Order("next_attempt_at, created_at").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		// 2. Push them back, if this relay dies they are due again after the claim timeout
+		ids := make([]uuid.UUID, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&models.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimTimeout)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *gormOutboxRepository) MarkSent(ctx context.Context, id uuid.UUID, sentAt time.Time) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Update("sent_at", sentAt).Error
+}
+
+func (r *gormOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastError,
+		}).Error
+}
+
+func (r *gormOutboxRepository) MarkDead(ctx context.Context, id uuid.UUID, failedAt time.Time, lastError string) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"failed_at":  failedAt,
+			"last_error": lastError,
+		}).Error
+}
@@ -13,6 +13,7 @@ type PassengerRepository interface {
 	Create(ctx context.Context, passenger *models.Passenger) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Passenger, error)
 	GetByAccountID(ctx context.Context, accountID uuid.UUID) (*models.Passenger, error)
+	UpdatePreferences(ctx context.Context, id uuid.UUID, preferences models.RidePreferences) error
 }
 
 type gormPassengerRepository struct {
@@ -54,3 +55,11 @@ func (r *gormPassengerRepository) GetByAccountID(ctx context.Context, accountID
 	}
 	return &passenger, nil
 }
+
+func (r *gormPassengerRepository) UpdatePreferences(ctx context.Context, id uuid.UUID, preferences models.RidePreferences) error {
+	tx := db.NewGormTx(ctx, r.db)
+	// Select every column so that cleared preferences (false / nil) are written too
+	return tx.Model(&models.Passenger{BaseModel: models.BaseModel{ID: id}}).
+		Select("pref_preferred_driver_gender", "pref_wheelchair_access", "pref_pet_friendly", "pref_child_seat", "pref_quiet_ride").
+		Updates(&models.Passenger{Preferences: preferences}).Error
+}
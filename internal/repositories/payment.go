@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"CabBookingService/internal/db"
 	"CabBookingService/internal/models"
 	"context"
 
@@ -21,9 +22,9 @@ func NewGormPaymentRepository(db *gorm.DB) PaymentRepository {
 }
 
 func (r *gormPaymentRepository) GetGatewayByName(ctx context.Context, name string) (*models.PaymentGateway, error) {
+	tx := db.NewGormTx(ctx, r.db)
 	var gateway models.PaymentGateway
-	err := r.db.WithContext(ctx).
-		Where("name = ?", name).
+	err := tx.Where("name = ?", name).
 		First(&gateway).Error
 	if err != nil {
 		return nil, err
@@ -32,5 +33,6 @@ func (r *gormPaymentRepository) GetGatewayByName(ctx context.Context, name strin
 }
 
 func (r *gormPaymentRepository) CreateReceipt(ctx context.Context, receipt *models.PaymentReceipt) error {
-	return r.db.WithContext(ctx).Create(receipt).Error
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(receipt).Error
 }
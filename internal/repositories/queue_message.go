@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"CabBookingService/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QueueMessageRepository interface {
+	// RegisterGroup adds a consumer group to the topic, registering it again is a no-op
+	RegisterGroup(ctx context.Context, topic string, group string) error
+	// Groups returns the consumer groups of the topic
+	Groups(ctx context.Context, topic string) ([]string, error)
+	// Enqueue stores the copies of a message for the consumer groups in one statement
+	Enqueue(ctx context.Context, messages []models.QueueMessage) error
+	// Claim returns up to limit visible messages of the group, oldest first. They count an attempt
+	// and stay hidden from the other consumers of the group for visibilityTimeout. The messages get
+	// a new ClaimToken, the calls below only change a message while it still carries that token.
+	Claim(ctx context.Context, topic string, group string, now time.Time, visibilityTimeout time.Duration, limit int) ([]models.QueueMessage, error)
+	// ExtendClaim keeps a claimed message hidden until visibleAt. Returns false if the claim was lost.
+	ExtendClaim(ctx context.Context, id, claimToken uuid.UUID, visibleAt time.Time) (bool, error)
+	// Release makes claimed messages visible again right away, without counting the attempt
+	Release(ctx context.Context, claimToken uuid.UUID, ids []uuid.UUID) error
+	// Delete removes a handled message. Returns false if the claim was lost.
+	Delete(ctx context.Context, id, claimToken uuid.UUID) (bool, error)
+	// Retry hides a failed message until visibleAt. Returns false if the claim was lost.
+	Retry(ctx context.Context, id, claimToken uuid.UUID, visibleAt time.Time, lastError string) (bool, error)
+	// DeadLetter moves a message to the dead-letter table. Returns false if the claim was lost.
+	DeadLetter(ctx context.Context, id, claimToken uuid.UUID, lastError string) (bool, error)
+	// AddDeadLetter stores a message another queue gave up on in the dead-letter table.
+	// Storing the same message again, e.g. when its publish is retried, is a no-op.
+	AddDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error
+}
+
+type gormQueueMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewGormQueueMessageRepository(db *gorm.DB) QueueMessageRepository {
+	return &gormQueueMessageRepository{db: db}
+}
+
+func (r *gormQueueMessageRepository) RegisterGroup(ctx context.Context, topic string, group string) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.QueueConsumerGroup{Topic: topic, ConsumerGroup: group, CreatedAt: time.Now()}).Error
+}
+
+func (r *gormQueueMessageRepository) Groups(ctx context.Context, topic string) ([]string, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var groups []string
+	err := tx.Model(&models.QueueConsumerGroup{}).
+		Where("topic = ?", topic).
+		Order("consumer_group").
+		Pluck("consumer_group", &groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (r *gormQueueMessageRepository) Enqueue(ctx context.Context, messages []models.QueueMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(&messages).Error
+}
+
+func (r *gormQueueMessageRepository) Claim(ctx context.Context, topic string, group string, now time.Time, visibilityTimeout time.Duration, limit int) ([]models.QueueMessage, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var messages []models.QueueMessage
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the visible messages, skipping the ones another consumer is claiming right now
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("topic = ? AND consumer_group = ? AND visible_at <= ?", topic, group, now).
+			Order("visible_at, created_at").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		// 2. Hide them for the visibility timeout, if this consumer dies they are delivered again
+		claimToken := uuid.New()
+		ids := make([]uuid.UUID, len(messages))
+		for i := range messages {
+			messages[i].Attempts++
+			messages[i].VisibleAt = now.Add(visibilityTimeout)
+			messages[i].ClaimToken = &claimToken
+			ids[i] = messages[i].ID
+		}
+		return tx.Model(&models.QueueMessage{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"attempts":    gorm.Expr("attempts + 1"),
+				"visible_at":  now.Add(visibilityTimeout),
+				"claim_token": claimToken,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *gormQueueMessageRepository) ExtendClaim(ctx context.Context, id, claimToken uuid.UUID, visibleAt time.Time) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+	res := tx.Model(&models.QueueMessage{}).
+		Where("id = ? AND claim_token = ?", id, claimToken).
+		Update("visible_at", visibleAt)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *gormQueueMessageRepository) Release(ctx context.Context, claimToken uuid.UUID, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.QueueMessage{}).
+		Where("id IN ? AND claim_token = ?", ids, claimToken).
+		Updates(map[string]interface{}{
+			"attempts":    gorm.Expr("attempts - 1"),
+			"visible_at":  gorm.Expr("now()"),
+			"claim_token": nil,
+		}).Error
+}
+
+func (r *gormQueueMessageRepository) Delete(ctx context.Context, id, claimToken uuid.UUID) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+	res := tx.Delete(&models.QueueMessage{}, "id = ? AND claim_token = ?", id, claimToken)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *gormQueueMessageRepository) Retry(ctx context.Context, id, claimToken uuid.UUID, visibleAt time.Time, lastError string) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+	res := tx.Model(&models.QueueMessage{}).
+		Where("id = ? AND claim_token = ?", id, claimToken).
+		Updates(map[string]interface{}{
+			"visible_at":  visibleAt,
+			"last_error":  lastError,
+			"claim_token": nil,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *gormQueueMessageRepository) DeadLetter(ctx context.Context, id, claimToken uuid.UUID, lastError string) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	moved := false
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the message, it only moves while this consumer still holds the claim
+		var message models.QueueMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&message, "id = ? AND claim_token = ?", id, claimToken).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// 2. Move it
+		deadLetter := &models.DeadLetter{
+			ID:            message.ID,
+			CreatedAt:     time.Now(),
+			Topic:         message.Topic,
+			ConsumerGroup: message.ConsumerGroup,
+			Payload:       message.Payload,
+			Attempts:      message.Attempts,
+			LastError:     lastError,
+			QueuedAt:      message.CreatedAt,
+		}
+		if err := tx.Create(deadLetter).Error; err != nil {
+			return err
+		}
+		moved = true
+		return tx.Delete(&models.QueueMessage{}, "id = ?", id).Error
+	})
+	return moved, err
+}
+
+func (r *gormQueueMessageRepository) AddDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) error {
+	tx := db.NewGormTx(ctx, r.db)
+	// The ID is the event ID, the outbox publishes an event again until the publish succeeded
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(deadLetter).Error
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"CabBookingService/internal/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecurringRideRepository interface {
+	Create(ctx context.Context, ride *models.RecurringRide) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.RecurringRide, error)
+	ListByPassenger(ctx context.Context, passengerID uuid.UUID) ([]models.RecurringRide, error)
+	// Update saves the series, except MaterializedUntil which only SetMaterializedUntil moves
+	Update(ctx context.Context, ride *models.RecurringRide) error
+	// ClaimDue returns up to limit ACTIVE series booked only up to before the cutoff, locked until
+	// the transaction ends. Series another instance is booking are skipped.
+	ClaimDue(ctx context.Context, cutoff time.Time, limit int) ([]models.RecurringRide, error)
+	SetMaterializedUntil(ctx context.Context, id uuid.UUID, until time.Time) error
+}
+
+type gormRecurringRideRepository struct {
+	db *gorm.DB
+}
+
+func NewGormRecurringRideRepository(db *gorm.DB) RecurringRideRepository {
+	return &gormRecurringRideRepository{db: db}
+}
+
+func (r *gormRecurringRideRepository) Create(ctx context.Context, ride *models.RecurringRide) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(ride).Error
+}
+
+func (r *gormRecurringRideRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.RecurringRide, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var ride models.RecurringRide
+	if err := tx.First(&ride, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &ride, nil
+}
+
+func (r *gormRecurringRideRepository) ListByPassenger(ctx context.Context, passengerID uuid.UUID) ([]models.RecurringRide, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var rides []models.RecurringRide
+	err := tx.Where("passenger_id = ?", passengerID).
+		Order("created_at").
+		Find(&rides).Error
+	if err != nil {
+		return nil, err
+	}
+	return rides, nil
+}
+
+func (r *gormRecurringRideRepository) Update(ctx context.Context, ride *models.RecurringRide) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Omit("MaterializedUntil").Save(ride).Error
+}
+
+func (r *gormRecurringRideRepository) ClaimDue(ctx context.Context, cutoff time.Time, limit int) ([]models.RecurringRide, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var rides []models.RecurringRide
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND materialized_until < ?", models.RecurringRideStatusActive, cutoff).
+		Order("materialized_until").
+		Limit(limit).
+		Find(&rides).Error
+	if err != nil {
+		return nil, err
+	}
+	return rides, nil
+}
+
+func (r *gormRecurringRideRepository) SetMaterializedUntil(ctx context.Context, id uuid.UUID, until time.Time) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.RecurringRide{}).
+		Where("id = ?", id).
+		Update("materialized_until", until).Error
+}
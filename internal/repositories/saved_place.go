@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"CabBookingService/internal/models"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SavedPlaceRepository interface {
+	Create(ctx context.Context, place *models.SavedPlace) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.SavedPlace, error)
+	GetByPassengerAndLabel(ctx context.Context, passengerID uuid.UUID, label string) (*models.SavedPlace, error)
+	ListByPassenger(ctx context.Context, passengerID uuid.UUID) ([]models.SavedPlace, error)
+	Update(ctx context.Context, place *models.SavedPlace) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type gormSavedPlaceRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSavedPlaceRepository(db *gorm.DB) SavedPlaceRepository {
+	return &gormSavedPlaceRepository{db: db}
+}
+
+func (r *gormSavedPlaceRepository) Create(ctx context.Context, place *models.SavedPlace) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(place).Error
+}
+
+func (r *gormSavedPlaceRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SavedPlace, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var place models.SavedPlace
+	if err := tx.First(&place, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &place, nil
+}
+
+func (r *gormSavedPlaceRepository) GetByPassengerAndLabel(ctx context.Context, passengerID uuid.UUID, label string) (*models.SavedPlace, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var place models.SavedPlace
+	if err := tx.First(&place, "passenger_id = ? AND label = ?", passengerID, label).Error; err != nil {
+		return nil, err
+	}
+	return &place, nil
+}
+
+func (r *gormSavedPlaceRepository) ListByPassenger(ctx context.Context, passengerID uuid.UUID) ([]models.SavedPlace, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var places []models.SavedPlace
+	err := tx.Where("passenger_id = ?", passengerID).
+		Order("label").
+		Find(&places).Error
+	if err != nil {
+		return nil, err
+	}
+	return places, nil
+}
+
+func (r *gormSavedPlaceRepository) Update(ctx context.Context, place *models.SavedPlace) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Save(place).Error
+}
+
+func (r *gormSavedPlaceRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Delete(&models.SavedPlace{}, "id = ?", id).Error
+}
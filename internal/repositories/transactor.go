@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Transactor runs several repository calls in one database transaction
+type Transactor interface {
+	// InTx runs fn in a transaction, committed if fn returns nil and rolled back otherwise.
+	// Repositories called with the context passed to fn join the transaction. Called with the context
+	// of a transaction, fn runs in a savepoint of it and a failure only rolls back what fn wrote.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type gormTransactor struct {
+	db *gorm.DB
+}
+
+func NewGormTransactor(db *gorm.DB) Transactor {
+	return &gormTransactor{db: db}
+}
+
+func (t *gormTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx := db.NewGormTx(ctx, t.db)
+	return tx.Transaction(func(tx *gorm.DB) error {
+		return fn(db.WithTx(ctx, tx))
+	})
+}
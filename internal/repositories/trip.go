@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"CabBookingService/internal/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TripRepository interface {
+	Create(ctx context.Context, trip *models.Trip) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Trip, error)
+	// GetActiveByDriver returns the ACTIVE trip of the driver, gorm.ErrRecordNotFound if there is none
+	GetActiveByDriver(ctx context.Context, driverID uuid.UUID) (*models.Trip, error)
+	GetActiveByDrivers(ctx context.Context, driverIDs []uuid.UUID) ([]models.Trip, error)
+	// AddBooking puts the booking on the trip and replaces the remaining stops with the new plan
+	AddBooking(ctx context.Context, tripID, bookingID uuid.UUID, remaining []models.TripStop) error
+	// ReplaceRemainingStops replaces the stops that weren't visited yet, completed stops are kept
+	ReplaceRemainingStops(ctx context.Context, tripID uuid.UUID, remaining []models.TripStop) error
+	// CompleteStop marks the pickup or drop-off of a booking as visited. Returns false if it already was.
+	CompleteStop(ctx context.Context, tripID, bookingID uuid.UUID, kind models.TripStopKind, at time.Time) (bool, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status models.TripStatus) error
+}
+
+type gormTripRepository struct {
+	db *gorm.DB
+}
+
+func NewGormTripRepository(db *gorm.DB) TripRepository {
+	return &gormTripRepository{db: db}
+}
+
+func (r *gormTripRepository) Create(ctx context.Context, trip *models.Trip) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(trip).Error
+}
+
+func (r *gormTripRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Trip, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var trip models.Trip
+	err := tx.Preload("Stops", orderBySequence).
+		First(&trip, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &trip, nil
+}
+
+func (r *gormTripRepository) GetActiveByDriver(ctx context.Context, driverID uuid.UUID) (*models.Trip, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var trip models.Trip
+	err := tx.Preload("Stops", orderBySequence).
+		Where("driver_id = ? AND status = ?", driverID, models.TripStatusActive).
+		First(&trip).Error
+	if err != nil {
+		return nil, err
+	}
+	return &trip, nil
+}
+
+func (r *gormTripRepository) GetActiveByDrivers(ctx context.Context, driverIDs []uuid.UUID) ([]models.Trip, error) {
+	if len(driverIDs) == 0 {
+		return nil, nil
+	}
+	tx := db.NewGormTx(ctx, r.db)
+
+	var trips []models.Trip
+	err := tx.Preload("Stops", orderBySequence).
+		Where("driver_id IN ? AND status = ?", driverIDs, models.TripStatusActive).
+		Find(&trips).Error
+	if err != nil {
+		return nil, err
+	}
+	return trips, nil
+}
+
+func (r *gormTripRepository) AddBooking(ctx context.Context, tripID, bookingID uuid.UUID, remaining []models.TripStop) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Booking{}).
+			Where("id = ?", bookingID).
+			Update("trip_id", tripID).Error
+		if err != nil {
+			return err
+		}
+		return replaceRemainingStops(tx, tripID, remaining)
+	})
+}
+
+func (r *gormTripRepository) ReplaceRemainingStops(ctx context.Context, tripID uuid.UUID, remaining []models.TripStop) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Transaction(func(tx *gorm.DB) error {
+		return replaceRemainingStops(tx, tripID, remaining)
+	})
+}
+
+func (r *gormTripRepository) CompleteStop(ctx context.Context, tripID, bookingID uuid.UUID, kind models.TripStopKind, at time.Time) (bool, error) {
+	tx := db.NewGormTx(ctx, r.db)
+	result := tx.Model(&models.TripStop{}).
+		Where("trip_id = ? AND booking_id = ? AND kind = ? AND completed_at IS NULL", tripID, bookingID, kind).
+		Updates(map[string]interface{}{
+			"completed_at": at,
+			"updated_at":   at,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *gormTripRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.TripStatus) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.Trip{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// replaceRemainingStops numbers the new stops after the completed ones
+func replaceRemainingStops(tx *gorm.DB, tripID uuid.UUID, remaining []models.TripStop) error {
+	// The plan is rewritten on every change, there is no point in keeping old stops around
+	err := tx.Unscoped().
+		Where("trip_id = ? AND completed_at IS NULL", tripID).
+		Delete(&models.TripStop{}).Error
+	if err != nil {
+		return err
+	}
+
+	var completed int64
+	err = tx.Model(&models.TripStop{}).
+		Where("trip_id = ?", tripID).
+		Count(&completed).Error
+	if err != nil {
+		return err
+	}
+
+	if len(remaining) == 0 {
+		return nil
+	}
+	for i := range remaining {
+		remaining[i].TripId = tripID
+		remaining[i].Sequence = int(completed) + i + 1
+	}
+	return tx.Create(&remaining).Error
+}
+
+func orderBySequence(db *gorm.DB) *gorm.DB {
+	return db.Order("sequence ASC")
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"CabBookingService/internal/db"
+	"CabBookingService/internal/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error)
+	// ListEndpoints returns the endpoints of a partner, active or not
+	ListEndpoints(ctx context.Context, passengerID uuid.UUID) ([]models.WebhookEndpoint, error)
+	// ListActiveEndpoints returns the endpoints that receive the events of a partner's bookings
+	ListActiveEndpoints(ctx context.Context, passengerID uuid.UUID) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+
+	// AddDelivery stores a delivery, unless the endpoint already has one for the event
+	AddDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error)
+	// ListDeliveries returns the delivery log of an endpoint, newest first
+	ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error)
+	// ClaimDueDeliveries returns up to limit pending deliveries that are due, oldest first. Their next
+	// attempt is pushed back by claimTimeout, so other dispatchers skip them while this one sends them.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, claimTimeout time.Duration, limit int) ([]models.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID, statusCode int, deliveredAt time.Time) error
+	// MarkFailed records a failed attempt. A PENDING delivery is tried again at nextAttemptAt.
+	MarkFailed(ctx context.Context, id uuid.UUID, status models.WebhookDeliveryStatus, nextAttemptAt time.Time, statusCode int, lastError string) error
+	// Replay makes a delivery pending again with a fresh set of attempts
+	Replay(ctx context.Context, id uuid.UUID, now time.Time) error
+}
+
+type gormWebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewGormWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &gormWebhookRepository{db: db}
+}
+
+func (r *gormWebhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Create(endpoint).Error
+}
+
+func (r *gormWebhookRepository) GetEndpoint(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var endpoint models.WebhookEndpoint
+	if err := tx.First(&endpoint, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *gormWebhookRepository) ListEndpoints(ctx context.Context, passengerID uuid.UUID) ([]models.WebhookEndpoint, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var endpoints []models.WebhookEndpoint
+	err := tx.Where("passenger_id = ?", passengerID).
+		Order("created_at").
+		Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *gormWebhookRepository) ListActiveEndpoints(ctx context.Context, passengerID uuid.UUID) ([]models.WebhookEndpoint, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var endpoints []models.WebhookEndpoint
+	err := tx.Where("passenger_id = ? AND is_active", passengerID).
+		Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *gormWebhookRepository) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Delete(&models.WebhookEndpoint{}, "id = ?", id).Error
+}
+
+func (r *gormWebhookRepository) AddDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint_id"}, {Name: "event_id"}},
+		DoNothing: true,
+	}).Create(delivery).Error
+}
+
+func (r *gormWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var delivery models.WebhookDelivery
+	if err := tx.First(&delivery, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *gormWebhookRepository) ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var deliveries []models.WebhookDelivery
+	err := tx.Where("endpoint_id = ?", endpointID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *gormWebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, claimTimeout time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	tx := db.NewGormTx(ctx, r.db)
+
+	var deliveries []models.WebhookDelivery
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the due deliveries, skipping the ones another dispatcher is claiming right now
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryStatusPending, now).
+			Order("next_attempt_at, created_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		// 2. Push them back, if this dispatcher dies they are due again after the claim timeout
+		ids := make([]uuid.UUID, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimTimeout)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *gormWebhookRepository) MarkDelivered(ctx context.Context, id uuid.UUID, statusCode int, deliveredAt time.Time) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":           models.WebhookDeliveryStatusDelivered,
+			"attempts":         gorm.Expr("attempts + 1"),
+			"last_status_code": statusCode,
+			"last_error":       "",
+			"delivered_at":     deliveredAt,
+		}).Error
+}
+
+func (r *gormWebhookRepository) MarkFailed(ctx context.Context, id uuid.UUID, status models.WebhookDeliveryStatus, nextAttemptAt time.Time, statusCode int, lastError string) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":           status,
+			"attempts":         gorm.Expr("attempts + 1"),
+			"next_attempt_at":  nextAttemptAt,
+			"last_status_code": statusCode,
+			"last_error":       lastError,
+		}).Error
+}
+
+func (r *gormWebhookRepository) Replay(ctx context.Context, id uuid.UUID, now time.Time) error {
+	tx := db.NewGormTx(ctx, r.db)
+	return tx.Model(&models.WebhookDelivery{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":          models.WebhookDeliveryStatusPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		}).Error
+}
@@ -0,0 +1,120 @@
+package assignment
+
+import (
+	"math"
+)
+
+// Unassignable marks a pair that must never be matched (e.g. driver not eligible for the booking)
+var Unassignable = math.Inf(1)
+
+// Solve finds the assignment of rows to columns with the minimum total cost using the
+// Hungarian algorithm in O(n²m). The matrix may be rectangular, each row gets at most one
+// column and each column at most one row.
+//
+// The result holds the assigned column of every row, -1 if the row stays unassigned
+// because there are fewer columns than rows or every remaining pair is Unassignable.
+func Solve(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return []int{}
+	}
+	cols := len(cost[0])
+
+	result := make([]int, rows)
+	for i := range result {
+		result[i] = -1
+	}
+	if cols == 0 {
+		return result
+	}
+
+	// The algorithm needs rows <= cols, solve the transposed problem otherwise
+	if rows > cols {
+		transposed := make([][]float64, cols)
+		for j := range transposed {
+			transposed[j] = make([]float64, rows)
+			for i := 0; i < rows; i++ {
+				transposed[j][i] = cost[i][j]
+			}
+		}
+		for j, i := range Solve(transposed) {
+			if i >= 0 {
+				result[i] = j
+			}
+		}
+		return result
+	}
+
+	// Forbidden pairs get a cost higher than any complete assignment of allowed pairs,
+	// so they are only used when a row can't be matched otherwise
+	forbidden := 1.0
+	for i := range cost {
+		for _, c := range cost[i] {
+			if !math.IsInf(c, 1) {
+				forbidden += math.Abs(c)
+			}
+		}
+	}
+	a := func(i, j int) float64 {
+		if math.IsInf(cost[i][j], 1) {
+			return forbidden
+		}
+		return cost[i][j]
+	}
+
+	// Potentials based implementation, rows and columns are 1-indexed, column 0 is a virtual one
+	u := make([]float64, rows+1)
+	v := make([]float64, cols+1)
+	p := make([]int, cols+1) // p[j] is the row matched to column j
+	way := make([]int, cols+1)
+
+	for i := 1; i <= rows; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, cols+1)
+		used := make([]bool, cols+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+
+		for p[j0] != 0 {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= cols; j++ {
+				if used[j] {
+					continue
+				}
+				cur := a(i0-1, j-1) - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= cols; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+		}
+
+		// Flip the augmenting path
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	for j := 1; j <= cols; j++ {
+		if i := p[j]; i != 0 && !math.IsInf(cost[i-1][j-1], 1) {
+			result[i-1] = j - 1
+		}
+	}
+	return result
+}
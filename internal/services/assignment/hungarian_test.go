@@ -0,0 +1,103 @@
+package assignment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func totalCost(cost [][]float64, result []int) float64 {
+	total := 0.0
+	for i, j := range result {
+		if j >= 0 {
+			total += cost[i][j]
+		}
+	}
+	return total
+}
+
+func TestSolve(t *testing.T) {
+	t.Parallel()
+
+	x := Unassignable
+
+	tests := []struct {
+		name          string
+		cost          [][]float64
+		expected      []int
+		expectedTotal float64
+	}{
+		{
+			name:          "Empty",
+			cost:          [][]float64{},
+			expected:      []int{},
+			expectedTotal: 0,
+		},
+		{
+			name: "Greedy is not optimal",
+			// Greedy would give row 0 column 0 (1), leaving row 1 with column 1 (10) = 11
+			cost: [][]float64{
+				{1, 2},
+				{3, 10},
+			},
+			expected:      []int{1, 0},
+			expectedTotal: 5,
+		},
+		{
+			name: "Classic 3x3",
+			cost: [][]float64{
+				{4, 1, 3},
+				{2, 0, 5},
+				{3, 2, 2},
+			},
+			expected:      []int{1, 0, 2},
+			expectedTotal: 5,
+		},
+		{
+			name: "More columns than rows",
+			cost: [][]float64{
+				{5, 9, 1},
+				{10, 3, 2},
+			},
+			expected:      []int{2, 1},
+			expectedTotal: 4,
+		},
+		{
+			name: "More rows than columns",
+			cost: [][]float64{
+				{5},
+				{1},
+				{3},
+			},
+			expected:      []int{-1, 0, -1},
+			expectedTotal: 1,
+		},
+		{
+			name: "Unassignable pairs are never used",
+			cost: [][]float64{
+				{1, x},
+				{2, x},
+			},
+			expected:      []int{0, -1},
+			expectedTotal: 1,
+		},
+		{
+			name: "Unassignable pairs force the second best",
+			cost: [][]float64{
+				{1, 2},
+				{x, 100},
+			},
+			expected:      []int{0, 1},
+			expectedTotal: 101,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result := Solve(tt.cost)
+			require.Equal(t, tt.expected, result)
+			require.InDelta(t, tt.expectedTotal, totalCost(tt.cost, result), 1e-9)
+		})
+	}
+}
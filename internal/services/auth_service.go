@@ -32,10 +32,22 @@ type UserClaims struct {
 
 type AuthService interface {
 	RegisterPassenger(ctx context.Context, username, password, name, phone string) (*models.Passenger, error)
-	RegisterDriver(ctx context.Context, username, password, name, phone, plate, carModel string) (*models.Driver, error)
+	RegisterDriver(ctx context.Context, params RegisterDriverParams) (*models.Driver, error)
 	Login(ctx context.Context, username, password string) (string, error)
 }
 
+type RegisterDriverParams struct {
+	Username          string
+	Password          string
+	Name              string
+	PhoneNumber       string
+	PlateNumber       string
+	CarModel          string
+	CarType           models.CarType // Defaults to ECONOMY
+	AcceptsLowerClass bool           // Take economy rides with a higher class car
+	Capabilities      DriverCapabilities
+}
+
 type authService struct {
 	accountRepo   repositories.AccountRepository
 	passengerRepo repositories.PassengerRepository
@@ -154,7 +166,13 @@ func (a *authService) RegisterPassenger(ctx context.Context, username, password,
 	return passenger, nil
 }
 
-func (a *authService) RegisterDriver(ctx context.Context, username, password, name, phone, plate, carModel string) (*models.Driver, error) {
+func (a *authService) RegisterDriver(ctx context.Context, params RegisterDriverParams) (*models.Driver, error) {
+	username, password := params.Username, params.Password
+	carType, err := normalizeCarType(params.CarType)
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. Fetch Role
 	role, err := a.roleRepo.GetByName(ctx, domain.RoleDriver)
 	if err != nil {
@@ -203,16 +221,22 @@ func (a *authService) RegisterDriver(ctx context.Context, username, password, na
 	driver := &models.Driver{
 		BaseModel:   models.BaseModel{ID: uuid.New(), CreatedAt: now, UpdatedAt: now},
 		AccountId:   account.ID,
-		Name:        name,
-		PhoneNumber: phone,
+		Name:        params.Name,
+		PhoneNumber: params.PhoneNumber,
+
+		OffersQuietRides: params.Capabilities.OffersQuietRides,
 	}
 
 	// 4. Prepare Car Profile
 	car := models.Car{
-		BaseModel:     models.BaseModel{ID: uuid.New(), CreatedAt: now, UpdatedAt: now},
-		DriverId:      driver.ID,
-		PlateNumber:   plate,
-		BrandAndModel: carModel,
+		BaseModel:         models.BaseModel{ID: uuid.New(), CreatedAt: now, UpdatedAt: now},
+		DriverId:          driver.ID,
+		PlateNumber:       params.PlateNumber,
+		BrandAndModel:     params.CarModel,
+		CarType:           carType,
+		AcceptsLowerClass: params.AcceptsLowerClass,
+		PetFriendly:       params.Capabilities.PetFriendly,
+		HasChildSeat:      params.Capabilities.HasChildSeat,
 	}
 
 	// 5. Execute Atomic Transaction
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/assignment"
+	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/services/ranking"
+	"CabBookingService/internal/services/routing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// A booking that lost its candidates to better suited bookings is retried on the next
+	// ticks, until it has been part of this many batches
+	batchMaxAttempts = 5
+)
+
+var errMatcherStopped = errors.New("batch matcher stopped before the booking was matched")
+
+// pendingBooking is a booking waiting for the next tick. Its message is held until the batch decides,
+// durable queues deliver it again if the instance dies in between.
+type pendingBooking struct {
+	message  queue.Message[domain.DriverMatchingRequested]
+	attempts int   // Batches the booking was part of
+	lastErr  error // Why the last batch couldn't match it, nil if it just found no driver
+}
+
+type batchDriverMatchingService struct {
+	matcher *driverMatchingService
+	window  time.Duration
+
+	// Bookings waiting for the next tick
+	pending map[uuid.UUID]*pendingBooking
+	mu      sync.Mutex
+
+	running sync.WaitGroup
+}
+
+// NewBatchDriverMatchingService collects bookings over a time window and assigns drivers to all
+// of them at once, minimising the total pickup ETA instead of serving bookings first come first served.
+// Every booking is offered to the single driver it was assigned to.
+func NewBatchDriverMatchingService(
+	queue queue.MessageQueue,
+	locationService LocationService,
+	bookingRepo repositories.BookingRepository,
+	driverRepo repositories.DriverRepository,
+	geofenceService GeofenceService,
+	routingProvider routing.RoutingProvider,
+	offerService OfferService,
+	poolingService PoolingService,
+	settings MatchingSettings,
+	window time.Duration,
+) DriverMatchingService {
+	return &batchDriverMatchingService{
+		matcher: newDriverMatchingService(queue, locationService, bookingRepo, driverRepo, geofenceService, routingProvider, offerService, poolingService, settings),
+		window:  window,
+		pending: make(map[uuid.UUID]*pendingBooking),
+	}
+}
+
+func (s *batchDriverMatchingService) StartConsuming(ctx context.Context) error {
+	sub, err := queue.Subscribe[domain.DriverMatchingRequested](s.matcher.queue, domain.TopicDriverMatching, domain.GroupDriverMatching)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to topic %s: %w", domain.TopicDriverMatching, err)
+	}
+	log.Info().Str("topic", domain.TopicDriverMatching).Str("group", domain.GroupDriverMatching).Dur("window", s.window).Msg("Subscribed to topic (batch mode)")
+
+	go func() {
+		<-ctx.Done()
+		sub.Unsubscribe()
+	}()
+
+	// Collect bookings as they come in
+	collected := make(chan struct{})
+	s.running.Add(2)
+	go func() {
+		defer s.running.Done()
+		defer close(collected)
+		for message := range sub.Messages() {
+			s.collect(ctx, message)
+		}
+	}()
+
+	// Match whatever was collected once per window
+	go func() {
+		defer s.running.Done()
+		ticker := time.NewTicker(s.window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// Hand back what the next batch would have matched, once nothing else comes in
+				<-collected
+				s.nackPending(ctx)
+				return
+			case <-ticker.C:
+				s.runBatchSafely(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// collect adds the booking of a message to the next batch
+func (s *batchDriverMatchingService) collect(ctx context.Context, message queue.Message[domain.DriverMatchingRequested]) {
+	bookingID := message.Payload.BookingID
+	s.mu.Lock()
+	held, exists := s.pending[bookingID]
+	redelivered := exists && held.message.Envelope().ID == message.Envelope().ID
+	switch {
+	case !exists:
+		s.pending[bookingID] = &pendingBooking{message: message}
+	case redelivered:
+		// The held message came again, e.g. because its visibility timeout ran out while the booking
+		// waited for a batch. Only the newer delivery can still be acked or nacked.
+		held.message = message
+	}
+	s.mu.Unlock()
+
+	// Another message for the same booking is covered by the one held
+	if exists && !redelivered {
+		if err := message.Ack(context.WithoutCancel(ctx)); err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("[DriverMatching] Failed to ack message")
+		}
+	}
+}
+
+// nackPending hands the messages of all pending bookings back to the queue
+func (s *batchDriverMatchingService) nackPending(ctx context.Context) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[uuid.UUID]*pendingBooking)
+	s.mu.Unlock()
+
+	for bookingID, booking := range pending {
+		if err := booking.message.Nack(context.WithoutCancel(ctx), errMatcherStopped); err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("[DriverMatching] Failed to nack message")
+		}
+	}
+	if len(pending) > 0 {
+		log.Info().Int("bookings", len(pending)).Msg("Handed pending bookings back to the queue")
+	}
+}
+
+func (s *batchDriverMatchingService) Wait(ctx context.Context) error {
+	return WaitFor(ctx, &s.running)
+}
+
+// runBatchSafely runs one batch under the match deadline, a panic only loses this batch
+func (s *batchDriverMatchingService) runBatchSafely(ctx context.Context) {
+	var panicked error
+	defer recoverMatching("batch", &panicked)
+
+	ctx, cancel := s.matcher.matchContext(ctx)
+	defer cancel()
+	s.runBatch(ctx)
+}
+
+// batchEntry is a booking of the current batch with its eligible drivers
+type batchEntry struct {
+	booking    *models.Booking
+	candidates map[uuid.UUID]ranking.ScoredDriver
+}
+
+func (s *batchDriverMatchingService) runBatch(ctx context.Context) {
+	// 1. Take the pending bookings
+	s.mu.Lock()
+	bookingIDs := make([]uuid.UUID, 0, len(s.pending))
+	for id := range s.pending {
+		bookingIDs = append(bookingIDs, id)
+	}
+	s.mu.Unlock()
+	if len(bookingIDs) == 0 {
+		return
+	}
+
+	// 2. Find the candidates of every booking that still needs a driver
+	entries := make([]batchEntry, 0, len(bookingIDs))
+	driverIndex := make(map[uuid.UUID]int)
+	driverIDs := make([]uuid.UUID, 0)
+	for _, bookingID := range bookingIDs {
+		booking, err := s.matcher.bookingRepo.GetByID(ctx, bookingID)
+		if err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Failed to fetch booking")
+			s.retryLater(ctx, bookingID, err)
+			continue
+		}
+		if booking.Status != models.BookingStatusRequested {
+			s.done(ctx, bookingID)
+			continue
+		}
+
+		ranked, err := s.matcher.rankedCandidates(ctx, booking)
+		if err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Error finding candidate drivers")
+			s.retryLater(ctx, bookingID, err)
+			continue
+		}
+		if len(ranked) == 0 {
+			log.Info().Str("booking_id", bookingID.String()).Msg("No matching drivers found")
+			s.done(ctx, bookingID)
+			continue
+		}
+
+		entry := batchEntry{booking: booking, candidates: make(map[uuid.UUID]ranking.ScoredDriver, len(ranked))}
+		for _, candidate := range ranked {
+			entry.candidates[candidate.Driver.ID] = candidate
+			if _, ok := driverIndex[candidate.Driver.ID]; !ok {
+				driverIndex[candidate.Driver.ID] = len(driverIDs)
+				driverIDs = append(driverIDs, candidate.Driver.ID)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	// 3. Solve the assignment, the cost of a pair is the driver's pickup ETA
+	cost := make([][]float64, len(entries))
+	for i, entry := range entries {
+		cost[i] = make([]float64, len(driverIDs))
+		for j, driverID := range driverIDs {
+			if candidate, ok := entry.candidates[driverID]; ok {
+				cost[i][j] = candidate.PickupETA.Seconds()
+			} else {
+				cost[i][j] = assignment.Unassignable
+			}
+		}
+	}
+	result := assignment.Solve(cost)
+
+	// 4. Offer every booking to its driver
+	assigned := 0
+	for i, j := range result {
+		entry := entries[i]
+		if j < 0 {
+			log.Info().Str("booking_id", entry.booking.ID.String()).Msg("No driver left for booking in this batch")
+			s.retryLater(ctx, entry.booking.ID, nil)
+			continue
+		}
+		candidate := entry.candidates[driverIDs[j]]
+		s.matcher.offerService.OfferRide(ctx, entry.booking, []ranking.ScoredDriver{candidate})
+		s.done(ctx, entry.booking.ID)
+		assigned++
+	}
+
+	log.Info().
+		Int("bookings", len(entries)).
+		Int("drivers", len(driverIDs)).
+		Int("assigned", assigned).
+		Msg("Dispatch batch matched")
+}
+
+// done acks the message of a booking the batch is finished with
+func (s *batchDriverMatchingService) done(ctx context.Context, bookingID uuid.UUID) {
+	s.mu.Lock()
+	booking, ok := s.pending[bookingID]
+	delete(s.pending, bookingID)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// Acknowledge even when shutting down, the match was finished
+	if err := booking.message.Ack(context.WithoutCancel(ctx)); err != nil {
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("[DriverMatching] Failed to ack message")
+	}
+}
+
+// retryLater keeps a booking for the next batch. Once it was part of too many batches its message is
+// acked if it just found no driver, like in greedy mode, or handed back to the queue after an error.
+func (s *batchDriverMatchingService) retryLater(ctx context.Context, bookingID uuid.UUID, reason error) {
+	s.mu.Lock()
+	booking, ok := s.pending[bookingID]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	booking.attempts++
+	booking.lastErr = reason
+	if booking.attempts < batchMaxAttempts {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.pending, bookingID)
+	s.mu.Unlock()
+
+	log.Warn().Str("booking_id", bookingID.String()).Msg("Giving up on matching booking")
+	ackCtx := context.WithoutCancel(ctx)
+	if booking.lastErr == nil {
+		if err := booking.message.Ack(ackCtx); err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("[DriverMatching] Failed to ack message")
+		}
+		return
+	}
+	if err := booking.message.Nack(ackCtx, booking.lastErr); err != nil {
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("[DriverMatching] Failed to nack message")
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/services/queue"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchMatcher(p *testPlatform) *batchDriverMatchingService {
+	return NewBatchDriverMatchingService(p.queue, p.locationService, p.bookingRepo, p.driverRepo, p.geofence,
+		p.routing, p.offerService, p.poolingService, p.matching, time.Second).(*batchDriverMatchingService)
+}
+
+// recordingDelivery is a delivery that remembers how it was settled
+type recordingDelivery struct {
+	envelope queue.Envelope
+
+	mu     sync.Mutex
+	acks   int
+	nacked []error
+}
+
+func (d *recordingDelivery) Envelope() queue.Envelope {
+	return d.envelope
+}
+
+func (d *recordingDelivery) Ack(context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.acks++
+	return nil
+}
+
+func (d *recordingDelivery) Nack(_ context.Context, reason error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nacked = append(d.nacked, reason)
+	return nil
+}
+
+func (d *recordingDelivery) DeadLetter(ctx context.Context, reason error) error {
+	return d.Nack(ctx, reason)
+}
+
+func (d *recordingDelivery) settled() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.acks, len(d.nacked)
+}
+
+// matchingMessage is a delivery of the matching request of a booking, envelope nil makes a new one
+func matchingMessage(t *testing.T, bookingID uuid.UUID, envelope *queue.Envelope) (queue.Message[domain.DriverMatchingRequested], *recordingDelivery) {
+	t.Helper()
+	payload := domain.DriverMatchingRequested{BookingID: bookingID}
+	if envelope == nil {
+		created, err := queue.NewEnvelope(context.Background(), payload)
+		require.NoError(t, err)
+		envelope = &created
+	}
+	delivery := &recordingDelivery{envelope: *envelope}
+	return queue.Message[domain.DriverMatchingRequested]{Delivery: delivery, Payload: payload}, delivery
+}
+
+func TestBatchMatchingCollect(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("A redelivery of the held message replaces it", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+		matcher := newBatchMatcher(p)
+
+		first, firstDelivery := matchingMessage(t, booking.ID, nil)
+		envelope := first.Envelope()
+		again, againDelivery := matchingMessage(t, booking.ID, &envelope)
+		matcher.collect(ctx, first)
+		matcher.collect(ctx, again)
+
+		// Acking the stale delivery would delete the row the batch holds on durable queues
+		acks, nacks := firstDelivery.settled()
+		require.Zero(t, acks)
+		require.Zero(t, nacks)
+
+		matcher.runBatch(ctx)
+		require.Equal(t, []uuid.UUID{booking.ID}, p.notifications.offersTo(driver.ID))
+		acks, _ = againDelivery.settled()
+		require.Equal(t, 1, acks)
+		acks, _ = firstDelivery.settled()
+		require.Zero(t, acks)
+	})
+
+	t.Run("Another message for a held booking is acked", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+		matcher := newBatchMatcher(p)
+
+		held, heldDelivery := matchingMessage(t, booking.ID, nil)
+		other, otherDelivery := matchingMessage(t, booking.ID, nil)
+		matcher.collect(ctx, held)
+		matcher.collect(ctx, other)
+
+		acks, _ := otherDelivery.settled()
+		require.Equal(t, 1, acks)
+		acks, _ = heldDelivery.settled()
+		require.Zero(t, acks)
+		require.Len(t, matcher.pending, 1)
+		require.Equal(t, held.Envelope().ID, matcher.pending[booking.ID].message.Envelope().ID)
+	})
+}
+
+func TestBatchMatchingRetries(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("A booking that lost its driver waits for the next batch", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		first := p.addDriver(t, "first", near(1))
+		passenger := p.addPassenger(t, "passenger")
+		bookings := []uuid.UUID{p.addBooking(t, passenger, testPickup).ID, p.addBooking(t, passenger, testPickup).ID}
+		matcher := newBatchMatcher(p)
+
+		deliveries := make(map[uuid.UUID]*recordingDelivery)
+		for _, bookingID := range bookings {
+			message, delivery := matchingMessage(t, bookingID, nil)
+			deliveries[bookingID] = delivery
+			matcher.collect(ctx, message)
+		}
+
+		matcher.runBatch(ctx)
+		offered := p.notifications.offersTo(first.ID)
+		require.Len(t, offered, 1)
+		waiting := bookings[0]
+		if offered[0] == waiting {
+			waiting = bookings[1]
+		}
+		require.Len(t, matcher.pending, 1)
+		require.Equal(t, 1, matcher.pending[waiting].attempts)
+		acks, nacks := deliveries[waiting].settled()
+		require.Zero(t, acks+nacks)
+
+		// A driver right at the pickup joins before the next tick
+		second := p.addDriver(t, "second", testPickup)
+		matcher.runBatch(ctx)
+		require.Equal(t, []uuid.UUID{waiting}, p.notifications.offersTo(second.ID))
+		require.Empty(t, matcher.pending)
+		acks, _ = deliveries[waiting].settled()
+		require.Equal(t, 1, acks)
+	})
+
+	t.Run("A booking that never gets a driver is given up on after the last batch", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		passenger := p.addPassenger(t, "passenger")
+		losing := p.addBooking(t, passenger, testPickup)
+		matcher := newBatchMatcher(p)
+
+		message, delivery := matchingMessage(t, losing.ID, nil)
+		matcher.collect(ctx, message)
+
+		// Every batch a booking right at the driver takes them
+		for attempt := 1; attempt <= batchMaxAttempts; attempt++ {
+			closer, _ := matchingMessage(t, p.addBooking(t, passenger, near(1)).ID, nil)
+			matcher.collect(ctx, closer)
+			matcher.runBatch(ctx)
+
+			acks, nacks := delivery.settled()
+			if attempt < batchMaxAttempts {
+				require.Equal(t, attempt, matcher.pending[losing.ID].attempts)
+				require.Zero(t, acks+nacks)
+				continue
+			}
+			// Like in greedy mode, finding no driver isn't an error
+			require.Empty(t, matcher.pending)
+			require.Equal(t, 1, acks)
+			require.Zero(t, nacks)
+		}
+		require.NotContains(t, p.notifications.offersTo(driver.ID), losing.ID)
+	})
+
+	t.Run("A booking that keeps failing is handed back to the queue", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		matcher := newBatchMatcher(p)
+		sub, err := queue.Subscribe[domain.DriverMatchingRequested](p.queue, domain.TopicDriverMatching, domain.GroupDriverMatching)
+		require.NoError(t, err)
+		defer sub.Unsubscribe()
+
+		// The booking can't be fetched, every batch fails on it
+		envelope, err := queue.NewEnvelope(ctx, domain.DriverMatchingRequested{BookingID: uuid.New()})
+		require.NoError(t, err)
+		require.NoError(t, p.queue.Publish(ctx, domain.TopicDriverMatching, envelope))
+		select {
+		case message := <-sub.Messages():
+			matcher.collect(ctx, message)
+		case <-time.After(time.Second):
+			t.Fatal("message not delivered")
+		}
+
+		for range batchMaxAttempts - 1 {
+			matcher.runBatch(ctx)
+			require.Len(t, matcher.pending, 1)
+			require.Empty(t, p.deadLetters.all())
+		}
+		matcher.runBatch(ctx)
+		require.Empty(t, matcher.pending)
+		require.Len(t, p.deadLetters.all(), 1)
+	})
+}
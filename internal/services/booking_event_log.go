@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/services/queue"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Consumer group of the booking event log
+const groupBookingEventLog = "booking-event-log"
+
+// BookingEventLog writes every booking lifecycle event to the log, an audit trail of what
+// happened to a booking that can be followed by its correlation ID
+type BookingEventLog interface {
+	// StartConsuming subscribes to the lifecycle topics until ctx is cancelled. The consumers are
+	// counted in running.
+	StartConsuming(ctx context.Context, running *sync.WaitGroup) error
+}
+
+type bookingEventLog struct {
+	queue queue.MessageQueue
+}
+
+func NewBookingEventLog(messageQueue queue.MessageQueue) BookingEventLog {
+	return &bookingEventLog{queue: messageQueue}
+}
+
+func (l *bookingEventLog) StartConsuming(ctx context.Context, running *sync.WaitGroup) error {
+	for _, topic := range domain.BookingEventTopics {
+		sub, err := l.queue.Subscribe(topic, groupBookingEventLog)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to topic %s: %w", topic, err)
+		}
+		go func() {
+			<-ctx.Done()
+			sub.Unsubscribe()
+		}()
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			l.consume(ctx, topic, sub)
+		}()
+	}
+	log.Info().Int("topics", len(domain.BookingEventTopics)).Msg("Booking event log started")
+	return nil
+}
+
+func (l *bookingEventLog) consume(ctx context.Context, topic string, sub queue.Subscription) {
+	ackCtx := context.WithoutCancel(ctx)
+	for delivery := range sub.Deliveries() {
+		envelope := delivery.Envelope()
+
+		// Every lifecycle event starts with the common booking fields
+		var event domain.BookingEvent
+		if err := json.Unmarshal(envelope.Payload, &event); err != nil {
+			log.Warn().Err(err).Str("topic", topic).Str("event_id", envelope.ID.String()).Msg("Dead-lettering undecodable booking event")
+			_ = delivery.DeadLetter(ackCtx, err)
+			continue
+		}
+
+		entry := log.Info().
+			Str("event", envelope.Type).
+			Str("event_id", envelope.ID.String()).
+			Str("correlation_id", envelope.CorrelationID).
+			Str("booking_id", event.BookingID.String()).
+			Str("status", event.Status).
+			Time("occurred_at", envelope.OccurredAt)
+		if event.DriverID != nil {
+			entry = entry.Str("driver_id", event.DriverID.String())
+		}
+		entry.Msg("Booking event")
+
+		if err := delivery.Ack(ackCtx); err != nil {
+			log.Error().Err(err).Str("event_id", envelope.ID.String()).Msg("Failed to ack booking event")
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/queue"
+)
+
+// bookingLifecycleEvent is a booking event that knows its topic
+type bookingLifecycleEvent interface {
+	queue.Event
+	Topic() string
+}
+
+// addBookingEvent writes a lifecycle event to the outbox. Called in the transaction of the change
+// it reports, subscribers only hear about changes that were committed.
+func addBookingEvent(ctx context.Context, outboxRepo repositories.OutboxRepository, event bookingLifecycleEvent) error {
+	return addOutboxEvent(ctx, outboxRepo, event.Topic(), event)
+}
+
+// newBookingEvent captures the booking after a change
+func newBookingEvent(booking *models.Booking) domain.BookingEvent {
+	return domain.BookingEvent{
+		BookingID:   booking.ID,
+		PassengerID: booking.PassengerId,
+		DriverID:    booking.DriverId,
+		Status:      booking.Status.String(),
+	}
+}
@@ -3,15 +3,32 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"CabBookingService/internal/domain"
 	"CabBookingService/internal/models"
 	"CabBookingService/internal/repositories"
-	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/services/geocoding"
+	"CabBookingService/internal/services/scheduling"
+	"CabBookingService/internal/util"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	maxBookingStops = 3 // Intermediate stops a passenger can add to a booking
+)
+
+var (
+	ErrBookingNotOwned             = errors.New("booking does not belong to this passenger")
+	ErrTooManyStops                = fmt.Errorf("a booking can have at most %d stops", maxBookingStops)
+	ErrDestinationChangeNotAllowed = errors.New("destination can only be changed while the ride is in progress")
+	ErrRescheduleNotAllowed        = errors.New("only scheduled bookings can be rescheduled")
+	ErrBookingChanged              = errors.New("the booking changed in the meantime, reload it and try again")
+	ErrOccurrenceTaken             = errors.New("another ride of this series is already booked at that time")
 )
 
 // Define the parameter struct
@@ -23,17 +40,48 @@ type CreateBookingParams struct {
 	DropoffLatitude    float64
 	DropoffLongitude   float64
 	ScheduledTime      *time.Time
+	// Saved places of the passenger, when set they take precedence over the coordinates
+	PickupPlaceID  *uuid.UUID
+	DropoffPlaceID *uuid.UUID
+	// Intermediate stops in visiting order, the drop-off stays the final destination
+	Stops []models.ExactLocation
+	// Requested vehicle class, defaults to ECONOMY
+	CarType models.CarType
+	// Preferences of this ride, the passenger's defaults are used when nil
+	Preferences *models.RidePreferences
+	// Offer the ride without the optional preferences if no driver meets them
+	AllowPreferenceFallback bool
+	// Share the ride with other passengers going the same way
+	Shared bool
+	// Set when the scheduler books an occurrence of a recurring ride
+	RecurringRideID *uuid.UUID
+	// The scheduled time is validated against this instead of the current time when set. The scheduler
+	// passes the time of its tick, which it picked the occurrences by.
+	RequestedAt time.Time
 	// Easy to add new fields later without breaking function signature
 }
 
+type ChangeDestinationParams struct {
+	Latitude  float64
+	Longitude float64
+	PlaceID   *uuid.UUID // Saved place of the passenger, takes precedence over the coordinates
+}
+
 type BookingService interface {
 	CreateBooking(ctx context.Context, params CreateBookingParams) (*models.Booking, error)
 	AcceptBooking(ctx context.Context, driverAccountID, bookingID uuid.UUID) error
+	DeclineBooking(ctx context.Context, driverAccountID, bookingID uuid.UUID) error
 	CancelBooking(ctx context.Context, driverAccountID, bookingID uuid.UUID) error
 	StartRide(ctx context.Context, driverAccountID, bookingID uuid.UUID, otpCode string) error
 	EndRide(ctx context.Context, driverAccountID, bookingID uuid.UUID) error
 	RateRide(ctx context.Context, bookingID uuid.UUID, rating int, note string, isPassenger bool) error
 	GetPendingRides(ctx context.Context, driverAccountID uuid.UUID, limit, offset int) ([]models.Booking, error)
+	// GetPassengerBooking returns a booking of the passenger, ErrBookingNotOwned if it belongs to someone else
+	GetPassengerBooking(ctx context.Context, passengerAccountID, bookingID uuid.UUID) (*models.Booking, error)
+	// ChangeDestination moves the drop-off of a ride in progress and lets the driver know
+	ChangeDestination(ctx context.Context, passengerAccountID, bookingID uuid.UUID, params ChangeDestinationParams) (*models.Booking, error)
+	// RescheduleBooking moves the pickup of a SCHEDULED booking. A driver who reserved it is released.
+	RescheduleBooking(ctx context.Context, passengerAccountID, bookingID uuid.UUID, scheduledTime time.Time) (*models.Booking, error)
 
 	// TODO: Move to DriverService?
 	ToggleDriverAvailability(ctx context.Context, driverAccountID uuid.UUID, available bool) error
@@ -46,10 +94,19 @@ type bookingService struct {
 	driverRepo      repositories.DriverRepository
 	passengerRepo   repositories.PassengerRepository
 	reviewRepo      repositories.ReviewRepository
+	savedPlaceRepo  repositories.SavedPlaceRepository
 	otpService      OTPService
 	locationService LocationService
 	paymentService  PaymentService
-	messageQueue    queue.MessageQueue
+	geofenceService GeofenceService
+	trackingService RideTrackingService
+	geocoder        geocoding.Geocoder
+	notifications   NotificationService
+	offerService    OfferService
+	poolingService  PoolingService
+	transactor      repositories.Transactor
+	outboxRepo      repositories.OutboxRepository
+	schedulingRules scheduling.Rules
 }
 
 func NewBookingService(
@@ -57,46 +114,138 @@ func NewBookingService(
 	driverRepo repositories.DriverRepository,
 	passengerRepo repositories.PassengerRepository,
 	reviewRepo repositories.ReviewRepository,
+	savedPlaceRepo repositories.SavedPlaceRepository,
 	otpService OTPService,
 	locationService LocationService,
 	paymentService PaymentService,
-	messageQueue queue.MessageQueue,
+	geofenceService GeofenceService,
+	trackingService RideTrackingService,
+	geocoder geocoding.Geocoder,
+	notifications NotificationService,
+	offerService OfferService,
+	poolingService PoolingService,
+	transactor repositories.Transactor,
+	outboxRepo repositories.OutboxRepository,
+	schedulingRules scheduling.Rules,
 ) BookingService {
 	return &bookingService{
 		bookingRepo:     bookingRepo,
 		driverRepo:      driverRepo,
 		passengerRepo:   passengerRepo,
 		reviewRepo:      reviewRepo,
+		savedPlaceRepo:  savedPlaceRepo,
 		otpService:      otpService,
 		locationService: locationService,
 		paymentService:  paymentService,
-		messageQueue:    messageQueue,
+		geofenceService: geofenceService,
+		trackingService: trackingService,
+		geocoder:        geocoder,
+		notifications:   notifications,
+		offerService:    offerService,
+		poolingService:  poolingService,
+		transactor:      transactor,
+		outboxRepo:      outboxRepo,
+		schedulingRules: schedulingRules,
 	}
 }
 
 // CreateBooking Passenger requests a ride
 func (b *bookingService) CreateBooking(ctx context.Context, params CreateBookingParams) (*models.Booking, error) {
+	if len(params.Stops) > maxBookingStops {
+		return nil, ErrTooManyStops
+	}
+	if params.Shared && len(params.Stops) > 0 {
+		return nil, ErrSharedRideWithStops
+	}
+	carType, err := normalizeCarType(params.CarType)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if params.ScheduledTime != nil {
+		requestedAt := params.RequestedAt
+		if requestedAt.IsZero() {
+			requestedAt = now
+		}
+		if err := b.schedulingRules.Validate(*params.ScheduledTime, requestedAt); err != nil {
+			return nil, err
+		}
+	}
+
 	// 1. Get Passenger Profile from Account ID
 	passenger, err := b.passengerRepo.GetByAccountID(ctx, params.PassengerAccountID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2. Generate OTP for ride start
-	otp, err := b.otpService.GenerateOTP(ctx, passenger.PhoneNumber)
+	// 2. Preferences of the ride. Wheelchair access needs an accessible car.
+	preferences := passenger.Preferences
+	if params.Preferences != nil {
+		preferences = *params.Preferences
+	}
+	if err := validatePreferences(preferences); err != nil {
+		return nil, err
+	}
+	if preferences.WheelchairAccess {
+		switch carType {
+		case models.CarTypeEconomy:
+			carType = models.CarTypeAccessible
+		case models.CarTypeAccessible:
+		default:
+			return nil, fmt.Errorf("%w: wheelchair access needs an %s car", ErrInvalidPreferences, models.CarTypeAccessible)
+		}
+	}
+
+	// 3. Resolve saved places into coordinates and addresses
+	var pickupAddress, dropoffAddress string
+	if params.PickupPlaceID != nil {
+		place, err := getPassengerPlace(ctx, b.savedPlaceRepo, passenger.ID, *params.PickupPlaceID)
+		if err != nil {
+			return nil, err
+		}
+		params.PickupLatitude, params.PickupLongitude, pickupAddress = place.Latitude, place.Longitude, place.Address
+	}
+	if params.DropoffPlaceID != nil {
+		place, err := getPassengerPlace(ctx, b.savedPlaceRepo, passenger.ID, *params.DropoffPlaceID)
+		if err != nil {
+			return nil, err
+		}
+		params.DropoffLatitude, params.DropoffLongitude, dropoffAddress = place.Latitude, place.Longitude, place.Address
+	}
+
+	// 4. Validate pickup and drop-off against service areas and restricted zones
+	zones, err := b.geofenceService.ValidateTrip(ctx,
+		params.PickupLatitude, params.PickupLongitude,
+		params.DropoffLatitude, params.DropoffLongitude,
+	)
 	if err != nil {
 		return nil, err
 	}
+	if err := b.geofenceService.ValidateDropoffs(ctx, params.Stops); err != nil {
+		return nil, err
+	}
 
-	now := time.Now()
+	// 5. Addresses for receipts and history
+	if pickupAddress == "" {
+		pickupAddress = reverseGeocode(ctx, b.geocoder, params.PickupLatitude, params.PickupLongitude)
+	}
+	if dropoffAddress == "" {
+		dropoffAddress = reverseGeocode(ctx, b.geocoder, params.DropoffLatitude, params.DropoffLongitude)
+	}
+
+	// 6. Generate OTP for ride start
+	otp, err := b.otpService.GenerateOTP(ctx, passenger.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
 
 	status := models.BookingStatusRequested
-	// If scheduled time is > 20 mins from now, set as SCHEDULED
-	if params.ScheduledTime != nil && params.ScheduledTime.After(now.Add(20*time.Minute)) {
+	// A scheduled time is at least the minimum lead ahead, the ride waits for the scheduler
+	if params.ScheduledTime != nil {
 		status = models.BookingStatusScheduled
 	}
 
-	// 3. Create Booking
+	// 7. Create Booking
 	booking := &models.Booking{
 		BaseModel: models.BaseModel{
 			ID:        uuid.New(),
@@ -111,10 +260,64 @@ func (b *bookingService) CreateBooking(ctx context.Context, params CreateBooking
 		PickupLongitude:  params.PickupLongitude,
 		DropoffLatitude:  params.DropoffLatitude,
 		DropoffLongitude: params.DropoffLongitude,
+		PickupAddress:    pickupAddress,
+		DropoffAddress:   dropoffAddress,
+		City:             zones.City,
+		CarType:          carType,
+		IsShared:         params.Shared,
 		ScheduledTime:    params.ScheduledTime,
+		RecurringRideId:  params.RecurringRideID,
+
+		Preferences:             preferences,
+		AllowPreferenceFallback: params.AllowPreferenceFallback,
+	}
+	for i, stop := range params.Stops {
+		booking.Stops = append(booking.Stops, models.BookingStop{
+			BaseModel: models.BaseModel{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			BookingId: booking.ID,
+			Sequence:  i + 1,
+			Latitude:  stop.Latitude,
+			Longitude: stop.Longitude,
+			Address:   reverseGeocode(ctx, b.geocoder, stop.Latitude, stop.Longitude),
+		})
 	}
 
-	if err := b.bookingRepo.Create(ctx, booking); err != nil {
+	// --- ASYNC LOGIC ---
+	// Instead of calling location service directly, the booking is matched by a queue consumer.
+	// This makes the API response fast (Fire and Forget)
+	// The matching event goes to the outbox in the same transaction as the booking, so a booking is
+	// never left unmatched because publishing failed or the process died right after the insert.
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := b.bookingRepo.Create(ctx, booking); err != nil {
+			return err
+		}
+		err := addBookingEvent(ctx, b.outboxRepo, domain.BookingCreated{
+			BookingEvent:    newBookingEvent(booking),
+			City:            booking.City,
+			CarType:         booking.CarType.String(),
+			Shared:          booking.IsShared,
+			ScheduledTime:   booking.ScheduledTime,
+			RecurringRideID: booking.RecurringRideId,
+		})
+		if err != nil {
+			return err
+		}
+		// Only match now if status is REQUESTED
+		// For scheduled rides, we rely on SchedulingService to pick them up later
+		// when their scheduled time is near.
+		if booking.Status == models.BookingStatusScheduled {
+			return addBookingEvent(ctx, b.outboxRepo, domain.BookingScheduled{
+				BookingEvent:  newBookingEvent(booking),
+				ScheduledTime: *booking.ScheduledTime,
+			})
+		}
+		return addOutboxEvent(ctx, b.outboxRepo, domain.TopicDriverMatching, domain.DriverMatchingRequested{BookingID: booking.ID})
+	})
+	if err != nil {
 		log.Error().Err(err).Msg("Failed to create booking record")
 		return nil, err
 	}
@@ -123,26 +326,9 @@ func (b *bookingService) CreateBooking(ctx context.Context, params CreateBooking
 	log.Info().
 		Str("booking_id", booking.ID.String()).
 		Str("passenger_id", passenger.ID.String()).
+		Str("car_type", booking.CarType.String()).
 		Msg("Booking created successfully")
 
-	// --- ASYNC LOGIC ---
-	// Instead of calling location service directly, we push to queue
-	// This makes the API response fast (Fire and Forget)
-	// Only push to queue if status is REQUESTED
-	if booking.Status == models.BookingStatusRequested {
-		if err := b.messageQueue.Publish(ctx, domain.TopicDriverMatching, booking.ID); err != nil {
-			// Log error but don't fail request
-			log.Error().Err(err).
-				Str("booking_id", booking.ID.String()).
-				Msg("Failed to publish driver matching event")
-			// Don't fail the request, just log it (or implement retry)
-		}
-	}
-
-	// For scheduled rides, we rely on SchedulingService to pick them up later
-	// when their scheduled time is near.
-	// So no need to push to queue now.
-
 	return booking, nil
 }
 
@@ -154,17 +340,20 @@ func (b *bookingService) AcceptBooking(ctx context.Context, driverAccountID, boo
 		return err
 	}
 
-	// 2. Check Permissions (Security) - Check if this driver was actually notified/authorized for this ride
-	allowed, err := b.bookingRepo.IsDriverNotified(ctx, bookingID, driver.ID)
-	if err != nil {
-		return errors.New("system error checking permissions")
-	}
-	if !allowed {
-		log.Warn().
-			Str("driver_id", driver.ID.String()).
-			Str("booking_id", bookingID.String()).
-			Msg("Driver attempted to accept booking without the ride assignment to them")
-		return errors.New("you are not authorized to accept this ride")
+	// 2. Check Permissions (Security) - Check if this driver holds a live offer for this ride
+	if err := b.offerService.CheckOffer(ctx, bookingID, driver.ID); err != nil {
+		switch {
+		case errors.Is(err, ErrNotOfferedToDriver):
+			log.Warn().
+				Str("driver_id", driver.ID.String()).
+				Str("booking_id", bookingID.String()).
+				Msg("Driver attempted to accept booking without the ride assignment to them")
+			return err
+		case errors.Is(err, ErrOfferExpired), errors.Is(err, ErrOfferDeclined):
+			return err
+		default:
+			return errors.New("system error checking permissions")
+		}
 	}
 
 	// 3. Get Booking by ID
@@ -185,15 +374,109 @@ func (b *bookingService) AcceptBooking(ctx context.Context, driverAccountID, boo
 		return err
 	}
 
-	// 3. Accept Booking Transactionally (accept and assign driver, mark driver unavailable)
-	if err := b.bookingRepo.AcceptBookingTransaction(ctx, bookingID, driver.ID, otp.ID); err != nil {
+	// 6. Accept Booking Transactionally (accept and assign driver, mark driver unavailable, join the shared trip).
+	// The driver row stays locked until commit, so concurrent accepts by the same driver plan against
+	// the trip and current ride the other one left behind.
+	var queuedBehind *uuid.UUID
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := b.driverRepo.LockForUpdate(ctx, driver.ID); err != nil {
+			return err
+		}
+		// The offer may have run out since the check above
+		if err := b.offerService.AcceptOffer(ctx, bookingID, driver.ID); err != nil {
+			return err
+		}
+
+		// Shared rides must still fit the driver's trip
+		var tripPlan *TripPlan
+		if booking.IsShared {
+			var err error
+			if tripPlan, err = b.poolingService.PlanJoin(ctx, booking, driver); err != nil {
+				return err
+			}
+		}
+
+		// A driver finishing a solo ride gets this one queued as their next ride
+		if !booking.IsShared {
+			current, err := b.bookingRepo.GetActiveBookingForDriver(ctx, driver.ID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if current != nil && current.Status == models.BookingStatusStarted && !current.IsShared {
+				queuedBehind = &current.ID
+			}
+		}
+
+		// The current ride may have ended since it was read, the booking is then not chained
+		chained, err := b.bookingRepo.AcceptBookingTransaction(ctx, bookingID, driver.ID, otp.ID, queuedBehind)
+		if err != nil {
+			return err
+		}
+		if !chained {
+			queuedBehind = nil
+		}
+		if tripPlan != nil {
+			if err := b.poolingService.JoinTrip(ctx, tripPlan); err != nil {
+				return err
+			}
+		}
+
+		booking.Status = models.BookingStatusAccepted
+		booking.DriverId = &driver.ID
+		booking.QueuedBehindBookingId = queuedBehind
+		return addBookingEvent(ctx, b.outboxRepo, domain.BookingAccepted{
+			BookingEvent:          newBookingEvent(booking),
+			QueuedBehindBookingID: queuedBehind,
+		})
+	})
+	if err != nil {
 		return err
 	}
 	log.Info().
 		Str("booking_id", bookingID.String()).
 		Str("driver_id", driver.ID.String()).
+		Bool("chained", queuedBehind != nil).
 		Msg("Booking accepted by driver")
 
+	// Let the passenger know a driver is on the way
+	booking.Driver = driver
+	b.trackingService.PublishProgress(ctx, booking)
+
+	return nil
+}
+
+// DeclineBooking Driver turns down a ride offer
+func (b *bookingService) DeclineBooking(ctx context.Context, driverAccountID, bookingID uuid.UUID) error {
+	// 1. Get Driver Profile from Account ID
+	driver, err := b.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return err
+	}
+
+	// 2. Decline and report it together, so a decline is never missing from the event log
+	var sequential bool
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		var err error
+		if sequential, err = b.offerService.DeclineOffer(ctx, bookingID, driver.ID); err != nil {
+			return err
+		}
+		booking, err := b.bookingRepo.GetByID(ctx, bookingID)
+		if err != nil {
+			return err
+		}
+		return addBookingEvent(ctx, b.outboxRepo, domain.BookingDeclined{
+			BookingEvent:       newBookingEvent(booking),
+			DeclinedByDriverID: driver.ID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// 3. Sequential offers move on to the next driver once the decline is committed
+	if sequential {
+		b.offerService.AdvanceSequence(ctx, bookingID)
+	}
 	return nil
 }
 
@@ -216,15 +499,32 @@ func (b *bookingService) CancelBooking(ctx context.Context, driverAccountID, boo
 		return errors.New("driver not assigned to this booking")
 	}
 
-	if booking.Status.IsCancellable() {
+	if !booking.Status.IsCancellable() {
 		return errors.New("booking cannot be cancelled at this stage")
 	}
 
-	// 4. Update Booking Status to REQUESTED and remove Driver assignment
+	// 4. A ride the driver reserved is close to its pickup by now, it goes back to open matching
+	// instead of leaving the passenger without a ride
+	if booking.ReservedDriverId != nil && *booking.ReservedDriverId == driver.ID {
+		return b.escalateReservedBooking(ctx, driver, booking)
+	}
+
+	// 5. Update Booking Status to CANCELLED and remove Driver assignment
 	booking.Status = models.BookingStatusCancelled
 	booking.DriverId = nil
+	booking.Driver = nil
 
-	if err := b.bookingRepo.Update(ctx, booking); err != nil {
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := b.bookingRepo.Update(ctx, booking); err != nil {
+			return err
+		}
+		return addBookingEvent(ctx, b.outboxRepo, domain.BookingCancelled{
+			BookingEvent:      newBookingEvent(booking),
+			CancelledBy:       domain.RoleDriver,
+			CancelledDriverID: &driver.ID,
+		})
+	})
+	if err != nil {
 		return err
 	}
 
@@ -232,10 +532,76 @@ func (b *bookingService) CancelBooking(ctx context.Context, driverAccountID, boo
 		Str("booking_id", booking.ID.String()).
 		Str("driver_id", driver.ID.String()).
 		Msg("Booking cancelled by driver")
+	b.trackingService.PublishProgress(ctx, booking)
 
 	// TODO: Notify Passenger about cancellation
-	// TODO: Re-emit event to "DriverMatchingService" to find another driver
 
+	if booking.IsShared {
+		tripDone, err := b.poolingService.LeaveTrip(ctx, booking)
+		if err != nil {
+			return err
+		}
+		if !tripDone {
+			// Other riders are still on the trip
+			return nil
+		}
+	}
+	if booking.QueuedBehindBookingId != nil {
+		// The driver is still busy with the ride this one was chained after
+		return nil
+	}
+	return b.driverRepo.UpdateAvailability(ctx, driver.ID, true)
+}
+
+// escalateReservedBooking takes the reserved driver off a booking they cancelled and sends it to
+// open matching
+func (b *bookingService) escalateReservedBooking(ctx context.Context, driver *models.Driver, booking *models.Booking) error {
+	from := booking.Status
+	booking.Status = models.BookingStatusRequested
+	booking.DriverId = nil
+	booking.Driver = nil
+	booking.ReservedDriverId = nil
+	booking.ReservedAt = nil
+	booking.DriverArrivedAt = nil
+
+	err := b.transactor.InTx(ctx, func(ctx context.Context) error {
+		// Only from the status read above, the passenger may have cancelled or the ride started since
+		returned, err := b.bookingRepo.ReturnReservedToMatching(ctx, booking.ID, driver.ID, from)
+		if err != nil {
+			return err
+		}
+		if !returned {
+			return ErrBookingChanged
+		}
+		err = addBookingEvent(ctx, b.outboxRepo, domain.BookingDriverReleased{
+			BookingEvent:     newBookingEvent(booking),
+			ReleasedDriverID: driver.ID,
+			Reason:           domain.ReleaseReasonDriverCancelled,
+		})
+		if err != nil {
+			return err
+		}
+		return addOutboxEvent(ctx, b.outboxRepo, domain.TopicDriverMatching, domain.DriverMatchingRequested{BookingID: booking.ID})
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Str("driver_id", driver.ID.String()).
+		Msg("Reserved booking cancelled by driver, queued for driver matching")
+	b.trackingService.PublishProgress(ctx, booking)
+
+	err = b.notifications.NotifyPassenger(ctx, &booking.Passenger, PassengerNotification{
+		Type:      PassengerNotificationDriverReleased,
+		BookingID: booking.ID,
+		Message:   fmt.Sprintf("%s can no longer take your ride, we are finding you another driver", driver.Name),
+	})
+	if err != nil {
+		// The passenger follows the booking's progress too, don't fail the cancellation
+		log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Failed to notify passenger about released driver")
+	}
 	return b.driverRepo.UpdateAvailability(ctx, driver.ID, true)
 }
 
@@ -258,7 +624,8 @@ func (b *bookingService) StartRide(ctx context.Context, driverAccountID, booking
 		return errors.New("driver not assigned to this booking")
 	}
 
-	if booking.Status != models.BookingStatusAccepted {
+	// Drivers may start before the arrival was detected (e.g. poor GPS at the pickup)
+	if !booking.Status.IsDriverEnRoute() {
 		return errors.New("booking is not in accepted status")
 	}
 
@@ -271,12 +638,28 @@ func (b *bookingService) StartRide(ctx context.Context, driverAccountID, booking
 		return errors.New("invalid OTP code")
 	}
 
-	// 5. Update Booking Status to STARTED
+	// 5. Update Booking Status to STARTED, this also stops the waiting time
 	booking.Status = models.BookingStatusStarted
-	if err := b.bookingRepo.Update(ctx, booking); err != nil {
+	booking.RideStartedAt = util.Ptr(time.Now())
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := b.bookingRepo.Update(ctx, booking); err != nil {
+			return err
+		}
+		return addBookingEvent(ctx, b.outboxRepo, domain.BookingStarted{
+			BookingEvent: newBookingEvent(booking),
+			StartedAt:    *booking.RideStartedAt,
+		})
+	})
+	if err != nil {
 		return err
 	}
 	log.Info().Str("booking_id", bookingID.String()).Msg("Ride started")
+	if booking.IsShared {
+		if err := b.poolingService.PickedUp(ctx, booking); err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Failed to record shared pickup")
+		}
+	}
+	b.trackingService.PublishProgress(ctx, booking)
 	return nil
 }
 
@@ -305,7 +688,16 @@ func (b *bookingService) EndRide(ctx context.Context, driverAccountID, bookingID
 
 	// 4. Update Booking Status to COMPLETED
 	booking.Status = models.BookingStatusCompleted
-	if err := b.bookingRepo.Update(ctx, booking); err != nil {
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := b.bookingRepo.Update(ctx, booking); err != nil {
+			return err
+		}
+		return addBookingEvent(ctx, b.outboxRepo, domain.BookingCompleted{
+			BookingEvent: newBookingEvent(booking),
+			CompletedAt:  time.Now(),
+		})
+	})
+	if err != nil {
 		return err
 	}
 
@@ -313,16 +705,92 @@ func (b *bookingService) EndRide(ctx context.Context, driverAccountID, bookingID
 		Str("booking_id", bookingID.String()).
 		Str("driver_id", driver.ID.String()).
 		Msg("Ride completed by driver")
+	b.trackingService.PublishProgress(ctx, booking)
+
+	// The driver stays busy until every rider of a shared trip is dropped off.
+	// The drop-off must be recorded before the payment, shared fares depend on it.
+	tripDone := true
+	if booking.IsShared {
+		if tripDone, err = b.poolingService.DroppedOff(ctx, booking); err != nil {
+			return err
+		}
+	}
 
 	// 3. --- TRIGGER PAYMENT ---
-	// This happens asynchronously in real life, but sync here for simplicity
-	if err := b.paymentService.ProcessPayment(ctx, booking); err != nil {
+	// This happens asynchronously in real life, but sync here for simplicity.
+	// The fare needs the routing provider, it is worked out before the transaction.
+	receipt, err := b.paymentService.PrepareReceipt(ctx, booking)
+	if err == nil {
+		err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+			if err := b.paymentService.ProcessPayment(ctx, receipt); err != nil {
+				return err
+			}
+			return addBookingEvent(ctx, b.outboxRepo, domain.BookingPaid{
+				BookingEvent: newBookingEvent(booking),
+				ReceiptID:    receipt.ID,
+				Amount:       receipt.Amount,
+				Currency:     receipt.Currency,
+			})
+		})
+	}
+	if err != nil {
 		// Log error, but don't fail the ride completion.
 		// In real world, this would trigger a "Payment Failed" flow.
 		log.Error().Err(err).Msg("Payment processing failed")
 	}
 
-	return b.driverRepo.UpdateAvailability(ctx, driver.ID, true)
+	if !tripDone {
+		return nil
+	}
+
+	// A chained ride starts right away, the driver stays busy. The driver row stays locked, so a ride
+	// accepted meanwhile was either chained and is released here, or keeps the driver busy.
+	var next *models.Booking
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := b.driverRepo.LockForUpdate(ctx, driver.ID); err != nil {
+			return err
+		}
+		var err error
+		next, err = b.bookingRepo.ReleaseQueuedBooking(ctx, booking.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		busy := next != nil
+		if !busy {
+			_, err := b.bookingRepo.GetActiveBookingForDriver(ctx, driver.ID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			busy = err == nil
+		}
+		return b.driverRepo.MarkRideEnded(ctx, driver.ID, time.Now(), !busy)
+	})
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		b.startNextRide(ctx, driver, next)
+	}
+	return nil
+}
+
+// startNextRide sends the driver on to the ride chained after the one they just finished
+func (b *bookingService) startNextRide(ctx context.Context, driver *models.Driver, next *models.Booking) {
+	log.Info().
+		Str("booking_id", next.ID.String()).
+		Str("driver_id", driver.ID.String()).
+		Msg("Driver heading to chained ride")
+
+	err := b.notifications.NotifyDriver(ctx, driver, DriverNotification{
+		Type:      DriverNotificationNextRide,
+		BookingID: next.ID,
+		Message:   fmt.Sprintf("Head to your next pickup at %s", next.PickupAddress),
+	})
+	if err != nil {
+		// The next ride is also listed in the driver's active bookings, don't fail the drop-off
+		log.Error().Err(err).Str("booking_id", next.ID.String()).Msg("Failed to notify driver about next ride")
+	}
+	b.trackingService.PublishProgress(ctx, next)
 }
 
 func (b *bookingService) RateRide(ctx context.Context, bookingID uuid.UUID, rating int, note string, isPassenger bool) error {
@@ -348,9 +816,20 @@ func (b *bookingService) RateRide(ctx context.Context, bookingID uuid.UUID, rati
 		BookingId: booking.ID,
 	}
 
+	rated := domain.BookingRated{
+		BookingEvent: newBookingEvent(booking),
+		Rating:       rating,
+		ByPassenger:  isPassenger,
+	}
+
 	if isPassenger {
 		review.PassengerId = &booking.PassengerId
-		return b.bookingRepo.SaveReviewAndRecalculateDriverRating(ctx, bookingID, review)
+		return b.transactor.InTx(ctx, func(ctx context.Context) error {
+			if err := b.bookingRepo.SaveReviewAndRecalculateDriverRating(ctx, bookingID, review); err != nil {
+				return err
+			}
+			return addBookingEvent(ctx, b.outboxRepo, rated)
+		})
 	}
 
 	if booking.DriverId == nil {
@@ -361,7 +840,12 @@ func (b *bookingService) RateRide(ctx context.Context, bookingID uuid.UUID, rati
 		return errors.New("no driver assigned")
 	}
 	review.DriverID = booking.DriverId
-	return b.bookingRepo.SaveReviewAndRecalculatePassengerRating(ctx, bookingID, review)
+	return b.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := b.bookingRepo.SaveReviewAndRecalculatePassengerRating(ctx, bookingID, review); err != nil {
+			return err
+		}
+		return addBookingEvent(ctx, b.outboxRepo, rated)
+	})
 }
 
 func (b *bookingService) GetPendingRides(ctx context.Context, driverAccountID uuid.UUID, limit, offset int) ([]models.Booking, error) {
@@ -375,6 +859,166 @@ func (b *bookingService) GetPendingRides(ctx context.Context, driverAccountID uu
 	return b.bookingRepo.GetPendingBookingsForDriver(ctx, driver.ID, limit, offset)
 }
 
+func (b *bookingService) GetPassengerBooking(ctx context.Context, passengerAccountID, bookingID uuid.UUID) (*models.Booking, error) {
+	// 1. Get Passenger Profile from Account ID
+	passenger, err := b.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Get Booking by ID
+	booking, err := b.bookingRepo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Authorization Check
+	if booking.PassengerId != passenger.ID {
+		return nil, ErrBookingNotOwned
+	}
+	return booking, nil
+}
+
+func (b *bookingService) ChangeDestination(ctx context.Context, passengerAccountID, bookingID uuid.UUID, params ChangeDestinationParams) (*models.Booking, error) {
+	// 1. Get and authorize the Booking
+	booking, err := b.GetPassengerBooking(ctx, passengerAccountID, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	// The other riders of a shared trip agreed to the original route
+	if booking.Status != models.BookingStatusStarted || booking.IsShared {
+		return nil, ErrDestinationChangeNotAllowed
+	}
+
+	// 2. Resolve the new destination
+	var address string
+	if params.PlaceID != nil {
+		place, err := getPassengerPlace(ctx, b.savedPlaceRepo, booking.PassengerId, *params.PlaceID)
+		if err != nil {
+			return nil, err
+		}
+		params.Latitude, params.Longitude, address = place.Latitude, place.Longitude, place.Address
+	}
+	destination := models.ExactLocation{Latitude: params.Latitude, Longitude: params.Longitude}
+	if err := b.geofenceService.ValidateDropoffs(ctx, []models.ExactLocation{destination}); err != nil {
+		return nil, err
+	}
+	if address == "" {
+		address = reverseGeocode(ctx, b.geocoder, params.Latitude, params.Longitude)
+	}
+
+	// 3. Update the drop-off, only if the ride is still in progress
+	changed, err := b.bookingRepo.ChangeDestination(ctx, booking.ID, params.Latitude, params.Longitude, address)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, ErrDestinationChangeNotAllowed
+	}
+	booking.DropoffLatitude = params.Latitude
+	booking.DropoffLongitude = params.Longitude
+	booking.DropoffAddress = address
+
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Float64("dropoff_lat", params.Latitude).
+		Float64("dropoff_lon", params.Longitude).
+		Msg("Destination changed by passenger")
+
+	// 4. Tell the driver
+	if booking.Driver != nil {
+		err := b.notifications.NotifyDriver(ctx, booking.Driver, DriverNotification{
+			Type:      DriverNotificationDestinationChanged,
+			BookingID: booking.ID,
+			Message:   fmt.Sprintf("The passenger changed the destination to %s", address),
+		})
+		if err != nil {
+			// The driver still sees the new destination in the booking, don't fail the change
+			log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Failed to notify driver about destination change")
+		}
+	}
+	b.trackingService.PublishProgress(ctx, booking)
+
+	return booking, nil
+}
+
+func (b *bookingService) RescheduleBooking(ctx context.Context, passengerAccountID, bookingID uuid.UUID, scheduledTime time.Time) (*models.Booking, error) {
+	// 1. Get and authorize the Booking
+	booking, err := b.GetPassengerBooking(ctx, passengerAccountID, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if booking.Status != models.BookingStatusScheduled || booking.ScheduledTime == nil {
+		return nil, ErrRescheduleNotAllowed
+	}
+
+	// 2. The new pickup follows the same rules as a new booking
+	if err := b.schedulingRules.Validate(scheduledTime, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// 3. Move the pickup, only if the booking wasn't activated meanwhile. The reservation was made
+	// for the old time, the driver is released.
+	previous := *booking.ScheduledTime
+	reservedDriverID := booking.ReservedDriverId
+	err = b.transactor.InTx(ctx, func(ctx context.Context) error {
+		rescheduled, err := b.bookingRepo.RescheduleBooking(ctx, booking.ID, scheduledTime)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrOccurrenceTaken
+		}
+		if err != nil {
+			return err
+		}
+		if !rescheduled {
+			return ErrRescheduleNotAllowed
+		}
+		booking.ScheduledTime = &scheduledTime
+		booking.ReservedDriverId = nil
+		booking.ReservedAt = nil
+		booking.ReminderSentAt = nil
+
+		err = addBookingEvent(ctx, b.outboxRepo, domain.BookingRescheduled{
+			BookingEvent:          newBookingEvent(booking),
+			PreviousScheduledTime: previous,
+			ScheduledTime:         scheduledTime,
+		})
+		if err != nil || reservedDriverID == nil {
+			return err
+		}
+		return addBookingEvent(ctx, b.outboxRepo, domain.BookingDriverReleased{
+			BookingEvent:     newBookingEvent(booking),
+			ReleasedDriverID: *reservedDriverID,
+			Reason:           domain.ReleaseReasonRescheduled,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Time("previous_scheduled_time", previous).
+		Time("scheduled_time", scheduledTime).
+		Msg("Booking rescheduled by passenger")
+
+	// 4. Tell the released driver
+	if reservedDriverID != nil {
+		driver, err := b.driverRepo.GetByID(ctx, *reservedDriverID)
+		if err == nil {
+			err = b.notifications.NotifyDriver(ctx, driver, DriverNotification{
+				Type:      DriverNotificationReservationEnded,
+				BookingID: booking.ID,
+				Message:   "The passenger moved your reserved ride to another time, it is no longer reserved for you",
+			})
+		}
+		if err != nil {
+			// The ride is gone from the driver's reservations either way, don't fail the reschedule
+			log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Failed to notify driver about rescheduled ride")
+		}
+	}
+	return booking, nil
+}
+
 func (b *bookingService) ToggleDriverAvailability(ctx context.Context, driverAccountID uuid.UUID, available bool) error {
 	// 1. Get Driver Profile from Account ID
 	driver, err := b.driverRepo.GetByAccountID(ctx, driverAccountID)
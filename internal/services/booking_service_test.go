@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/services/scheduling"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (p *testPlatform) createBooking(t *testing.T, passenger *models.Passenger, scheduledTime *time.Time) *models.Booking {
+	t.Helper()
+	booking, err := p.bookingService.CreateBooking(context.Background(), CreateBookingParams{
+		PassengerAccountID: passenger.AccountId,
+		PickupLatitude:     testPickup.Latitude,
+		PickupLongitude:    testPickup.Longitude,
+		DropoffLatitude:    testPickup.Latitude + 0.05,
+		DropoffLongitude:   testPickup.Longitude + 0.05,
+		ScheduledTime:      scheduledTime,
+	})
+	require.NoError(t, err)
+	return booking
+}
+
+func TestBookingLifecycleEvents(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Every step of a ride is published", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		tap := p.tapEvents(t)
+		driver := p.addDriver(t, "driver", near(1))
+		declining := p.addDriver(t, "declining", near(2))
+		passenger := p.addPassenger(t, "passenger")
+
+		booking := p.createBooking(t, passenger, nil)
+		require.ElementsMatch(t, []string{"booking.created", "driver_matching.requested"}, p.relayed(t, tap))
+
+		p.offerService.OfferRide(ctx, booking, ranked(driver, declining))
+		require.NoError(t, p.bookingService.DeclineBooking(ctx, declining.AccountId, booking.ID))
+		require.Equal(t, []string{"booking.declined"}, p.relayed(t, tap))
+
+		require.NoError(t, p.bookingService.AcceptBooking(ctx, driver.AccountId, booking.ID))
+		require.Equal(t, []string{"booking.accepted"}, p.relayed(t, tap))
+
+		require.NoError(t, p.tracking.OnDriverLocationUpdate(ctx, driver.AccountId, testPickup))
+		require.Equal(t, []string{"booking.driver_arrived"}, p.relayed(t, tap))
+
+		otp, err := p.otpRepo.GetById(ctx, *p.booking(t, booking.ID).RideStartOTPId)
+		require.NoError(t, err)
+		require.NoError(t, p.bookingService.StartRide(ctx, driver.AccountId, booking.ID, otp.Code))
+		require.Equal(t, []string{"booking.started"}, p.relayed(t, tap))
+
+		require.NoError(t, p.bookingService.EndRide(ctx, driver.AccountId, booking.ID))
+		require.ElementsMatch(t, []string{"booking.completed", "booking.paid"}, p.relayed(t, tap))
+
+		require.NoError(t, p.bookingService.RateRide(ctx, booking.ID, 5, "", true))
+		require.Equal(t, []string{"booking.rated"}, p.relayed(t, tap))
+	})
+
+	t.Run("A scheduled booking is published when booked and when activated", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		tap := p.tapEvents(t)
+		passenger := p.addPassenger(t, "passenger")
+
+		pickupAt := time.Now().Add(30 * time.Minute)
+		booking := p.createBooking(t, passenger, &pickupAt)
+		require.ElementsMatch(t, []string{"booking.created", "booking.scheduled"}, p.relayed(t, tap))
+
+		// Due once the pickup is within the activation window
+		moved, err := p.bookingRepo.RescheduleBooking(ctx, booking.ID, time.Now().Add(10*time.Minute))
+		require.NoError(t, err)
+		require.True(t, moved)
+		claimed, _ := p.activateDue(t)
+		require.Equal(t, 1, claimed)
+		require.ElementsMatch(t, []string{"booking.activated", "driver_matching.requested"}, p.relayed(t, tap))
+	})
+
+	t.Run("Events carry the booking after the change", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		tap := p.tapEvents(t)
+		driver := p.addDriver(t, "driver", near(1))
+		passenger := p.addPassenger(t, "passenger")
+		booking := p.createBooking(t, passenger, nil)
+		p.relayed(t, tap)
+
+		p.offerService.OfferRide(ctx, booking, ranked(driver))
+		require.NoError(t, p.bookingService.AcceptBooking(ctx, driver.AccountId, booking.ID))
+		require.Equal(t, 1, p.relay.RelayDue(ctx))
+		envelope := <-tap.envelopes
+
+		accepted, err := queue.DecodePayload[domain.BookingAccepted](envelope)
+		require.NoError(t, err)
+		require.Equal(t, booking.ID, accepted.BookingID)
+		require.Equal(t, passenger.ID, accepted.PassengerID)
+		require.Equal(t, driver.ID, *accepted.DriverID)
+		require.Equal(t, models.BookingStatusAccepted.String(), accepted.Status)
+	})
+}
+
+func TestRescheduleBooking(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("The pickup moves and the reserved driver is released", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		passenger := p.addPassenger(t, "passenger")
+		booking := p.addScheduledBooking(t, passenger, time.Now().Add(time.Hour), driver)
+		tap := p.tapEvents(t)
+
+		pickupAt := time.Now().Add(3 * time.Hour)
+		rescheduled, err := p.bookingService.RescheduleBooking(ctx, passenger.AccountId, booking.ID, pickupAt)
+		require.NoError(t, err)
+		require.True(t, pickupAt.Equal(*rescheduled.ScheduledTime))
+
+		stored := p.booking(t, booking.ID)
+		require.Equal(t, models.BookingStatusScheduled, stored.Status)
+		require.True(t, pickupAt.Equal(*stored.ScheduledTime))
+		require.Nil(t, stored.ReservedDriverId)
+		require.Equal(t, []DriverNotificationType{DriverNotificationReservationEnded}, p.notifications.driverTypes(driver.ID))
+		require.ElementsMatch(t, []string{"booking.rescheduled", "booking.driver_released"}, p.relayed(t, tap))
+	})
+
+	t.Run("The new pickup follows the scheduling rules", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		passenger := p.addPassenger(t, "passenger")
+		booking := p.addScheduledBooking(t, passenger, time.Now().Add(time.Hour), nil)
+
+		_, err := p.bookingService.RescheduleBooking(ctx, passenger.AccountId, booking.ID, time.Now().Add(5*time.Minute))
+		require.ErrorIs(t, err, scheduling.ErrTooSoon)
+		_, err = p.bookingService.RescheduleBooking(ctx, passenger.AccountId, booking.ID, time.Now().Add(30*24*time.Hour))
+		require.ErrorIs(t, err, scheduling.ErrTooFar)
+	})
+
+	t.Run("Only a scheduled booking of the passenger can be rescheduled", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		passenger := p.addPassenger(t, "passenger")
+		pickupAt := time.Now().Add(2 * time.Hour)
+
+		scheduled := p.addScheduledBooking(t, passenger, time.Now().Add(time.Hour), nil)
+		_, err := p.bookingService.RescheduleBooking(ctx, p.addPassenger(t, "other").AccountId, scheduled.ID, pickupAt)
+		require.ErrorIs(t, err, ErrBookingNotOwned)
+
+		requested := p.addBooking(t, passenger, testPickup)
+		_, err = p.bookingService.RescheduleBooking(ctx, passenger.AccountId, requested.ID, pickupAt)
+		require.ErrorIs(t, err, ErrRescheduleNotAllowed)
+	})
+
+	t.Run("An occurrence can't be moved onto another one of its series", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		passenger := p.addPassenger(t, "passenger")
+		p.createRecurringRide(t, passenger, "09:00")
+		p.recurringRides.MaterializeDue(ctx)
+		bookings := p.scheduledBookings(t)
+		require.GreaterOrEqual(t, len(bookings), 2)
+
+		_, err := p.bookingService.RescheduleBooking(ctx, passenger.AccountId, bookings[1].ID, *bookings[0].ScheduledTime)
+		require.ErrorIs(t, err, ErrOccurrenceTaken)
+		require.True(t, bookings[1].ScheduledTime.Equal(*p.booking(t, bookings[1].ID).ScheduledTime))
+	})
+}
+
+func TestCancelBooking(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// accepted returns a booking the driver accepted
+	accepted := func(t *testing.T, p *testPlatform, driver *models.Driver) *models.Booking {
+		t.Helper()
+		booking := p.createBooking(t, p.addPassenger(t, "passenger"), nil)
+		p.offerService.OfferRide(ctx, booking, ranked(driver))
+		require.NoError(t, p.bookingService.AcceptBooking(ctx, driver.AccountId, booking.ID))
+		return booking
+	}
+
+	t.Run("The driver can cancel a ride before it started", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		booking := accepted(t, p, driver)
+		tap := p.tapEvents(t)
+		p.relayed(t, tap)
+
+		require.NoError(t, p.bookingService.CancelBooking(ctx, driver.AccountId, booking.ID))
+		cancelled := p.booking(t, booking.ID)
+		require.Equal(t, models.BookingStatusCancelled, cancelled.Status)
+		require.Nil(t, cancelled.DriverId)
+		stored, err := p.driverRepo.GetByID(ctx, driver.ID)
+		require.NoError(t, err)
+		require.True(t, stored.IsAvailable)
+		require.Equal(t, []string{"booking.cancelled"}, p.relayed(t, tap))
+	})
+
+	t.Run("A ride that started can't be cancelled", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		booking := accepted(t, p, driver)
+		otp, err := p.otpRepo.GetById(ctx, *p.booking(t, booking.ID).RideStartOTPId)
+		require.NoError(t, err)
+		require.NoError(t, p.bookingService.StartRide(ctx, driver.AccountId, booking.ID, otp.Code))
+
+		require.Error(t, p.bookingService.CancelBooking(ctx, driver.AccountId, booking.ID))
+		require.Equal(t, models.BookingStatusStarted, p.booking(t, booking.ID).Status)
+	})
+
+	t.Run("Only the assigned driver can cancel", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		booking := accepted(t, p, p.addDriver(t, "driver", near(1)))
+		other := p.addDriver(t, "other", near(2))
+
+		require.Error(t, p.bookingService.CancelBooking(ctx, other.AccountId, booking.ID))
+		require.Equal(t, models.BookingStatusAccepted, p.booking(t, booking.ID).Status)
+	})
+
+	t.Run("A reserved ride the driver cancels goes back to matching", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		booking := p.addScheduledBooking(t, p.addPassenger(t, "passenger"), time.Now().Add(10*time.Minute), driver)
+		p.activateDue(t)
+		require.Equal(t, models.BookingStatusAccepted, p.booking(t, booking.ID).Status)
+		tap := p.tapEvents(t)
+		p.relayed(t, tap)
+
+		require.NoError(t, p.bookingService.CancelBooking(ctx, driver.AccountId, booking.ID))
+		returned := p.booking(t, booking.ID)
+		require.Equal(t, models.BookingStatusRequested, returned.Status)
+		require.Nil(t, returned.DriverId)
+		require.Nil(t, returned.ReservedDriverId)
+		require.ElementsMatch(t, []string{"booking.driver_released", "driver_matching.requested"}, p.relayed(t, tap))
+	})
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrDestinationModeLimitReached = errors.New("destination mode was already used the maximum number of times today")
+
+// DestinationModeService lets drivers only get rides that take them towards a destination (e.g. home)
+type DestinationModeService interface {
+	// SetDestination turns destination mode on. Every call counts towards the daily limit.
+	SetDestination(ctx context.Context, driverAccountID uuid.UUID, location models.ExactLocation) (*models.Driver, error)
+	ClearDestination(ctx context.Context, driverAccountID uuid.UUID) error
+}
+
+type destinationModeService struct {
+	driverRepo repositories.DriverRepository
+	dailyLimit int
+}
+
+func NewDestinationModeService(driverRepo repositories.DriverRepository, dailyLimit int) DestinationModeService {
+	return &destinationModeService{
+		driverRepo: driverRepo,
+		dailyLimit: dailyLimit,
+	}
+}
+
+func (s *destinationModeService) SetDestination(ctx context.Context, driverAccountID uuid.UUID, location models.ExactLocation) (*models.Driver, error) {
+	// 1. Validate
+	if !util.IsValidCoordinate(location.Latitude, location.Longitude) {
+		return nil, ErrInvalidCoordinates
+	}
+
+	// 2. Get Driver Profile from Account ID
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Turn the mode on, unless the daily limit is used up
+	set, err := s.driverRepo.SetDestination(ctx, driver.ID, location.Latitude, location.Longitude, time.Now(), s.dailyLimit)
+	if err != nil {
+		return nil, err
+	}
+	if !set {
+		return nil, ErrDestinationModeLimitReached
+	}
+
+	log.Info().
+		Str("driver_id", driver.ID.String()).
+		Float64("lat", location.Latitude).
+		Float64("lon", location.Longitude).
+		Msg("Destination mode turned on")
+	return s.driverRepo.GetByID(ctx, driver.ID)
+}
+
+func (s *destinationModeService) ClearDestination(ctx context.Context, driverAccountID uuid.UUID) error {
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return err
+	}
+	if err := s.driverRepo.ClearDestination(ctx, driver.ID); err != nil {
+		return err
+	}
+
+	log.Info().Str("driver_id", driver.ID.String()).Msg("Destination mode turned off")
+	return nil
+}
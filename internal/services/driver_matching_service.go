@@ -3,132 +3,397 @@ package services
 import (
 	"CabBookingService/internal/domain"
 	"CabBookingService/internal/services/filters"
+	"CabBookingService/internal/services/ranking"
+	"CabBookingService/internal/services/routing"
 	"context"
 	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
 
+	"CabBookingService/internal/models"
 	"CabBookingService/internal/repositories"
 	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/util"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// Drivers further away than this are not considered at all
+	maxPickupETA = 10 * time.Minute
+	// Drivers are searched within this distance of the pickup, in km
+	searchRadiusKm = 2.0
+)
+
+// MatchingSettings tune how candidates are filtered and ranked
+type MatchingSettings struct {
+	RankingWeights ranking.Weights
+	// A driver in destination mode only gets rides that bring them at least this much closer to their destination
+	DestinationMinProgressKm float64
+	// Drivers with at most this much left on a solo ride ending near the pickup can be offered it as
+	// their next ride. Zero disables chaining.
+	ChainMaxRemaining time.Duration
+	// Bookings matched at the same time, at least one
+	Workers int
+	// Matching a booking is given up after this long, zero means no deadline
+	MatchTimeout time.Duration
+}
+
 // DriverMatchingService defines the contract for driver matching services
 type DriverMatchingService interface {
-	StartConsuming() error
+	// StartConsuming matches bookings from the queue until ctx is cancelled
+	StartConsuming(ctx context.Context) error
+	// Wait blocks until consuming stopped and the matches in flight are done, or until ctx is done
+	Wait(ctx context.Context) error
 }
 
 type driverMatchingService struct {
-	queue           queue.MessageQueue
-	locationService LocationService
-	bookingRepo     repositories.BookingRepository
-	driverRepo      repositories.DriverRepository
-	filters         []filters.DriverFilter
+	queue             queue.MessageQueue
+	locationService   LocationService
+	bookingRepo       repositories.BookingRepository
+	driverRepo        repositories.DriverRepository
+	routingProvider   routing.RoutingProvider
+	offerService      OfferService
+	poolingService    PoolingService
+	chainMaxRemaining time.Duration
+	filters           []filters.DriverFilter
+	// Optional passenger preferences, only dropped when the booking allows the fallback
+	preferenceFilters []filters.DriverFilter
+	ranker            ranking.DriverRanker
+
+	workers      int
+	matchTimeout time.Duration
+	inFlight     sync.WaitGroup
 }
 
+// NewDriverMatchingService matches every booking on its own as soon as it comes off the queue (greedy)
 func NewDriverMatchingService(
 	queue queue.MessageQueue,
 	locationService LocationService,
 	bookingRepo repositories.BookingRepository,
 	driverRepo repositories.DriverRepository,
+	geofenceService GeofenceService,
+	routingProvider routing.RoutingProvider,
+	offerService OfferService,
+	poolingService PoolingService,
+	settings MatchingSettings,
 ) DriverMatchingService {
+	return newDriverMatchingService(queue, locationService, bookingRepo, driverRepo, geofenceService, routingProvider, offerService, poolingService, settings)
+}
+
+func newDriverMatchingService(
+	queue queue.MessageQueue,
+	locationService LocationService,
+	bookingRepo repositories.BookingRepository,
+	driverRepo repositories.DriverRepository,
+	geofenceService GeofenceService,
+	routingProvider routing.RoutingProvider,
+	offerService OfferService,
+	poolingService PoolingService,
+	settings MatchingSettings,
+) *driverMatchingService {
 	return &driverMatchingService{
-		queue:           queue,
-		locationService: locationService,
-		bookingRepo:     bookingRepo,
-		driverRepo:      driverRepo,
+		queue:             queue,
+		locationService:   locationService,
+		bookingRepo:       bookingRepo,
+		driverRepo:        driverRepo,
+		routingProvider:   routingProvider,
+		offerService:      offerService,
+		poolingService:    poolingService,
+		chainMaxRemaining: settings.ChainMaxRemaining,
 		filters: []filters.DriverFilter{
-			// Add filters here
-			filters.NewETABasedFilter(5.0), // Max 5km away
-			filters.NewGenderFilter(),
+			// Add filters here, cheap ones first so fewer drivers need to be routed
+			filters.NewCarTypeFilter(),
+			filters.NewCapabilityFilter(),
+			filters.NewDestinationFilter(settings.DestinationMinProgressKm),
+			filters.NewETABasedFilter(routingProvider, maxPickupETA),
+			filters.NewAirportQueueFilter(geofenceService),
+		},
+		preferenceFilters: []filters.DriverFilter{
+			filters.NewDriverGenderFilter(),
+			filters.NewQuietRideFilter(),
 		},
+		ranker:       ranking.NewWeightedRanker(routingProvider, settings.RankingWeights, maxPickupETA),
+		workers:      max(settings.Workers, 1),
+		matchTimeout: settings.MatchTimeout,
 	}
 }
 
-func (s *driverMatchingService) StartConsuming() error {
-	ch, err := s.queue.Subscribe(domain.TopicDriverMatching)
+func (s *driverMatchingService) StartConsuming(ctx context.Context) error {
+	sub, err := queue.Subscribe[domain.DriverMatchingRequested](s.queue, domain.TopicDriverMatching, domain.GroupDriverMatching)
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to topic %s: %w", domain.TopicDriverMatching, err)
 	}
-	log.Info().Str("topic", domain.TopicDriverMatching).Msg("Subscribed to topic")
+	log.Info().Str("topic", domain.TopicDriverMatching).Str("group", domain.GroupDriverMatching).Int("workers", s.workers).Msg("Subscribed to topic")
 
-	// Start a background goroutine to consume messages
-	// TODO: When service is closed we should also stop this goroutine gracefully
+	// Leaving the group closes the messages once the message in hand was handed back
 	go func() {
-		log.Info().Msg("[DriverMatching] Started consuming messages...")
-		for msg := range ch {
-			// Type Assertion
-			bookingID, ok := msg.(uuid.UUID)
-			if !ok {
-				log.Warn().Interface("msg", msg).Msg("[DriverMatching] Invalid message format received")
-				continue
-				// TODO: In production, consider dead-letter queue or alerting
-			}
-			s.handleDriverMatching(bookingID)
-		}
+		<-ctx.Done()
+		sub.Unsubscribe()
 	}()
+
+	// A fixed pool of workers takes turns on the topic, a slow booking only holds up its own worker
+	for range s.workers {
+		s.inFlight.Add(1)
+		go func() {
+			defer s.inFlight.Done()
+			for message := range sub.Messages() {
+				s.consume(ctx, message)
+			}
+		}()
+	}
+	log.Info().Msg("[DriverMatching] Started consuming messages...")
 	return nil
 }
 
-func (s *driverMatchingService) handleDriverMatching(bookingID uuid.UUID) {
+func (s *driverMatchingService) Wait(ctx context.Context) error {
+	return WaitFor(ctx, &s.inFlight)
+}
+
+// consume matches the booking of a message. Failed matches are handed back to the queue,
+// durable queues retry them and dead-letter them in the end.
+func (s *driverMatchingService) consume(ctx context.Context, message queue.Message[domain.DriverMatchingRequested]) {
+	bookingID := message.Payload.BookingID
+	// Events published while matching belong to the request that created the booking
+	ctx = queue.WithCorrelationID(ctx, message.Envelope().CorrelationID)
+	// Acknowledge even when shutting down, the match was finished
+	ackCtx := context.WithoutCancel(ctx)
+
+	if err := s.handleDriverMatching(ctx, bookingID); err != nil {
+		if err := message.Nack(ackCtx, err); err != nil {
+			log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("[DriverMatching] Failed to nack message")
+		}
+		return
+	}
+	if err := message.Ack(ackCtx); err != nil {
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("[DriverMatching] Failed to ack message")
+	}
+}
+
+// handleDriverMatching returns an error if matching should be tried again
+func (s *driverMatchingService) handleDriverMatching(ctx context.Context, bookingID uuid.UUID) (err error) {
 	log.Info().Str("booking_id", bookingID.String()).Msg("Handling driver matching")
+	defer recoverMatching(bookingID.String(), &err)
 
-	ctx := context.Background() // TODO: Either pass context from message or create with timeout
+	ctx, cancel := s.matchContext(ctx)
+	defer cancel()
 
 	// 1. Fetch Booking
 	booking, err := s.bookingRepo.GetByID(ctx, bookingID)
 	if err != nil {
 		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Failed to fetch booking")
-		return
+		return err
 	}
-
-	// 2. Find nearby drivers using pickup location from booking
-	radiusToSearch := 2.0 // in km // TODO: Make configurable
-	nearbyDriverIDs := s.locationService.GetNearbyDrivers(booking.PickupLatitude, booking.PickupLongitude, radiusToSearch)
-	if len(nearbyDriverIDs) == 0 {
-		log.Info().Str("booking_id", bookingID.String()).Msg("No drivers nearby")
-		return
+	// Events are delivered at least once, the booking may have been matched already
+	if booking.Status != models.BookingStatusRequested {
+		log.Info().Str("booking_id", bookingID.String()).Str("status", string(booking.Status)).Msg("Booking no longer needs a driver")
+		return nil
 	}
 
-	// 3. Fetch Full Driver Profiles
-	candidateDrivers, err := s.driverRepo.GetByAccountIDs(ctx, nearbyDriverIDs)
+	// 2. Find, filter and rank candidates
+	ranked, err := s.rankedCandidates(ctx, booking)
 	if err != nil {
-		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Error fetching driver profiles")
-		return
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Error finding candidate drivers")
+		return err
+	}
+	if len(ranked) == 0 {
+		log.Info().Str("booking_id", bookingID.String()).Msg("No matching drivers found")
+		return nil
+	}
+
+	// 3. Offer the ride, best drivers first
+	s.offerService.OfferRide(ctx, booking, ranked)
+	return nil
+}
+
+// matchContext bounds a single match. It is not cancelled with the consumer, matches in flight are
+// finished on shutdown instead of being abandoned half way through an offer.
+func (s *driverMatchingService) matchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if s.matchTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, s.matchTimeout)
+}
 
-	// 4. Apply Filters
-	validDrivers := candidateDrivers
-	for _, filter := range s.filters {
-		validDrivers = filter.Filter(validDrivers, booking)
+// recoverMatching keeps a worker alive when matching panics and turns the panic into *err.
+// match names what was being matched, a booking ID or the batch.
+func recoverMatching(match string, err *error) {
+	if r := recover(); r != nil {
+		log.Error().Interface("panic", r).Str("match", match).Str("stack", string(debug.Stack())).Msg("[DriverMatching] Recovered from panic")
+		*err = fmt.Errorf("matching panicked: %v", r)
 	}
+}
 
-	if len(validDrivers) == 0 {
-		log.Info().Str("booking_id", bookingID.String()).Msg("No matching drivers after filtering")
-		return
+// WaitFor waits for wg, unless ctx is done first
+func WaitFor(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	if err := s.bookingRepo.AddNotifiedDrivers(ctx, bookingID, validDrivers); err != nil {
-		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Error saving notified drivers")
-		return
+// rankedCandidates returns the drivers near the pickup that pass every filter, best first
+func (s *driverMatchingService) rankedCandidates(ctx context.Context, booking *models.Booking) ([]ranking.ScoredDriver, error) {
+	// 1. Find nearby drivers using pickup location from booking
+	var candidateDrivers []models.Driver
+	nearbyDriverIDs := s.locationService.GetNearbyDrivers(booking.PickupLatitude, booking.PickupLongitude, searchRadiusKm)
+	if len(nearbyDriverIDs) > 0 {
+		// 2. Fetch Full Driver Profiles
+		drivers, err := s.driverRepo.GetByAccountIDs(ctx, nearbyDriverIDs)
+		if err != nil {
+			return nil, err
+		}
+		candidateDrivers = drivers
 	}
 
-	// 5. Notify
-	log.Info().
-		Str("booking_id", bookingID.String()).
-		Int("driver_count", len(candidateDrivers)).
-		Msg("Found matching drivers. Notifying...")
+	// Prefer the live location over the last persisted one
+	for i := range candidateDrivers {
+		if location, ok := s.locationService.GetDriverLocation(candidateDrivers[i].AccountId); ok {
+			candidateDrivers[i].LastKnownLocation = location
+		}
+		if heading, ok := s.locationService.GetDriverHeading(candidateDrivers[i].AccountId); ok {
+			candidateDrivers[i].LastKnownHeading = &heading
+		}
+	}
+
+	// Drivers about to drop off a passenger near the pickup can take the ride next
+	chained, err := s.chainedCandidates(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+	candidateDrivers = mergeDrivers(candidateDrivers, chained)
+	if len(candidateDrivers) == 0 {
+		return nil, nil
+	}
+
+	// 3. Apply Filters
+	validDrivers := applyFilters(ctx, s.filters, candidateDrivers, booking)
+	if len(validDrivers) == 0 {
+		return nil, nil
+	}
 
-	for _, d := range validDrivers {
-		// Mock Notification
-		// TODO: In a real app, this would be an async call to a notification service
-		// TODO: Integrate with real notification service (e.g., Firebase, Twilio)
-		// For now, we just log the notification
+	// 4. Apply the optional preferences, falling back only if the passenger allowed it
+	preferredDrivers := applyFilters(ctx, s.preferenceFilters, validDrivers, booking)
+	if len(preferredDrivers) == 0 {
+		if !booking.AllowPreferenceFallback {
+			log.Info().Str("booking_id", booking.ID.String()).Msg("No driver meets the ride preferences, fallback not allowed")
+			return nil, nil
+		}
+		if !booking.PreferencesRelaxed {
+			if err := s.bookingRepo.MarkPreferencesRelaxed(ctx, booking.ID); err != nil {
+				return nil, err
+			}
+			booking.PreferencesRelaxed = true
+		}
 		log.Info().
-			Str("booking_id", bookingID.String()).
-			Str("driver_id", d.ID.String()).
-			Str("driver_name", d.Name).
-			Str("phone", d.PhoneNumber).
-			Msg(">> Push Notification Sent")
+			Str("booking_id", booking.ID.String()).
+			Int("driver_count", len(validDrivers)).
+			Msg("No driver meets the ride preferences, offering without the optional ones")
+		preferredDrivers = validDrivers
+	}
+
+	// 5. Drivers on a shared trip only get shared bookings that fit their trip
+	pooled, free, err := s.poolingService.Candidates(ctx, booking, preferredDrivers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other drivers must be free, or about to finish their current ride
+	available := free[:0]
+	for _, driver := range free {
+		if driver.IsAvailable || driver.BusyFor > 0 {
+			available = append(available, driver)
+		}
+	}
+
+	// 6. Rank, drivers who can add the ride to their trip go first
+	return append(pooled, s.ranker.Rank(ctx, available, booking)...), nil
+}
+
+// chainedCandidates returns the drivers of solo rides that end near the pickup within chainMaxRemaining.
+// Each driver is placed at their drop-off, BusyFor holds the time left until they get there.
+func (s *driverMatchingService) chainedCandidates(ctx context.Context, booking *models.Booking) ([]models.Driver, error) {
+	// Shared bookings are planned on the driver's trip instead
+	if s.chainMaxRemaining <= 0 || booking.IsShared {
+		return nil, nil
+	}
+
+	minLat, maxLat, minLon, maxLon := util.BoundingBox(booking.PickupLatitude, booking.PickupLongitude, searchRadiusKm)
+	current, err := s.bookingRepo.GetChainableBookings(ctx, minLat, maxLat, minLon, maxLon)
+	if err != nil {
+		return nil, err
+	}
+
+	var drivers []models.Driver
+	for _, ride := range current {
+		if ride.Driver == nil {
+			continue
+		}
+		dropoff := models.ExactLocation{Latitude: ride.DropoffLatitude, Longitude: ride.DropoffLongitude}
+		if util.DistanceKm(booking.PickupLatitude, booking.PickupLongitude, dropoff.Latitude, dropoff.Longitude) > searchRadiusKm {
+			continue
+		}
+
+		driver := *ride.Driver
+		if location, ok := s.locationService.GetDriverLocation(driver.AccountId); ok {
+			driver.LastKnownLocation = location
+		}
+		if driver.LastKnownLocation == nil {
+			continue
+		}
+		route, err := s.routingProvider.Route(ctx, *driver.LastKnownLocation, dropoff)
+		if err != nil {
+			log.Debug().Err(err).
+				Str("booking_id", ride.ID.String()).
+				Str("driver_id", driver.ID.String()).
+				Msg("Could not route driver to drop-off")
+			continue
+		}
+		if route.Duration > s.chainMaxRemaining {
+			continue
+		}
+
+		driver.LastKnownLocation = &dropoff
+		// Their heading now says nothing about where they'll be going from the drop-off
+		driver.LastKnownHeading = nil
+		// Never zero, so the driver isn't mistaken for a free one
+		driver.BusyFor = max(route.Duration, time.Second)
+		drivers = append(drivers, driver)
+	}
+	return drivers, nil
+}
+
+// mergeDrivers adds the chained drivers to the nearby ones, a driver found in both keeps the chained entry
+func mergeDrivers(nearby, chained []models.Driver) []models.Driver {
+	if len(chained) == 0 {
+		return nearby
+	}
+	chainedIDs := make(map[uuid.UUID]bool, len(chained))
+	for _, driver := range chained {
+		chainedIDs[driver.ID] = true
+	}
+	merged := make([]models.Driver, 0, len(nearby)+len(chained))
+	for _, driver := range nearby {
+		if !chainedIDs[driver.ID] {
+			merged = append(merged, driver)
+		}
+	}
+	return append(merged, chained...)
+}
+
+func applyFilters(ctx context.Context, driverFilters []filters.DriverFilter, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	for _, filter := range driverFilters {
+		drivers = filter.Filter(ctx, drivers, booking)
 	}
+	return drivers
 }
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/pooling"
+	"CabBookingService/internal/services/routing"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	fareBaseAmount      = 5.0  // Flat amount charged on every ride
+	farePerKmAmount     = 2.0  // Charged per km travelled
+	farePerMinuteAmount = 0.25 // Charged per minute of driving time
+	farePerWaitMinute   = 0.20 // Charged per minute the driver waits at the pickup beyond the free time
+	fareFreeWaitingTime = 3 * time.Minute
+	fareCurrency        = "USD"
+	// Shared riders never pay more than their solo fare minus this discount
+	fareSharedDiscount = 0.25
+
+	// Radius in which we look for a driver to give the passenger a pickup ETA
+	pickupETASearchRadiusKm = 5.0
+)
+
+// carTypeMultipliers scale the metered part of the fare per vehicle class
+var carTypeMultipliers = map[models.CarType]float64{
+	models.CarTypeEconomy:    1.0,
+	models.CarTypeXL:         1.4,
+	models.CarTypeAccessible: 1.0, // Priced like economy, accessibility must not cost extra
+	models.CarTypePremium:    1.6,
+}
+
+var ErrInvalidCarType = errors.New("invalid car type")
+
+// normalizeCarType defaults an empty car type to economy and rejects unknown ones
+func normalizeCarType(carType models.CarType) (models.CarType, error) {
+	if carType == "" {
+		return models.CarTypeEconomy, nil
+	}
+	if !carType.IsValid() {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCarType, carType)
+	}
+	return carType, nil
+}
+
+type FareEstimateParams struct {
+	PickupLatitude   float64
+	PickupLongitude  float64
+	DropoffLatitude  float64
+	DropoffLongitude float64
+	Stops            []models.ExactLocation // Intermediate stops in visiting order
+	CarType          models.CarType         // Defaults to ECONOMY
+}
+
+// FareEstimate is the breakdown of a fare
+type FareEstimate struct {
+	DistanceKm       float64       // Sum over every leg of the trip
+	Duration         time.Duration // Expected driving time of the trip, including every leg
+	BaseAmount       float64
+	DistanceAmount   float64
+	TimeAmount       float64
+	WaitingAmount    float64 // Only on final fares, waiting time isn't known upfront
+	CarType          models.CarType
+	ClassMultiplier  float64 // Vehicle class multiplier applied to the distance and time amounts
+	ZoneMultiplier   float64 // Airport tariff multiplier applied to the distance and time amounts
+	AirportSurcharge float64
+	Amount           float64 // Total amount to be paid
+	Currency         string
+
+	// Most a passenger pays for the same ride when sharing it. Only set on estimates.
+	MaxSharedAmount float64
+	// The amount is the rider's share of a shared trip. Only set on final fares.
+	Shared bool
+
+	// PickupETA is how long the closest available driver needs to reach the pickup.
+	// Nil when no driver is around. Only set on estimates, not on final fares.
+	PickupETA *time.Duration
+}
+
+type FareService interface {
+	// EstimateFare validates the trip against the geofences and returns the expected fare of the requested class
+	EstimateFare(ctx context.Context, params FareEstimateParams) (*FareEstimate, error)
+	// EstimateFares returns the expected fare of every vehicle class, cheapest class first
+	EstimateFares(ctx context.Context, params FareEstimateParams) ([]FareEstimate, error)
+	// CalculateFare returns the final fare of a booking
+	CalculateFare(ctx context.Context, booking *models.Booking) (*FareEstimate, error)
+}
+
+type fareService struct {
+	geofenceService GeofenceService
+	locationService LocationService
+	routingProvider routing.RoutingProvider
+	tripRepo        repositories.TripRepository
+}
+
+func NewFareService(
+	geofenceService GeofenceService,
+	locationService LocationService,
+	routingProvider routing.RoutingProvider,
+	tripRepo repositories.TripRepository,
+) FareService {
+	return &fareService{
+		geofenceService: geofenceService,
+		locationService: locationService,
+		routingProvider: routingProvider,
+		tripRepo:        tripRepo,
+	}
+}
+
+func (s *fareService) EstimateFare(ctx context.Context, params FareEstimateParams) (*FareEstimate, error) {
+	carType, err := normalizeCarType(params.CarType)
+	if err != nil {
+		return nil, err
+	}
+	trip, err := s.routeTrip(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	estimate := trip.price(carType)
+	estimate.PickupETA = s.closestDriverETA(ctx, trip.waypoints[0])
+	return estimate, nil
+}
+
+func (s *fareService) EstimateFares(ctx context.Context, params FareEstimateParams) ([]FareEstimate, error) {
+	// The route is the same for every class, only the pricing differs
+	trip, err := s.routeTrip(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	pickupETA := s.closestDriverETA(ctx, trip.waypoints[0])
+
+	estimates := make([]FareEstimate, 0, len(models.CarTypes))
+	for _, carType := range models.CarTypes {
+		estimate := trip.price(carType)
+		estimate.PickupETA = pickupETA
+		estimates = append(estimates, *estimate)
+	}
+	return estimates, nil
+}
+
+// routeTrip validates an estimate request and routes it through its waypoints
+func (s *fareService) routeTrip(ctx context.Context, params FareEstimateParams) (*routedTrip, error) {
+	zones, err := s.geofenceService.ValidateTrip(ctx,
+		params.PickupLatitude, params.PickupLongitude,
+		params.DropoffLatitude, params.DropoffLongitude,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.geofenceService.ValidateDropoffs(ctx, params.Stops); err != nil {
+		return nil, err
+	}
+
+	pickup := models.ExactLocation{Latitude: params.PickupLatitude, Longitude: params.PickupLongitude}
+	dropoff := models.ExactLocation{Latitude: params.DropoffLatitude, Longitude: params.DropoffLongitude}
+	waypoints := append(append([]models.ExactLocation{pickup}, params.Stops...), dropoff)
+	return s.route(ctx, waypoints, zones)
+}
+
+func (s *fareService) CalculateFare(ctx context.Context, booking *models.Booking) (*FareEstimate, error) {
+	// The booking was validated on creation. We don't re-validate here since the ride already
+	// happened, we only need the zones to apply the right tariff.
+	pickupZones, err := s.geofenceService.ZonesAt(ctx, booking.PickupLatitude, booking.PickupLongitude)
+	if err != nil {
+		return nil, err
+	}
+	dropoffZones, err := s.geofenceService.ZonesAt(ctx, booking.DropoffLatitude, booking.DropoffLongitude)
+	if err != nil {
+		return nil, err
+	}
+
+	// The drop-off is the final destination, so a destination changed mid-trip is charged up to where the ride ended
+	zones := &TripZones{City: booking.City, PickupZones: pickupZones, DropoffZones: dropoffZones}
+	trip, err := s.route(ctx, booking.Waypoints(), zones)
+	if err != nil {
+		return nil, err
+	}
+	carType, err := normalizeCarType(booking.CarType)
+	if err != nil {
+		return nil, err
+	}
+	fare := trip.price(carType)
+	fare.MaxSharedAmount = 0
+	if booking.IsShared && booking.TripId != nil {
+		if err := s.shareFare(ctx, booking, trip, fare); err != nil {
+			return nil, err
+		}
+	}
+
+	// Waiting time counts from the driver's arrival at the pickup until the ride started
+	if billable := booking.WaitingTime(time.Now()) - fareFreeWaitingTime; billable > 0 {
+		fare.WaitingAmount = billable.Minutes() * farePerWaitMinute
+		fare.Amount = roundToCents(fare.Amount + fare.WaitingAmount)
+	}
+	return fare, nil
+}
+
+// routedTrip is a trip routed leg by leg, ready to be priced for any vehicle class
+type routedTrip struct {
+	waypoints  []models.ExactLocation
+	zones      *TripZones
+	distanceKm float64
+	duration   time.Duration
+}
+
+// route sums the legs of the trip through the waypoints (pickup, stops and drop-off)
+func (s *fareService) route(ctx context.Context, waypoints []models.ExactLocation, zones *TripZones) (*routedTrip, error) {
+	trip := &routedTrip{waypoints: waypoints, zones: zones}
+	for i := 1; i < len(waypoints); i++ {
+		route, err := s.routingProvider.Route(ctx, waypoints[i-1], waypoints[i])
+		if err != nil {
+			return nil, err
+		}
+		trip.distanceKm += route.DistanceKm
+		trip.duration += route.Duration
+	}
+	return trip, nil
+}
+
+// price applies the tariff of the vehicle class and the zones to the trip
+func (t *routedTrip) price(carType models.CarType) *FareEstimate {
+	// Real world: Calculate based on Distance + Time + Surge
+	estimate := &FareEstimate{
+		DistanceKm:      t.distanceKm,
+		Duration:        t.duration,
+		BaseAmount:      fareBaseAmount,
+		DistanceAmount:  t.distanceKm * farePerKmAmount,
+		TimeAmount:      t.duration.Minutes() * farePerMinuteAmount,
+		CarType:         carType,
+		ClassMultiplier: 1.0,
+		ZoneMultiplier:  1.0,
+		Currency:        fareCurrency,
+	}
+	if multiplier, ok := carTypeMultipliers[carType]; ok {
+		estimate.ClassMultiplier = multiplier
+	}
+
+	// Airport tariff
+	if airport := t.zones.Airport(); airport != nil {
+		if airport.FareMultiplier > 0 {
+			estimate.ZoneMultiplier = airport.FareMultiplier
+		}
+		estimate.AirportSurcharge = airport.FlatSurcharge
+	}
+
+	metered := (estimate.DistanceAmount + estimate.TimeAmount) * estimate.ClassMultiplier * estimate.ZoneMultiplier
+	estimate.Amount = roundToCents(estimate.BaseAmount + metered + estimate.AirportSurcharge)
+	estimate.MaxSharedAmount = roundToCents(estimate.Amount * (1 - fareSharedDiscount))
+	return estimate
+}
+
+// shareFare replaces the solo amount of a shared booking by the rider's share of the legs driven
+// while they were on board, capped at the discounted solo amount
+func (s *fareService) shareFare(ctx context.Context, booking *models.Booking, solo *routedTrip, fare *FareEstimate) error {
+	trip, err := s.tripRepo.GetByID(ctx, *booking.TripId)
+	if err != nil {
+		return err
+	}
+
+	visited := trip.CompletedStops()
+	stops := make([]pooling.Stop, 0, len(visited))
+	legCosts := make([]float64, 0, len(visited))
+	for i, stop := range visited {
+		stops = append(stops, pooling.Stop{BookingID: stop.BookingId, Kind: stop.Kind, Location: stop.Location()})
+		if i == 0 {
+			continue
+		}
+		route, err := s.routingProvider.Route(ctx, visited[i-1].Location(), stop.Location())
+		if err != nil {
+			return err
+		}
+		metered := route.DistanceKm*farePerKmAmount + route.Duration.Minutes()*farePerMinuteAmount
+		legCosts = append(legCosts, metered*fare.ClassMultiplier*fare.ZoneMultiplier)
+	}
+
+	share := pooling.SplitLegCosts(stops, legCosts)[booking.ID]
+	shared := roundToCents(fare.BaseAmount + share + fare.AirportSurcharge)
+	maxShared := roundToCents(solo.price(fare.CarType).Amount * (1 - fareSharedDiscount))
+
+	fare.Amount = math.Min(shared, maxShared)
+	fare.Shared = true
+	return nil
+}
+
+// closestDriverETA returns the shortest driving time of a nearby driver to the pickup
+func (s *fareService) closestDriverETA(ctx context.Context, pickup models.ExactLocation) *time.Duration {
+	var best *time.Duration
+	for _, driverID := range s.locationService.GetNearbyDrivers(pickup.Latitude, pickup.Longitude, pickupETASearchRadiusKm) {
+		location, ok := s.locationService.GetDriverLocation(driverID)
+		if !ok {
+			continue
+		}
+
+		route, err := s.routingProvider.Route(ctx, *location, pickup)
+		if err != nil {
+			log.Debug().Err(err).Str("driver_id", driverID.String()).Msg("Could not route driver to pickup")
+			continue
+		}
+		if best == nil || route.Duration < *best {
+			eta := route.Duration
+			best = &eta
+		}
+	}
+	return best
+}
+
+func roundToCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
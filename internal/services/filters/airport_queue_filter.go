@@ -0,0 +1,52 @@
+package filters
+
+import (
+	"context"
+
+	"CabBookingService/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+type airportQueueFilter struct {
+	zoneLocator ZoneLocator
+}
+
+// NewAirportQueueFilter only lets drivers waiting inside the airport zone take pickups from an
+// airport that requires a driver queue. Bookings outside such airports are not affected.
+func NewAirportQueueFilter(zoneLocator ZoneLocator) DriverFilter {
+	return &airportQueueFilter{
+		zoneLocator: zoneLocator,
+	}
+}
+
+func (f *airportQueueFilter) Filter(ctx context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	zones, err := f.zoneLocator.ZonesAt(ctx, booking.PickupLatitude, booking.PickupLongitude)
+	if err != nil {
+		// Don't block matching if geofences can't be loaded, the rest of the chain still applies
+		log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Failed to resolve pickup zones")
+		return drivers
+	}
+
+	var airport *models.Geofence
+	for i := range zones {
+		if zones[i].Type == models.GeofenceTypeAirport && zones[i].RequiresDriverQueue {
+			airport = &zones[i]
+			break
+		}
+	}
+	if airport == nil {
+		return drivers
+	}
+
+	validDrivers := make([]models.Driver, 0)
+	for _, driver := range drivers {
+		if driver.LastKnownLocation == nil {
+			continue
+		}
+		if airport.Contains(driver.LastKnownLocation.Latitude, driver.LastKnownLocation.Longitude) {
+			validDrivers = append(validDrivers, driver)
+		}
+	}
+	return validDrivers
+}
@@ -0,0 +1,33 @@
+package filters
+
+import (
+	"context"
+
+	"CabBookingService/internal/models"
+)
+
+type carTypeFilter struct{}
+
+// NewCarTypeFilter keeps drivers whose car can serve the vehicle class requested for the booking
+func NewCarTypeFilter() DriverFilter {
+	return &carTypeFilter{}
+}
+
+func (*carTypeFilter) Filter(_ context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	requested := booking.CarType
+	if requested == "" {
+		requested = models.CarTypeEconomy
+	}
+
+	validDrivers := make([]models.Driver, 0)
+	for _, driver := range drivers {
+		carType := driver.Car.CarType
+		if carType == "" {
+			carType = models.CarTypeEconomy
+		}
+		if carType.CanServe(requested, driver.Car.AcceptsLowerClass) {
+			validDrivers = append(validDrivers, driver)
+		}
+	}
+	return validDrivers
+}
@@ -0,0 +1,42 @@
+package filters
+
+import (
+	"context"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/util"
+)
+
+type destinationFilter struct {
+	minProgressKm float64
+}
+
+// NewDestinationFilter only offers drivers in destination mode rides whose drop-off brings them
+// at least minProgressKm closer to their destination. Drivers not in destination mode are kept.
+func NewDestinationFilter(minProgressKm float64) DriverFilter {
+	return &destinationFilter{minProgressKm: minProgressKm}
+}
+
+func (f *destinationFilter) Filter(_ context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	validDrivers := make([]models.Driver, 0)
+	for _, driver := range drivers {
+		destination := driver.Destination()
+		if destination == nil {
+			validDrivers = append(validDrivers, driver)
+			continue
+		}
+
+		// Without a known location the pickup is the best guess of where the driver is
+		from := models.ExactLocation{Latitude: booking.PickupLatitude, Longitude: booking.PickupLongitude}
+		if driver.LastKnownLocation != nil {
+			from = *driver.LastKnownLocation
+		}
+
+		before := util.DistanceKm(from.Latitude, from.Longitude, destination.Latitude, destination.Longitude)
+		after := util.DistanceKm(booking.DropoffLatitude, booking.DropoffLongitude, destination.Latitude, destination.Longitude)
+		if before-after >= f.minProgressKm {
+			validDrivers = append(validDrivers, driver)
+		}
+	}
+	return validDrivers
+}
@@ -1,40 +1,49 @@
 package filters
 
 import (
+	"context"
+	"time"
+
 	"CabBookingService/internal/models"
-	"CabBookingService/internal/util"
+	"CabBookingService/internal/services/routing"
+
+	"github.com/rs/zerolog/log"
 )
 
 type etaBasedFilter struct {
-	maxDistanceKm float64
+	routingProvider routing.RoutingProvider
+	maxETA          time.Duration
 }
 
-func NewETABasedFilter(maxDistanceKm float64) DriverFilter {
+// NewETABasedFilter keeps drivers whose driving time to the pickup is within maxETA.
+// Time left on a driver's current ride counts towards it.
+func NewETABasedFilter(routingProvider routing.RoutingProvider, maxETA time.Duration) DriverFilter {
 	return &etaBasedFilter{
-		maxDistanceKm: maxDistanceKm,
+		routingProvider: routingProvider,
+		maxETA:          maxETA,
 	}
 }
-func (f *etaBasedFilter) Filter(drivers []models.Driver, booking *models.Booking) []models.Driver {
-	validDrivers := make([]models.Driver, 0)
 
-	// NOTE: In a real ETA filter, you would call Google Maps API here.
-	// For this implementation, we will use the "Naive" distance calculation
-	// we already have, filtering out drivers who might have drifted too far
-	// since the initial spatial search.
+func (f *etaBasedFilter) Filter(ctx context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	validDrivers := make([]models.Driver, 0)
+	pickup := models.ExactLocation{Latitude: booking.PickupLatitude, Longitude: booking.PickupLongitude}
 
 	for _, driver := range drivers {
-		// We assume driver.LastKnownLocation is populated
+		// Drivers without a known location can't be routed
 		if driver.LastKnownLocation == nil {
 			continue
 		}
 
-		// Use the Haversine formula (exported from your location service or a util package)
-		distance := util.DistanceKm(
-			booking.PickupLatitude, booking.PickupLongitude,
-			driver.LastKnownLocation.Latitude, driver.LastKnownLocation.Longitude,
-		)
+		route, err := f.routingProvider.Route(ctx, *driver.LastKnownLocation, pickup)
+		if err != nil {
+			log.Debug().Err(err).
+				Str("booking_id", booking.ID.String()).
+				Str("driver_id", driver.ID.String()).
+				Msg("Could not route driver to pickup")
+			continue
+		}
 
-		if distance <= f.maxDistanceKm {
+		if route.Duration+driver.BusyFor <= f.maxETA {
 			validDrivers = append(validDrivers, driver)
 		}
 	}
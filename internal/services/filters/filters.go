@@ -1,10 +1,17 @@
 package filters
 
 import (
+	"context"
+
 	"CabBookingService/internal/models"
 )
 
 // DriverFilter defines the contract for filtering drivers based on booking criteria
 type DriverFilter interface {
-	Filter(drivers []models.Driver, booking *models.Booking) []models.Driver
+	Filter(ctx context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver
+}
+
+// ZoneLocator resolves the geofences containing a point
+type ZoneLocator interface {
+	ZonesAt(ctx context.Context, lat, lon float64) ([]models.Geofence, error)
 }
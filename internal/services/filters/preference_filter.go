@@ -0,0 +1,78 @@
+package filters
+
+import (
+	"context"
+
+	"CabBookingService/internal/models"
+)
+
+type capabilityFilter struct{}
+
+// NewCapabilityFilter keeps drivers whose car meets the essential preferences of the booking
+// (wheelchair access, pets and child seat). These are never relaxed.
+func NewCapabilityFilter() DriverFilter {
+	return &capabilityFilter{}
+}
+
+func (*capabilityFilter) Filter(_ context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	prefs := booking.Preferences
+
+	validDrivers := make([]models.Driver, 0)
+	for _, driver := range drivers {
+		if prefs.WheelchairAccess && !driver.Car.IsWheelchairAccessible() {
+			continue
+		}
+		if prefs.PetFriendly && !driver.Car.PetFriendly {
+			continue
+		}
+		if prefs.ChildSeat && !driver.Car.HasChildSeat {
+			continue
+		}
+		validDrivers = append(validDrivers, driver)
+	}
+	return validDrivers
+}
+
+type driverGenderFilter struct{}
+
+// NewDriverGenderFilter keeps drivers of the gender the passenger prefers, if any
+func NewDriverGenderFilter() DriverFilter {
+	return &driverGenderFilter{}
+}
+
+func (*driverGenderFilter) Filter(_ context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	preferred := booking.Preferences.PreferredDriverGender
+	if preferred == nil {
+		return drivers
+	}
+
+	validDrivers := make([]models.Driver, 0)
+	for _, driver := range drivers {
+		// Drivers who didn't state their gender can't be matched to a preference
+		if driver.Gender != nil && *driver.Gender == *preferred {
+			validDrivers = append(validDrivers, driver)
+		}
+	}
+	return validDrivers
+}
+
+type quietRideFilter struct{}
+
+// NewQuietRideFilter keeps drivers who offer quiet rides when the passenger asked for one
+func NewQuietRideFilter() DriverFilter {
+	return &quietRideFilter{}
+}
+
+func (*quietRideFilter) Filter(_ context.Context, drivers []models.Driver, booking *models.Booking) []models.Driver {
+	if !booking.Preferences.QuietRide {
+		return drivers
+	}
+
+	validDrivers := make([]models.Driver, 0)
+	for _, driver := range drivers {
+		if driver.OffersQuietRides {
+			validDrivers = append(validDrivers, driver)
+		}
+	}
+	return validDrivers
+}
@@ -0,0 +1,142 @@
+package geocoding
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"CabBookingService/internal/util"
+)
+
+const (
+	// Size of a spatial index cell in degrees (~11 km at the equator)
+	gazetteerCellSize = 0.1
+
+	// Column positions in the GeoNames dump format (tab separated)
+	// See https://download.geonames.org/export/dump/readme.txt
+	geoNamesColumnName        = 1
+	geoNamesColumnLatitude    = 4
+	geoNamesColumnLongitude   = 5
+	geoNamesColumnCountryCode = 8
+	geoNamesMinColumns        = 9
+)
+
+type gazetteerEntry struct {
+	name        string
+	countryCode string
+	lat         float64
+	lon         float64
+}
+
+type gazetteerCell struct {
+	x int
+	y int
+}
+
+type gazetteerGeocoder struct {
+	entries       []gazetteerEntry
+	index         map[gazetteerCell][]int
+	maxDistanceKm float64
+}
+
+// LoadGazetteerGeocoder builds an offline reverse geocoder from a GeoNames style gazetteer
+// file (tab separated: id, name, ascii name, alternate names, latitude, longitude, ..., country code, ...).
+// Points further than maxDistanceKm from every entry get ErrNoAddressFound.
+func LoadGazetteerGeocoder(path string, maxDistanceKm float64) (Geocoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gazetteer file: %w", err)
+	}
+	defer f.Close()
+
+	return ParseGazetteer(f, maxDistanceKm)
+}
+
+// ParseGazetteer builds the geocoder from a gazetteer stream, see LoadGazetteerGeocoder
+func ParseGazetteer(r io.Reader, maxDistanceKm float64) (Geocoder, error) {
+	g := &gazetteerGeocoder{
+		index:         make(map[gazetteerCell][]int),
+		maxDistanceKm: maxDistanceKm,
+	}
+
+	scanner := bufio.NewScanner(r)
+	// Alternate names can make lines very long
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		columns := strings.Split(line, "\t")
+		if len(columns) < geoNamesMinColumns {
+			return nil, fmt.Errorf("gazetteer line %d: expected at least %d columns, got %d", lineNumber, geoNamesMinColumns, len(columns))
+		}
+		lat, err := strconv.ParseFloat(columns[geoNamesColumnLatitude], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: invalid latitude: %w", lineNumber, err)
+		}
+		lon, err := strconv.ParseFloat(columns[geoNamesColumnLongitude], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: invalid longitude: %w", lineNumber, err)
+		}
+
+		idx := len(g.entries)
+		g.entries = append(g.entries, gazetteerEntry{
+			name:        columns[geoNamesColumnName],
+			countryCode: columns[geoNamesColumnCountryCode],
+			lat:         lat,
+			lon:         lon,
+		})
+		cell := gazetteerCellFor(lat, lon)
+		g.index[cell] = append(g.index[cell], idx)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gazetteer: %w", err)
+	}
+
+	return g, nil
+}
+
+func (g *gazetteerGeocoder) ReverseGeocode(_ context.Context, lat, lon float64) (string, error) {
+	// Look at the cell of the point and its neighbours, wide enough to cover maxDistanceKm
+	rings := int(math.Ceil(g.maxDistanceKm/111.0/gazetteerCellSize)) + 1
+	center := gazetteerCellFor(lat, lon)
+
+	best, bestDistance := -1, math.MaxFloat64
+	for dx := -rings; dx <= rings; dx++ {
+		for dy := -rings; dy <= rings; dy++ {
+			for _, idx := range g.index[gazetteerCell{x: center.x + dx, y: center.y + dy}] {
+				d := util.DistanceKm(lat, lon, g.entries[idx].lat, g.entries[idx].lon)
+				if d < bestDistance {
+					best, bestDistance = idx, d
+				}
+			}
+		}
+	}
+
+	if best < 0 || bestDistance > g.maxDistanceKm {
+		return "", ErrNoAddressFound
+	}
+
+	entry := g.entries[best]
+	if entry.countryCode == "" {
+		return entry.name, nil
+	}
+	return entry.name + ", " + entry.countryCode, nil
+}
+
+func gazetteerCellFor(lat, lon float64) gazetteerCell {
+	return gazetteerCell{
+		x: int(math.Floor(lon / gazetteerCellSize)),
+		y: int(math.Floor(lat / gazetteerCellSize)),
+	}
+}
@@ -0,0 +1,55 @@
+package geocoding
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Trimmed GeoNames rows (id, name, ascii name, alternate names, lat, lon, class, code, country code)
+const testGazetteer = `# comment lines are ignored
+1277333	Bengaluru	Bengaluru	Bangalore	12.97194	77.59369	P	PPLA	IN
+1264527	Koramangala	Koramangala		12.93520	77.62450	P	PPLX	IN
+1275339	Mumbai	Mumbai	Bombay	19.07283	72.88261	P	PPLA	IN
+`
+
+func TestGazetteerGeocoder_ReverseGeocode(t *testing.T) {
+	t.Parallel()
+
+	geocoder, err := ParseGazetteer(strings.NewReader(testGazetteer), 5.0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected string
+		err      error
+	}{
+		{"Exact match", 12.97194, 77.59369, "Bengaluru, IN", nil},
+		{"Closest of two candidates", 12.9400, 77.6200, "Koramangala, IN", nil},
+		{"Other city", 19.0700, 72.8800, "Mumbai, IN", nil},
+		{"Too far from everything", 28.6139, 77.2090, "", ErrNoAddressFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			address, err := geocoder.ReverseGeocode(context.Background(), tt.lat, tt.lon)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, address)
+		})
+	}
+}
+
+func TestParseGazetteer_InvalidLine(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseGazetteer(strings.NewReader("1\tNowhere\tNowhere\t\tnot-a-number\t1.0\tP\tPPL\tXX\n"), 5.0)
+	require.Error(t, err)
+}
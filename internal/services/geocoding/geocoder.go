@@ -0,0 +1,33 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoAddressFound is returned when nothing in the gazetteer is close enough to the point
+	ErrNoAddressFound = errors.New("no address found for the given location")
+)
+
+// Geocoder defines the contract for turning coordinates into human-readable addresses
+type Geocoder interface {
+	ReverseGeocode(ctx context.Context, lat, lon float64) (string, error)
+}
+
+// FormatCoordinates is the address of last resort when nothing better is known
+func FormatCoordinates(lat, lon float64) string {
+	return fmt.Sprintf("%.5f, %.5f", lat, lon)
+}
+
+type noopGeocoder struct{}
+
+// NewNoopGeocoder never finds an address, used when no gazetteer is configured
+func NewNoopGeocoder() Geocoder {
+	return noopGeocoder{}
+}
+
+func (noopGeocoder) ReverseGeocode(_ context.Context, _, _ float64) (string, error) {
+	return "", ErrNoAddressFound
+}
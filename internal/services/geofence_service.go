@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidCoordinates       = errors.New("invalid coordinates")
+	ErrPickupOutsideServiceArea = errors.New("pickup location is outside the service area")
+	ErrPickupInRestrictedZone   = errors.New("pickups are not allowed at this location")
+	ErrDropoffInRestrictedZone  = errors.New("drop-offs are not allowed at this location")
+	ErrInvalidGeofence          = errors.New("invalid geofence")
+)
+
+type CreateGeofenceParams struct {
+	Name                string
+	City                string
+	Type                models.GeofenceType
+	Polygon             []models.GeoPoint
+	NoPickup            bool
+	NoDropoff           bool
+	FareMultiplier      float64
+	FlatSurcharge       float64
+	RequiresDriverQueue bool
+}
+
+// TripZones holds the geofences a trip touches, resolved during validation
+type TripZones struct {
+	// City of the service area containing the pickup, empty if no service areas are configured
+	City         string
+	PickupZones  []models.Geofence
+	DropoffZones []models.Geofence
+}
+
+// Airport returns the first airport zone containing the pickup or the drop-off, nil if none
+func (z *TripZones) Airport() *models.Geofence {
+	for _, zones := range [][]models.Geofence{z.PickupZones, z.DropoffZones} {
+		for i := range zones {
+			if zones[i].Type == models.GeofenceTypeAirport {
+				return &zones[i]
+			}
+		}
+	}
+	return nil
+}
+
+type GeofenceService interface {
+	CreateGeofence(ctx context.Context, params CreateGeofenceParams) (*models.Geofence, error)
+	ListGeofences(ctx context.Context, city string) ([]models.Geofence, error)
+	DeleteGeofence(ctx context.Context, id uuid.UUID) error
+
+	// ZonesAt returns every active geofence containing the given point
+	ZonesAt(ctx context.Context, lat, lon float64) ([]models.Geofence, error)
+	// ValidateTrip checks pickup and drop-off against service areas and restricted zones
+	ValidateTrip(ctx context.Context, pickupLat, pickupLon, dropoffLat, dropoffLon float64) (*TripZones, error)
+	// ValidateDropoffs checks points the passenger gets out at (stops, new destinations) against restricted zones
+	ValidateDropoffs(ctx context.Context, points []models.ExactLocation) error
+}
+
+type geofenceService struct {
+	geofenceRepo repositories.GeofenceRepository
+}
+
+func NewGeofenceService(geofenceRepo repositories.GeofenceRepository) GeofenceService {
+	return &geofenceService{
+		geofenceRepo: geofenceRepo,
+	}
+}
+
+func (s *geofenceService) CreateGeofence(ctx context.Context, params CreateGeofenceParams) (*models.Geofence, error) {
+	// 1. Validate
+	if params.Name == "" || params.City == "" {
+		return nil, fmt.Errorf("%w: name and city are required", ErrInvalidGeofence)
+	}
+	if !params.Type.IsValid() {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidGeofence, params.Type)
+	}
+	if len(params.Polygon) < 3 {
+		return nil, fmt.Errorf("%w: polygon needs at least 3 points", ErrInvalidGeofence)
+	}
+	for _, p := range params.Polygon {
+		if !util.IsValidCoordinate(p.Latitude, p.Longitude) {
+			return nil, fmt.Errorf("%w: polygon has invalid coordinates", ErrInvalidGeofence)
+		}
+	}
+
+	fareMultiplier := params.FareMultiplier
+	if fareMultiplier <= 0 {
+		fareMultiplier = 1.0
+	}
+
+	now := time.Now()
+
+	// 2. Create Geofence
+	geofence := &models.Geofence{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		Name:                params.Name,
+		City:                params.City,
+		Type:                params.Type,
+		IsActive:            true,
+		Polygon:             params.Polygon,
+		NoPickup:            params.NoPickup,
+		NoDropoff:           params.NoDropoff,
+		FareMultiplier:      fareMultiplier,
+		FlatSurcharge:       params.FlatSurcharge,
+		RequiresDriverQueue: params.RequiresDriverQueue,
+	}
+
+	if err := s.geofenceRepo.Create(ctx, geofence); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("geofence_id", geofence.ID.String()).
+		Str("city", geofence.City).
+		Str("type", geofence.Type.String()).
+		Msg("Geofence created")
+	return geofence, nil
+}
+
+func (s *geofenceService) ListGeofences(ctx context.Context, city string) ([]models.Geofence, error) {
+	return s.geofenceRepo.List(ctx, city)
+}
+
+func (s *geofenceService) DeleteGeofence(ctx context.Context, id uuid.UUID) error {
+	return s.geofenceRepo.Delete(ctx, id)
+}
+
+func (s *geofenceService) ZonesAt(ctx context.Context, lat, lon float64) ([]models.Geofence, error) {
+	geofences, err := s.geofenceRepo.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return zonesContaining(geofences, lat, lon), nil
+}
+
+func (s *geofenceService) ValidateTrip(ctx context.Context, pickupLat, pickupLon, dropoffLat, dropoffLon float64) (*TripZones, error) {
+	// 1. Sanity check coordinates
+	if !util.IsValidCoordinate(pickupLat, pickupLon) || !util.IsValidCoordinate(dropoffLat, dropoffLon) {
+		return nil, ErrInvalidCoordinates
+	}
+
+	// 2. Load active geofences
+	// TODO: Cache geofences in memory, they change rarely compared to how often we read them
+	geofences, err := s.geofenceRepo.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := &TripZones{
+		PickupZones:  zonesContaining(geofences, pickupLat, pickupLon),
+		DropoffZones: zonesContaining(geofences, dropoffLat, dropoffLon),
+	}
+
+	// 3. Service Area check
+	// If no service area is configured at all we don't restrict pickups (e.g. local development)
+	hasServiceAreas := false
+	for _, g := range geofences {
+		if g.Type == models.GeofenceTypeServiceArea {
+			hasServiceAreas = true
+			break
+		}
+	}
+	if hasServiceAreas {
+		for _, g := range zones.PickupZones {
+			if g.Type == models.GeofenceTypeServiceArea {
+				zones.City = g.City
+				break
+			}
+		}
+		if zones.City == "" {
+			return nil, ErrPickupOutsideServiceArea
+		}
+	}
+
+	// 4. Restricted Zone checks
+	for _, g := range zones.PickupZones {
+		if g.Type == models.GeofenceTypeRestricted && g.NoPickup {
+			return nil, ErrPickupInRestrictedZone
+		}
+	}
+	for _, g := range zones.DropoffZones {
+		if g.Type == models.GeofenceTypeRestricted && g.NoDropoff {
+			return nil, ErrDropoffInRestrictedZone
+		}
+	}
+
+	return zones, nil
+}
+
+func (s *geofenceService) ValidateDropoffs(ctx context.Context, points []models.ExactLocation) error {
+	if len(points) == 0 {
+		return nil
+	}
+	for _, p := range points {
+		if !util.IsValidCoordinate(p.Latitude, p.Longitude) {
+			return ErrInvalidCoordinates
+		}
+	}
+
+	geofences, err := s.geofenceRepo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range points {
+		for _, g := range zonesContaining(geofences, p.Latitude, p.Longitude) {
+			if g.Type == models.GeofenceTypeRestricted && g.NoDropoff {
+				return ErrDropoffInRestrictedZone
+			}
+		}
+	}
+	return nil
+}
+
+func zonesContaining(geofences []models.Geofence, lat, lon float64) []models.Geofence {
+	zones := make([]models.Geofence, 0)
+	for _, g := range geofences {
+		if g.Contains(lat, lon) {
+			zones = append(zones, g)
+		}
+	}
+	return zones
+}
@@ -4,21 +4,34 @@ import (
 	"context"
 	"sync"
 
+	"CabBookingService/internal/models"
 	"CabBookingService/internal/repositories"
 	"CabBookingService/internal/util"
 
 	"github.com/google/uuid"
 )
 
+const (
+	// Below this movement GPS noise dominates, so the previous heading is kept
+	minHeadingMovementKm = 0.02
+)
+
 // driverLocation is a simple struct to hold coordinates
 type driverLocation struct {
-	latitude  float64
-	longitude float64
+	latitude   float64
+	longitude  float64
+	heading    float64 // Degrees clockwise from north, derived from the last movement
+	hasHeading bool
 }
 
+// LocationService tracks live driver locations. Drivers are identified by their account ID.
 type LocationService interface {
 	UpdateDriverLocation(ctx context.Context, driverID uuid.UUID, latitude, longitude float64) error
 	GetNearbyDrivers(lat, lon float64, radiusKm float64) []uuid.UUID
+	// GetDriverLocation returns the last live location of a driver, false if the driver never reported one
+	GetDriverLocation(driverID uuid.UUID) (*models.ExactLocation, bool)
+	// GetDriverHeading returns the direction the driver is moving in, false until the driver moved
+	GetDriverHeading(driverID uuid.UUID) (float64, bool)
 }
 
 // NaiveLocationService uses a map and loops through all drivers.
@@ -42,15 +55,23 @@ func (s *naiveLocationService) UpdateDriverLocation(ctx context.Context, driverI
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.driverLocations[driverID] = driverLocation{
+	location := driverLocation{
 		latitude:  latitude,
 		longitude: longitude,
 	}
+	if previous, ok := s.driverLocations[driverID]; ok {
+		location.heading, location.hasHeading = previous.heading, previous.hasHeading
+		if util.DistanceKm(previous.latitude, previous.longitude, latitude, longitude) >= minHeadingMovementKm {
+			location.heading = util.BearingDegrees(previous.latitude, previous.longitude, latitude, longitude)
+			location.hasHeading = true
+		}
+	}
+	s.driverLocations[driverID] = location
 
 	// 2. Persist to DB (Reliable)
 	// We do this async or strictly depending on requirements.
 	// For now simply do it synchronously.
-	return s.driverRepo.UpdateLocation(ctx, driverID, latitude, longitude)
+	return s.driverRepo.UpdateLocationByAccountID(ctx, driverID, latitude, longitude)
 }
 
 func (s *naiveLocationService) GetNearbyDrivers(lat, lon float64, radiusKm float64) []uuid.UUID {
@@ -67,3 +88,25 @@ func (s *naiveLocationService) GetNearbyDrivers(lat, lon float64, radiusKm float
 	}
 	return nearbyDrivers
 }
+
+func (s *naiveLocationService) GetDriverLocation(driverID uuid.UUID) (*models.ExactLocation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	location, ok := s.driverLocations[driverID]
+	if !ok {
+		return nil, false
+	}
+	return &models.ExactLocation{Latitude: location.latitude, Longitude: location.longitude}, true
+}
+
+func (s *naiveLocationService) GetDriverHeading(driverID uuid.UUID) (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	location, ok := s.driverLocations[driverID]
+	if !ok || !location.hasHeading {
+		return 0, false
+	}
+	return location.heading, true
+}
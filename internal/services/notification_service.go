@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+
+	"CabBookingService/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type DriverNotificationType string
+
+const (
+	DriverNotificationRideOffer          DriverNotificationType = "RIDE_OFFER"
+	DriverNotificationDestinationChanged DriverNotificationType = "DESTINATION_CHANGED"
+	DriverNotificationNextRide           DriverNotificationType = "NEXT_RIDE"
+	DriverNotificationRideReminder       DriverNotificationType = "RIDE_REMINDER"
+	DriverNotificationReservationEnded   DriverNotificationType = "RESERVATION_ENDED"
+)
+
+// DriverNotification is a message pushed to a driver's device
+type DriverNotification struct {
+	Type      DriverNotificationType
+	BookingID uuid.UUID
+	Message   string
+}
+
+type PassengerNotificationType string
+
+const (
+	PassengerNotificationDriverReserved PassengerNotificationType = "DRIVER_RESERVED"
+	PassengerNotificationRideReminder   PassengerNotificationType = "RIDE_REMINDER"
+	PassengerNotificationDriverReleased PassengerNotificationType = "DRIVER_RELEASED"
+)
+
+// PassengerNotification is a message pushed to a passenger's device
+type PassengerNotification struct {
+	Type      PassengerNotificationType
+	BookingID uuid.UUID
+	Message   string
+}
+
+// NotificationService is the channel ride offers and ride updates are delivered through
+type NotificationService interface {
+	NotifyDriver(ctx context.Context, driver *models.Driver, notification DriverNotification) error
+	NotifyPassenger(ctx context.Context, passenger *models.Passenger, notification PassengerNotification) error
+}
+
+type logNotificationService struct{}
+
+// NewLogNotificationService only logs notifications
+// TODO: Integrate with real notification service (e.g., Firebase, Twilio)
+func NewLogNotificationService() NotificationService {
+	return &logNotificationService{}
+}
+
+func (s *logNotificationService) NotifyDriver(_ context.Context, driver *models.Driver, notification DriverNotification) error {
+	log.Info().
+		Str("booking_id", notification.BookingID.String()).
+		Str("driver_id", driver.ID.String()).
+		Str("driver_name", driver.Name).
+		Str("phone", driver.PhoneNumber).
+		Str("type", string(notification.Type)).
+		Str("message", notification.Message).
+		Msg(">> Push Notification Sent")
+	return nil
+}
+
+func (s *logNotificationService) NotifyPassenger(_ context.Context, passenger *models.Passenger, notification PassengerNotification) error {
+	log.Info().
+		Str("booking_id", notification.BookingID.String()).
+		Str("passenger_id", passenger.ID.String()).
+		Str("passenger_name", passenger.Name).
+		Str("phone", passenger.PhoneNumber).
+		Str("type", string(notification.Type)).
+		Str("message", notification.Message).
+		Msg(">> Push Notification Sent")
+	return nil
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/ranking"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// OfferMode decides how a ride is offered to the selected drivers
+type OfferMode string
+
+const (
+	OfferModeBroadcast  OfferMode = "broadcast"  // Every selected driver gets the offer at once, the first to accept wins
+	OfferModeSequential OfferMode = "sequential" // One driver at a time in ranked order, each with an acceptance window
+)
+
+func (m OfferMode) IsValid() bool {
+	return m == OfferModeBroadcast || m == OfferModeSequential
+}
+
+var (
+	ErrNotOfferedToDriver = errors.New("you are not authorized to accept this ride")
+	ErrOfferExpired       = errors.New("the offer for this ride has expired")
+	ErrOfferDeclined      = errors.New("the offer for this ride was declined")
+)
+
+type OfferSettings struct {
+	DefaultMode   OfferMode
+	CityModes     map[string]OfferMode // Overrides the default mode for some cities
+	Timeout       time.Duration        // Acceptance window of sequential offers
+	TopK          int                  // Number of best ranked drivers that get a broadcast offer
+	SweepInterval time.Duration        // How often sequential offers that ran out are moved on
+	SweepBatch    int                  // Sequences moved on per query of the sweeper
+}
+
+type OfferService interface {
+	// OfferRide offers the booking to the ranked drivers (best first) using the mode of the booking's city
+	OfferRide(ctx context.Context, booking *models.Booking, ranked []ranking.ScoredDriver)
+	// CheckOffer returns nil if the driver holds an offer for the booking that can still be accepted
+	CheckOffer(ctx context.Context, bookingID, driverID uuid.UUID) error
+	// AcceptOffer marks the driver's offer accepted, failing with ErrOfferExpired if it ran out or was
+	// answered in the meantime. Run it in the accepting transaction.
+	AcceptOffer(ctx context.Context, bookingID, driverID uuid.UUID) error
+	// DeclineOffer rejects an offer, run it in the declining transaction. It returns true for a
+	// sequential offer, which moves on to the next driver with AdvanceSequence once committed.
+	DeclineOffer(ctx context.Context, bookingID, driverID uuid.UUID) (bool, error)
+	// AdvanceSequence offers a sequential ride to the next driver in line
+	AdvanceSequence(ctx context.Context, bookingID uuid.UUID)
+	// Start moves sequential offers on to the next driver once their window ran out, until ctx is
+	// cancelled. The sweeper is counted in running.
+	Start(ctx context.Context, running *sync.WaitGroup)
+	// SweepDue moves on the sequential offers that ran out and returns how many bookings it looked at
+	SweepDue(ctx context.Context) int
+}
+
+type offerService struct {
+	bookingRepo         repositories.BookingRepository
+	driverRepo          repositories.DriverRepository
+	notificationService NotificationService
+	settings            OfferSettings
+}
+
+func NewOfferService(
+	bookingRepo repositories.BookingRepository,
+	driverRepo repositories.DriverRepository,
+	notificationService NotificationService,
+	settings OfferSettings,
+) OfferService {
+	// City names are matched case-insensitively
+	cityModes := make(map[string]OfferMode, len(settings.CityModes))
+	for city, mode := range settings.CityModes {
+		cityModes[strings.ToLower(city)] = mode
+	}
+	settings.CityModes = cityModes
+
+	return &offerService{
+		bookingRepo:         bookingRepo,
+		driverRepo:          driverRepo,
+		notificationService: notificationService,
+		settings:            settings,
+	}
+}
+
+func (s *offerService) OfferRide(ctx context.Context, booking *models.Booking, ranked []ranking.ScoredDriver) {
+	if len(ranked) == 0 {
+		return
+	}
+
+	mode := s.modeFor(booking.City)
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Str("city", booking.City).
+		Str("mode", string(mode)).
+		Int("candidate_count", len(ranked)).
+		Msg("Offering ride")
+
+	if mode == OfferModeSequential {
+		// The sequence outlives the matching request, declines and the sweeper move it on
+		s.offerSequentially(ctx, booking, ranked)
+		return
+	}
+	s.broadcast(ctx, booking, ranked)
+}
+
+func (s *offerService) CheckOffer(ctx context.Context, bookingID, driverID uuid.UUID) error {
+	_, err := s.liveOffer(ctx, bookingID, driverID)
+	return err
+}
+
+// liveOffer returns the offer of the booking to the driver if it can still be accepted
+func (s *offerService) liveOffer(ctx context.Context, bookingID, driverID uuid.UUID) (*models.BookingOffer, error) {
+	offer, err := s.bookingRepo.GetOffer(ctx, bookingID, driverID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOfferedToDriver
+		}
+		return nil, err
+	}
+
+	switch {
+	case offer.Status == models.OfferStatusQueued:
+		// Not their turn yet
+		return nil, ErrNotOfferedToDriver
+	case offer.Status == models.OfferStatusDeclined:
+		return nil, ErrOfferDeclined
+	case !offer.IsActive(time.Now()):
+		return nil, ErrOfferExpired
+	}
+	return offer, nil
+}
+
+func (s *offerService) AcceptOffer(ctx context.Context, bookingID, driverID uuid.UUID) error {
+	accepted, err := s.bookingRepo.AcceptOffer(ctx, bookingID, driverID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		// Expired or declined between the check and the accept
+		return ErrOfferExpired
+	}
+	return nil
+}
+
+func (s *offerService) DeclineOffer(ctx context.Context, bookingID, driverID uuid.UUID) (bool, error) {
+	offer, err := s.liveOffer(ctx, bookingID, driverID)
+	if err != nil {
+		return false, err
+	}
+
+	declined, err := s.bookingRepo.UpdateOfferStatus(ctx, bookingID, driverID, models.OfferStatusOffered, models.OfferStatusDeclined)
+	if err != nil {
+		return false, err
+	}
+	if !declined {
+		// Expired between the check and the update
+		return false, ErrOfferExpired
+	}
+
+	log.Info().
+		Str("booking_id", bookingID.String()).
+		Str("driver_id", driverID.String()).
+		Msg("Offer declined by driver")
+	return offer.Sequence != nil, nil
+}
+
+func (s *offerService) AdvanceSequence(ctx context.Context, bookingID uuid.UUID) {
+	s.advance(ctx, bookingID)
+}
+
+func (s *offerService) Start(ctx context.Context, running *sync.WaitGroup) {
+	ticker := time.NewTicker(s.settings.SweepInterval)
+	log.Info().Dur("interval", s.settings.SweepInterval).Msg("Offer sweeper started")
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info().Msg("Offer sweeper stopped")
+				return
+			case <-ticker.C:
+				// A full batch means there is a backlog, keep going instead of waiting for the next tick
+				for ctx.Err() == nil {
+					if s.SweepDue(ctx) < s.settings.SweepBatch {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (s *offerService) SweepDue(ctx context.Context) int {
+	bookingIDs, err := s.bookingRepo.ListStalledOfferSequences(ctx, time.Now(), s.settings.SweepBatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list stalled offer sequences")
+		return 0
+	}
+	for _, bookingID := range bookingIDs {
+		s.advance(ctx, bookingID)
+	}
+	return len(bookingIDs)
+}
+
+// broadcast offers the ride to the top K drivers at once
+func (s *offerService) broadcast(ctx context.Context, booking *models.Booking, ranked []ranking.ScoredDriver) {
+	if s.settings.TopK > 0 && len(ranked) > s.settings.TopK {
+		ranked = ranked[:s.settings.TopK]
+	}
+
+	selectedDrivers := make([]models.Driver, 0, len(ranked))
+	selectedIDs := make([]uuid.UUID, 0, len(ranked))
+	for i, candidate := range ranked {
+		logScoreBreakdown(booking.ID, i+1, candidate)
+		selectedDrivers = append(selectedDrivers, candidate.Driver)
+		selectedIDs = append(selectedIDs, candidate.Driver.ID)
+	}
+
+	if err := s.bookingRepo.AddNotifiedDrivers(ctx, booking.ID, selectedDrivers); err != nil {
+		log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Error saving notified drivers")
+		return
+	}
+	if err := s.driverRepo.IncrementOffersReceived(ctx, selectedIDs); err != nil {
+		// Only affects future acceptance rates, keep dispatching
+		log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Error counting driver offers")
+	}
+
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Int("driver_count", len(selectedDrivers)).
+		Msg("Found matching drivers. Notifying...")
+	for i := range selectedDrivers {
+		s.notifyOffer(ctx, booking, &selectedDrivers[i])
+	}
+}
+
+// offerSequentially offers the ride to one driver at a time until one accepts or the list runs out.
+// The ranked drivers are queued in the database, so any instance can move the sequence on after a restart.
+// A driver who lets the acceptance window run out is treated as having declined.
+func (s *offerService) offerSequentially(ctx context.Context, booking *models.Booking, ranked []ranking.ScoredDriver) {
+	// 1. Queue the drivers in ranked order
+	driverIDs := make([]uuid.UUID, 0, len(ranked))
+	for i, candidate := range ranked {
+		logScoreBreakdown(booking.ID, i+1, candidate)
+		driverIDs = append(driverIDs, candidate.Driver.ID)
+	}
+	if err := s.bookingRepo.CreateOfferSequence(ctx, booking.ID, driverIDs); err != nil {
+		log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Error saving offer sequence")
+		return
+	}
+
+	// 2. Offer to the first driver, declines and the sweeper take it from there
+	s.advance(ctx, booking.ID)
+}
+
+// advance offers the booking to the next queued driver, unless it was accepted or the current offer is still live
+func (s *offerService) advance(ctx context.Context, bookingID uuid.UUID) {
+	// 1. Move on in the database
+	now := time.Now()
+	offer, err := s.bookingRepo.AdvanceOfferSequence(ctx, bookingID, now, now.Add(s.settings.Timeout))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		log.Info().Str("booking_id", bookingID.String()).Msg("No driver accepted the ride")
+		return
+	case err != nil:
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Error moving offer on to the next driver")
+		return
+	case offer == nil:
+		return
+	}
+
+	// 2. Notify the driver, the offer stands even if the notification fails and runs out like an unanswered one
+	if err := s.driverRepo.IncrementOffersReceived(ctx, []uuid.UUID{offer.DriverId}); err != nil {
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Error counting driver offers")
+	}
+	booking, err := s.bookingRepo.GetByID(ctx, bookingID)
+	if err != nil {
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Failed to fetch booking, driver not notified")
+		return
+	}
+	driver, err := s.driverRepo.GetByID(ctx, offer.DriverId)
+	if err != nil {
+		log.Error().Err(err).Str("booking_id", bookingID.String()).Msg("Failed to fetch driver, driver not notified")
+		return
+	}
+	log.Info().
+		Str("booking_id", bookingID.String()).
+		Str("driver_id", offer.DriverId.String()).
+		Int("rank", *offer.Sequence+1).
+		Msg("Offering ride to the next driver")
+	s.notifyOffer(ctx, booking, driver)
+}
+
+func (s *offerService) notifyOffer(ctx context.Context, booking *models.Booking, driver *models.Driver) {
+	message := fmt.Sprintf("New ride request, pickup at %s", booking.PickupAddress)
+	if len(booking.Stops) > 0 {
+		message = fmt.Sprintf("%s with %d stop(s)", message, len(booking.Stops))
+	}
+	err := s.notificationService.NotifyDriver(ctx, driver, DriverNotification{
+		Type:      DriverNotificationRideOffer,
+		BookingID: booking.ID,
+		Message:   message,
+	})
+	if err != nil {
+		log.Error().Err(err).
+			Str("booking_id", booking.ID.String()).
+			Str("driver_id", driver.ID.String()).
+			Msg("Failed to notify driver")
+	}
+}
+
+func (s *offerService) modeFor(city string) OfferMode {
+	if mode, ok := s.settings.CityModes[strings.ToLower(city)]; ok {
+		return mode
+	}
+	return s.settings.DefaultMode
+}
+
+func logScoreBreakdown(bookingID uuid.UUID, rank int, candidate ranking.ScoredDriver) {
+	log.Info().
+		Str("booking_id", bookingID.String()).
+		Str("driver_id", candidate.Driver.ID.String()).
+		Int("rank", rank).
+		Float64("score", candidate.Score).
+		Dur("pickup_eta", candidate.PickupETA).
+		Float64("eta_factor", candidate.Factors.ETA).
+		Float64("rating_factor", candidate.Factors.Rating).
+		Float64("acceptance_factor", candidate.Factors.AcceptanceRate).
+		Float64("idle_factor", candidate.Factors.IdleTime).
+		Float64("heading_factor", candidate.Factors.Heading).
+		Msg("Driver selected for dispatch")
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services/ranking"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// ranked lists the drivers as matching would, best first
+func ranked(drivers ...*models.Driver) []ranking.ScoredDriver {
+	scored := make([]ranking.ScoredDriver, 0, len(drivers))
+	for i, driver := range drivers {
+		scored = append(scored, ranking.ScoredDriver{Driver: *driver, PickupETA: time.Duration(i+1) * time.Minute})
+	}
+	return scored
+}
+
+func (p *testPlatform) offer(t *testing.T, bookingID, driverID uuid.UUID) *models.BookingOffer {
+	t.Helper()
+	offer, err := p.bookingRepo.GetOffer(context.Background(), bookingID, driverID)
+	require.NoError(t, err)
+	return offer
+}
+
+func TestBroadcastOffers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("The best drivers get the offer at once", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		var drivers []*models.Driver
+		for _, name := range []string{"a", "b", "c", "d"} {
+			drivers = append(drivers, p.addDriver(t, name, near(1)))
+		}
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+
+		p.offerService.OfferRide(ctx, booking, ranked(drivers...))
+		for _, driver := range drivers[:3] {
+			require.Equal(t, []uuid.UUID{booking.ID}, p.notifications.offersTo(driver.ID))
+			require.NoError(t, p.offerService.CheckOffer(ctx, booking.ID, driver.ID))
+		}
+		// Only the top K are offered the ride
+		require.Empty(t, p.notifications.offersTo(drivers[3].ID))
+		require.ErrorIs(t, p.offerService.CheckOffer(ctx, booking.ID, drivers[3].ID), ErrNotOfferedToDriver)
+	})
+
+	t.Run("Another broadcast keeps the earlier offers", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		declining := p.addDriver(t, "declining", near(1))
+		other := p.addDriver(t, "other", near(2))
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+
+		p.offerService.OfferRide(ctx, booking, ranked(declining))
+		require.NoError(t, p.bookingService.DeclineBooking(ctx, declining.AccountId, booking.ID))
+
+		// The booking is matched again, the decline isn't forgotten
+		p.offerService.OfferRide(ctx, booking, ranked(declining, other))
+		require.Equal(t, models.OfferStatusDeclined, p.offer(t, booking.ID, declining.ID).Status)
+		require.ErrorIs(t, p.bookingService.AcceptBooking(ctx, declining.AccountId, booking.ID), ErrOfferDeclined)
+		require.NoError(t, p.bookingService.AcceptBooking(ctx, other.AccountId, booking.ID))
+		require.Equal(t, models.BookingStatusAccepted, p.booking(t, booking.ID).Status)
+	})
+}
+
+func TestSequentialOffers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("A decline moves the offer on to the next driver", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t, withOfferMode(OfferModeSequential))
+		first := p.addDriver(t, "first", near(1))
+		second := p.addDriver(t, "second", near(2))
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+
+		p.offerService.OfferRide(ctx, booking, ranked(first, second))
+		require.Equal(t, []uuid.UUID{booking.ID}, p.notifications.offersTo(first.ID))
+		require.Empty(t, p.notifications.offersTo(second.ID))
+		// Not their turn yet
+		require.ErrorIs(t, p.bookingService.AcceptBooking(ctx, second.AccountId, booking.ID), ErrNotOfferedToDriver)
+
+		require.NoError(t, p.bookingService.DeclineBooking(ctx, first.AccountId, booking.ID))
+		require.Equal(t, []uuid.UUID{booking.ID}, p.notifications.offersTo(second.ID))
+		require.NoError(t, p.bookingService.AcceptBooking(ctx, second.AccountId, booking.ID))
+		require.Equal(t, second.ID, *p.booking(t, booking.ID).DriverId)
+	})
+
+	t.Run("The sweeper moves on an offer that ran out", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t, withOfferMode(OfferModeSequential), withOfferTimeout(20*time.Millisecond))
+		first := p.addDriver(t, "first", near(1))
+		second := p.addDriver(t, "second", near(2))
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+
+		p.offerService.OfferRide(ctx, booking, ranked(first, second))
+		// A live offer is left alone
+		require.Zero(t, p.offerService.SweepDue(ctx))
+
+		time.Sleep(30 * time.Millisecond)
+		require.Equal(t, 1, p.offerService.SweepDue(ctx))
+		require.Equal(t, models.OfferStatusExpired, p.offer(t, booking.ID, first.ID).Status)
+		require.Equal(t, models.OfferStatusOffered, p.offer(t, booking.ID, second.ID).Status)
+		require.Equal(t, []uuid.UUID{booking.ID}, p.notifications.offersTo(second.ID))
+	})
+
+	t.Run("An offer that was moved on can't be accepted", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t, withOfferMode(OfferModeSequential), withOfferTimeout(20*time.Millisecond))
+		first := p.addDriver(t, "first", near(1))
+		second := p.addDriver(t, "second", near(2))
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+
+		p.offerService.OfferRide(ctx, booking, ranked(first, second))
+		time.Sleep(30 * time.Millisecond)
+		require.ErrorIs(t, p.bookingService.AcceptBooking(ctx, first.AccountId, booking.ID), ErrOfferExpired)
+
+		p.offerService.SweepDue(ctx)
+		require.ErrorIs(t, p.bookingService.AcceptBooking(ctx, first.AccountId, booking.ID), ErrOfferExpired)
+		require.Equal(t, models.BookingStatusRequested, p.booking(t, booking.ID).Status)
+		require.Nil(t, p.booking(t, booking.ID).DriverId)
+	})
+
+	t.Run("A re-match queues its drivers after the earlier ones", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t, withOfferMode(OfferModeSequential))
+		first := p.addDriver(t, "first", near(1))
+		second := p.addDriver(t, "second", near(2))
+		third := p.addDriver(t, "third", near(3))
+		booking := p.addBooking(t, p.addPassenger(t, "passenger"), testPickup)
+
+		p.offerService.OfferRide(ctx, booking, ranked(first, second))
+		p.offerService.OfferRide(ctx, booking, ranked(third, first))
+		require.Equal(t, 0, *p.offer(t, booking.ID, first.ID).Sequence)
+		require.Equal(t, 1, *p.offer(t, booking.ID, second.ID).Sequence)
+		require.Equal(t, 2, *p.offer(t, booking.ID, third.ID).Sequence)
+
+		// The earlier drivers still go first
+		require.NoError(t, p.bookingService.DeclineBooking(ctx, first.AccountId, booking.ID))
+		require.Equal(t, []uuid.UUID{booking.ID}, p.notifications.offersTo(second.ID))
+		require.NoError(t, p.bookingService.DeclineBooking(ctx, second.AccountId, booking.ID))
+		require.Equal(t, []uuid.UUID{booking.ID}, p.notifications.offersTo(third.ID))
+	})
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/util"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OutboxSettings tune how often and how eagerly the relay delivers events
+type OutboxSettings struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// An event claimed by a relay that died is delivered again after this long
+	ClaimTimeout time.Duration
+	// Failed deliveries are retried after RetryBackoff, doubling with every attempt up to MaxBackoff,
+	// and given up on after MaxAttempts attempts
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	MaxAttempts  int
+}
+
+// OutboxRelay delivers the events of the outbox to the queue. An event is only marked sent after
+// it was published, so every event is delivered at least once, also across restarts.
+type OutboxRelay interface {
+	// Start polls the outbox until ctx is cancelled. The loop is counted in running, so shutdown can
+	// wait for the events in hand before closing the queue.
+	Start(ctx context.Context, running *sync.WaitGroup)
+	// RelayDue delivers the events that are due now and returns how many were delivered
+	RelayDue(ctx context.Context) int
+}
+
+type outboxRelay struct {
+	outboxRepo   repositories.OutboxRepository
+	messageQueue queue.MessageQueue
+	settings     OutboxSettings
+}
+
+func NewOutboxRelay(outboxRepo repositories.OutboxRepository, messageQueue queue.MessageQueue, settings OutboxSettings) OutboxRelay {
+	return &outboxRelay{
+		outboxRepo:   outboxRepo,
+		messageQueue: messageQueue,
+		settings:     settings,
+	}
+}
+
+func (r *outboxRelay) Start(ctx context.Context, running *sync.WaitGroup) {
+	ticker := time.NewTicker(r.settings.PollInterval)
+	log.Info().Dur("interval", r.settings.PollInterval).Msg("Outbox relay started")
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info().Msg("Outbox relay stopped")
+				return
+			case <-ticker.C:
+				// A full batch means there is a backlog, keep going instead of waiting for the next tick
+				for ctx.Err() == nil {
+					if r.RelayDue(ctx) < r.settings.BatchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (r *outboxRelay) RelayDue(ctx context.Context) int {
+	// 1. Claim the due events, so other instances skip them
+	now := time.Now()
+	events, err := r.outboxRepo.ClaimDue(ctx, now, r.settings.ClaimTimeout, r.settings.BatchSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to claim outbox events")
+		return 0
+	}
+
+	delivered := 0
+	for i := range events {
+		event := &events[i]
+
+		// 2. Decode, an event that can't be decoded never will be
+		envelope, err := outboxCodec.Decode([]byte(event.Payload))
+		if err != nil {
+			r.markDead(ctx, event, fmt.Errorf("decoding outbox event: %w", err))
+			continue
+		}
+
+		// 3. Publish, a failed event is retried with backoff until the attempts run out
+		if err := r.messageQueue.Publish(ctx, event.Topic, envelope); err != nil {
+			if event.Attempts+1 >= r.settings.MaxAttempts {
+				r.markDead(ctx, event, err)
+				continue
+			}
+			nextAttemptAt := time.Now().Add(util.Backoff(r.settings.RetryBackoff, r.settings.MaxBackoff, event.Attempts+1))
+			log.Warn().Err(err).
+				Str("event_id", event.ID.String()).
+				Str("topic", event.Topic).
+				Int("attempts", event.Attempts+1).
+				Time("next_attempt_at", nextAttemptAt).
+				Msg("Failed to deliver outbox event")
+			if err := r.outboxRepo.MarkFailed(ctx, event.ID, nextAttemptAt, err.Error()); err != nil {
+				log.Error().Err(err).Str("event_id", event.ID.String()).Msg("Failed to record outbox delivery failure")
+			}
+			continue
+		}
+
+		// 4. Mark sent. If this fails the event is delivered again once the claim times out.
+		if err := r.outboxRepo.MarkSent(ctx, event.ID, time.Now()); err != nil {
+			log.Error().Err(err).Str("event_id", event.ID.String()).Msg("Failed to mark outbox event sent")
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// markDead gives up on an event, it stays in the outbox with the reason for inspection
+func (r *outboxRelay) markDead(ctx context.Context, event *models.OutboxEvent, reason error) {
+	log.Error().Err(reason).
+		Str("event_id", event.ID.String()).
+		Str("topic", event.Topic).
+		Int("attempts", event.Attempts+1).
+		Msg("Giving up on outbox event")
+	if err := r.outboxRepo.MarkDead(ctx, event.ID, time.Now(), reason.Error()); err != nil {
+		log.Error().Err(err).Str("event_id", event.ID.String()).Msg("Failed to record outbox delivery failure")
+	}
+}
+
+// outboxCodec encodes the envelopes of the outbox, stored as JSONB
+var outboxCodec queue.Codec = queue.JSONCodec{}
+
+// addOutboxEvent writes event to the outbox. Called inside a transaction, it is only published
+// if the transaction commits.
+func addOutboxEvent(ctx context.Context, outboxRepo repositories.OutboxRepository, topic string, event queue.Event) error {
+	envelope, err := queue.NewEnvelope(ctx, event)
+	if err != nil {
+		return err
+	}
+	payload, err := outboxCodec.Encode(envelope)
+	if err != nil {
+		return fmt.Errorf("encoding %s event: %w", envelope.Type, err)
+	}
+	now := time.Now()
+	return outboxRepo.Add(ctx, &models.OutboxEvent{
+		ID:            envelope.ID,
+		CreatedAt:     now,
+		Topic:         topic,
+		Payload:       string(payload),
+		NextAttemptAt: now,
+	})
+}
@@ -7,40 +7,45 @@ import (
 	"CabBookingService/internal/domain"
 	"CabBookingService/internal/models"
 	"CabBookingService/internal/repositories"
-	"CabBookingService/internal/util"
 
 	"github.com/google/uuid"
 )
 
 type PaymentService interface {
-	ProcessPayment(ctx context.Context, booking *models.Booking) error
+	// PrepareReceipt works out the fare of a completed booking and the receipt to charge it with.
+	// The fare depends on the routing provider, keep it out of transactions.
+	PrepareReceipt(ctx context.Context, booking *models.Booking) (*models.PaymentReceipt, error)
+	// ProcessPayment charges a receipt made by PrepareReceipt and stores it
+	ProcessPayment(ctx context.Context, receipt *models.PaymentReceipt) error
 }
 
 type paymentService struct {
 	paymentRepo repositories.PaymentRepository
+	fareService FareService
 }
 
-func NewPaymentService(paymentRepo repositories.PaymentRepository) PaymentService {
+func NewPaymentService(paymentRepo repositories.PaymentRepository, fareService FareService) PaymentService {
 	return &paymentService{
 		paymentRepo: paymentRepo,
+		fareService: fareService,
 	}
 }
 
-func (s *paymentService) ProcessPayment(ctx context.Context, booking *models.Booking) error {
-	// 1. Calculate Amount (Mock Logic)
-	// Real world: Calculate based on Distance + Time + Surge
-	// Let's assume $5 Base + Distance * $2
-	distance := util.DistanceKm(booking.PickupLatitude, booking.PickupLongitude, booking.DropoffLatitude, booking.DropoffLongitude)
-	amount := 5.0 + (distance * 2.0)
+func (s *paymentService) PrepareReceipt(ctx context.Context, booking *models.Booking) (*models.PaymentReceipt, error) {
+	// 1. Calculate Amount
+	fare, err := s.fareService.CalculateFare(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. Get Payment Gateway (Default to "Cash" or "Stripe" seed data)
 	gateway, err := s.paymentRepo.GetGatewayByName(ctx, domain.PaymentGatewayStripe)
 	if err != nil {
 		// TODO: Fallback to Cash or other gateway
-		return err
+		return nil, err
 	}
 
-	receipt := &models.PaymentReceipt{
+	return &models.PaymentReceipt{
 		BaseModel: models.BaseModel{
 			ID:        uuid.New(),
 			CreatedAt: time.Now(),
@@ -48,10 +53,12 @@ func (s *paymentService) ProcessPayment(ctx context.Context, booking *models.Boo
 		},
 		BookingId:        booking.ID,
 		PaymentGatewayID: gateway.ID,
-		Amount:           amount,
-		Currency:         "USD",
+		Amount:           fare.Amount,
+		Currency:         fare.Currency,
 		Details:          "Payment processed successfully",
-	}
+	}, nil
+}
 
+func (s *paymentService) ProcessPayment(ctx context.Context, receipt *models.PaymentReceipt) error {
 	return s.paymentRepo.CreateReceipt(ctx, receipt)
 }
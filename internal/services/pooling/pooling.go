@@ -0,0 +1,244 @@
+package pooling
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services/routing"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoFeasibleInsertion = errors.New("no insertion keeps every rider within the pooling limits")
+
+// Stop is a pickup or drop-off of a rider on a shared trip
+type Stop struct {
+	BookingID uuid.UUID
+	Kind      models.TripStopKind
+	Location  models.ExactLocation
+}
+
+// Rider is a booking on a shared trip
+type Rider struct {
+	BookingID      uuid.UUID
+	DirectDuration time.Duration // Driving time from pickup to drop-off when riding alone
+	OnBoardFor     time.Duration // Time spent in the car so far, zero until picked up
+}
+
+// Limits keep sharing worthwhile for every rider
+type Limits struct {
+	Capacity int // Riders in the car at once
+	// Extra in-vehicle time a rider accepts, relative to their direct duration
+	MaxDetourRatio float64
+	// Cap on the extra in-vehicle time regardless of the trip length, zero for no cap
+	MaxDetour time.Duration
+	// Longest wait for the pickup of a new rider, zero for no cap
+	MaxPickupETA time.Duration
+}
+
+// allowedDetour is the extra in-vehicle time a rider with this direct duration accepts
+func (l Limits) allowedDetour(direct time.Duration) time.Duration {
+	allowed := time.Duration(float64(direct) * l.MaxDetourRatio)
+	if l.MaxDetour > 0 && allowed > l.MaxDetour {
+		return l.MaxDetour
+	}
+	return allowed
+}
+
+// Insertion is the stop sequence of a trip after adding a rider
+type Insertion struct {
+	Stops         []Stop
+	AddedDuration time.Duration // Extra driving time of the whole trip
+	PickupETA     time.Duration // Time until the new rider is picked up
+}
+
+// Planner inserts riders into shared trips
+type Planner struct {
+	routingProvider routing.RoutingProvider
+	limits          Limits
+}
+
+func NewPlanner(routingProvider routing.RoutingProvider, limits Limits) *Planner {
+	return &Planner{
+		routingProvider: routingProvider,
+		limits:          limits,
+	}
+}
+
+// Insert finds the cheapest place for the pickup and drop-off of a new rider among the remaining
+// stops of a trip, starting from the driver's current location. Every position pair is tried,
+// the pickup always before the drop-off. Riders of the trip whose pickup isn't among the stops
+// are on board already.
+func (p *Planner) Insert(
+	ctx context.Context,
+	start models.ExactLocation,
+	stops []Stop,
+	riders []Rider,
+	newRider Rider,
+	pickup, dropoff models.ExactLocation,
+) (*Insertion, error) {
+	durations := newDurationCache(p.routingProvider)
+
+	ridersByID := make(map[uuid.UUID]Rider, len(riders)+1)
+	for _, rider := range riders {
+		ridersByID[rider.BookingID] = rider
+	}
+	ridersByID[newRider.BookingID] = newRider
+
+	baseDuration, _, err := p.evaluate(ctx, durations, start, stops, ridersByID, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pickupStop := Stop{BookingID: newRider.BookingID, Kind: models.TripStopPickup, Location: pickup}
+	dropoffStop := Stop{BookingID: newRider.BookingID, Kind: models.TripStopDropoff, Location: dropoff}
+
+	var best *Insertion
+	for i := 0; i <= len(stops); i++ {
+		for j := i; j <= len(stops); j++ {
+			candidate := make([]Stop, 0, len(stops)+2)
+			candidate = append(candidate, stops[:i]...)
+			candidate = append(candidate, pickupStop)
+			candidate = append(candidate, stops[i:j]...)
+			candidate = append(candidate, dropoffStop)
+			candidate = append(candidate, stops[j:]...)
+
+			total, pickupETA, err := p.evaluate(ctx, durations, start, candidate, ridersByID, newRider.BookingID)
+			if errors.Is(err, ErrNoFeasibleInsertion) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if best == nil || total-baseDuration < best.AddedDuration {
+				best = &Insertion{
+					Stops:         candidate,
+					AddedDuration: total - baseDuration,
+					PickupETA:     pickupETA,
+				}
+			}
+		}
+	}
+	if best == nil {
+		return nil, ErrNoFeasibleInsertion
+	}
+	return best, nil
+}
+
+// evaluate drives the stops in order and checks capacity and detours. It returns the total
+// driving time and the time at which the tracked rider is picked up.
+func (p *Planner) evaluate(
+	ctx context.Context,
+	durations *durationCache,
+	start models.ExactLocation,
+	stops []Stop,
+	riders map[uuid.UUID]Rider,
+	tracked uuid.UUID,
+) (time.Duration, time.Duration, error) {
+	// Riders with a drop-off but no pickup ahead are in the car already
+	onBoard := 0
+	pickups := make(map[uuid.UUID]bool, len(stops))
+	for _, stop := range stops {
+		if stop.Kind == models.TripStopPickup {
+			pickups[stop.BookingID] = true
+		}
+	}
+	for _, stop := range stops {
+		if stop.Kind == models.TripStopDropoff && !pickups[stop.BookingID] {
+			onBoard++
+		}
+	}
+
+	var elapsed, trackedPickup time.Duration
+	pickedUpAt := make(map[uuid.UUID]time.Duration, len(stops))
+	at := start
+	for _, stop := range stops {
+		leg, err := durations.get(ctx, at, stop.Location)
+		if err != nil {
+			return 0, 0, err
+		}
+		elapsed += leg
+		at = stop.Location
+
+		if stop.Kind == models.TripStopPickup {
+			onBoard++
+			if p.limits.Capacity > 0 && onBoard > p.limits.Capacity {
+				return 0, 0, ErrNoFeasibleInsertion
+			}
+			pickedUpAt[stop.BookingID] = elapsed
+			if stop.BookingID == tracked {
+				if p.limits.MaxPickupETA > 0 && elapsed > p.limits.MaxPickupETA {
+					return 0, 0, ErrNoFeasibleInsertion
+				}
+				trackedPickup = elapsed
+			}
+			continue
+		}
+
+		onBoard--
+		rider := riders[stop.BookingID]
+		ride := rider.OnBoardFor + elapsed
+		if pickedUp, ok := pickedUpAt[stop.BookingID]; ok {
+			ride = elapsed - pickedUp
+		}
+		if ride-rider.DirectDuration > p.limits.allowedDetour(rider.DirectDuration) {
+			return 0, 0, ErrNoFeasibleInsertion
+		}
+	}
+	return elapsed, trackedPickup, nil
+}
+
+// SplitLegCosts shares the cost of every leg of a driven trip equally among the riders on board
+// during that leg. stops are the visited stops in order, legCosts[i] is the cost of driving from
+// stops[i] to stops[i+1]. Legs without anyone on board aren't charged to anyone.
+func SplitLegCosts(stops []Stop, legCosts []float64) map[uuid.UUID]float64 {
+	shares := make(map[uuid.UUID]float64)
+	onBoard := make(map[uuid.UUID]bool)
+	for i := 0; i < len(stops)-1 && i < len(legCosts); i++ {
+		if stops[i].Kind == models.TripStopPickup {
+			onBoard[stops[i].BookingID] = true
+		} else {
+			delete(onBoard, stops[i].BookingID)
+		}
+		if len(onBoard) == 0 {
+			continue
+		}
+		share := legCosts[i] / float64(len(onBoard))
+		for bookingID := range onBoard {
+			shares[bookingID] += share
+		}
+	}
+	for bookingID, share := range shares {
+		shares[bookingID] = math.Round(share*100) / 100
+	}
+	return shares
+}
+
+// durationCache avoids routing the same pair twice while trying every insertion
+type durationCache struct {
+	routingProvider routing.RoutingProvider
+	durations       map[[2]models.ExactLocation]time.Duration
+}
+
+func newDurationCache(routingProvider routing.RoutingProvider) *durationCache {
+	return &durationCache{
+		routingProvider: routingProvider,
+		durations:       make(map[[2]models.ExactLocation]time.Duration),
+	}
+}
+
+func (c *durationCache) get(ctx context.Context, from, to models.ExactLocation) (time.Duration, error) {
+	key := [2]models.ExactLocation{from, to}
+	if duration, ok := c.durations[key]; ok {
+		return duration, nil
+	}
+	route, err := c.routingProvider.Route(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	c.durations[key] = route.Duration
+	return route.Duration, nil
+}
@@ -0,0 +1,136 @@
+package pooling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services/routing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// km returns a point on the equator, about x km east of the origin
+func km(x float64) models.ExactLocation {
+	return models.ExactLocation{Latitude: 0, Longitude: x / 111.32}
+}
+
+func direct(t *testing.T, provider routing.RoutingProvider, from, to models.ExactLocation) time.Duration {
+	route, err := provider.Route(context.Background(), from, to)
+	require.NoError(t, err)
+	return route.Duration
+}
+
+func kinds(stops []Stop) []string {
+	result := make([]string, 0, len(stops))
+	for _, stop := range stops {
+		result = append(result, stop.Kind.String())
+	}
+	return result
+}
+
+func TestPlannerInsert(t *testing.T) {
+	t.Parallel()
+
+	provider := routing.NewHaversineProvider(30)
+	ctx := context.Background()
+	limits := Limits{Capacity: 3, MaxDetourRatio: 0.5, MaxDetour: 15 * time.Minute}
+
+	t.Run("Empty trip", func(t *testing.T) {
+		t.Parallel()
+		planner := NewPlanner(provider, limits)
+		rider := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(1), km(5))}
+
+		insertion, err := planner.Insert(ctx, km(0), nil, nil, rider, km(1), km(5))
+		require.NoError(t, err)
+		require.Equal(t, []string{"PICKUP", "DROPOFF"}, kinds(insertion.Stops))
+		require.Equal(t, direct(t, provider, km(0), km(1)), insertion.PickupETA)
+	})
+
+	t.Run("Rider on the way is picked up and dropped off before the current rider", func(t *testing.T) {
+		t.Parallel()
+		planner := NewPlanner(provider, limits)
+		current := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(0), km(10))}
+		stops := []Stop{{BookingID: current.BookingID, Kind: models.TripStopDropoff, Location: km(10)}}
+		rider := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(2), km(8))}
+
+		insertion, err := planner.Insert(ctx, km(0), stops, []Rider{current}, rider, km(2), km(8))
+		require.NoError(t, err)
+		require.Equal(t, []string{"PICKUP", "DROPOFF", "DROPOFF"}, kinds(insertion.Stops))
+		require.Equal(t, rider.BookingID, insertion.Stops[0].BookingID)
+		require.Equal(t, current.BookingID, insertion.Stops[2].BookingID)
+		require.InDelta(t, 0, insertion.AddedDuration.Seconds(), 1)
+	})
+
+	t.Run("Full car picks up after the current drop-off", func(t *testing.T) {
+		t.Parallel()
+		planner := NewPlanner(provider, Limits{Capacity: 1, MaxDetourRatio: 0.5})
+		current := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(0), km(10))}
+		stops := []Stop{{BookingID: current.BookingID, Kind: models.TripStopDropoff, Location: km(10)}}
+		rider := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(2), km(8))}
+
+		insertion, err := planner.Insert(ctx, km(0), stops, []Rider{current}, rider, km(2), km(8))
+		require.NoError(t, err)
+		require.Equal(t, current.BookingID, insertion.Stops[0].BookingID)
+		require.Equal(t, []string{"DROPOFF", "PICKUP", "DROPOFF"}, kinds(insertion.Stops))
+	})
+
+	t.Run("Opposite direction exceeds the limits", func(t *testing.T) {
+		t.Parallel()
+		planner := NewPlanner(provider, Limits{Capacity: 3, MaxDetourRatio: 0.2, MaxPickupETA: 10 * time.Minute})
+		current := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(0), km(10))}
+		stops := []Stop{{BookingID: current.BookingID, Kind: models.TripStopDropoff, Location: km(10)}}
+		rider := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(-1), km(-8))}
+
+		_, err := planner.Insert(ctx, km(0), stops, []Rider{current}, rider, km(-1), km(-8))
+		require.ErrorIs(t, err, ErrNoFeasibleInsertion)
+	})
+
+	t.Run("Time already spent on board counts towards the detour", func(t *testing.T) {
+		t.Parallel()
+		planner := NewPlanner(provider, Limits{Capacity: 3, MaxDetourRatio: 0.5, MaxPickupETA: 10 * time.Minute})
+		directDuration := direct(t, provider, km(0), km(10))
+		// Stuck in traffic, the rider has no detour left
+		current := Rider{BookingID: uuid.New(), DirectDuration: directDuration, OnBoardFor: directDuration / 2}
+		stops := []Stop{{BookingID: current.BookingID, Kind: models.TripStopDropoff, Location: km(10)}}
+		rider := Rider{BookingID: uuid.New(), DirectDuration: direct(t, provider, km(1), km(3))}
+
+		insertion, err := planner.Insert(ctx, km(0), stops, []Rider{current}, rider, km(1), km(3))
+		require.NoError(t, err)
+		require.Equal(t, []string{"PICKUP", "DROPOFF", "DROPOFF"}, kinds(insertion.Stops))
+
+		_, err = planner.Insert(ctx, km(0), stops, []Rider{current}, rider, km(-2), km(3))
+		require.ErrorIs(t, err, ErrNoFeasibleInsertion)
+	})
+}
+
+func TestSplitLegCosts(t *testing.T) {
+	t.Parallel()
+
+	a, b := uuid.New(), uuid.New()
+	stops := []Stop{
+		{BookingID: a, Kind: models.TripStopPickup},
+		{BookingID: b, Kind: models.TripStopPickup},
+		{BookingID: a, Kind: models.TripStopDropoff},
+		{BookingID: b, Kind: models.TripStopDropoff},
+	}
+
+	tests := []struct {
+		name     string
+		legCosts []float64
+		expected map[uuid.UUID]float64
+	}{
+		{"Shared middle leg", []float64{4, 6, 2}, map[uuid.UUID]float64{a: 7, b: 5}},
+		{"Free legs", []float64{0, 0, 0}, map[uuid.UUID]float64{a: 0, b: 0}},
+		{"Rounded to cents", []float64{0, 0.01, 0}, map[uuid.UUID]float64{a: 0.01, b: 0.01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, SplitLegCosts(stops, tt.legCosts))
+		})
+	}
+}
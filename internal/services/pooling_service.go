@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/pooling"
+	"CabBookingService/internal/services/ranking"
+	"CabBookingService/internal/services/routing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSharedRideWithStops    = errors.New("shared rides can't have intermediate stops")
+	ErrSharedRideNoLongerFits = errors.New("the ride no longer fits on your shared trip")
+)
+
+// TripPlan is the stop sequence of a driver's shared trip once a booking joins it
+type TripPlan struct {
+	trip      *models.Trip // Nil when the booking starts a new trip
+	driverID  uuid.UUID
+	bookingID uuid.UUID
+	stops     []pooling.Stop
+}
+
+type PoolingService interface {
+	// Candidates splits the drivers into those who can add the booking to their active shared trip
+	// (smallest detour first) and drivers without a trip. Drivers on a trip only get shared bookings.
+	Candidates(ctx context.Context, booking *models.Booking, drivers []models.Driver) ([]ranking.ScoredDriver, []models.Driver, error)
+	// PlanJoin checks that a shared booking still fits the driver's trip, before the driver accepts it.
+	// Call it in the accepting transaction with the driver row locked, so the plan can't go stale before JoinTrip.
+	PlanJoin(ctx context.Context, booking *models.Booking, driver *models.Driver) (*TripPlan, error)
+	// JoinTrip puts the accepted booking on the driver's trip, starting a new trip if the driver has none
+	JoinTrip(ctx context.Context, plan *TripPlan) error
+	// PickedUp marks the pickup of a shared booking as visited
+	PickedUp(ctx context.Context, booking *models.Booking) error
+	// DroppedOff marks the drop-off of a shared booking as visited. Returns true once the trip is over.
+	DroppedOff(ctx context.Context, booking *models.Booking) (bool, error)
+	// LeaveTrip removes the stops of a cancelled booking. Returns true if nobody is left on the trip.
+	LeaveTrip(ctx context.Context, booking *models.Booking) (bool, error)
+}
+
+type poolingService struct {
+	tripRepo        repositories.TripRepository
+	bookingRepo     repositories.BookingRepository
+	locationService LocationService
+	routingProvider routing.RoutingProvider
+	planner         *pooling.Planner
+}
+
+func NewPoolingService(
+	tripRepo repositories.TripRepository,
+	bookingRepo repositories.BookingRepository,
+	locationService LocationService,
+	routingProvider routing.RoutingProvider,
+	limits pooling.Limits,
+) PoolingService {
+	// New riders don't wait longer for a shared ride than for a solo one
+	if limits.MaxPickupETA == 0 {
+		limits.MaxPickupETA = maxPickupETA
+	}
+	return &poolingService{
+		tripRepo:        tripRepo,
+		bookingRepo:     bookingRepo,
+		locationService: locationService,
+		routingProvider: routingProvider,
+		planner:         pooling.NewPlanner(routingProvider, limits),
+	}
+}
+
+func (s *poolingService) Candidates(ctx context.Context, booking *models.Booking, drivers []models.Driver) ([]ranking.ScoredDriver, []models.Driver, error) {
+	driverIDs := make([]uuid.UUID, 0, len(drivers))
+	for _, driver := range drivers {
+		driverIDs = append(driverIDs, driver.ID)
+	}
+	trips, err := s.tripRepo.GetActiveByDrivers(ctx, driverIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	tripsByDriver := make(map[uuid.UUID]*models.Trip, len(trips))
+	for i := range trips {
+		tripsByDriver[trips[i].DriverId] = &trips[i]
+	}
+
+	var pooled []ranking.ScoredDriver
+	free := make([]models.Driver, 0, len(drivers))
+	added := make(map[uuid.UUID]time.Duration)
+	for _, driver := range drivers {
+		trip, onTrip := tripsByDriver[driver.ID]
+		if !onTrip {
+			free = append(free, driver)
+			continue
+		}
+		if !booking.IsShared {
+			continue
+		}
+
+		insertion, err := s.insert(ctx, booking, &driver, trip)
+		if err != nil {
+			if !errors.Is(err, pooling.ErrNoFeasibleInsertion) {
+				log.Debug().Err(err).
+					Str("booking_id", booking.ID.String()).
+					Str("driver_id", driver.ID.String()).
+					Msg("Could not plan shared trip")
+			}
+			continue
+		}
+		added[driver.ID] = insertion.AddedDuration
+		pooled = append(pooled, ranking.ScoredDriver{
+			Driver:    driver,
+			PickupETA: insertion.PickupETA,
+			// Joining a trip always beats starting a new one, the smaller the detour the better
+			Score: 1 / (1 + insertion.AddedDuration.Minutes()),
+		})
+	}
+
+	sort.SliceStable(pooled, func(i, j int) bool {
+		return added[pooled[i].Driver.ID] < added[pooled[j].Driver.ID]
+	})
+	return pooled, free, nil
+}
+
+func (s *poolingService) PlanJoin(ctx context.Context, booking *models.Booking, driver *models.Driver) (*TripPlan, error) {
+	plan := &TripPlan{driverID: driver.ID, bookingID: booking.ID}
+
+	trip, err := s.tripRepo.GetActiveByDriver(ctx, driver.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// First rider of a new trip
+		plan.stops = []pooling.Stop{
+			{BookingID: booking.ID, Kind: models.TripStopPickup, Location: pickupOf(booking)},
+			{BookingID: booking.ID, Kind: models.TripStopDropoff, Location: dropoffOf(booking)},
+		}
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	insertion, err := s.insert(ctx, booking, driver, trip)
+	if err != nil {
+		if errors.Is(err, pooling.ErrNoFeasibleInsertion) {
+			return nil, ErrSharedRideNoLongerFits
+		}
+		return nil, err
+	}
+	plan.trip = trip
+	plan.stops = insertion.Stops
+	return plan, nil
+}
+
+func (s *poolingService) JoinTrip(ctx context.Context, plan *TripPlan) error {
+	trip := plan.trip
+	if trip == nil {
+		now := time.Now()
+		trip = &models.Trip{
+			BaseModel: models.BaseModel{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			DriverId: plan.driverID,
+			Status:   models.TripStatusActive,
+		}
+		if err := s.tripRepo.Create(ctx, trip); err != nil {
+			return err
+		}
+	}
+
+	if err := s.tripRepo.AddBooking(ctx, trip.ID, plan.bookingID, toTripStops(plan.stops)); err != nil {
+		return err
+	}
+	log.Info().
+		Str("trip_id", trip.ID.String()).
+		Str("booking_id", plan.bookingID.String()).
+		Str("driver_id", plan.driverID.String()).
+		Int("remaining_stops", len(plan.stops)).
+		Msg("Booking joined shared trip")
+	return nil
+}
+
+func (s *poolingService) PickedUp(ctx context.Context, booking *models.Booking) error {
+	if booking.TripId == nil {
+		return nil
+	}
+	_, err := s.tripRepo.CompleteStop(ctx, *booking.TripId, booking.ID, models.TripStopPickup, time.Now())
+	return err
+}
+
+func (s *poolingService) DroppedOff(ctx context.Context, booking *models.Booking) (bool, error) {
+	if booking.TripId == nil {
+		return true, nil
+	}
+	if _, err := s.tripRepo.CompleteStop(ctx, *booking.TripId, booking.ID, models.TripStopDropoff, time.Now()); err != nil {
+		return false, err
+	}
+	return s.completeIfEmpty(ctx, *booking.TripId)
+}
+
+func (s *poolingService) LeaveTrip(ctx context.Context, booking *models.Booking) (bool, error) {
+	if booking.TripId == nil {
+		return true, nil
+	}
+	trip, err := s.tripRepo.GetByID(ctx, *booking.TripId)
+	if err != nil {
+		return false, err
+	}
+
+	remaining := make([]models.TripStop, 0)
+	for _, stop := range trip.RemainingStops() {
+		if stop.BookingId != booking.ID {
+			remaining = append(remaining, stop)
+		}
+	}
+	if err := s.tripRepo.ReplaceRemainingStops(ctx, trip.ID, remaining); err != nil {
+		return false, err
+	}
+	return s.completeIfEmpty(ctx, trip.ID)
+}
+
+// completeIfEmpty ends the trip once no stops are left
+func (s *poolingService) completeIfEmpty(ctx context.Context, tripID uuid.UUID) (bool, error) {
+	trip, err := s.tripRepo.GetByID(ctx, tripID)
+	if err != nil {
+		return false, err
+	}
+	if len(trip.RemainingStops()) > 0 {
+		return false, nil
+	}
+	if err := s.tripRepo.UpdateStatus(ctx, trip.ID, models.TripStatusCompleted); err != nil {
+		return false, err
+	}
+	log.Info().Str("trip_id", trip.ID.String()).Msg("Shared trip completed")
+	return true, nil
+}
+
+// insert plans the booking into the remaining stops of the driver's trip
+func (s *poolingService) insert(ctx context.Context, booking *models.Booking, driver *models.Driver, trip *models.Trip) (*pooling.Insertion, error) {
+	// Prefer the live location over the last persisted one
+	start := driver.LastKnownLocation
+	if location, ok := s.locationService.GetDriverLocation(driver.AccountId); ok {
+		start = location
+	}
+	if start == nil {
+		return nil, pooling.ErrNoFeasibleInsertion
+	}
+
+	now := time.Now()
+	remaining := trip.RemainingStops()
+	stops := make([]pooling.Stop, 0, len(remaining))
+	riders := make([]pooling.Rider, 0, len(remaining)/2+1)
+	seen := make(map[uuid.UUID]bool)
+	for _, stop := range remaining {
+		stops = append(stops, pooling.Stop{BookingID: stop.BookingId, Kind: stop.Kind, Location: stop.Location()})
+		if seen[stop.BookingId] {
+			continue
+		}
+		seen[stop.BookingId] = true
+
+		rider, err := s.rider(ctx, stop.BookingId, now)
+		if err != nil {
+			return nil, err
+		}
+		riders = append(riders, *rider)
+	}
+
+	newRider := pooling.Rider{BookingID: booking.ID}
+	route, err := s.routingProvider.Route(ctx, pickupOf(booking), dropoffOf(booking))
+	if err != nil {
+		return nil, err
+	}
+	newRider.DirectDuration = route.Duration
+
+	return s.planner.Insert(ctx, *start, stops, riders, newRider, pickupOf(booking), dropoffOf(booking))
+}
+
+func (s *poolingService) rider(ctx context.Context, bookingID uuid.UUID, now time.Time) (*pooling.Rider, error) {
+	booking, err := s.bookingRepo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	route, err := s.routingProvider.Route(ctx, pickupOf(booking), dropoffOf(booking))
+	if err != nil {
+		return nil, err
+	}
+
+	rider := &pooling.Rider{BookingID: booking.ID, DirectDuration: route.Duration}
+	if booking.RideStartedAt != nil {
+		rider.OnBoardFor = now.Sub(*booking.RideStartedAt)
+	}
+	return rider, nil
+}
+
+func toTripStops(stops []pooling.Stop) []models.TripStop {
+	now := time.Now()
+	tripStops := make([]models.TripStop, 0, len(stops))
+	for _, stop := range stops {
+		tripStops = append(tripStops, models.TripStop{
+			BaseModel: models.BaseModel{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			BookingId: stop.BookingID,
+			Kind:      stop.Kind,
+			Latitude:  stop.Location.Latitude,
+			Longitude: stop.Location.Longitude,
+		})
+	}
+	return tripStops
+}
+
+func pickupOf(booking *models.Booking) models.ExactLocation {
+	return models.ExactLocation{Latitude: booking.PickupLatitude, Longitude: booking.PickupLongitude}
+}
+
+func dropoffOf(booking *models.Booking) models.ExactLocation {
+	return models.ExactLocation{Latitude: booking.DropoffLatitude, Longitude: booking.DropoffLongitude}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrInvalidPreferences = errors.New("invalid ride preferences")
+
+// DriverCapabilities is what a driver and their car offer to passengers
+type DriverCapabilities struct {
+	OffersQuietRides bool
+	PetFriendly      bool
+	HasChildSeat     bool
+}
+
+type PreferenceService interface {
+	// GetPassengerPreferences returns the default ride preferences of the passenger
+	GetPassengerPreferences(ctx context.Context, passengerAccountID uuid.UUID) (*models.RidePreferences, error)
+	UpdatePassengerPreferences(ctx context.Context, passengerAccountID uuid.UUID, preferences models.RidePreferences) (*models.RidePreferences, error)
+	UpdateDriverCapabilities(ctx context.Context, driverAccountID uuid.UUID, capabilities DriverCapabilities) (*models.Driver, error)
+}
+
+type preferenceService struct {
+	passengerRepo repositories.PassengerRepository
+	driverRepo    repositories.DriverRepository
+}
+
+func NewPreferenceService(passengerRepo repositories.PassengerRepository, driverRepo repositories.DriverRepository) PreferenceService {
+	return &preferenceService{
+		passengerRepo: passengerRepo,
+		driverRepo:    driverRepo,
+	}
+}
+
+func (s *preferenceService) GetPassengerPreferences(ctx context.Context, passengerAccountID uuid.UUID) (*models.RidePreferences, error) {
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return &passenger.Preferences, nil
+}
+
+func (s *preferenceService) UpdatePassengerPreferences(ctx context.Context, passengerAccountID uuid.UUID, preferences models.RidePreferences) (*models.RidePreferences, error) {
+	// 1. Validate
+	if err := validatePreferences(preferences); err != nil {
+		return nil, err
+	}
+
+	// 2. Get Passenger Profile from Account ID
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Save
+	if err := s.passengerRepo.UpdatePreferences(ctx, passenger.ID, preferences); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("passenger_id", passenger.ID.String()).Msg("Ride preferences updated")
+	return &preferences, nil
+}
+
+func (s *preferenceService) UpdateDriverCapabilities(ctx context.Context, driverAccountID uuid.UUID, capabilities DriverCapabilities) (*models.Driver, error) {
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.driverRepo.UpdateCapabilities(ctx, driver.ID, capabilities.OffersQuietRides, capabilities.PetFriendly, capabilities.HasChildSeat)
+	if err != nil {
+		return nil, err
+	}
+	driver.OffersQuietRides = capabilities.OffersQuietRides
+	driver.Car.PetFriendly = capabilities.PetFriendly
+	driver.Car.HasChildSeat = capabilities.HasChildSeat
+
+	log.Info().Str("driver_id", driver.ID.String()).Msg("Driver capabilities updated")
+	return driver, nil
+}
+
+func validatePreferences(preferences models.RidePreferences) error {
+	if gender := preferences.PreferredDriverGender; gender != nil && !gender.IsValid() {
+		return fmt.Errorf("%w: unknown driver gender %q", ErrInvalidPreferences, *gender)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DeadLetterSink receives the messages a queue gave up on, so they can be inspected instead of
+// disappearing
+type DeadLetterSink interface {
+	DeadLetter(ctx context.Context, topic string, envelope Envelope, reason error) error
+}
+
+type logDeadLetterSink struct{}
+
+// NewLogDeadLetterSink logs dead letters with their payload, for queues without storage
+func NewLogDeadLetterSink() DeadLetterSink {
+	return logDeadLetterSink{}
+}
+
+func (logDeadLetterSink) DeadLetter(_ context.Context, topic string, envelope Envelope, reason error) error {
+	log.Error().Err(reason).
+		Str("topic", topic).
+		Str("event_id", envelope.ID.String()).
+		Str("event_type", envelope.Type).
+		Int("event_version", envelope.Version).
+		Str("correlation_id", envelope.CorrelationID).
+		RawJSON("payload", envelope.Payload).
+		Msg("Dead letter")
+	return nil
+}
+
+type storedDeadLetterSink struct {
+	repo  repositories.QueueMessageRepository
+	codec Codec
+}
+
+// NewStoredDeadLetterSink keeps dead letters in the dead-letter table of the Postgres queue
+func NewStoredDeadLetterSink(repo repositories.QueueMessageRepository, codec Codec) DeadLetterSink {
+	return &storedDeadLetterSink{repo: repo, codec: codec}
+}
+
+func (s *storedDeadLetterSink) DeadLetter(ctx context.Context, topic string, envelope Envelope, reason error) error {
+	log.Error().Err(reason).
+		Str("topic", topic).
+		Str("event_id", envelope.ID.String()).
+		Str("event_type", envelope.Type).
+		Msg("Storing dead letter")
+
+	payload, err := s.codec.Encode(envelope)
+	if err != nil {
+		return err
+	}
+	return s.repo.AddDeadLetter(ctx, &models.DeadLetter{
+		ID:        envelope.ID,
+		CreatedAt: time.Now(),
+		Topic:     topic,
+		Payload:   string(payload),
+		Attempts:  1,
+		LastError: reason.Error(),
+		QueuedAt:  envelope.OccurredAt,
+	})
+}
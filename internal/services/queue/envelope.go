@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnexpectedEventType = errors.New("unexpected event type")
+	ErrUnsupportedVersion  = errors.New("unsupported event schema version")
+)
+
+// Event is the payload of an envelope. Every event type has a fixed name and schema version,
+// the version goes up whenever a change would break existing consumers.
+type Event interface {
+	EventType() string
+	EventVersion() int
+}
+
+// Envelope wraps every message on the queue
+type Envelope struct {
+	ID         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Shared by every event caused by the same request, for tracing a request through the consumers
+	CorrelationID string          `json:"correlation_id"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// NewEnvelope wraps event, with the correlation ID of ctx. Without one the event starts a new
+// correlation, named after its own ID.
+func NewEnvelope(ctx context.Context, event Event) (Envelope, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("encoding %s event: %w", event.EventType(), err)
+	}
+
+	id := uuid.New()
+	correlationID := CorrelationID(ctx)
+	if correlationID == "" {
+		correlationID = id.String()
+	}
+	return Envelope{
+		ID:            id,
+		Type:          event.EventType(),
+		Version:       event.EventVersion(),
+		OccurredAt:    time.Now().UTC(),
+		CorrelationID: correlationID,
+		Payload:       payload,
+	}, nil
+}
+
+// DecodePayload decodes the payload of an envelope of event type T. Payloads of older schema
+// versions are decoded as well, T must stay able to read them.
+func DecodePayload[T Event](envelope Envelope) (T, error) {
+	var event T
+	if envelope.Type != event.EventType() {
+		return event, fmt.Errorf("%w: %s, expected %s", ErrUnexpectedEventType, envelope.Type, event.EventType())
+	}
+	if envelope.Version > event.EventVersion() {
+		return event, fmt.Errorf("%w: %s version %d, up to %d is supported", ErrUnsupportedVersion, envelope.Type, envelope.Version, event.EventVersion())
+	}
+	if err := json.Unmarshal(envelope.Payload, &event); err != nil {
+		return event, fmt.Errorf("decoding %s event: %w", envelope.Type, err)
+	}
+	return event, nil
+}
+
+// Codec serialises envelopes for transports that store or send bytes
+type Codec interface {
+	Encode(envelope Envelope) ([]byte, error)
+	Decode(data []byte) (Envelope, error)
+}
+
+// JSONCodec is the codec of the outbox and the Postgres queue, both store envelopes as JSONB
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(envelope Envelope) ([]byte, error) {
+	return json.Marshal(envelope)
+}
+
+func (JSONCodec) Decode(data []byte) (Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return envelope, err
+	}
+	if envelope.Type == "" {
+		return envelope, errors.New("envelope without event type")
+	}
+	return envelope, nil
+}
+
+// correlationKey is the context key of the correlation ID
+type correlationKey struct{}
+
+// WithCorrelationID returns a context whose new envelopes carry correlationID
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation ID of ctx, empty if there is none
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationKey{}).(string)
+	return correlationID
+}
@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type rideRequested struct {
+	BookingID uuid.UUID `json:"booking_id"`
+}
+
+func (rideRequested) EventType() string { return "ride.requested" }
+func (rideRequested) EventVersion() int { return 2 }
+
+type rideCancelled struct{}
+
+func (rideCancelled) EventType() string { return "ride.cancelled" }
+func (rideCancelled) EventVersion() int { return 1 }
+
+func TestNewEnvelope(t *testing.T) {
+	t.Parallel()
+
+	event := rideRequested{BookingID: uuid.New()}
+
+	t.Run("Starts a correlation without one in the context", func(t *testing.T) {
+		t.Parallel()
+		envelope, err := NewEnvelope(context.Background(), event)
+		require.NoError(t, err)
+		require.Equal(t, "ride.requested", envelope.Type)
+		require.Equal(t, 2, envelope.Version)
+		require.Equal(t, envelope.ID.String(), envelope.CorrelationID)
+	})
+
+	t.Run("Keeps the correlation of the context", func(t *testing.T) {
+		t.Parallel()
+		envelope, err := NewEnvelope(WithCorrelationID(context.Background(), "request-1"), event)
+		require.NoError(t, err)
+		require.Equal(t, "request-1", envelope.CorrelationID)
+	})
+}
+
+func TestJSONCodec(t *testing.T) {
+	t.Parallel()
+
+	event := rideRequested{BookingID: uuid.New()}
+	envelope, err := NewEnvelope(context.Background(), event)
+	require.NoError(t, err)
+
+	data, err := JSONCodec{}.Encode(envelope)
+	require.NoError(t, err)
+	decoded, err := JSONCodec{}.Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, envelope.ID, decoded.ID)
+	require.Equal(t, envelope.CorrelationID, decoded.CorrelationID)
+	require.True(t, envelope.OccurredAt.Equal(decoded.OccurredAt))
+
+	payload, err := DecodePayload[rideRequested](decoded)
+	require.NoError(t, err)
+	require.Equal(t, event, payload)
+
+	_, err = JSONCodec{}.Decode([]byte(`{"payload":{}}`))
+	require.Error(t, err, "an envelope without a type can't be routed")
+}
+
+func TestDecodePayload(t *testing.T) {
+	t.Parallel()
+
+	bookingID := uuid.New()
+	payload, err := json.Marshal(rideRequested{BookingID: bookingID})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		envelope Envelope
+		err      error
+	}{
+		{"Current version", Envelope{Type: "ride.requested", Version: 2, Payload: payload}, nil},
+		{"Older version", Envelope{Type: "ride.requested", Version: 1, Payload: payload}, nil},
+		{"Newer version", Envelope{Type: "ride.requested", Version: 3, Payload: payload}, ErrUnsupportedVersion},
+		{"Other event type", Envelope{Type: "ride.cancelled", Version: 1, Payload: payload}, ErrUnexpectedEventType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			event, err := DecodePayload[rideRequested](tt.envelope)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, bookingID, event.BookingID)
+		})
+	}
+
+	_, err = DecodePayload[rideRequested](Envelope{Type: "ride.requested", Version: 2, Payload: []byte(`{"booking_id":42}`)})
+	require.Error(t, err, "a broken payload")
+}
+
+// recordingSink remembers the dead letters
+type recordingSink struct {
+	mu      sync.Mutex
+	letters []Envelope
+	reasons []error
+}
+
+func (s *recordingSink) DeadLetter(_ context.Context, _ string, envelope Envelope, reason error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(s.letters, envelope)
+	s.reasons = append(s.reasons, reason)
+	return nil
+}
+
+func (s *recordingSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.letters)
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	sink := &recordingSink{}
+	q := NewInMemoryQueue(sink)
+	sub, err := Subscribe[rideRequested](q, "rides", "matching")
+	require.NoError(t, err)
+	messages := sub.Messages()
+
+	// A message of another type is dead-lettered, the next one still arrives
+	cancelled, err := NewEnvelope(ctx, rideCancelled{})
+	require.NoError(t, err)
+	require.NoError(t, q.Publish(ctx, "rides", cancelled))
+
+	requested := rideRequested{BookingID: uuid.New()}
+	envelope, err := NewEnvelope(ctx, requested)
+	require.NoError(t, err)
+	require.NoError(t, q.Publish(ctx, "rides", envelope))
+
+	select {
+	case message := <-messages:
+		require.Equal(t, requested, message.Payload)
+		require.Equal(t, envelope.ID, message.Envelope().ID)
+		require.NoError(t, message.Ack(ctx))
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+	}
+
+	require.Equal(t, 1, sink.count())
+	require.Equal(t, cancelled.ID, sink.letters[0].ID)
+	require.ErrorIs(t, sink.reasons[0], ErrUnexpectedEventType)
+
+	// Without retries in memory a nacked message is dead-lettered as well
+	require.NoError(t, q.Publish(ctx, "rides", envelope))
+	message := <-messages
+	require.NoError(t, message.Nack(ctx, errors.New("matching failed")))
+	require.Equal(t, 2, sink.count())
+
+	// Unsubscribing closes the messages
+	sub.Unsubscribe()
+	_, ok := <-messages
+	require.False(t, ok)
+}
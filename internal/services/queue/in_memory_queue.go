@@ -2,55 +2,359 @@ package queue
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Messages a consumer group buffers before publishers have to wait
+const inMemoryGroupBuffer = 100
+
+// InMemoryQueue fans every message out to the consumer groups of its topic. Each group buffers
+// messages in a bounded channel, publishers wait for room once a group is full.
 type InMemoryQueue struct {
-	topics map[string]chan interface{}
-	mu     sync.RWMutex
+	topics      map[string]*memoryTopic
+	broadcasts  int // Broadcast subscriptions so far, names their groups
+	closed      bool
+	mu          sync.RWMutex
+	deadLetters DeadLetterSink
+}
+
+type memoryTopic struct {
+	name   string
+	groups map[string]*memoryGroup
+
+	// Backpressure metrics
+	published    atomic.Int64
+	deadLettered atomic.Int64
+	blocked      atomic.Int64 // Deliveries that had to wait for room in a full group
+	blockedNanos atomic.Int64
+}
+
+type memoryGroup struct {
+	name        string
+	broadcast   bool // The group of a single subscriber without a named group
+	ch          chan Delivery
+	removed     chan struct{} // Closed when the last subscriber left
+	subscribers map[*memorySubscription]struct{}
 }
 
-func NewInMemoryQueue() *InMemoryQueue {
+// NewInMemoryQueue returns a queue without retries, failed messages go straight to deadLetters
+func NewInMemoryQueue(deadLetters DeadLetterSink) *InMemoryQueue {
 	return &InMemoryQueue{
-		topics: make(map[string]chan interface{}),
+		topics:      make(map[string]*memoryTopic),
+		deadLetters: deadLetters,
 	}
 }
 
-func (q *InMemoryQueue) Publish(ctx context.Context, topic string, message interface{}) error {
-	q.mu.RLock()
-	ch, exists := q.topics[topic]
-	q.mu.RUnlock()
-	if !exists {
-		// If topic doesn't exist, create it on the fly (or return error depending on strictness)
-		// For this MVP, let's create it safely.
-		q.mu.Lock()
-		ch = make(chan interface{}, 100) // buffered channel
-		q.topics[topic] = ch
+// Publish hands the message to every consumer group of the topic. If ctx is done while waiting for
+// a full group, the groups before it already got the message.
+func (q *InMemoryQueue) Publish(ctx context.Context, topic string, envelope Envelope) error {
+	// 1. Snapshot the groups, sending must not hold the lock
+	q.mu.Lock()
+	if q.closed {
 		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	t := q.topic(topic)
+	groups := make([]*memoryGroup, 0, len(t.groups))
+	for _, group := range t.groups {
+		groups = append(groups, group)
+	}
+	q.mu.Unlock()
+
+	t.published.Add(1)
+	if len(groups) == 0 {
+		t.deadLettered.Add(1)
+		return q.deadLetters.DeadLetter(ctx, topic, envelope, ErrNoSubscribers)
 	}
 
-	// Blocking send with Context Cancellation
-	// In case the channel is full, we wait until there's space or context is done
-	// In a production system, consider using a more robust queuing mechanism to handle backpressure and retries.
+	// 2. Hand it to every group
+	for _, group := range groups {
+		delivery := inMemoryDelivery{queue: q, topic: topic, envelope: envelope}
+		if err := t.send(ctx, group, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send puts the delivery in the group's buffer, waiting for room if the group is full. The group may
+// have been removed since Publish took its snapshot, its last subscriber then possibly drained the
+// buffer before the delivery got there. Named groups are drained again so nothing is left behind.
+func (t *memoryTopic) send(ctx context.Context, group *memoryGroup, delivery Delivery) error {
+	sent := true
 	select {
-	case ch <- message:
+	case group.ch <- delivery:
+	default:
+		// The group is full, wait for room or context is done
+		t.blocked.Add(1)
+		start := time.Now()
+		select {
+		case group.ch <- delivery:
+		case <-group.removed:
+			sent = false
+		case <-ctx.Done():
+			// If the context (request) times out before we can push to queue, return error
+			t.blockedNanos.Add(int64(time.Since(start)))
+			return ctx.Err()
+		}
+		t.blockedNanos.Add(int64(time.Since(start)))
+	}
+
+	select {
+	case <-group.removed:
+		if group.broadcast {
+			// Dropped with the rest of the buffer
+			return nil
+		}
+		if !sent {
+			t.deadLetter(delivery)
+		}
+		t.drain(group)
+	default:
+	}
+	return nil
+}
+
+func (q *InMemoryQueue) Subscribe(topic string, group string) (Subscription, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+
+	t := q.topic(topic)
+	sub := &memorySubscription{
+		queue: q,
+		topic: t,
+		out:   make(chan Delivery),
+		done:  make(chan struct{}),
+	}
+
+	g, ok := t.groups[group]
+	if group == "" || !ok {
+		g = &memoryGroup{
+			name:        group,
+			broadcast:   group == "",
+			ch:          make(chan Delivery, inMemoryGroupBuffer),
+			removed:     make(chan struct{}),
+			subscribers: make(map[*memorySubscription]struct{}),
+		}
+		if g.broadcast {
+			// Nobody else joins a broadcast group, any unique name will do
+			q.broadcasts++
+			g.name = fmt.Sprintf("broadcast-%d", q.broadcasts)
+		}
+		t.groups[g.name] = g
+	}
+	g.subscribers[sub] = struct{}{}
+	sub.group = g
+
+	go sub.forward()
+	return sub, nil
+}
+
+// Close unsubscribes everyone, messages still buffered are dead-lettered
+func (q *InMemoryQueue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
 		return nil
-	case <-ctx.Done():
-		// If the context (request) times out before we can push to queue, return error
-		return ctx.Err()
 	}
+	q.closed = true
+	var subs []*memorySubscription
+	for _, t := range q.topics {
+		for _, g := range t.groups {
+			for sub := range g.subscribers {
+				subs = append(subs, sub)
+			}
+		}
+	}
+	q.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	return nil
+}
+
+// topic returns the topic, created on first use. Callers hold the write lock.
+func (q *InMemoryQueue) topic(name string) *memoryTopic {
+	t, ok := q.topics[name]
+	if !ok {
+		t = &memoryTopic{name: name, groups: make(map[string]*memoryGroup)}
+		q.topics[name] = t
+	}
+	return t
+}
+
+// TopicStats are the backpressure metrics of a topic
+type TopicStats struct {
+	Topic        string `json:"topic"`
+	Published    int64  `json:"published"`
+	DeadLettered int64  `json:"dead_lettered"` // Without subscribers, or left behind by a group
+	// Deliveries that waited for room in a full group, and the time publishers spent waiting
+	BlockedPublishes int64         `json:"blocked_publishes"`
+	BlockedTime      time.Duration `json:"blocked_time_ns"`
+	Groups           []GroupStats  `json:"groups"`
+}
+
+type GroupStats struct {
+	Group       string `json:"group"`
+	Broadcast   bool   `json:"broadcast"`
+	Subscribers int    `json:"subscribers"`
+	Depth       int    `json:"depth"` // Messages waiting for a subscriber
+	Capacity    int    `json:"capacity"`
 }
 
-func (q *InMemoryQueue) Subscribe(topic string) (<-chan interface{}, error) {
+// StatsReporter is implemented by queues that report backpressure metrics
+type StatsReporter interface {
+	Stats() []TopicStats
+}
+
+func (q *InMemoryQueue) Stats() []TopicStats {
 	q.mu.RLock()
-	ch, exists := q.topics[topic]
-	q.mu.RUnlock()
-	if !exists {
-		// Create topic if it doesn't exist
-		q.mu.Lock()
-		ch = make(chan interface{}, 100) // buffered channel
-		q.topics[topic] = ch
-		q.mu.Unlock()
+	defer q.mu.RUnlock()
+
+	stats := make([]TopicStats, 0, len(q.topics))
+	for _, t := range q.topics {
+		topicStats := TopicStats{
+			Topic:            t.name,
+			Published:        t.published.Load(),
+			DeadLettered:     t.deadLettered.Load(),
+			BlockedPublishes: t.blocked.Load(),
+			BlockedTime:      time.Duration(t.blockedNanos.Load()),
+		}
+		for _, g := range t.groups {
+			topicStats.Groups = append(topicStats.Groups, GroupStats{
+				Group:       g.name,
+				Broadcast:   g.broadcast,
+				Subscribers: len(g.subscribers),
+				Depth:       len(g.ch),
+				Capacity:    cap(g.ch),
+			})
+		}
+		sort.Slice(topicStats.Groups, func(i, j int) bool { return topicStats.Groups[i].Group < topicStats.Groups[j].Group })
+		stats = append(stats, topicStats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Topic < stats[j].Topic })
+	return stats
+}
+
+type memorySubscription struct {
+	queue *InMemoryQueue
+	topic *memoryTopic
+	group *memoryGroup
+	out   chan Delivery
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *memorySubscription) Deliveries() <-chan Delivery {
+	return s.out
+}
+
+// Unsubscribe leaves the group. The last subscriber of a named group dead-letters what is still
+// buffered, the buffer of a broadcast subscriber is dropped with it.
+func (s *memorySubscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+
+		s.queue.mu.Lock()
+		delete(s.group.subscribers, s)
+		last := len(s.group.subscribers) == 0
+		if last {
+			delete(s.topic.groups, s.group.name)
+			close(s.group.removed)
+		}
+		s.queue.mu.Unlock()
+
+		if last && !s.group.broadcast {
+			s.topic.drain(s.group)
+		}
+	})
+}
+
+// forward hands the messages of the group to this subscriber, competing with the other
+// subscribers of the group
+func (s *memorySubscription) forward() {
+	defer close(s.out)
+	for {
+		select {
+		case <-s.done:
+			return
+		case delivery := <-s.group.ch:
+			select {
+			case s.out <- delivery:
+			case <-s.done:
+				s.giveBack(delivery)
+				return
+			}
+		}
+	}
+}
+
+// giveBack returns a message taken just before unsubscribing to the group
+func (s *memorySubscription) giveBack(delivery Delivery) {
+	if s.group.broadcast {
+		// Dropped with the rest of the buffer
+		return
+	}
+	select {
+	case s.group.ch <- delivery:
+	default:
+		s.topic.deadLetter(delivery)
+		return
+	}
+
+	// If the group was removed meanwhile nobody takes it from there anymore
+	select {
+	case <-s.group.removed:
+		s.topic.drain(s.group)
+	default:
+	}
+}
+
+// drain dead-letters what is left in the buffer of a removed group
+func (t *memoryTopic) drain(group *memoryGroup) {
+	for {
+		select {
+		case delivery := <-group.ch:
+			t.deadLetter(delivery)
+		default:
+			return
+		}
 	}
-	return ch, nil
+}
+
+func (t *memoryTopic) deadLetter(delivery Delivery) {
+	t.deadLettered.Add(1)
+	_ = delivery.DeadLetter(context.Background(), ErrUnsubscribed)
+}
+
+// inMemoryDelivery is never delivered again, messages are gone once a subscriber took them
+type inMemoryDelivery struct {
+	queue    *InMemoryQueue
+	topic    string
+	envelope Envelope
+}
+
+func (d inMemoryDelivery) Envelope() Envelope {
+	return d.envelope
+}
+
+func (d inMemoryDelivery) Ack(context.Context) error {
+	return nil
+}
+
+// Nack dead-letters the message, there are no retries in memory
+func (d inMemoryDelivery) Nack(ctx context.Context, reason error) error {
+	return d.DeadLetter(ctx, reason)
+}
+
+func (d inMemoryDelivery) DeadLetter(ctx context.Context, reason error) error {
+	return d.queue.deadLetters.DeadLetter(ctx, d.topic, d.envelope, reason)
 }
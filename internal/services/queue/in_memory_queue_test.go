@@ -0,0 +1,225 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func publishRide(t *testing.T, q MessageQueue, topic string) Envelope {
+	t.Helper()
+	envelope, err := NewEnvelope(context.Background(), rideRequested{BookingID: uuid.New()})
+	require.NoError(t, err)
+	require.NoError(t, q.Publish(context.Background(), topic, envelope))
+	return envelope
+}
+
+func receive(t *testing.T, sub Subscription) Delivery {
+	t.Helper()
+	select {
+	case delivery, ok := <-sub.Deliveries():
+		require.True(t, ok, "deliveries closed")
+		return delivery
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+		return nil
+	}
+}
+
+func TestInMemoryQueueFanOut(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Every group gets the message", func(t *testing.T) {
+		t.Parallel()
+		q := NewInMemoryQueue(&recordingSink{})
+		matching, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+		analytics, err := q.Subscribe("rides", "analytics")
+		require.NoError(t, err)
+
+		envelope := publishRide(t, q, "rides")
+		require.Equal(t, envelope.ID, receive(t, matching).Envelope().ID)
+		require.Equal(t, envelope.ID, receive(t, analytics).Envelope().ID)
+	})
+
+	t.Run("Subscribers of a group compete", func(t *testing.T) {
+		t.Parallel()
+		q := NewInMemoryQueue(&recordingSink{})
+		subs := make([]Subscription, 3)
+		for i := range subs {
+			sub, err := q.Subscribe("rides", "matching")
+			require.NoError(t, err)
+			subs[i] = sub
+		}
+
+		const published = 30
+		for range published {
+			publishRide(t, q, "rides")
+		}
+
+		var mu sync.Mutex
+		seen := make(map[uuid.UUID]int)
+		var wg sync.WaitGroup
+		for _, sub := range subs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case delivery := <-sub.Deliveries():
+						mu.Lock()
+						seen[delivery.Envelope().ID]++
+						mu.Unlock()
+					case <-time.After(100 * time.Millisecond):
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Len(t, seen, published)
+		for _, count := range seen {
+			require.Equal(t, 1, count, "a message goes to one subscriber of the group")
+		}
+	})
+
+	t.Run("Broadcast subscribers get every message", func(t *testing.T) {
+		t.Parallel()
+		q := NewInMemoryQueue(&recordingSink{})
+		first, err := q.Subscribe("rides", "")
+		require.NoError(t, err)
+		second, err := q.Subscribe("rides", "")
+		require.NoError(t, err)
+
+		envelope := publishRide(t, q, "rides")
+		require.Equal(t, envelope.ID, receive(t, first).Envelope().ID)
+		require.Equal(t, envelope.ID, receive(t, second).Envelope().ID)
+	})
+
+	t.Run("Without subscribers the message is dead-lettered", func(t *testing.T) {
+		t.Parallel()
+		sink := &recordingSink{}
+		q := NewInMemoryQueue(sink)
+
+		publishRide(t, q, "rides")
+		require.Equal(t, 1, sink.count())
+		require.ErrorIs(t, sink.reasons[0], ErrNoSubscribers)
+	})
+}
+
+func TestInMemoryQueueUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("The last subscriber of a group dead-letters its backlog", func(t *testing.T) {
+		t.Parallel()
+		sink := &recordingSink{}
+		q := NewInMemoryQueue(sink)
+		sub, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+
+		publishRide(t, q, "rides")
+		publishRide(t, q, "rides")
+		sub.Unsubscribe()
+
+		_, ok := <-sub.Deliveries()
+		require.False(t, ok)
+		require.Eventually(t, func() bool { return sink.count() == 2 }, time.Second, 10*time.Millisecond)
+
+		// The group is gone, the next message has nobody to go to
+		publishRide(t, q, "rides")
+		require.Equal(t, 3, sink.count())
+	})
+
+	t.Run("A message sent to a group that just left is dead-lettered", func(t *testing.T) {
+		t.Parallel()
+		sink := &recordingSink{}
+		q := NewInMemoryQueue(sink)
+		sub, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+
+		// Publish took its snapshot of the groups before the last subscriber left
+		topic := q.topics["rides"]
+		group := topic.groups["matching"]
+		sub.Unsubscribe()
+
+		envelope, err := NewEnvelope(context.Background(), rideRequested{BookingID: uuid.New()})
+		require.NoError(t, err)
+		require.NoError(t, topic.send(context.Background(), group, inMemoryDelivery{queue: q, topic: "rides", envelope: envelope}))
+		require.Equal(t, 1, sink.count())
+		require.Zero(t, len(group.ch))
+	})
+
+	t.Run("The group keeps its backlog for the remaining subscribers", func(t *testing.T) {
+		t.Parallel()
+		sink := &recordingSink{}
+		q := NewInMemoryQueue(sink)
+		leaving, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+		staying, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+
+		leaving.Unsubscribe()
+		envelope := publishRide(t, q, "rides")
+		require.Equal(t, envelope.ID, receive(t, staying).Envelope().ID)
+		require.Zero(t, sink.count())
+	})
+
+	t.Run("Close ends every subscription", func(t *testing.T) {
+		t.Parallel()
+		q := NewInMemoryQueue(&recordingSink{})
+		matching, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+		broadcast, err := q.Subscribe("rides", "")
+		require.NoError(t, err)
+
+		require.NoError(t, q.Close())
+		_, ok := <-matching.Deliveries()
+		require.False(t, ok)
+		_, ok = <-broadcast.Deliveries()
+		require.False(t, ok)
+
+		_, err = q.Subscribe("rides", "matching")
+		require.ErrorIs(t, err, ErrQueueClosed)
+		require.ErrorIs(t, q.Publish(context.Background(), "rides", Envelope{}), ErrQueueClosed)
+	})
+}
+
+func TestInMemoryQueueStats(t *testing.T) {
+	t.Parallel()
+	q := NewInMemoryQueue(&recordingSink{})
+	_, err := q.Subscribe("rides", "matching")
+	require.NoError(t, err)
+	_, err = q.Subscribe("rides", "")
+	require.NoError(t, err)
+
+	// Fill the buffer of the groups, nobody reads. Each subscriber holds one more message.
+	for range inMemoryGroupBuffer + 1 {
+		publishRide(t, q, "rides")
+	}
+
+	// The next message waits for room until the publisher gives up
+	envelope, err := NewEnvelope(context.Background(), rideRequested{BookingID: uuid.New()})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, q.Publish(ctx, "rides", envelope), context.DeadlineExceeded)
+
+	stats := q.Stats()
+	require.Len(t, stats, 1)
+	require.Equal(t, "rides", stats[0].Topic)
+	require.EqualValues(t, inMemoryGroupBuffer+2, stats[0].Published)
+	require.GreaterOrEqual(t, stats[0].BlockedPublishes, int64(1))
+	require.Positive(t, stats[0].BlockedTime)
+
+	require.Len(t, stats[0].Groups, 2)
+	for _, group := range stats[0].Groups {
+		require.Equal(t, 1, group.Subscribers)
+		require.Equal(t, inMemoryGroupBuffer, group.Depth)
+		require.Equal(t, inMemoryGroupBuffer, group.Capacity)
+	}
+}
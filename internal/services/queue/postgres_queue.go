@@ -0,0 +1,313 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// PostgresSettings tune how the Postgres queue polls and retries
+type PostgresSettings struct {
+	PollInterval time.Duration // Wait between two polls of a topic without visible messages
+	Prefetch     int           // Messages claimed per poll
+	// A message that is not acknowledged within this time is delivered again
+	VisibilityTimeout time.Duration
+	// Nacked messages are retried after RetryBackoff, doubling up to MaxBackoff, and dead-lettered
+	// after MaxAttempts deliveries
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	MaxAttempts  int
+}
+
+// ErrGroupRequired is returned for broadcast subscriptions to the Postgres queue, a group without
+// a name couldn't be found again after a restart
+var ErrGroupRequired = errors.New("postgres queue subscriptions need a consumer group")
+
+// PostgresQueue stores messages in Postgres, so they survive restarts and several API instances
+// share one work queue. Every consumer group of a topic gets its own copy of a message, the
+// subscribers of a group poll it and compete for messages with SELECT ... FOR UPDATE SKIP LOCKED.
+// Groups stay registered after their subscribers left, their messages wait for the next one.
+type PostgresQueue struct {
+	ctx      context.Context
+	repo     repositories.QueueMessageRepository
+	codec    Codec
+	settings PostgresSettings
+
+	subscriptions map[*postgresSubscription]struct{}
+	closed        bool
+	mu            sync.Mutex
+}
+
+// NewPostgresQueue returns a queue whose subscriptions poll until ctx is cancelled or the queue is
+// closed. Envelopes are stored encoded with codec.
+func NewPostgresQueue(ctx context.Context, repo repositories.QueueMessageRepository, codec Codec, settings PostgresSettings) *PostgresQueue {
+	return &PostgresQueue{
+		ctx:           ctx,
+		repo:          repo,
+		codec:         codec,
+		settings:      settings,
+		subscriptions: make(map[*postgresSubscription]struct{}),
+	}
+}
+
+func (q *PostgresQueue) Publish(ctx context.Context, topic string, envelope Envelope) error {
+	q.mu.Lock()
+	closed := q.closed
+	q.mu.Unlock()
+	if closed {
+		return ErrQueueClosed
+	}
+
+	// 1. Encode
+	payload, err := q.codec.Encode(envelope)
+	if err != nil {
+		return fmt.Errorf("encoding message for %s: %w", topic, err)
+	}
+
+	// 2. Find the groups, without any the message is kept with the dead letters
+	groups, err := q.repo.Groups(ctx, topic)
+	if err != nil {
+		return fmt.Errorf("finding consumer groups of %s: %w", topic, err)
+	}
+	now := time.Now()
+	if len(groups) == 0 {
+		return q.repo.AddDeadLetter(ctx, &models.DeadLetter{
+			ID:        envelope.ID,
+			CreatedAt: now,
+			Topic:     topic,
+			Payload:   string(payload),
+			LastError: ErrNoSubscribers.Error(),
+			QueuedAt:  now,
+		})
+	}
+
+	// 3. Store a copy for every group
+	messages := make([]models.QueueMessage, len(groups))
+	for i, group := range groups {
+		messages[i] = models.QueueMessage{
+			ID:            uuid.New(),
+			CreatedAt:     now,
+			Topic:         topic,
+			ConsumerGroup: group,
+			Payload:       string(payload),
+			VisibleAt:     now,
+		}
+	}
+	return q.repo.Enqueue(ctx, messages)
+}
+
+func (q *PostgresQueue) Subscribe(topic string, group string) (Subscription, error) {
+	if group == "" {
+		return nil, ErrGroupRequired
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+
+	// Messages published from now on are stored for the group
+	if err := q.repo.RegisterGroup(q.ctx, topic, group); err != nil {
+		return nil, fmt.Errorf("registering consumer group %s of %s: %w", group, topic, err)
+	}
+
+	sub := &postgresSubscription{
+		queue: q,
+		// Unbuffered, messages are only claimed once the subscriber is ready for them
+		ch:   make(chan Delivery),
+		done: make(chan struct{}),
+	}
+	q.subscriptions[sub] = struct{}{}
+	go sub.poll(topic, group)
+	return sub, nil
+}
+
+// Close stops every subscription, claimed messages are released for the other instances
+func (q *PostgresQueue) Close() error {
+	q.mu.Lock()
+	q.closed = true
+	subs := make([]*postgresSubscription, 0, len(q.subscriptions))
+	for sub := range q.subscriptions {
+		subs = append(subs, sub)
+	}
+	q.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	return nil
+}
+
+type postgresSubscription struct {
+	queue *PostgresQueue
+	ch    chan Delivery
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *postgresSubscription) Deliveries() <-chan Delivery {
+	return s.ch
+}
+
+// Unsubscribe stops polling, the group keeps its messages
+func (s *postgresSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.queue.mu.Lock()
+		delete(s.queue.subscriptions, s)
+		s.queue.mu.Unlock()
+	})
+}
+
+func (s *postgresSubscription) poll(topic string, group string) {
+	defer close(s.ch)
+	q := s.queue
+	log.Info().Str("topic", topic).Str("group", group).Msg("Polling Postgres queue")
+	for {
+		// 1. Claim the next messages
+		messages, err := q.repo.Claim(q.ctx, topic, group, time.Now(), q.settings.VisibilityTimeout, q.settings.Prefetch)
+		if err != nil && q.ctx.Err() == nil {
+			log.Error().Err(err).Str("topic", topic).Str("group", group).Msg("Failed to claim queue messages")
+		}
+
+		// 2. Hand them to the subscriber
+		for i := range messages {
+			delivery := &postgresDelivery{queue: q, message: messages[i]}
+			if delivery.envelope, err = q.codec.Decode([]byte(messages[i].Payload)); err != nil {
+				// Retrying won't make it decodable
+				_ = delivery.DeadLetter(context.WithoutCancel(q.ctx), fmt.Errorf("decoding message: %w", err))
+				continue
+			}
+			if !s.handOff(delivery) {
+				q.release(messages[i:])
+				return
+			}
+		}
+
+		// 3. Poll again right away while there is a backlog, otherwise wait
+		if len(messages) == q.settings.Prefetch {
+			continue
+		}
+		select {
+		case <-time.After(q.settings.PollInterval):
+		case <-q.ctx.Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// handOff waits for the subscriber to take the delivery and returns false if it stopped first.
+// Prefetched messages can wait longer than the visibility timeout, their claim is extended halfway
+// through. A message whose claim was lost meanwhile is skipped, another consumer has it.
+func (s *postgresSubscription) handOff(delivery *postgresDelivery) bool {
+	q := s.queue
+	extendAt := delivery.message.VisibleAt.Add(-q.settings.VisibilityTimeout / 2)
+	for {
+		extend := time.NewTimer(time.Until(extendAt))
+		select {
+		case s.ch <- delivery:
+			extend.Stop()
+			return true
+		case <-q.ctx.Done():
+			extend.Stop()
+			return false
+		case <-s.done:
+			extend.Stop()
+			return false
+		case <-extend.C:
+		}
+
+		visibleAt := time.Now().Add(q.settings.VisibilityTimeout)
+		extended, err := q.repo.ExtendClaim(q.ctx, delivery.message.ID, *delivery.message.ClaimToken, visibleAt)
+		switch {
+		case err != nil:
+			// Try again on the next poll, the claim may run out meanwhile
+			log.Error().Err(err).Str("message_id", delivery.message.ID.String()).Msg("Failed to extend claim of queue message")
+			extendAt = time.Now().Add(q.settings.PollInterval)
+		case !extended:
+			log.Warn().Str("message_id", delivery.message.ID.String()).Msg("Claim of prefetched queue message lost, skipping it")
+			return true
+		default:
+			delivery.message.VisibleAt = visibleAt
+			extendAt = visibleAt.Add(-q.settings.VisibilityTimeout / 2)
+		}
+	}
+}
+
+// release gives claimed messages back on shutdown, other instances pick them up right away
+func (q *PostgresQueue) release(messages []models.QueueMessage) {
+	ids := make([]uuid.UUID, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+	// The messages of a poll share one claim
+	if err := q.repo.Release(context.WithoutCancel(q.ctx), *messages[0].ClaimToken, ids); err != nil {
+		log.Error().Err(err).Int("messages", len(ids)).Msg("Failed to release claimed queue messages, they are delivered again after the visibility timeout")
+	}
+}
+
+type postgresDelivery struct {
+	queue    *PostgresQueue
+	message  models.QueueMessage
+	envelope Envelope
+}
+
+func (d *postgresDelivery) Envelope() Envelope {
+	return d.envelope
+}
+
+func (d *postgresDelivery) Ack(ctx context.Context) error {
+	deleted, err := d.queue.repo.Delete(ctx, d.message.ID, *d.message.ClaimToken)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+func (d *postgresDelivery) Nack(ctx context.Context, reason error) error {
+	if d.message.Attempts >= d.queue.settings.MaxAttempts {
+		return d.DeadLetter(ctx, reason)
+	}
+	settings := d.queue.settings
+	visibleAt := time.Now().Add(util.Backoff(settings.RetryBackoff, settings.MaxBackoff, d.message.Attempts))
+	retried, err := d.queue.repo.Retry(ctx, d.message.ID, *d.message.ClaimToken, visibleAt, reason.Error())
+	if err != nil {
+		return err
+	}
+	if !retried {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+func (d *postgresDelivery) DeadLetter(ctx context.Context, reason error) error {
+	log.Error().Err(reason).
+		Str("message_id", d.message.ID.String()).
+		Str("topic", d.message.Topic).
+		Str("group", d.message.ConsumerGroup).
+		Int("attempts", d.message.Attempts).
+		Msg("Moving queue message to the dead-letter table")
+	moved, err := d.queue.repo.DeadLetter(ctx, d.message.ID, *d.message.ClaimToken, reason.Error())
+	if err != nil {
+		log.Error().Err(err).Str("message_id", d.message.ID.String()).Msg("Failed to dead-letter queue message")
+		return err
+	}
+	if !moved {
+		return ErrClaimLost
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/repositories/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPostgresQueue(t *testing.T, visibilityTimeout time.Duration, prefetch int) *PostgresQueue {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewPostgresQueue(ctx, memory.NewQueueMessageRepository(memory.NewStore()), JSONCodec{}, PostgresSettings{
+		PollInterval:      5 * time.Millisecond,
+		Prefetch:          prefetch,
+		VisibilityTimeout: visibilityTimeout,
+		RetryBackoff:      time.Millisecond,
+		MaxBackoff:        time.Millisecond,
+		MaxAttempts:       5,
+	})
+}
+
+func TestPostgresQueueClaims(t *testing.T) {
+	t.Parallel()
+
+	t.Run("A stale ack doesn't delete a message claimed again", func(t *testing.T) {
+		t.Parallel()
+		q := newTestPostgresQueue(t, 50*time.Millisecond, 1)
+		sub, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+		t.Cleanup(sub.Unsubscribe)
+
+		envelope := publishRide(t, q, "rides")
+		stale := receive(t, sub)
+
+		// The visibility timeout runs out, the message is delivered again
+		again := receive(t, sub)
+		require.Equal(t, envelope.ID, again.Envelope().ID)
+
+		require.ErrorIs(t, stale.Ack(context.Background()), ErrClaimLost)
+		require.ErrorIs(t, stale.Nack(context.Background(), context.Canceled), ErrClaimLost)
+		require.NoError(t, again.Ack(context.Background()))
+		require.ErrorIs(t, again.Ack(context.Background()), ErrClaimLost)
+	})
+
+	t.Run("A prefetched message keeps its claim while it waits", func(t *testing.T) {
+		t.Parallel()
+		q := newTestPostgresQueue(t, 50*time.Millisecond, 2)
+		first, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+		t.Cleanup(first.Unsubscribe)
+
+		publishRide(t, q, "rides")
+		publishRide(t, q, "rides")
+		require.NoError(t, receive(t, first).Ack(context.Background()))
+
+		// The second message waits for the first subscriber longer than the visibility timeout,
+		// another subscriber of the group doesn't get it meanwhile
+		second, err := q.Subscribe("rides", "matching")
+		require.NoError(t, err)
+		t.Cleanup(second.Unsubscribe)
+		select {
+		case <-second.Deliveries():
+			t.Fatal("prefetched message delivered to another subscriber")
+		case <-time.After(150 * time.Millisecond):
+		}
+
+		require.NoError(t, receive(t, first).Ack(context.Background()))
+	})
+}
@@ -2,10 +2,46 @@ package queue
 
 import (
 	"context"
+	"errors"
+)
+
+var (
+	ErrQueueClosed = errors.New("queue is closed")
+	// Published messages nobody subscribed to are dead-lettered with this reason
+	ErrNoSubscribers = errors.New("no subscribers")
+	// Messages left behind by a consumer group without subscribers are dead-lettered with this reason
+	ErrUnsubscribed = errors.New("consumer group unsubscribed")
+	// A delivery whose visibility timeout ran out can't be settled anymore, the message was
+	// delivered again and another consumer may be handling it
+	ErrClaimLost = errors.New("claim on the message was lost, it is delivered again")
 )
 
 // MessageQueue defines the contract for our async messaging
 type MessageQueue interface {
-	Publish(ctx context.Context, topic string, message interface{}) error
-	Subscribe(topic string) (<-chan interface{}, error)
+	Publish(ctx context.Context, topic string, envelope Envelope) error
+	// Subscribe adds a subscriber to a consumer group of topic. Every group receives each message
+	// once and the subscribers of a group compete for it. A subscriber without a group gets a group
+	// of its own, so it receives every message (broadcast).
+	Subscribe(topic string, group string) (Subscription, error)
+	// Close ends every subscription, publishing fails afterwards
+	Close() error
+}
+
+// Subscription is one subscriber of a topic
+type Subscription interface {
+	// Deliveries is closed once the subscriber unsubscribed or the queue was closed
+	Deliveries() <-chan Delivery
+	Unsubscribe()
+}
+
+// Delivery is a message handed to a subscriber. Durable queues deliver it again until it is
+// acknowledged, so every delivery must end with Ack, Nack or DeadLetter.
+type Delivery interface {
+	Envelope() Envelope
+	// Ack marks the message as handled
+	Ack(ctx context.Context) error
+	// Nack hands the message back to be retried later, or dead-lettered once it ran out of attempts
+	Nack(ctx context.Context, reason error) error
+	// DeadLetter gives up on the message right away, e.g. because it can't be decoded
+	DeadLetter(ctx context.Context, reason error) error
 }
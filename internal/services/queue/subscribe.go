@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message is a delivery with its payload decoded
+type Message[T Event] struct {
+	Delivery
+	Payload T
+}
+
+// TypedSubscription delivers the messages of a subscription with their payload decoded
+type TypedSubscription[T Event] struct {
+	subscription Subscription
+	messages     chan Message[T]
+	done         chan struct{}
+	once         sync.Once
+}
+
+// Subscribe joins group of topic like MessageQueue.Subscribe, with the payloads decoded as T.
+// Messages of another event type, a newer schema version or with a broken payload are dead-lettered.
+func Subscribe[T Event](q MessageQueue, topic string, group string) (*TypedSubscription[T], error) {
+	subscription, err := q.Subscribe(topic, group)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TypedSubscription[T]{
+		subscription: subscription,
+		messages:     make(chan Message[T]),
+		done:         make(chan struct{}),
+	}
+	go s.decode(topic)
+	return s, nil
+}
+
+// Messages is closed once unsubscribed or the queue was closed
+func (s *TypedSubscription[T]) Messages() <-chan Message[T] {
+	return s.messages
+}
+
+func (s *TypedSubscription[T]) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.subscription.Unsubscribe()
+	})
+}
+
+func (s *TypedSubscription[T]) decode(topic string) {
+	defer close(s.messages)
+	for delivery := range s.subscription.Deliveries() {
+		payload, err := DecodePayload[T](delivery.Envelope())
+		if err != nil {
+			log.Warn().Err(err).Str("topic", topic).Str("event_id", delivery.Envelope().ID.String()).Msg("Dead-lettering undecodable message")
+			if err := delivery.DeadLetter(context.Background(), err); err != nil {
+				log.Error().Err(err).Str("topic", topic).Msg("Failed to dead-letter message")
+			}
+			continue
+		}
+
+		select {
+		case s.messages <- Message[T]{Delivery: delivery, Payload: payload}:
+		case <-s.done:
+			// Taken just before unsubscribing, durable queues deliver it again
+			if err := delivery.Nack(context.Background(), ErrUnsubscribed); err != nil {
+				log.Error().Err(err).Str("topic", topic).Msg("Failed to hand back message")
+			}
+		}
+	}
+}
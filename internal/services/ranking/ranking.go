@@ -0,0 +1,175 @@
+package ranking
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services/routing"
+	"CabBookingService/internal/util"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Unrated drivers are treated as this rating so they aren't ranked last forever
+	unratedDriverRating = 4.0
+	maxDriverRating     = 5.0
+	// Idle time beyond this doesn't raise the score any further
+	maxIdleTime = 30 * time.Minute
+	// Factor value used when a signal is unknown (e.g. heading of a driver that hasn't moved yet)
+	neutralFactor = 0.5
+)
+
+// Weights of the ranking factors. They don't need to add up to 1, scores are normalized by their sum.
+type Weights struct {
+	ETA            float64
+	Rating         float64
+	AcceptanceRate float64
+	IdleTime       float64
+	Heading        float64
+}
+
+func (w Weights) sum() float64 {
+	return w.ETA + w.Rating + w.AcceptanceRate + w.IdleTime + w.Heading
+}
+
+// Factors are the normalized signals of a candidate, each within [0, 1] where higher is better
+type Factors struct {
+	ETA            float64
+	Rating         float64
+	AcceptanceRate float64
+	IdleTime       float64
+	Heading        float64
+}
+
+// Score combines the factors into a single score within [0, 1]
+func (f Factors) Score(w Weights) float64 {
+	total := w.sum()
+	if total <= 0 {
+		return 0
+	}
+	return (f.ETA*w.ETA +
+		f.Rating*w.Rating +
+		f.AcceptanceRate*w.AcceptanceRate +
+		f.IdleTime*w.IdleTime +
+		f.Heading*w.Heading) / total
+}
+
+// ScoredDriver is a candidate with its score breakdown
+type ScoredDriver struct {
+	Driver    models.Driver
+	PickupETA time.Duration
+	Factors   Factors
+	Score     float64
+}
+
+// DriverRanker orders the candidates of a booking, best first
+type DriverRanker interface {
+	Rank(ctx context.Context, drivers []models.Driver, booking *models.Booking) []ScoredDriver
+}
+
+type weightedRanker struct {
+	routingProvider routing.RoutingProvider
+	weights         Weights
+	maxETA          time.Duration
+	now             func() time.Time
+}
+
+// NewWeightedRanker scores drivers by a weighted sum of their factors.
+// maxETA is the pickup ETA at which the ETA factor drops to zero.
+func NewWeightedRanker(routingProvider routing.RoutingProvider, weights Weights, maxETA time.Duration) DriverRanker {
+	return &weightedRanker{
+		routingProvider: routingProvider,
+		weights:         weights,
+		maxETA:          maxETA,
+		now:             time.Now,
+	}
+}
+
+func (r *weightedRanker) Rank(ctx context.Context, drivers []models.Driver, booking *models.Booking) []ScoredDriver {
+	pickup := models.ExactLocation{Latitude: booking.PickupLatitude, Longitude: booking.PickupLongitude}
+	now := r.now()
+
+	scored := make([]ScoredDriver, 0, len(drivers))
+	for _, driver := range drivers {
+		// Drivers without a known location can't be routed
+		if driver.LastKnownLocation == nil {
+			continue
+		}
+		route, err := r.routingProvider.Route(ctx, *driver.LastKnownLocation, pickup)
+		if err != nil {
+			log.Debug().Err(err).
+				Str("booking_id", booking.ID.String()).
+				Str("driver_id", driver.ID.String()).
+				Msg("Could not route driver to pickup")
+			continue
+		}
+
+		// Drivers finishing a ride first have to drop off their passenger
+		eta := route.Duration + driver.BusyFor
+		factors := Factors{
+			ETA:            etaFactor(eta, r.maxETA),
+			Rating:         ratingFactor(&driver),
+			AcceptanceRate: driver.AcceptanceRate(),
+			IdleTime:       idleFactor(&driver, now),
+			Heading:        headingFactor(&driver, pickup),
+		}
+		scored = append(scored, ScoredDriver{
+			Driver:    driver,
+			PickupETA: eta,
+			Factors:   factors,
+			Score:     factors.Score(r.weights),
+		})
+	}
+
+	// Ties go to the closer driver
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].PickupETA < scored[j].PickupETA
+	})
+	return scored
+}
+
+func etaFactor(eta, maxETA time.Duration) float64 {
+	if maxETA <= 0 {
+		return neutralFactor
+	}
+	return clamp01(1 - float64(eta)/float64(maxETA))
+}
+
+func ratingFactor(driver *models.Driver) float64 {
+	rating := driver.AverageRating
+	if driver.RatingCount == 0 {
+		rating = unratedDriverRating
+	}
+	return clamp01(rating / maxDriverRating)
+}
+
+// idleFactor favours drivers who have been waiting longer for a ride
+func idleFactor(driver *models.Driver, now time.Time) float64 {
+	if driver.LastRideEndedAt == nil {
+		return 1
+	}
+	return clamp01(float64(now.Sub(*driver.LastRideEndedAt)) / float64(maxIdleTime))
+}
+
+// headingFactor is 1 for a driver heading straight to the pickup and 0 for one driving away from it
+func headingFactor(driver *models.Driver, pickup models.ExactLocation) float64 {
+	if driver.LastKnownHeading == nil || driver.LastKnownLocation == nil {
+		return neutralFactor
+	}
+	bearing := util.BearingDegrees(
+		driver.LastKnownLocation.Latitude, driver.LastKnownLocation.Longitude,
+		pickup.Latitude, pickup.Longitude,
+	)
+	return 1 - util.AngleDiffDegrees(*driver.LastKnownHeading, bearing)/180
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
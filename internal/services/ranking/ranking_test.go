@@ -0,0 +1,134 @@
+package ranking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/services/routing"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testDriver(lat, lon float64) models.Driver {
+	return models.Driver{
+		BaseModel:         models.BaseModel{ID: uuid.New()},
+		LastKnownLocation: &models.ExactLocation{Latitude: lat, Longitude: lon},
+	}
+}
+
+func TestFactorsScore(t *testing.T) {
+	t.Parallel()
+
+	factors := Factors{ETA: 1, Rating: 0.5, AcceptanceRate: 0, IdleTime: 0, Heading: 0}
+
+	tests := []struct {
+		name     string
+		weights  Weights
+		expected float64
+	}{
+		{"Only ETA", Weights{ETA: 1}, 1},
+		{"Only rating", Weights{Rating: 2}, 0.5},
+		{"ETA and acceptance", Weights{ETA: 1, AcceptanceRate: 1}, 0.5},
+		{"No weights", Weights{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.InDelta(t, tt.expected, factors.Score(tt.weights), 1e-9)
+		})
+	}
+}
+
+func TestWeightedRankerRank(t *testing.T) {
+	t.Parallel()
+
+	booking := &models.Booking{PickupLatitude: 12.9700, PickupLongitude: 77.5900}
+	provider := routing.NewHaversineProvider(30)
+	ctx := context.Background()
+
+	t.Run("Closer driver wins on ETA", func(t *testing.T) {
+		t.Parallel()
+		near := testDriver(12.9710, 77.5900)
+		far := testDriver(12.9900, 77.5900)
+
+		ranker := NewWeightedRanker(provider, Weights{ETA: 1}, 10*time.Minute)
+		ranked := ranker.Rank(ctx, []models.Driver{far, near}, booking)
+
+		require.Len(t, ranked, 2)
+		require.Equal(t, near.ID, ranked[0].Driver.ID)
+		require.Greater(t, ranked[0].Factors.ETA, ranked[1].Factors.ETA)
+	})
+
+	t.Run("Better rating beats a slightly shorter ETA", func(t *testing.T) {
+		t.Parallel()
+		nearLowRated := testDriver(12.9710, 77.5900)
+		nearLowRated.AverageRating, nearLowRated.RatingCount = 2.0, 50
+		farHighRated := testDriver(12.9730, 77.5900)
+		farHighRated.AverageRating, farHighRated.RatingCount = 5.0, 50
+
+		ranker := NewWeightedRanker(provider, Weights{ETA: 1, Rating: 1}, 10*time.Minute)
+		ranked := ranker.Rank(ctx, []models.Driver{nearLowRated, farHighRated}, booking)
+
+		require.Equal(t, farHighRated.ID, ranked[0].Driver.ID)
+	})
+
+	t.Run("Heading towards the pickup", func(t *testing.T) {
+		t.Parallel()
+		// Both drivers are south of the pickup, one is driving north (towards it), the other south
+		towards := testDriver(12.9650, 77.5900)
+		towards.LastKnownHeading = util.Ptr(0.0)
+		away := testDriver(12.9650, 77.5900)
+		away.LastKnownHeading = util.Ptr(180.0)
+		unknown := testDriver(12.9650, 77.5900)
+
+		ranker := NewWeightedRanker(provider, Weights{Heading: 1}, 10*time.Minute)
+		ranked := ranker.Rank(ctx, []models.Driver{away, unknown, towards}, booking)
+
+		require.Equal(t, towards.ID, ranked[0].Driver.ID)
+		require.InDelta(t, 1.0, ranked[0].Factors.Heading, 0.01)
+		require.Equal(t, unknown.ID, ranked[1].Driver.ID)
+		require.InDelta(t, neutralFactor, ranked[1].Factors.Heading, 1e-9)
+		require.Equal(t, away.ID, ranked[2].Driver.ID)
+		require.InDelta(t, 0.0, ranked[2].Factors.Heading, 0.01)
+	})
+
+	t.Run("Longer idle time ranks higher", func(t *testing.T) {
+		t.Parallel()
+		busy := testDriver(12.9710, 77.5900)
+		busy.LastRideEndedAt = util.Ptr(time.Now().Add(-time.Minute))
+		idle := testDriver(12.9710, 77.5900)
+		idle.LastRideEndedAt = util.Ptr(time.Now().Add(-time.Hour))
+
+		ranker := NewWeightedRanker(provider, Weights{IdleTime: 1}, 10*time.Minute)
+		ranked := ranker.Rank(ctx, []models.Driver{busy, idle}, booking)
+
+		require.Equal(t, idle.ID, ranked[0].Driver.ID)
+		require.InDelta(t, 1.0, ranked[0].Factors.IdleTime, 1e-9)
+	})
+
+	t.Run("Time left on the current ride counts towards the ETA", func(t *testing.T) {
+		t.Parallel()
+		finishing := testDriver(12.9710, 77.5900)
+		finishing.BusyFor = 3 * time.Minute
+		free := testDriver(12.9750, 77.5900)
+
+		ranker := NewWeightedRanker(provider, Weights{ETA: 1}, 10*time.Minute)
+		ranked := ranker.Rank(ctx, []models.Driver{finishing, free}, booking)
+
+		require.Len(t, ranked, 2)
+		require.Equal(t, free.ID, ranked[0].Driver.ID)
+		require.Greater(t, ranked[1].PickupETA, 3*time.Minute)
+	})
+
+	t.Run("Drivers without location are skipped", func(t *testing.T) {
+		t.Parallel()
+		ranker := NewWeightedRanker(provider, Weights{ETA: 1}, 10*time.Minute)
+		ranked := ranker.Rank(ctx, []models.Driver{{BaseModel: models.BaseModel{ID: uuid.New()}}}, booking)
+		require.Empty(t, ranked)
+	})
+}
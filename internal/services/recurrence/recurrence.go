@@ -0,0 +1,140 @@
+// Package recurrence expands weekly ride schedules into pickup times
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidDate      = errors.New("invalid date, expected YYYY-MM-DD")
+	ErrInvalidTimeOfDay = errors.New("invalid time of day, expected HH:MM")
+	ErrInvalidWeekday   = errors.New("invalid day of week, expected MON to SUN")
+)
+
+const dateLayout = "2006-01-02"
+
+// Date is a calendar day, without a time of day or a location
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ParseDate parses a YYYY-MM-DD date
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("%w: %q", ErrInvalidDate, s)
+	}
+	return DateOf(t), nil
+}
+
+// DateOf returns the day of t in t's location
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{Year: year, Month: month, Day: day}
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Time returns the start of the day in UTC, as dates are stored
+func (d Date) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+func (d Date) AddDays(n int) Date {
+	return DateOf(d.Time().AddDate(0, 0, n))
+}
+
+func (d Date) Before(other Date) bool {
+	return d.Time().Before(other.Time())
+}
+
+func (d Date) After(other Date) bool {
+	return d.Time().After(other.Time())
+}
+
+func (d Date) Weekday() time.Weekday {
+	return d.Time().Weekday()
+}
+
+// TimeOfDay is a wall clock time, minutes precision
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+// ParseTimeOfDay parses a 24-hour HH:MM time
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return TimeOfDay{}, fmt.Errorf("%w: %q", ErrInvalidTimeOfDay, s)
+	}
+	return TimeOfDay{Hour: t.Hour(), Minute: t.Minute()}, nil
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+var weekdayNames = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+// ParseWeekday parses a three letter day of week, MON to SUN
+func ParseWeekday(s string) (time.Weekday, error) {
+	i := slices.Index(weekdayNames, strings.ToUpper(s))
+	if i < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidWeekday, s)
+	}
+	return time.Weekday(i), nil
+}
+
+// WeekdayName returns the three letter name of the day, MON to SUN
+func WeekdayName(day time.Weekday) string {
+	return weekdayNames[day]
+}
+
+// Rule is a ride taken at the same local time on some days of every week
+type Rule struct {
+	TimeOfDay TimeOfDay
+	Weekdays  []time.Weekday
+	Location  *time.Location
+	StartDate Date
+	EndDate   *Date  // Last day of the series, nil if it doesn't end
+	SkipDates []Date // Days without a ride, e.g. holidays or cancelled occurrences
+}
+
+// At returns the pickup time on the given day. A time skipped by a daylight saving change moves
+// forward by the length of the gap.
+func (r Rule) At(day Date) time.Time {
+	return time.Date(day.Year, day.Month, day.Day, r.TimeOfDay.Hour, r.TimeOfDay.Minute, 0, 0, r.Location)
+}
+
+// Includes reports whether the rule has a ride on the given day
+func (r Rule) Includes(day Date) bool {
+	if day.Before(r.StartDate) || (r.EndDate != nil && day.After(*r.EndDate)) {
+		return false
+	}
+	return slices.Contains(r.Weekdays, day.Weekday()) && !slices.Contains(r.SkipDates, day)
+}
+
+// Occurrences returns the pickup times after from and up to and including until, in order
+func (r Rule) Occurrences(from, until time.Time) []time.Time {
+	var times []time.Time
+	// A day before and after, the local days of the range can start or end in another day in UTC
+	last := DateOf(until.In(r.Location)).AddDays(1)
+	for day := DateOf(from.In(r.Location)).AddDays(-1); !day.After(last); day = day.AddDays(1) {
+		if !r.Includes(day) {
+			continue
+		}
+		if at := r.At(day); at.After(from) && !at.After(until) {
+			times = append(times, at)
+		}
+	}
+	return times
+}
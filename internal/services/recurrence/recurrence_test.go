@@ -0,0 +1,114 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustDate(t *testing.T, s string) Date {
+	d, err := ParseDate(s)
+	require.NoError(t, err)
+	return d
+}
+
+func weekdays() []time.Weekday {
+	return []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	d, err := ParseDate("2026-03-09")
+	require.NoError(t, err)
+	require.Equal(t, Date{Year: 2026, Month: time.March, Day: 9}, d)
+	require.Equal(t, "2026-03-09", d.String())
+	_, err = ParseDate("09/03/2026")
+	require.ErrorIs(t, err, ErrInvalidDate)
+
+	tod, err := ParseTimeOfDay("07:45")
+	require.NoError(t, err)
+	require.Equal(t, TimeOfDay{Hour: 7, Minute: 45}, tod)
+	_, err = ParseTimeOfDay("24:00")
+	require.ErrorIs(t, err, ErrInvalidTimeOfDay)
+
+	day, err := ParseWeekday("mon")
+	require.NoError(t, err)
+	require.Equal(t, time.Monday, day)
+	require.Equal(t, "SUN", WeekdayName(time.Sunday))
+	_, err = ParseWeekday("MONDAY")
+	require.ErrorIs(t, err, ErrInvalidWeekday)
+}
+
+func TestOccurrences(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	t.Run("Weekdays only", func(t *testing.T) {
+		t.Parallel()
+		rule := Rule{TimeOfDay: TimeOfDay{Hour: 8}, Weekdays: weekdays(), Location: berlin, StartDate: mustDate(t, "2026-01-01")}
+
+		// Friday 9 Jan to Tuesday 13 Jan
+		from := time.Date(2026, 1, 9, 0, 0, 0, 0, berlin)
+		times := rule.Occurrences(from, from.AddDate(0, 0, 4).Add(12*time.Hour))
+		require.Equal(t, []time.Time{
+			time.Date(2026, 1, 9, 8, 0, 0, 0, berlin),
+			time.Date(2026, 1, 12, 8, 0, 0, 0, berlin),
+			time.Date(2026, 1, 13, 8, 0, 0, 0, berlin),
+		}, times)
+	})
+
+	t.Run("Range is exclusive at the start and inclusive at the end", func(t *testing.T) {
+		t.Parallel()
+		rule := Rule{TimeOfDay: TimeOfDay{Hour: 8}, Weekdays: weekdays(), Location: berlin, StartDate: mustDate(t, "2026-01-01")}
+
+		monday := time.Date(2026, 1, 12, 8, 0, 0, 0, berlin)
+		require.Empty(t, rule.Occurrences(monday, monday.Add(time.Hour)))
+		require.Equal(t, []time.Time{monday}, rule.Occurrences(monday.Add(-time.Hour), monday))
+	})
+
+	t.Run("Start, end and skip dates", func(t *testing.T) {
+		t.Parallel()
+		end := mustDate(t, "2026-01-16")
+		rule := Rule{
+			TimeOfDay: TimeOfDay{Hour: 17, Minute: 30},
+			Weekdays:  weekdays(),
+			Location:  berlin,
+			StartDate: mustDate(t, "2026-01-13"),
+			EndDate:   &end,
+			SkipDates: []Date{mustDate(t, "2026-01-15")},
+		}
+
+		times := rule.Occurrences(time.Date(2026, 1, 1, 0, 0, 0, 0, berlin), time.Date(2026, 2, 1, 0, 0, 0, 0, berlin))
+		days := make([]string, 0, len(times))
+		for _, at := range times {
+			days = append(days, DateOf(at).String())
+		}
+		require.Equal(t, []string{"2026-01-13", "2026-01-14", "2026-01-16"}, days)
+	})
+
+	t.Run("Local time is kept across daylight saving changes", func(t *testing.T) {
+		t.Parallel()
+		rule := Rule{TimeOfDay: TimeOfDay{Hour: 8}, Weekdays: []time.Weekday{time.Friday, time.Monday}, Location: berlin, StartDate: mustDate(t, "2026-01-01")}
+
+		// Clocks go forward on Sunday 29 March
+		times := rule.Occurrences(time.Date(2026, 3, 27, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC))
+		require.Len(t, times, 2)
+		require.Equal(t, 7, times[0].UTC().Hour())
+		require.Equal(t, 6, times[1].UTC().Hour())
+	})
+
+	t.Run("Local day differs from the UTC day", func(t *testing.T) {
+		t.Parallel()
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		require.NoError(t, err)
+		rule := Rule{TimeOfDay: TimeOfDay{Hour: 7}, Weekdays: []time.Weekday{time.Monday}, Location: tokyo, StartDate: mustDate(t, "2026-01-01")}
+
+		// Monday 07:00 in Tokyo is Sunday 22:00 in UTC
+		from := time.Date(2026, 1, 11, 20, 0, 0, 0, time.UTC)
+		require.Equal(t, []time.Time{time.Date(2026, 1, 12, 7, 0, 0, 0, tokyo)}, rule.Occurrences(from, from.Add(4*time.Hour)))
+	})
+}
@@ -0,0 +1,606 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/geocoding"
+	"CabBookingService/internal/services/recurrence"
+	"CabBookingService/internal/services/scheduling"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRecurringRide   = errors.New("invalid recurring ride")
+	ErrRecurringRideNotFound  = errors.New("recurring ride not found")
+	ErrRecurringRideNotOwned  = errors.New("recurring ride does not belong to this passenger")
+	ErrRecurringRideCancelled = errors.New("recurring ride is cancelled")
+	ErrNoOccurrence           = errors.New("recurring ride has no ride on this date")
+)
+
+type RecurringRideParams struct {
+	PickupLatitude   float64
+	PickupLongitude  float64
+	DropoffLatitude  float64
+	DropoffLongitude float64
+	// Saved places of the passenger, when set they take precedence over the coordinates
+	PickupPlaceID  *uuid.UUID
+	DropoffPlaceID *uuid.UUID
+
+	CarType                 models.CarType
+	Shared                  bool
+	AllowPreferenceFallback bool
+
+	TimeOfDay string   // Pickup time, HH:MM
+	Weekdays  []string // MON to SUN
+	TimeZone  string   // IANA name, e.g. Europe/Berlin
+	StartDate string   // YYYY-MM-DD, today when empty
+	EndDate   string   // YYYY-MM-DD, optional
+	SkipDates []string // YYYY-MM-DD
+}
+
+// RecurringRideSettings tune how far ahead occurrences are booked
+type RecurringRideSettings struct {
+	Horizon   time.Duration // Occurrences are booked this long ahead
+	BatchSize int           // Series booked per transaction
+	// Occurrences are booked like any scheduled ride, within the minimum lead and maximum horizon
+	Rules scheduling.Rules
+}
+
+// RecurringRideService manages rides a passenger takes every week. The occurrences are booked as
+// SCHEDULED bookings ahead of time, the scheduler activates them like any other scheduled ride.
+type RecurringRideService interface {
+	CreateRecurringRide(ctx context.Context, passengerAccountID uuid.UUID, params RecurringRideParams) (*models.RecurringRide, error)
+	ListRecurringRides(ctx context.Context, passengerAccountID uuid.UUID) ([]models.RecurringRide, error)
+	// UpdateRecurringRide replaces the series. Occurrences booked already are cancelled and booked again.
+	UpdateRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID, params RecurringRideParams) (*models.RecurringRide, error)
+	// PauseRecurringRide cancels the booked occurrences and books no more until resumed
+	PauseRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID) (*models.RecurringRide, error)
+	ResumeRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID) (*models.RecurringRide, error)
+	// CancelRecurringRide ends the series and cancels the booked occurrences
+	CancelRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID) error
+	// CancelOccurrence skips the ride on one day (YYYY-MM-DD), cancelling its booking if it was booked already
+	CancelOccurrence(ctx context.Context, passengerAccountID, rideID uuid.UUID, date string) (*models.RecurringRide, error)
+
+	// MaterializeDue books the occurrences coming up within the horizon and returns how many it booked
+	MaterializeDue(ctx context.Context) int
+}
+
+type recurringRideService struct {
+	recurringRideRepo repositories.RecurringRideRepository
+	bookingRepo       repositories.BookingRepository
+	passengerRepo     repositories.PassengerRepository
+	savedPlaceRepo    repositories.SavedPlaceRepository
+	geofenceService   GeofenceService
+	geocoder          geocoding.Geocoder
+	bookingService    BookingService
+	transactor        repositories.Transactor
+	outboxRepo        repositories.OutboxRepository
+	settings          RecurringRideSettings
+}
+
+func NewRecurringRideService(
+	recurringRideRepo repositories.RecurringRideRepository,
+	bookingRepo repositories.BookingRepository,
+	passengerRepo repositories.PassengerRepository,
+	savedPlaceRepo repositories.SavedPlaceRepository,
+	geofenceService GeofenceService,
+	geocoder geocoding.Geocoder,
+	bookingService BookingService,
+	transactor repositories.Transactor,
+	outboxRepo repositories.OutboxRepository,
+	settings RecurringRideSettings,
+) RecurringRideService {
+	return &recurringRideService{
+		recurringRideRepo: recurringRideRepo,
+		bookingRepo:       bookingRepo,
+		passengerRepo:     passengerRepo,
+		savedPlaceRepo:    savedPlaceRepo,
+		geofenceService:   geofenceService,
+		geocoder:          geocoder,
+		bookingService:    bookingService,
+		transactor:        transactor,
+		outboxRepo:        outboxRepo,
+		settings:          settings,
+	}
+}
+
+func (s *recurringRideService) CreateRecurringRide(ctx context.Context, passengerAccountID uuid.UUID, params RecurringRideParams) (*models.RecurringRide, error) {
+	// 1. Get Passenger Profile from Account ID
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Validate the schedule and the route
+	now := time.Now()
+	ride := &models.RecurringRide{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		PassengerId:       passenger.ID,
+		Status:            models.RecurringRideStatusActive,
+		MaterializedUntil: now,
+	}
+	if err := s.applyParams(ctx, ride, params, now); err != nil {
+		return nil, err
+	}
+
+	// 3. Create, the scheduler books the occurrences on its next tick
+	if err := s.recurringRideRepo.Create(ctx, ride); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("recurring_ride_id", ride.ID.String()).
+		Str("passenger_id", passenger.ID.String()).
+		Strs("weekdays", ride.Weekdays).
+		Str("time_of_day", ride.TimeOfDay).
+		Msg("Recurring ride created")
+	return ride, nil
+}
+
+func (s *recurringRideService) ListRecurringRides(ctx context.Context, passengerAccountID uuid.UUID) ([]models.RecurringRide, error) {
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.recurringRideRepo.ListByPassenger(ctx, passenger.ID)
+}
+
+func (s *recurringRideService) UpdateRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID, params RecurringRideParams) (*models.RecurringRide, error) {
+	// 1. Get the series and check the passenger owns it
+	ride, err := s.getPassengerRide(ctx, passengerAccountID, rideID)
+	if err != nil {
+		return nil, err
+	}
+	if ride.Status == models.RecurringRideStatusCancelled {
+		return nil, ErrRecurringRideCancelled
+	}
+
+	// 2. Validate the new schedule and route
+	now := time.Now()
+	if err := s.applyParams(ctx, ride, params, now); err != nil {
+		return nil, err
+	}
+	ride.UpdatedAt = now
+
+	// 3. Save, then rebook the occurrences from now on with the new schedule
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := s.recurringRideRepo.Update(ctx, ride); err != nil {
+			return err
+		}
+		if err := s.cancelBookedOccurrences(ctx, ride); err != nil {
+			return err
+		}
+		ride.MaterializedUntil = now
+		return s.recurringRideRepo.SetMaterializedUntil(ctx, ride.ID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("recurring_ride_id", ride.ID.String()).Msg("Recurring ride updated")
+	return ride, nil
+}
+
+func (s *recurringRideService) PauseRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID) (*models.RecurringRide, error) {
+	ride, err := s.getPassengerRide(ctx, passengerAccountID, rideID)
+	if err != nil {
+		return nil, err
+	}
+	switch ride.Status {
+	case models.RecurringRideStatusCancelled:
+		return nil, ErrRecurringRideCancelled
+	case models.RecurringRideStatusPaused:
+		return ride, nil
+	}
+
+	ride.Status = models.RecurringRideStatusPaused
+	ride.UpdatedAt = time.Now()
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := s.recurringRideRepo.Update(ctx, ride); err != nil {
+			return err
+		}
+		return s.cancelBookedOccurrences(ctx, ride)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("recurring_ride_id", ride.ID.String()).Msg("Recurring ride paused")
+	return ride, nil
+}
+
+func (s *recurringRideService) ResumeRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID) (*models.RecurringRide, error) {
+	ride, err := s.getPassengerRide(ctx, passengerAccountID, rideID)
+	if err != nil {
+		return nil, err
+	}
+	switch ride.Status {
+	case models.RecurringRideStatusCancelled:
+		return nil, ErrRecurringRideCancelled
+	case models.RecurringRideStatusActive:
+		return ride, nil
+	}
+
+	// Occurrences missed while paused are not booked
+	now := time.Now()
+	ride.Status = models.RecurringRideStatusActive
+	ride.UpdatedAt = now
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := s.recurringRideRepo.Update(ctx, ride); err != nil {
+			return err
+		}
+		ride.MaterializedUntil = now
+		return s.recurringRideRepo.SetMaterializedUntil(ctx, ride.ID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("recurring_ride_id", ride.ID.String()).Msg("Recurring ride resumed")
+	return ride, nil
+}
+
+func (s *recurringRideService) CancelRecurringRide(ctx context.Context, passengerAccountID, rideID uuid.UUID) error {
+	ride, err := s.getPassengerRide(ctx, passengerAccountID, rideID)
+	if err != nil {
+		return err
+	}
+	if ride.Status == models.RecurringRideStatusCancelled {
+		return nil
+	}
+
+	ride.Status = models.RecurringRideStatusCancelled
+	ride.UpdatedAt = time.Now()
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := s.recurringRideRepo.Update(ctx, ride); err != nil {
+			return err
+		}
+		return s.cancelBookedOccurrences(ctx, ride)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("recurring_ride_id", ride.ID.String()).Msg("Recurring ride cancelled")
+	return nil
+}
+
+func (s *recurringRideService) CancelOccurrence(ctx context.Context, passengerAccountID, rideID uuid.UUID, date string) (*models.RecurringRide, error) {
+	// 1. Get the series and check the passenger owns it
+	ride, err := s.getPassengerRide(ctx, passengerAccountID, rideID)
+	if err != nil {
+		return nil, err
+	}
+	if ride.Status == models.RecurringRideStatusCancelled {
+		return nil, ErrRecurringRideCancelled
+	}
+
+	// 2. The series must have an upcoming ride that day
+	day, err := recurrence.ParseDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurringRide, err)
+	}
+	rule, err := ruleOf(ride)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.Includes(day) || !rule.At(day).After(time.Now()) {
+		return nil, ErrNoOccurrence
+	}
+
+	// 3. Skip the day from now on, and cancel its booking if it was booked already
+	ride.SkipDates = append(ride.SkipDates, day.String())
+	ride.UpdatedAt = time.Now()
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := s.recurringRideRepo.Update(ctx, ride); err != nil {
+			return err
+		}
+		dayStart := time.Date(day.Year, day.Month, day.Day, 0, 0, 0, 0, rule.Location)
+		return s.cancelOccurrences(ctx, ride, dayStart, dayStart.AddDate(0, 0, 1))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("recurring_ride_id", ride.ID.String()).Str("date", day.String()).Msg("Recurring ride occurrence cancelled")
+	return ride, nil
+}
+
+// cancelBookedOccurrences cancels every SCHEDULED booking of the series
+func (s *recurringRideService) cancelBookedOccurrences(ctx context.Context, ride *models.RecurringRide) error {
+	return s.cancelOccurrences(ctx, ride, time.Time{}, time.Time{})
+}
+
+// cancelOccurrences cancels the SCHEDULED bookings of the series in [from, to), a zero to has no
+// end. Occurrences that are looking for a driver already are left alone.
+func (s *recurringRideService) cancelOccurrences(ctx context.Context, ride *models.RecurringRide, from, to time.Time) error {
+	bookings, err := s.bookingRepo.CancelScheduledOccurrences(ctx, ride.ID, from, to)
+	if err != nil {
+		return err
+	}
+	for i := range bookings {
+		err := addBookingEvent(ctx, s.outboxRepo, domain.BookingCancelled{
+			BookingEvent: newBookingEvent(&bookings[i]),
+			CancelledBy:  domain.RolePassenger,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getPassengerRide returns a series of the passenger, ErrRecurringRideNotOwned if it belongs to someone else
+func (s *recurringRideService) getPassengerRide(ctx context.Context, passengerAccountID, rideID uuid.UUID) (*models.RecurringRide, error) {
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+	ride, err := s.recurringRideRepo.GetByID(ctx, rideID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecurringRideNotFound
+		}
+		return nil, err
+	}
+	if ride.PassengerId != passenger.ID {
+		return nil, ErrRecurringRideNotOwned
+	}
+	return ride, nil
+}
+
+func (s *recurringRideService) MaterializeDue(ctx context.Context) int {
+	// Series are topped up to the full horizon once half of it is left, not on every tick
+	now := time.Now()
+	until := now.Add(s.settings.Horizon)
+	if latest := s.settings.Rules.Latest(now); until.After(latest) {
+		until = latest
+	}
+	cutoff := now.Add(s.settings.Horizon / 2)
+
+	booked := 0
+	for ctx.Err() == nil {
+		claimed := 0
+		err := s.transactor.InTx(ctx, func(ctx context.Context) error {
+			// 1. Claim the series, other instances skip them until this commits
+			rides, err := s.recurringRideRepo.ClaimDue(ctx, cutoff, s.settings.BatchSize)
+			if err != nil {
+				return err
+			}
+			claimed = len(rides)
+
+			// 2. Book their occurrences and remember how far they are booked
+			for i := range rides {
+				booked += s.materialize(ctx, &rides[i], now, until)
+				if err := s.recurringRideRepo.SetMaterializedUntil(ctx, rides[i].ID, until); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to book recurring rides")
+			break
+		}
+		if claimed == 0 || claimed < s.settings.BatchSize {
+			break
+		}
+	}
+	return booked
+}
+
+// materialize books the occurrences of the series up to until and returns how many it booked. An
+// occurrence that can't be booked, e.g. because the pickup left the service area, is skipped.
+func (s *recurringRideService) materialize(ctx context.Context, ride *models.RecurringRide, now, until time.Time) int {
+	rule, err := ruleOf(ride)
+	if err != nil {
+		log.Error().Err(err).Str("recurring_ride_id", ride.ID.String()).Msg("Invalid recurring ride")
+		return 0
+	}
+	// Occurrences sooner than the minimum lead can't be scheduled anymore
+	from := ride.MaterializedUntil
+	if earliest := s.settings.Rules.Earliest(now); from.Before(earliest) {
+		from = earliest
+	}
+	occurrences := rule.Occurrences(from, until)
+	if len(occurrences) == 0 {
+		return 0
+	}
+
+	passenger, err := s.passengerRepo.GetByID(ctx, ride.PassengerId)
+	if err != nil {
+		log.Error().Err(err).Str("recurring_ride_id", ride.ID.String()).Msg("Failed to get passenger of recurring ride")
+		return 0
+	}
+
+	booked := 0
+	for _, at := range occurrences {
+		scheduledTime := at
+		// Each occurrence in its own savepoint, a failed one doesn't roll back the rest of the batch
+		var booking *models.Booking
+		err := s.transactor.InTx(ctx, func(ctx context.Context) error {
+			var err error
+			booking, err = s.bookingService.CreateBooking(ctx, CreateBookingParams{
+				PassengerAccountID:      passenger.AccountId,
+				PickupLatitude:          ride.PickupLatitude,
+				PickupLongitude:         ride.PickupLongitude,
+				DropoffLatitude:         ride.DropoffLatitude,
+				DropoffLongitude:        ride.DropoffLongitude,
+				ScheduledTime:           &scheduledTime,
+				CarType:                 ride.CarType,
+				AllowPreferenceFallback: ride.AllowPreferenceFallback,
+				Shared:                  ride.IsShared,
+				RecurringRideID:         &ride.ID,
+				// The occurrences were picked at now, one right at the minimum lead is still bookable
+				RequestedAt: now,
+			})
+			return err
+		})
+		if err != nil {
+			log.Warn().Err(err).
+				Str("recurring_ride_id", ride.ID.String()).
+				Time("scheduled_time", at).
+				Msg("Failed to book recurring ride occurrence")
+			continue
+		}
+		log.Info().
+			Str("recurring_ride_id", ride.ID.String()).
+			Str("booking_id", booking.ID.String()).
+			Time("scheduled_time", at).
+			Msg("Recurring ride occurrence booked")
+		booked++
+	}
+	return booked
+}
+
+// applyParams validates the params and sets them on the series
+func (s *recurringRideService) applyParams(ctx context.Context, ride *models.RecurringRide, params RecurringRideParams, now time.Time) error {
+	// 1. Schedule
+	loc, err := time.LoadLocation(params.TimeZone)
+	if err != nil || params.TimeZone == "" {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidRecurringRide, params.TimeZone)
+	}
+	timeOfDay, err := recurrence.ParseTimeOfDay(params.TimeOfDay)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecurringRide, err)
+	}
+	if len(params.Weekdays) == 0 {
+		return fmt.Errorf("%w: pick at least one day of the week", ErrInvalidRecurringRide)
+	}
+	weekdays := make([]string, 0, len(params.Weekdays))
+	for _, name := range params.Weekdays {
+		day, err := recurrence.ParseWeekday(name)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecurringRide, err)
+		}
+		if !slices.Contains(weekdays, recurrence.WeekdayName(day)) {
+			weekdays = append(weekdays, recurrence.WeekdayName(day))
+		}
+	}
+	startDate := recurrence.DateOf(now.In(loc))
+	if params.StartDate != "" {
+		if startDate, err = recurrence.ParseDate(params.StartDate); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecurringRide, err)
+		}
+	}
+	var endDate *time.Time
+	if params.EndDate != "" {
+		end, err := recurrence.ParseDate(params.EndDate)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecurringRide, err)
+		}
+		if end.Before(startDate) {
+			return fmt.Errorf("%w: end date is before the start date", ErrInvalidRecurringRide)
+		}
+		endTime := end.Time()
+		endDate = &endTime
+	}
+	skipDates := make([]string, 0, len(params.SkipDates))
+	for _, date := range params.SkipDates {
+		day, err := recurrence.ParseDate(date)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRecurringRide, err)
+		}
+		skipDates = append(skipDates, day.String())
+	}
+	carType, err := normalizeCarType(params.CarType)
+	if err != nil {
+		return err
+	}
+
+	// 2. Resolve saved places into coordinates and addresses
+	var pickupAddress, dropoffAddress string
+	if params.PickupPlaceID != nil {
+		place, err := getPassengerPlace(ctx, s.savedPlaceRepo, ride.PassengerId, *params.PickupPlaceID)
+		if err != nil {
+			return err
+		}
+		params.PickupLatitude, params.PickupLongitude, pickupAddress = place.Latitude, place.Longitude, place.Address
+	}
+	if params.DropoffPlaceID != nil {
+		place, err := getPassengerPlace(ctx, s.savedPlaceRepo, ride.PassengerId, *params.DropoffPlaceID)
+		if err != nil {
+			return err
+		}
+		params.DropoffLatitude, params.DropoffLongitude, dropoffAddress = place.Latitude, place.Longitude, place.Address
+	}
+
+	// 3. Validate pickup and drop-off against service areas and restricted zones now, rather than
+	// failing every occurrence later
+	if _, err := s.geofenceService.ValidateTrip(ctx,
+		params.PickupLatitude, params.PickupLongitude,
+		params.DropoffLatitude, params.DropoffLongitude,
+	); err != nil {
+		return err
+	}
+	if pickupAddress == "" {
+		pickupAddress = reverseGeocode(ctx, s.geocoder, params.PickupLatitude, params.PickupLongitude)
+	}
+	if dropoffAddress == "" {
+		dropoffAddress = reverseGeocode(ctx, s.geocoder, params.DropoffLatitude, params.DropoffLongitude)
+	}
+
+	ride.PickupLatitude, ride.PickupLongitude = params.PickupLatitude, params.PickupLongitude
+	ride.DropoffLatitude, ride.DropoffLongitude = params.DropoffLatitude, params.DropoffLongitude
+	ride.PickupAddress, ride.DropoffAddress = pickupAddress, dropoffAddress
+	ride.CarType = carType
+	ride.IsShared = params.Shared
+	ride.AllowPreferenceFallback = params.AllowPreferenceFallback
+	ride.TimeOfDay = timeOfDay.String()
+	ride.Weekdays = weekdays
+	ride.TimeZone = loc.String()
+	ride.StartDate = startDate.Time()
+	ride.EndDate = endDate
+	ride.SkipDates = skipDates
+	return nil
+}
+
+// ruleOf returns the schedule of the series
+func ruleOf(ride *models.RecurringRide) (recurrence.Rule, error) {
+	loc, err := time.LoadLocation(ride.TimeZone)
+	if err != nil {
+		return recurrence.Rule{}, err
+	}
+	timeOfDay, err := recurrence.ParseTimeOfDay(ride.TimeOfDay)
+	if err != nil {
+		return recurrence.Rule{}, err
+	}
+	rule := recurrence.Rule{
+		TimeOfDay: timeOfDay,
+		Location:  loc,
+		StartDate: recurrence.DateOf(ride.StartDate),
+	}
+	for _, name := range ride.Weekdays {
+		day, err := recurrence.ParseWeekday(name)
+		if err != nil {
+			return recurrence.Rule{}, err
+		}
+		rule.Weekdays = append(rule.Weekdays, day)
+	}
+	if ride.EndDate != nil {
+		end := recurrence.DateOf(*ride.EndDate)
+		rule.EndDate = &end
+	}
+	for _, date := range ride.SkipDates {
+		day, err := recurrence.ParseDate(date)
+		if err != nil {
+			return recurrence.Rule{}, err
+		}
+		rule.SkipDates = append(rule.SkipDates, day)
+	}
+	return rule, nil
+}
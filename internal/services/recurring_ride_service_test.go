@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+var everyDay = []string{"MON", "TUE", "WED", "THU", "FRI", "SAT", "SUN"}
+
+func (p *testPlatform) createRecurringRide(t *testing.T, passenger *models.Passenger, timeOfDay string) *models.RecurringRide {
+	t.Helper()
+	ride, err := p.recurringRides.CreateRecurringRide(context.Background(), passenger.AccountId, RecurringRideParams{
+		PickupLatitude:   testPickup.Latitude,
+		PickupLongitude:  testPickup.Longitude,
+		DropoffLatitude:  testPickup.Latitude + 0.05,
+		DropoffLongitude: testPickup.Longitude + 0.05,
+		TimeOfDay:        timeOfDay,
+		Weekdays:         everyDay,
+		TimeZone:         "UTC",
+		StartDate:        time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly),
+	})
+	require.NoError(t, err)
+	return ride
+}
+
+// scheduledBookings returns the SCHEDULED bookings picking up within the maximum horizon, earliest first
+func (p *testPlatform) scheduledBookings(t *testing.T) []models.Booking {
+	t.Helper()
+	now := time.Now()
+	bookings, err := p.bookingRepo.ListReservableBookings(context.Background(), "", now, p.rules.Latest(now), 100, 0)
+	require.NoError(t, err)
+	return bookings
+}
+
+func TestMaterializeRecurringRides(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("The occurrences within the horizon are booked once", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		ride := p.createRecurringRide(t, p.addPassenger(t, "passenger"), "09:00")
+
+		booked := p.recurringRides.MaterializeDue(ctx)
+		require.GreaterOrEqual(t, booked, 2)
+		bookings := p.scheduledBookings(t)
+		require.Len(t, bookings, booked)
+		horizon := time.Now().Add(p.recurringRides.settings.Horizon)
+		for _, booking := range bookings {
+			require.Equal(t, ride.ID, *booking.RecurringRideId)
+			require.Equal(t, "09:00", booking.ScheduledTime.UTC().Format("15:04"))
+			require.False(t, booking.ScheduledTime.After(horizon))
+		}
+
+		// Booked far enough ahead, the next tick leaves the series alone
+		require.Zero(t, p.recurringRides.MaterializeDue(ctx))
+		require.Len(t, p.scheduledBookings(t), booked)
+	})
+
+	t.Run("An occurrence right after the minimum lead is booked", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		// The tick started a moment before the first pickup it can still book
+		at := time.Now().Add(p.rules.MinLead).Truncate(time.Minute)
+		now := at.Add(-p.rules.MinLead - time.Millisecond)
+		ride := p.createRecurringRide(t, p.addPassenger(t, "passenger"), at.UTC().Format("15:04"))
+		ride.MaterializedUntil = now
+
+		require.Equal(t, 1, p.recurringRides.materialize(ctx, ride, now, at.Add(time.Hour)))
+		bookings := p.scheduledBookings(t)
+		require.Len(t, bookings, 1)
+		require.True(t, at.Equal(*bookings[0].ScheduledTime))
+	})
+
+	t.Run("A paused series isn't booked", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		passenger := p.addPassenger(t, "passenger")
+		ride := p.createRecurringRide(t, passenger, "09:00")
+		_, err := p.recurringRides.PauseRecurringRide(ctx, passenger.AccountId, ride.ID)
+		require.NoError(t, err)
+
+		require.Zero(t, p.recurringRides.MaterializeDue(ctx))
+		require.Empty(t, p.scheduledBookings(t))
+	})
+}
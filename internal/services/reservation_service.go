@@ -0,0 +1,475 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/routing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRideNotReservable   = errors.New("scheduled ride cannot be reserved")
+	ErrReservationConflict = errors.New("scheduled ride overlaps another ride reserved by the driver")
+	ErrReservationNotFound = errors.New("driver has not reserved this ride")
+	ErrReservationDue      = errors.New("reserved ride is due already, cancel the booking instead")
+)
+
+// ReservationSettings tune how drivers reserve scheduled rides
+type ReservationSettings struct {
+	BrowseHorizon time.Duration // Rides picking up within this long can be reserved
+	Buffer        time.Duration // Minimum gap between reserved rides, on top of the ride duration
+	ReminderLead  time.Duration // Both parties are reminded this long before pickup
+	BatchSize     int           // Reminders claimed per transaction
+}
+
+// ReservationService lets drivers reserve scheduled rides in advance. The reserved driver is
+// assigned when the scheduler activates the ride, or the ride goes to open matching if they can't
+// take it by then.
+type ReservationService interface {
+	// ListReservableRides returns the scheduled rides the driver could reserve, earliest first
+	ListReservableRides(ctx context.Context, driverAccountID uuid.UUID, limit, offset int) ([]models.Booking, error)
+	// ListReservedRides returns the upcoming rides the driver reserved, earliest first
+	ListReservedRides(ctx context.Context, driverAccountID uuid.UUID) ([]models.Booking, error)
+	ReserveRide(ctx context.Context, driverAccountID, bookingID uuid.UUID) (*models.Booking, error)
+	// ReleaseRide gives a reservation up, other drivers can reserve the ride again
+	ReleaseRide(ctx context.Context, driverAccountID, bookingID uuid.UUID) error
+
+	// AssignReservedDriver is called by the scheduler, in its transaction, for an activated booking
+	// that a driver reserved. It assigns the driver, or releases them if they are not available and
+	// returns false, the caller then sends the booking to open matching.
+	AssignReservedDriver(ctx context.Context, booking *models.Booking) (bool, error)
+	// NotifyActivated tells the reserved driver and the passenger how the activation went, once the
+	// scheduler's transaction committed
+	NotifyActivated(ctx context.Context, booking *models.Booking, reservedDriverID uuid.UUID)
+	// SendReminders reminds driver and passenger of the reserved rides coming up, and returns how many
+	// rides it reminded them of
+	SendReminders(ctx context.Context) int
+}
+
+type reservationService struct {
+	bookingRepo     repositories.BookingRepository
+	driverRepo      repositories.DriverRepository
+	passengerRepo   repositories.PassengerRepository
+	otpService      OTPService
+	routingProvider routing.RoutingProvider
+	trackingService RideTrackingService
+	notifications   NotificationService
+	transactor      repositories.Transactor
+	outboxRepo      repositories.OutboxRepository
+	settings        ReservationSettings
+}
+
+func NewReservationService(
+	bookingRepo repositories.BookingRepository,
+	driverRepo repositories.DriverRepository,
+	passengerRepo repositories.PassengerRepository,
+	otpService OTPService,
+	routingProvider routing.RoutingProvider,
+	trackingService RideTrackingService,
+	notifications NotificationService,
+	transactor repositories.Transactor,
+	outboxRepo repositories.OutboxRepository,
+	settings ReservationSettings,
+) ReservationService {
+	return &reservationService{
+		bookingRepo:     bookingRepo,
+		driverRepo:      driverRepo,
+		passengerRepo:   passengerRepo,
+		otpService:      otpService,
+		routingProvider: routingProvider,
+		trackingService: trackingService,
+		notifications:   notifications,
+		transactor:      transactor,
+		outboxRepo:      outboxRepo,
+		settings:        settings,
+	}
+}
+
+func (s *reservationService) ListReservableRides(ctx context.Context, driverAccountID uuid.UUID, limit, offset int) ([]models.Booking, error) {
+	// 1. Get Driver Profile from Account ID
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Rides of the driver's city within the horizon. Shared rides are matched into trips, they
+	// can't be reserved.
+	now := time.Now()
+	bookings, err := s.bookingRepo.ListReservableBookings(ctx, driver.ActiveCity, now, now.Add(s.settings.BrowseHorizon), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Only the rides the driver's car can serve
+	rides := make([]models.Booking, 0, len(bookings))
+	for _, booking := range bookings {
+		if canServe(driver, &booking) {
+			rides = append(rides, booking)
+		}
+	}
+	return rides, nil
+}
+
+func (s *reservationService) ListReservedRides(ctx context.Context, driverAccountID uuid.UUID) ([]models.Booking, error) {
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.bookingRepo.ListDriverReservations(ctx, driver.ID)
+}
+
+func (s *reservationService) ReserveRide(ctx context.Context, driverAccountID, bookingID uuid.UUID) (*models.Booking, error) {
+	// 1. Get Driver Profile from Account ID
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Get Booking by ID and check the driver can take it
+	booking, err := s.bookingRepo.GetByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case booking.Status != models.BookingStatusScheduled || booking.ScheduledTime == nil:
+		return nil, fmt.Errorf("%w: it is not scheduled anymore", ErrRideNotReservable)
+	case booking.ReservedDriverId != nil:
+		return nil, fmt.Errorf("%w: another driver reserved it", ErrRideNotReservable)
+	case booking.IsShared:
+		return nil, fmt.Errorf("%w: shared rides are matched when they are due", ErrRideNotReservable)
+	case booking.ScheduledTime.After(now.Add(s.settings.BrowseHorizon)):
+		return nil, fmt.Errorf("%w: it picks up more than %s from now", ErrRideNotReservable, s.settings.BrowseHorizon)
+	case driver.ActiveCity != "" && booking.City != driver.ActiveCity:
+		return nil, fmt.Errorf("%w: it picks up outside the driver's city", ErrRideNotReservable)
+	case !canServe(driver, booking):
+		return nil, fmt.Errorf("%w: the driver's car cannot serve a %s ride", ErrRideNotReservable, booking.CarType)
+	}
+	duration, err := s.rideDuration(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Reserve. The driver's lock makes concurrent reservations of the same driver take turns, so
+	// both can't pass the conflict check.
+	reservedAt := now
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := s.driverRepo.LockForUpdate(ctx, driver.ID); err != nil {
+			return err
+		}
+		if err := s.checkConflicts(ctx, driver.ID, *booking.ScheduledTime, duration); err != nil {
+			return err
+		}
+		ok, err := s.bookingRepo.ReserveScheduledBooking(ctx, booking.ID, driver.ID, reservedAt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: another driver reserved it", ErrRideNotReservable)
+		}
+		booking.ReservedDriverId = &driver.ID
+		booking.ReservedAt = &reservedAt
+		return addBookingEvent(ctx, s.outboxRepo, domain.BookingReserved{
+			BookingEvent:     newBookingEvent(booking),
+			ReservedDriverID: driver.ID,
+			ScheduledTime:    *booking.ScheduledTime,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Str("driver_id", driver.ID.String()).
+		Time("scheduled_time", *booking.ScheduledTime).
+		Msg("Scheduled ride reserved by driver")
+
+	// 4. Let the passenger know who is coming
+	s.notifyPassenger(ctx, booking.PassengerId, PassengerNotification{
+		Type:      PassengerNotificationDriverReserved,
+		BookingID: booking.ID,
+		Message:   fmt.Sprintf("%s will pick you up for your scheduled ride", driver.Name),
+	})
+	return booking, nil
+}
+
+// checkConflicts returns ErrReservationConflict if the ride, picking up at start and taking about
+// duration, comes closer than the buffer to another ride the driver reserved
+func (s *reservationService) checkConflicts(ctx context.Context, driverID uuid.UUID, start time.Time, duration time.Duration) error {
+	reserved, err := s.bookingRepo.ListDriverReservations(ctx, driverID)
+	if err != nil {
+		return err
+	}
+	end := start.Add(duration)
+	for i := range reserved {
+		other := &reserved[i]
+		otherDuration, err := s.rideDuration(ctx, other)
+		if err != nil {
+			return err
+		}
+		otherStart := *other.ScheduledTime
+		otherEnd := otherStart.Add(otherDuration)
+		if start.Before(otherEnd.Add(s.settings.Buffer)) && otherStart.Before(end.Add(s.settings.Buffer)) {
+			return fmt.Errorf("%w: ride at %s", ErrReservationConflict, otherStart.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// rideDuration estimates how long the ride takes from pickup to drop-off
+func (s *reservationService) rideDuration(ctx context.Context, booking *models.Booking) (time.Duration, error) {
+	route, err := s.routingProvider.Route(ctx,
+		models.ExactLocation{Latitude: booking.PickupLatitude, Longitude: booking.PickupLongitude},
+		models.ExactLocation{Latitude: booking.DropoffLatitude, Longitude: booking.DropoffLongitude},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("estimating the duration of ride %s: %w", booking.ID, err)
+	}
+	return route.Duration, nil
+}
+
+func (s *reservationService) ReleaseRide(ctx context.Context, driverAccountID, bookingID uuid.UUID) error {
+	// 1. Get Driver Profile from Account ID
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return err
+	}
+
+	// 2. Get Booking by ID
+	booking, err := s.bookingRepo.GetByID(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+
+	// 3. Release, unless the driver doesn't hold the reservation. Once the ride is due the driver is
+	// assigned and cancels the booking instead.
+	if booking.Status != models.BookingStatusScheduled && booking.DriverId != nil && *booking.DriverId == driver.ID {
+		return ErrReservationDue
+	}
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		return s.release(ctx, booking, driver.ID, domain.ReleaseReasonDriverReleased)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Str("driver_id", driver.ID.String()).
+		Msg("Scheduled ride released by driver")
+
+	// 4. A ride that is still scheduled can be reserved by someone else, or is matched when it's due
+	s.notifyPassenger(ctx, booking.PassengerId, PassengerNotification{
+		Type:      PassengerNotificationDriverReleased,
+		BookingID: booking.ID,
+		Message:   fmt.Sprintf("%s can no longer take your scheduled ride, we will find you another driver", driver.Name),
+	})
+	return nil
+}
+
+// release takes the driver's reservation off the booking and records why
+func (s *reservationService) release(ctx context.Context, booking *models.Booking, driverID uuid.UUID, reason string) error {
+	ok, err := s.bookingRepo.ReleaseReservation(ctx, booking.ID, driverID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReservationNotFound
+	}
+	booking.ReservedDriverId = nil
+	booking.ReservedAt = nil
+	booking.ReminderSentAt = nil
+	return addBookingEvent(ctx, s.outboxRepo, domain.BookingDriverReleased{
+		BookingEvent:     newBookingEvent(booking),
+		ReleasedDriverID: driverID,
+		Reason:           reason,
+	})
+}
+
+func (s *reservationService) AssignReservedDriver(ctx context.Context, booking *models.Booking) (bool, error) {
+	driverID := *booking.ReservedDriverId
+
+	// 1. The driver row stays locked until the scheduler commits, so the driver can't end their
+	// current ride or accept another one while this is decided
+	if err := s.driverRepo.LockForUpdate(ctx, driverID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	driver, err := s.driverRepo.GetByID(ctx, driverID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if driver == nil {
+		return false, s.release(ctx, booking, driverID, domain.ReleaseReasonDriverOffline)
+	}
+
+	// A driver is unavailable while on a ride too, only one without an active ride is offline.
+	// A driver finishing a solo ride gets the reserved ride queued as their next one, any other
+	// ride keeps them busy for too long and the ride goes to open matching instead.
+	var queuedBehind *uuid.UUID
+	if !driver.IsAvailable {
+		current, err := s.bookingRepo.GetActiveBookingForDriver(ctx, driverID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, err
+		}
+		if current == nil {
+			return false, s.release(ctx, booking, driverID, domain.ReleaseReasonDriverOffline)
+		}
+		if current.Status != models.BookingStatusStarted || current.IsShared {
+			return false, s.release(ctx, booking, driverID, domain.ReleaseReasonDriverBusy)
+		}
+		queuedBehind = &current.ID
+	}
+
+	// 2. Generate OTP for Ride Start, the one of the booking may have expired by now
+	passenger, err := s.passengerRepo.GetByID(ctx, booking.PassengerId)
+	if err != nil {
+		return false, err
+	}
+	otp, err := s.otpService.GenerateOTP(ctx, passenger.PhoneNumber)
+	if err != nil {
+		return false, err
+	}
+
+	// 3. Accept on behalf of the driver, like AcceptBooking does. The current ride can't end while
+	// the driver is locked, so a queued booking is always chained.
+	chained, err := s.bookingRepo.AcceptBookingTransaction(ctx, booking.ID, driverID, otp.ID, queuedBehind)
+	if err != nil {
+		return false, err
+	}
+	if !chained {
+		queuedBehind = nil
+	}
+	booking.Status = models.BookingStatusAccepted
+	booking.DriverId = &driverID
+	booking.Driver = driver
+	booking.RideStartOTPId = &otp.ID
+	booking.QueuedBehindBookingId = queuedBehind
+	return true, addBookingEvent(ctx, s.outboxRepo, domain.BookingAccepted{
+		BookingEvent:          newBookingEvent(booking),
+		QueuedBehindBookingID: queuedBehind,
+	})
+}
+
+func (s *reservationService) NotifyActivated(ctx context.Context, booking *models.Booking, reservedDriverID uuid.UUID) {
+	if booking.DriverId == nil {
+		log.Info().
+			Str("booking_id", booking.ID.String()).
+			Str("driver_id", reservedDriverID.String()).
+			Msg("Reserved driver unavailable, scheduled booking queued for driver matching")
+		s.notifyDriver(ctx, reservedDriverID, DriverNotification{
+			Type:      DriverNotificationReservationEnded,
+			BookingID: booking.ID,
+			Message:   "You were not available when your reserved ride was due, it was offered to other drivers",
+		})
+		s.notifyPassenger(ctx, booking.PassengerId, PassengerNotification{
+			Type:      PassengerNotificationDriverReleased,
+			BookingID: booking.ID,
+			Message:   "Your reserved driver is not available, we are finding you another driver",
+		})
+		return
+	}
+
+	log.Info().
+		Str("booking_id", booking.ID.String()).
+		Str("driver_id", reservedDriverID.String()).
+		Bool("chained", booking.QueuedBehindBookingId != nil).
+		Msg("Scheduled booking assigned to its reserved driver")
+	message := fmt.Sprintf("Your reserved ride is due, head to the pickup at %s", booking.PickupAddress)
+	if booking.QueuedBehindBookingId != nil {
+		message = fmt.Sprintf("Your reserved ride is due, head to the pickup at %s after your current ride", booking.PickupAddress)
+	}
+	s.notifyDriver(ctx, reservedDriverID, DriverNotification{
+		Type:      DriverNotificationNextRide,
+		BookingID: booking.ID,
+		Message:   message,
+	})
+	// Let the passenger know a driver is on the way
+	s.trackingService.PublishProgress(ctx, booking)
+}
+
+func (s *reservationService) SendReminders(ctx context.Context) int {
+	// Reservations picking up within the lead, a batch at a time until none are left
+	sent := 0
+	for ctx.Err() == nil {
+		now := time.Now()
+		bookings, err := s.bookingRepo.ClaimReservationReminders(ctx, now.Add(s.settings.ReminderLead), now, s.settings.BatchSize)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to claim reservation reminders")
+			break
+		}
+		for i := range bookings {
+			s.remind(ctx, &bookings[i], now)
+		}
+		sent += len(bookings)
+		if len(bookings) == 0 || len(bookings) < s.settings.BatchSize {
+			break
+		}
+	}
+	return sent
+}
+
+// remind tells both parties the reserved ride is coming up
+func (s *reservationService) remind(ctx context.Context, booking *models.Booking, now time.Time) {
+	in := booking.ScheduledTime.Sub(now).Round(time.Minute)
+	s.notifyDriver(ctx, *booking.ReservedDriverId, DriverNotification{
+		Type:      DriverNotificationRideReminder,
+		BookingID: booking.ID,
+		Message:   fmt.Sprintf("Your reserved ride picks up at %s in %s", booking.PickupAddress, in),
+	})
+	s.notifyPassenger(ctx, booking.PassengerId, PassengerNotification{
+		Type:      PassengerNotificationRideReminder,
+		BookingID: booking.ID,
+		Message:   fmt.Sprintf("Your driver picks you up at %s in %s", booking.PickupAddress, in),
+	})
+}
+
+// notifyDriver sends a notification, failures are logged. The driver also sees their reservations
+// in the app.
+func (s *reservationService) notifyDriver(ctx context.Context, driverID uuid.UUID, notification DriverNotification) {
+	driver, err := s.driverRepo.GetByID(ctx, driverID)
+	if err == nil {
+		err = s.notifications.NotifyDriver(ctx, driver, notification)
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Str("booking_id", notification.BookingID.String()).
+			Str("type", string(notification.Type)).
+			Msg("Failed to notify driver")
+	}
+}
+
+// notifyPassenger sends a notification, failures are logged like for drivers
+func (s *reservationService) notifyPassenger(ctx context.Context, passengerID uuid.UUID, notification PassengerNotification) {
+	passenger, err := s.passengerRepo.GetByID(ctx, passengerID)
+	if err == nil {
+		err = s.notifications.NotifyPassenger(ctx, passenger, notification)
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Str("booking_id", notification.BookingID.String()).
+			Str("type", string(notification.Type)).
+			Msg("Failed to notify passenger")
+	}
+}
+
+// canServe reports whether the driver's car can serve the vehicle class requested for the booking
+func canServe(driver *models.Driver, booking *models.Booking) bool {
+	requested, carType := booking.CarType, driver.Car.CarType
+	if requested == "" {
+		requested = models.CarTypeEconomy
+	}
+	if carType == "" {
+		carType = models.CarTypeEconomy
+	}
+	return carType.CanServe(requested, driver.Car.AcceptsLowerClass)
+}
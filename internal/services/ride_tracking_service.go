@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/routing"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// Buffer per live stream subscriber. Slow subscribers miss intermediate updates rather than blocking.
+	tripProgressBufferSize = 8
+)
+
+// TripProgress is what the passenger sees about the assigned driver
+type TripProgress struct {
+	BookingID          uuid.UUID
+	Status             models.BookingStatus
+	DriverLocation     *models.ExactLocation
+	DistanceToPickupKm *float64       // Road distance, only while the driver is on the way
+	PickupETA          *time.Duration // Only while the driver is on the way
+	DriverArrivedAt    *time.Time
+	WaitingTime        time.Duration
+	UpdatedAt          time.Time
+}
+
+type RideTrackingService interface {
+	// OnDriverLocationUpdate refreshes the progress of the driver's active booking and
+	// moves it to ARRIVED once the driver is within the pickup radius
+	OnDriverLocationUpdate(ctx context.Context, driverAccountID uuid.UUID, location models.ExactLocation) error
+	// GetTripProgress computes the current progress of a booking
+	GetTripProgress(ctx context.Context, booking *models.Booking) (*TripProgress, error)
+	// PublishProgress recomputes and broadcasts the progress, used after status changes
+	PublishProgress(ctx context.Context, booking *models.Booking)
+	// Subscribe streams progress updates of a booking until the returned cancel func is called
+	Subscribe(bookingID uuid.UUID) (<-chan TripProgress, func())
+}
+
+type rideTrackingService struct {
+	bookingRepo     repositories.BookingRepository
+	driverRepo      repositories.DriverRepository
+	locationService LocationService
+	routingProvider routing.RoutingProvider
+	transactor      repositories.Transactor
+	outboxRepo      repositories.OutboxRepository
+	arrivalRadiusKm float64
+
+	subscribers map[uuid.UUID]map[chan TripProgress]struct{}
+	mu          sync.RWMutex
+}
+
+func NewRideTrackingService(
+	bookingRepo repositories.BookingRepository,
+	driverRepo repositories.DriverRepository,
+	locationService LocationService,
+	routingProvider routing.RoutingProvider,
+	transactor repositories.Transactor,
+	outboxRepo repositories.OutboxRepository,
+	arrivalRadiusMeters float64,
+) RideTrackingService {
+	return &rideTrackingService{
+		bookingRepo:     bookingRepo,
+		driverRepo:      driverRepo,
+		locationService: locationService,
+		routingProvider: routingProvider,
+		transactor:      transactor,
+		outboxRepo:      outboxRepo,
+		arrivalRadiusKm: arrivalRadiusMeters / 1000,
+		subscribers:     make(map[uuid.UUID]map[chan TripProgress]struct{}),
+	}
+}
+
+func (s *rideTrackingService) OnDriverLocationUpdate(ctx context.Context, driverAccountID uuid.UUID, location models.ExactLocation) error {
+	// 1. Find the booking the driver is serving, if any
+	driver, err := s.driverRepo.GetByAccountID(ctx, driverAccountID)
+	if err != nil {
+		return err
+	}
+	booking, err := s.bookingRepo.GetActiveBookingForDriver(ctx, driver.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // Driver is idle, nothing to track
+		}
+		return err
+	}
+
+	// 2. Arrival detection
+	if booking.Status == models.BookingStatusAccepted {
+		distance := util.DistanceKm(location.Latitude, location.Longitude, booking.PickupLatitude, booking.PickupLongitude)
+		if distance <= s.arrivalRadiusKm {
+			now := time.Now()
+			var arrived bool
+			err := s.transactor.InTx(ctx, func(ctx context.Context) error {
+				var err error
+				if arrived, err = s.bookingRepo.MarkDriverArrived(ctx, booking.ID, now); err != nil || !arrived {
+					return err
+				}
+				booking.Status = models.BookingStatusArrived
+				booking.DriverArrivedAt = &now
+				return addBookingEvent(ctx, s.outboxRepo, domain.BookingDriverArrived{
+					BookingEvent: newBookingEvent(booking),
+					ArrivedAt:    now,
+				})
+			})
+			if err != nil {
+				return err
+			}
+			if arrived {
+				log.Info().
+					Str("booking_id", booking.ID.String()).
+					Str("driver_id", driver.ID.String()).
+					Msg("Driver arrived at pickup")
+			}
+		}
+	}
+
+	// 3. Broadcast
+	progress := s.progressFor(ctx, booking, &location)
+	s.broadcast(progress)
+	return nil
+}
+
+func (s *rideTrackingService) GetTripProgress(ctx context.Context, booking *models.Booking) (*TripProgress, error) {
+	var location *models.ExactLocation
+	if booking.Driver != nil {
+		location, _ = s.locationService.GetDriverLocation(booking.Driver.AccountId)
+	} else if booking.DriverId != nil {
+		driver, err := s.driverRepo.GetByID(ctx, *booking.DriverId)
+		if err != nil {
+			return nil, err
+		}
+		location, _ = s.locationService.GetDriverLocation(driver.AccountId)
+	}
+
+	progress := s.progressFor(ctx, booking, location)
+	return &progress, nil
+}
+
+func (s *rideTrackingService) PublishProgress(ctx context.Context, booking *models.Booking) {
+	progress, err := s.GetTripProgress(ctx, booking)
+	if err != nil {
+		log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Failed to compute trip progress")
+		return
+	}
+	s.broadcast(*progress)
+}
+
+func (s *rideTrackingService) Subscribe(bookingID uuid.UUID) (<-chan TripProgress, func()) {
+	ch := make(chan TripProgress, tripProgressBufferSize)
+
+	s.mu.Lock()
+	if s.subscribers[bookingID] == nil {
+		s.subscribers[bookingID] = make(map[chan TripProgress]struct{})
+	}
+	s.subscribers[bookingID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.subscribers[bookingID], ch)
+			if len(s.subscribers[bookingID]) == 0 {
+				delete(s.subscribers, bookingID)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// progressFor builds the progress of a booking given the driver's current location (may be nil)
+func (s *rideTrackingService) progressFor(ctx context.Context, booking *models.Booking, driverLocation *models.ExactLocation) TripProgress {
+	now := time.Now()
+	progress := TripProgress{
+		BookingID:       booking.ID,
+		Status:          booking.Status,
+		DriverLocation:  driverLocation,
+		DriverArrivedAt: booking.DriverArrivedAt,
+		WaitingTime:     booking.WaitingTime(now),
+		UpdatedAt:       now,
+	}
+
+	if booking.Status.IsDriverEnRoute() && driverLocation != nil {
+		pickup := models.ExactLocation{Latitude: booking.PickupLatitude, Longitude: booking.PickupLongitude}
+		if booking.Status == models.BookingStatusArrived {
+			progress.DistanceToPickupKm = util.Ptr(0.0)
+			progress.PickupETA = util.Ptr(time.Duration(0))
+		} else if route, err := s.routeToPickup(ctx, booking, *driverLocation, pickup); err == nil {
+			progress.DistanceToPickupKm = &route.DistanceKm
+			progress.PickupETA = &route.Duration
+		} else {
+			log.Debug().Err(err).Str("booking_id", booking.ID.String()).Msg("Could not route driver to pickup")
+		}
+	}
+	return progress
+}
+
+// routeToPickup routes the driver to the pickup, by way of the drop-off of the ride they are
+// finishing if the booking is chained after it
+func (s *rideTrackingService) routeToPickup(ctx context.Context, booking *models.Booking, driverLocation, pickup models.ExactLocation) (*routing.Route, error) {
+	if booking.QueuedBehindBookingId == nil {
+		return s.routingProvider.Route(ctx, driverLocation, pickup)
+	}
+
+	current, err := s.bookingRepo.GetByID(ctx, *booking.QueuedBehindBookingId)
+	if err != nil {
+		return nil, err
+	}
+	dropoff := models.ExactLocation{Latitude: current.DropoffLatitude, Longitude: current.DropoffLongitude}
+	toDropoff, err := s.routingProvider.Route(ctx, driverLocation, dropoff)
+	if err != nil {
+		return nil, err
+	}
+	toPickup, err := s.routingProvider.Route(ctx, dropoff, pickup)
+	if err != nil {
+		return nil, err
+	}
+	return &routing.Route{
+		DistanceKm: toDropoff.DistanceKm + toPickup.DistanceKm,
+		Duration:   toDropoff.Duration + toPickup.Duration,
+	}, nil
+}
+
+func (s *rideTrackingService) broadcast(progress TripProgress) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for ch := range s.subscribers[progress.BookingID] {
+		select {
+		case ch <- progress:
+		default:
+			// Subscriber is lagging behind, it will catch up with the next update
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package routing
+
+import (
+	"context"
+
+	"CabBookingService/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+type fallbackProvider struct {
+	primary  RoutingProvider
+	fallback RoutingProvider
+}
+
+// NewFallbackProvider asks the primary provider first and falls back to the secondary one
+// when the primary fails (e.g. points outside the loaded road graph).
+func NewFallbackProvider(primary, fallback RoutingProvider) RoutingProvider {
+	return &fallbackProvider{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+func (p *fallbackProvider) Route(ctx context.Context, from, to models.ExactLocation) (*Route, error) {
+	route, err := p.primary.Route(ctx, from, to)
+	if err == nil {
+		return route, nil
+	}
+
+	// Don't fall back if the caller is gone
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	log.Debug().Err(err).Msg("Primary routing provider failed, using fallback")
+	return p.fallback.Route(ctx, from, to)
+}
@@ -0,0 +1,285 @@
+package routing
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"CabBookingService/internal/util"
+)
+
+const (
+	// Size of a spatial index cell in degrees (~1.1 km at the equator)
+	graphIndexCellSize = 0.01
+)
+
+// Default driving speeds (km/h) per OSM highway type, used when a way has no maxspeed tag.
+// Ways with a highway type not listed here (footways, cycleways, steps, ...) are not routable.
+var highwaySpeedsKmh = map[string]float64{
+	"motorway":       90,
+	"motorway_link":  50,
+	"trunk":          70,
+	"trunk_link":     40,
+	"primary":        50,
+	"primary_link":   35,
+	"secondary":      40,
+	"secondary_link": 30,
+	"tertiary":       35,
+	"tertiary_link":  25,
+	"unclassified":   30,
+	"residential":    25,
+	"living_street":  10,
+	"service":        15,
+}
+
+// Graph is a directed road graph built from an OpenStreetMap extract.
+// It is immutable once loaded and safe for concurrent use.
+type Graph struct {
+	nodes       []graphNode
+	edges       [][]graphEdge // Adjacency list, indexed by node
+	index       map[graphCell][]int
+	maxSpeedKmh float64 // Fastest edge in the graph, keeps the A* heuristic admissible
+}
+
+type graphNode struct {
+	lat float64
+	lon float64
+}
+
+type graphEdge struct {
+	to         int
+	distanceKm float64
+	seconds    float64
+}
+
+type graphCell struct {
+	x int
+	y int
+}
+
+// NodeCount returns the number of routable nodes in the graph
+func (g *Graph) NodeCount() int {
+	return len(g.nodes)
+}
+
+// LoadOSMGraph loads a road graph from an OSM XML extract (.osm file)
+func LoadOSMGraph(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open road graph file: %w", err)
+	}
+	defer f.Close()
+
+	return ParseOSMGraph(f)
+}
+
+// osmWay is the subset of an OSM way we need for routing
+type osmWay struct {
+	nodeRefs []int64
+	tags     map[string]string
+}
+
+// ParseOSMGraph builds a road graph from OSM XML. Only ways tagged with a routable
+// highway type are kept, and only the nodes they reference end up in the graph.
+func ParseOSMGraph(r io.Reader) (*Graph, error) {
+	coords := make(map[int64]graphNode)
+	ways := make([]osmWay, 0)
+
+	// 1. Stream the XML, it can be far too large to unmarshal in one go
+	decoder := xml.NewDecoder(r)
+	var current *osmWay
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse OSM XML: %w", err)
+		}
+
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "node":
+				id, lat, lon, err := parseOSMNode(el)
+				if err != nil {
+					return nil, err
+				}
+				coords[id] = graphNode{lat: lat, lon: lon}
+			case "way":
+				current = &osmWay{tags: make(map[string]string)}
+			case "nd":
+				if current != nil {
+					ref, err := strconv.ParseInt(xmlAttr(el, "ref"), 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid node reference in way: %w", err)
+					}
+					current.nodeRefs = append(current.nodeRefs, ref)
+				}
+			case "tag":
+				if current != nil {
+					current.tags[xmlAttr(el, "k")] = xmlAttr(el, "v")
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local == "way" && current != nil {
+				if _, routable := highwaySpeedsKmh[current.tags["highway"]]; routable {
+					ways = append(ways, *current)
+				}
+				current = nil
+			}
+		}
+	}
+
+	// 2. Build the graph from the routable ways
+	g := &Graph{
+		index: make(map[graphCell][]int),
+	}
+	nodeIndex := make(map[int64]int)
+	nodeFor := func(ref int64) (int, bool) {
+		if idx, ok := nodeIndex[ref]; ok {
+			return idx, true
+		}
+		coord, ok := coords[ref]
+		if !ok {
+			// Extracts cut at a bounding box can reference nodes outside of it
+			return 0, false
+		}
+		idx := len(g.nodes)
+		g.nodes = append(g.nodes, coord)
+		g.edges = append(g.edges, nil)
+		nodeIndex[ref] = idx
+
+		cell := cellFor(coord.lat, coord.lon)
+		g.index[cell] = append(g.index[cell], idx)
+		return idx, true
+	}
+
+	for _, way := range ways {
+		speed := waySpeedKmh(way.tags)
+		if speed > g.maxSpeedKmh {
+			g.maxSpeedKmh = speed
+		}
+		forward, backward := wayDirections(way.tags)
+
+		for i := 1; i < len(way.nodeRefs); i++ {
+			a, okA := nodeFor(way.nodeRefs[i-1])
+			b, okB := nodeFor(way.nodeRefs[i])
+			if !okA || !okB {
+				continue
+			}
+
+			distance := util.DistanceKm(g.nodes[a].lat, g.nodes[a].lon, g.nodes[b].lat, g.nodes[b].lon)
+			seconds := distance / speed * 3600
+			if forward {
+				g.edges[a] = append(g.edges[a], graphEdge{to: b, distanceKm: distance, seconds: seconds})
+			}
+			if backward {
+				g.edges[b] = append(g.edges[b], graphEdge{to: a, distanceKm: distance, seconds: seconds})
+			}
+		}
+	}
+
+	if len(g.nodes) == 0 {
+		return nil, fmt.Errorf("OSM extract has no routable roads")
+	}
+	return g, nil
+}
+
+// nearestNode finds the closest graph node within maxDistanceKm of the given point
+func (g *Graph) nearestNode(lat, lon, maxDistanceKm float64) (int, float64, bool) {
+	center := cellFor(lat, lon)
+	// How many rings of cells around the center we need to cover maxDistanceKm.
+	// A degree of longitude shrinks towards the poles, so we widen the search accordingly.
+	latRings := int(math.Ceil(maxDistanceKm / 111.0 / graphIndexCellSize))
+	lonRings := latRings
+	if cosLat := math.Cos(lat * math.Pi / 180); cosLat > 0.01 {
+		lonRings = int(math.Ceil(maxDistanceKm / (111.0 * cosLat) / graphIndexCellSize))
+	}
+
+	best, bestDistance := -1, math.MaxFloat64
+	for dx := -lonRings; dx <= lonRings; dx++ {
+		for dy := -latRings; dy <= latRings; dy++ {
+			for _, idx := range g.index[graphCell{x: center.x + dx, y: center.y + dy}] {
+				d := util.DistanceKm(lat, lon, g.nodes[idx].lat, g.nodes[idx].lon)
+				if d < bestDistance {
+					best, bestDistance = idx, d
+				}
+			}
+		}
+	}
+
+	if best < 0 || bestDistance > maxDistanceKm {
+		return 0, 0, false
+	}
+	return best, bestDistance, true
+}
+
+func cellFor(lat, lon float64) graphCell {
+	return graphCell{
+		x: int(math.Floor(lon / graphIndexCellSize)),
+		y: int(math.Floor(lat / graphIndexCellSize)),
+	}
+}
+
+func parseOSMNode(el xml.StartElement) (int64, float64, float64, error) {
+	id, err := strconv.ParseInt(xmlAttr(el, "id"), 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid OSM node id: %w", err)
+	}
+	lat, err := strconv.ParseFloat(xmlAttr(el, "lat"), 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid latitude on OSM node %d: %w", id, err)
+	}
+	lon, err := strconv.ParseFloat(xmlAttr(el, "lon"), 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid longitude on OSM node %d: %w", id, err)
+	}
+	return id, lat, lon, nil
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// waySpeedKmh uses the maxspeed tag if it can be parsed (e.g. "50", "30 mph"),
+// otherwise the default speed of the highway type.
+func waySpeedKmh(tags map[string]string) float64 {
+	if maxSpeed := strings.TrimSpace(tags["maxspeed"]); maxSpeed != "" {
+		fields := strings.Fields(maxSpeed)
+		if value, err := strconv.ParseFloat(fields[0], 64); err == nil && value > 0 {
+			if len(fields) > 1 && fields[1] == "mph" {
+				value *= 1.609
+			}
+			return value
+		}
+	}
+	return highwaySpeedsKmh[tags["highway"]]
+}
+
+// wayDirections reports in which directions a way can be driven
+func wayDirections(tags map[string]string) (forward, backward bool) {
+	switch tags["oneway"] {
+	case "yes", "true", "1":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	case "no", "false", "0":
+		return true, true
+	}
+
+	// Implied oneway
+	if tags["highway"] == "motorway" || tags["junction"] == "roundabout" {
+		return true, false
+	}
+	return true, true
+}
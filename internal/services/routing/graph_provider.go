@@ -0,0 +1,174 @@
+package routing
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/util"
+)
+
+const (
+	// Points further than this from any road node are considered off the graph
+	defaultMaxSnapDistanceKm = 1.0
+	// Speed used for the stretch between a point and its nearest road node
+	defaultAccessSpeedKmh = 15.0
+	// How often (in settled nodes) the search checks if the context was cancelled
+	ctxCheckInterval = 1024
+)
+
+type graphProvider struct {
+	graph          *Graph
+	maxSnapKm      float64
+	accessSpeedKmh float64
+}
+
+// NewGraphProvider routes over an offline road graph using A* with a travel time cost.
+// It returns ErrNoRoute for points outside the graph, wrap it with NewFallbackProvider
+// to still get an estimate in that case.
+func NewGraphProvider(graph *Graph) RoutingProvider {
+	return &graphProvider{
+		graph:          graph,
+		maxSnapKm:      defaultMaxSnapDistanceKm,
+		accessSpeedKmh: defaultAccessSpeedKmh,
+	}
+}
+
+func (p *graphProvider) Route(ctx context.Context, from, to models.ExactLocation) (*Route, error) {
+	// 1. Snap both points to the road network
+	src, srcSnapKm, ok := p.graph.nearestNode(from.Latitude, from.Longitude, p.maxSnapKm)
+	if !ok {
+		return nil, ErrNoRoute
+	}
+	dst, dstSnapKm, ok := p.graph.nearestNode(to.Latitude, to.Longitude, p.maxSnapKm)
+	if !ok {
+		return nil, ErrNoRoute
+	}
+
+	// 2. Search the graph
+	path, err := p.graph.shortestPath(ctx, src, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Assemble the route, including the access legs to and from the road network
+	distance := srcSnapKm + dstSnapKm
+	seconds := (srcSnapKm + dstSnapKm) / p.accessSpeedKmh * 3600
+	points := make([]models.ExactLocation, 0, len(path)+2)
+	points = append(points, from)
+	for i, node := range path {
+		points = append(points, models.ExactLocation{Latitude: p.graph.nodes[node].lat, Longitude: p.graph.nodes[node].lon})
+		if i > 0 {
+			edge := p.graph.edgeBetween(path[i-1], node)
+			distance += edge.distanceKm
+			seconds += edge.seconds
+		}
+	}
+	points = append(points, to)
+
+	return &Route{
+		DistanceKm: distance,
+		Duration:   time.Duration(seconds * float64(time.Second)),
+		Polyline:   EncodePolyline(points),
+	}, nil
+}
+
+// shortestPath runs A* from src to dst minimising travel time and returns the node path
+func (g *Graph) shortestPath(ctx context.Context, src, dst int) ([]int, error) {
+	if src == dst {
+		return []int{src}, nil
+	}
+
+	// Straight line at the fastest speed in the graph never overestimates, so A* stays optimal
+	heuristic := func(node int) float64 {
+		if g.maxSpeedKmh <= 0 {
+			return 0
+		}
+		d := util.DistanceKm(g.nodes[node].lat, g.nodes[node].lon, g.nodes[dst].lat, g.nodes[dst].lon)
+		return d / g.maxSpeedKmh * 3600
+	}
+
+	cost := map[int]float64{src: 0}
+	previous := make(map[int]int)
+	settled := make(map[int]bool)
+
+	open := &searchQueue{}
+	heap.Push(open, searchItem{node: src, priority: heuristic(src)})
+
+	for iterations := 0; open.Len() > 0; iterations++ {
+		if iterations%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		current := heap.Pop(open).(searchItem)
+		if settled[current.node] {
+			continue // Stale queue entry
+		}
+		if current.node == dst {
+			return buildPath(previous, src, dst), nil
+		}
+		settled[current.node] = true
+
+		for _, edge := range g.edges[current.node] {
+			if settled[edge.to] {
+				continue
+			}
+			newCost := cost[current.node] + edge.seconds
+			if known, ok := cost[edge.to]; ok && known <= newCost {
+				continue
+			}
+			cost[edge.to] = newCost
+			previous[edge.to] = current.node
+			heap.Push(open, searchItem{node: edge.to, priority: newCost + heuristic(edge.to)})
+		}
+	}
+
+	return nil, ErrNoRoute
+}
+
+// edgeBetween returns the fastest edge from a to b
+func (g *Graph) edgeBetween(a, b int) graphEdge {
+	best := graphEdge{to: b, seconds: math.MaxFloat64}
+	for _, edge := range g.edges[a] {
+		if edge.to == b && edge.seconds < best.seconds {
+			best = edge
+		}
+	}
+	return best
+}
+
+func buildPath(previous map[int]int, src, dst int) []int {
+	path := []int{dst}
+	for node := dst; node != src; {
+		node = previous[node]
+		path = append(path, node)
+	}
+	// Reverse to go from src to dst
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// --- Priority Queue for A* (container/heap) ---
+
+type searchItem struct {
+	node     int
+	priority float64
+}
+
+type searchQueue []searchItem
+
+func (q searchQueue) Len() int            { return len(q) }
+func (q searchQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q searchQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *searchQueue) Push(x interface{}) { *q = append(*q, x.(searchItem)) }
+func (q *searchQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
@@ -0,0 +1,121 @@
+package routing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"CabBookingService/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A small network around (12.97, 77.59):
+//
+//	1 ---- 2 ---- 3      residential (25 km/h), 1-2-3 along the same latitude
+//	 \           /
+//	  4 ------- 5        primary (50 km/h) slightly south, a bit longer but faster
+//
+// 6 -> 7 is a oneway street going east, far from everything else.
+const testOSM = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="12.9700" lon="77.5900"/>
+  <node id="2" lat="12.9700" lon="77.6000"/>
+  <node id="3" lat="12.9700" lon="77.6100"/>
+  <node id="4" lat="12.9680" lon="77.5920"/>
+  <node id="5" lat="12.9680" lon="77.6080"/>
+  <node id="6" lat="12.9900" lon="77.5900"/>
+  <node id="7" lat="12.9900" lon="77.6000"/>
+  <node id="8" lat="12.9500" lon="77.5900"/>
+  <way id="100">
+    <nd ref="1"/><nd ref="2"/><nd ref="3"/>
+    <tag k="highway" v="residential"/>
+  </way>
+  <way id="101">
+    <nd ref="1"/><nd ref="4"/><nd ref="5"/><nd ref="3"/>
+    <tag k="highway" v="primary"/>
+  </way>
+  <way id="102">
+    <nd ref="6"/><nd ref="7"/>
+    <tag k="highway" v="residential"/>
+    <tag k="oneway" v="yes"/>
+  </way>
+  <way id="103">
+    <nd ref="8"/><nd ref="1"/>
+    <tag k="highway" v="footway"/>
+  </way>
+</osm>`
+
+func loadTestGraph(t *testing.T) *Graph {
+	t.Helper()
+	graph, err := ParseOSMGraph(strings.NewReader(testOSM))
+	require.NoError(t, err)
+	return graph
+}
+
+func TestParseOSMGraph(t *testing.T) {
+	t.Parallel()
+	graph := loadTestGraph(t)
+
+	// Node 8 is only referenced by a footway, so it must not be part of the road graph
+	require.Equal(t, 7, graph.NodeCount())
+}
+
+func TestGraphProvider_PrefersFasterRoad(t *testing.T) {
+	t.Parallel()
+	provider := NewGraphProvider(loadTestGraph(t))
+
+	from := models.ExactLocation{Latitude: 12.9700, Longitude: 77.5900}
+	to := models.ExactLocation{Latitude: 12.9700, Longitude: 77.6100}
+
+	route, err := provider.Route(context.Background(), from, to)
+	require.NoError(t, err)
+
+	// The direct residential road is ~2.17 km, the primary detour is longer
+	require.Greater(t, route.DistanceKm, 2.2)
+	// ...but at 50 km/h it takes less than the ~5.2 minutes of the residential road
+	require.Less(t, route.Duration.Minutes(), 5.0)
+	require.NotEmpty(t, route.Polyline)
+}
+
+func TestGraphProvider_RespectsOneway(t *testing.T) {
+	t.Parallel()
+	provider := NewGraphProvider(loadTestGraph(t))
+
+	west := models.ExactLocation{Latitude: 12.9900, Longitude: 77.5900}
+	east := models.ExactLocation{Latitude: 12.9900, Longitude: 77.6000}
+
+	_, err := provider.Route(context.Background(), west, east)
+	require.NoError(t, err)
+
+	_, err = provider.Route(context.Background(), east, west)
+	require.ErrorIs(t, err, ErrNoRoute)
+}
+
+func TestGraphProvider_OffGraphFallsBack(t *testing.T) {
+	t.Parallel()
+	graphProvider := NewGraphProvider(loadTestGraph(t))
+
+	from := models.ExactLocation{Latitude: 12.9700, Longitude: 77.5900}
+	farAway := models.ExactLocation{Latitude: 19.0760, Longitude: 72.8777}
+
+	_, err := graphProvider.Route(context.Background(), from, farAway)
+	require.ErrorIs(t, err, ErrNoRoute)
+
+	provider := NewFallbackProvider(graphProvider, NewHaversineProvider(30))
+	route, err := provider.Route(context.Background(), from, farAway)
+	require.NoError(t, err)
+	require.Greater(t, route.DistanceKm, 800.0)
+}
+
+func TestEncodePolyline(t *testing.T) {
+	t.Parallel()
+
+	// Example from the polyline algorithm documentation
+	points := []models.ExactLocation{
+		{Latitude: 38.5, Longitude: -120.2},
+		{Latitude: 40.7, Longitude: -120.95},
+		{Latitude: 43.252, Longitude: -126.453},
+	}
+	require.Equal(t, "_p~iF~ps|U_ulLnnqC_mqNvxq`@", EncodePolyline(points))
+}
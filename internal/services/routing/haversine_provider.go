@@ -0,0 +1,47 @@
+package routing
+
+import (
+	"context"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/util"
+)
+
+const (
+	// Roads are never straight. A straight line distance multiplied by this factor
+	// is a decent approximation of the driven distance in a city.
+	defaultDetourFactor = 1.3
+)
+
+type haversineProvider struct {
+	averageSpeedKmh float64
+	detourFactor    float64
+}
+
+// NewHaversineProvider estimates routes from the straight line distance and an average speed.
+// It never fails, which makes it a good fallback for the other providers.
+func NewHaversineProvider(averageSpeedKmh float64) RoutingProvider {
+	return &haversineProvider{
+		averageSpeedKmh: averageSpeedKmh,
+		detourFactor:    defaultDetourFactor,
+	}
+}
+
+func (p *haversineProvider) Route(_ context.Context, from, to models.ExactLocation) (*Route, error) {
+	distance := util.DistanceKm(from.Latitude, from.Longitude, to.Latitude, to.Longitude) * p.detourFactor
+
+	return &Route{
+		DistanceKm: distance,
+		Duration:   travelTime(distance, p.averageSpeedKmh),
+		Polyline:   EncodePolyline([]models.ExactLocation{from, to}),
+	}, nil
+}
+
+// travelTime returns how long it takes to drive the distance at the given speed
+func travelTime(distanceKm, speedKmh float64) time.Duration {
+	if speedKmh <= 0 {
+		return 0
+	}
+	return time.Duration(distanceKm / speedKmh * float64(time.Hour))
+}
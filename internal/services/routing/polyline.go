@@ -0,0 +1,41 @@
+package routing
+
+import (
+	"math"
+	"strings"
+
+	"CabBookingService/internal/models"
+)
+
+// EncodePolyline encodes points with the Google encoded polyline algorithm (precision 1e5).
+// See https://developers.google.com/maps/documentation/utilities/polylinealgorithm
+func EncodePolyline(points []models.ExactLocation) string {
+	var sb strings.Builder
+
+	prevLat, prevLon := 0, 0
+	for _, p := range points {
+		lat := int(math.Round(p.Latitude * 1e5))
+		lon := int(math.Round(p.Longitude * 1e5))
+
+		encodePolylineValue(&sb, lat-prevLat)
+		encodePolylineValue(&sb, lon-prevLon)
+
+		prevLat, prevLon = lat, lon
+	}
+	return sb.String()
+}
+
+func encodePolylineValue(sb *strings.Builder, value int) {
+	// Left shift and invert negative values so the sign ends up in the lowest bit
+	v := value << 1
+	if value < 0 {
+		v = ^v
+	}
+
+	// Emit 5-bit chunks, lowest first, with 0x20 set on every chunk except the last
+	for v >= 0x20 {
+		sb.WriteByte(byte((0x20 | (v & 0x1f)) + 63))
+		v >>= 5
+	}
+	sb.WriteByte(byte(v + 63))
+}
@@ -0,0 +1,27 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"CabBookingService/internal/models"
+)
+
+var (
+	// ErrNoRoute is returned when a provider cannot connect the two points
+	ErrNoRoute = errors.New("no route found between the given points")
+)
+
+// Route is the result of a routing request
+type Route struct {
+	DistanceKm float64
+	Duration   time.Duration
+	// Polyline is the route geometry encoded with the Google encoded polyline algorithm
+	Polyline string
+}
+
+// RoutingProvider defines the contract for computing road routes between two points
+type RoutingProvider interface {
+	Route(ctx context.Context, from, to models.ExactLocation) (*Route, error)
+}
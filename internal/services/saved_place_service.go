@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/geocoding"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	maxPlaceLabelLength = 50
+)
+
+var (
+	ErrInvalidSavedPlace  = errors.New("invalid saved place")
+	ErrPlaceLabelTaken    = errors.New("a saved place with this label already exists")
+	ErrSavedPlaceNotFound = errors.New("saved place not found")
+	ErrSavedPlaceNotOwned = errors.New("saved place does not belong to this passenger")
+)
+
+type SavedPlaceParams struct {
+	Label     string // HOME, WORK or a custom label
+	Latitude  float64
+	Longitude float64
+	Address   string // Optional, reverse geocoded if empty
+}
+
+type SavedPlaceService interface {
+	CreatePlace(ctx context.Context, passengerAccountID uuid.UUID, params SavedPlaceParams) (*models.SavedPlace, error)
+	ListPlaces(ctx context.Context, passengerAccountID uuid.UUID) ([]models.SavedPlace, error)
+	UpdatePlace(ctx context.Context, passengerAccountID, placeID uuid.UUID, params SavedPlaceParams) (*models.SavedPlace, error)
+	DeletePlace(ctx context.Context, passengerAccountID, placeID uuid.UUID) error
+}
+
+type savedPlaceService struct {
+	savedPlaceRepo repositories.SavedPlaceRepository
+	passengerRepo  repositories.PassengerRepository
+	geocoder       geocoding.Geocoder
+}
+
+func NewSavedPlaceService(
+	savedPlaceRepo repositories.SavedPlaceRepository,
+	passengerRepo repositories.PassengerRepository,
+	geocoder geocoding.Geocoder,
+) SavedPlaceService {
+	return &savedPlaceService{
+		savedPlaceRepo: savedPlaceRepo,
+		passengerRepo:  passengerRepo,
+		geocoder:       geocoder,
+	}
+}
+
+func (s *savedPlaceService) CreatePlace(ctx context.Context, passengerAccountID uuid.UUID, params SavedPlaceParams) (*models.SavedPlace, error) {
+	// 1. Validate
+	label, err := normalizePlaceParams(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Get Passenger Profile from Account ID
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Labels are unique per passenger
+	if _, err := s.savedPlaceRepo.GetByPassengerAndLabel(ctx, passenger.ID, label); err == nil {
+		return nil, ErrPlaceLabelTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 4. Create Place
+	now := time.Now()
+	place := &models.SavedPlace{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		PassengerId: passenger.ID,
+		Label:       label,
+		Latitude:    params.Latitude,
+		Longitude:   params.Longitude,
+		Address:     s.addressOrGeocode(ctx, params),
+	}
+	if err := s.savedPlaceRepo.Create(ctx, place); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("place_id", place.ID.String()).
+		Str("passenger_id", passenger.ID.String()).
+		Str("label", place.Label).
+		Msg("Saved place created")
+	return place, nil
+}
+
+func (s *savedPlaceService) ListPlaces(ctx context.Context, passengerAccountID uuid.UUID) ([]models.SavedPlace, error) {
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.savedPlaceRepo.ListByPassenger(ctx, passenger.ID)
+}
+
+func (s *savedPlaceService) UpdatePlace(ctx context.Context, passengerAccountID, placeID uuid.UUID, params SavedPlaceParams) (*models.SavedPlace, error) {
+	// 1. Validate
+	label, err := normalizePlaceParams(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Load and authorize
+	place, err := s.getOwnedPlace(ctx, passengerAccountID, placeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Renaming must not clash with another place
+	if label != place.Label {
+		if _, err := s.savedPlaceRepo.GetByPassengerAndLabel(ctx, place.PassengerId, label); err == nil {
+			return nil, ErrPlaceLabelTaken
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	// 4. Update
+	place.Label = label
+	place.Latitude = params.Latitude
+	place.Longitude = params.Longitude
+	place.Address = s.addressOrGeocode(ctx, params)
+	place.UpdatedAt = time.Now()
+	if err := s.savedPlaceRepo.Update(ctx, place); err != nil {
+		return nil, err
+	}
+	return place, nil
+}
+
+func (s *savedPlaceService) DeletePlace(ctx context.Context, passengerAccountID, placeID uuid.UUID) error {
+	place, err := s.getOwnedPlace(ctx, passengerAccountID, placeID)
+	if err != nil {
+		return err
+	}
+	return s.savedPlaceRepo.Delete(ctx, place.ID)
+}
+
+func (s *savedPlaceService) getOwnedPlace(ctx context.Context, passengerAccountID, placeID uuid.UUID) (*models.SavedPlace, error) {
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, passengerAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return getPassengerPlace(ctx, s.savedPlaceRepo, passenger.ID, placeID)
+}
+
+func (s *savedPlaceService) addressOrGeocode(ctx context.Context, params SavedPlaceParams) string {
+	if params.Address != "" {
+		return params.Address
+	}
+	return reverseGeocode(ctx, s.geocoder, params.Latitude, params.Longitude)
+}
+
+// getPassengerPlace loads a saved place and makes sure it belongs to the passenger
+func getPassengerPlace(ctx context.Context, repo repositories.SavedPlaceRepository, passengerID, placeID uuid.UUID) (*models.SavedPlace, error) {
+	place, err := repo.GetByID(ctx, placeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSavedPlaceNotFound
+		}
+		return nil, err
+	}
+	if place.PassengerId != passengerID {
+		return nil, ErrSavedPlaceNotOwned
+	}
+	return place, nil
+}
+
+// reverseGeocode never fails, if no address is known the coordinates are used instead
+func reverseGeocode(ctx context.Context, geocoder geocoding.Geocoder, lat, lon float64) string {
+	address, err := geocoder.ReverseGeocode(ctx, lat, lon)
+	if err != nil {
+		if !errors.Is(err, geocoding.ErrNoAddressFound) {
+			log.Warn().Err(err).Float64("lat", lat).Float64("lon", lon).Msg("Reverse geocoding failed")
+		}
+		return geocoding.FormatCoordinates(lat, lon)
+	}
+	return address
+}
+
+// normalizePlaceParams validates the params and returns the normalized label
+func normalizePlaceParams(params *SavedPlaceParams) (string, error) {
+	label := strings.ToUpper(strings.TrimSpace(params.Label))
+	if label == "" || len(label) > maxPlaceLabelLength {
+		return "", fmt.Errorf("%w: label must be between 1 and %d characters", ErrInvalidSavedPlace, maxPlaceLabelLength)
+	}
+	if !util.IsValidCoordinate(params.Latitude, params.Longitude) {
+		return "", fmt.Errorf("%w: %w", ErrInvalidSavedPlace, ErrInvalidCoordinates)
+	}
+	params.Address = strings.TrimSpace(params.Address)
+	return label, nil
+}
@@ -0,0 +1,62 @@
+// Package scheduling holds the rules for rides booked ahead of time
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTooSoon = errors.New("scheduled time is too soon")
+	ErrTooFar  = errors.New("scheduled time is too far ahead")
+)
+
+// Rules tell when a ride can be scheduled for, and when a scheduled ride starts looking for a driver
+type Rules struct {
+	MinLead    time.Duration // A scheduled pickup is at least this far ahead, sooner rides are booked without one
+	MaxHorizon time.Duration // A scheduled pickup is at most this far ahead
+	// Scheduled rides are sent to driver matching this long before pickup
+	ActivationWindow time.Duration
+	// Activation windows of cities that need more or less time to find a driver, by city name
+	CityActivationWindows map[string]time.Duration
+}
+
+// Validate returns ErrTooSoon or ErrTooFar if a ride can't be scheduled for the given pickup time
+func (r Rules) Validate(at, now time.Time) error {
+	if at.Before(r.Earliest(now)) {
+		return fmt.Errorf("%w: the pickup must be at least %s ahead, book the ride without a scheduled time instead", ErrTooSoon, r.MinLead)
+	}
+	if at.After(r.Latest(now)) {
+		return fmt.Errorf("%w: the pickup can be at most %s ahead", ErrTooFar, r.MaxHorizon)
+	}
+	return nil
+}
+
+// Earliest returns the first pickup time a ride can be scheduled for
+func (r Rules) Earliest(now time.Time) time.Time {
+	return now.Add(r.MinLead)
+}
+
+// Latest returns the last pickup time a ride can be scheduled for
+func (r Rules) Latest(now time.Time) time.Time {
+	return now.Add(r.MaxHorizon)
+}
+
+// ActivationWindowOf returns how long before pickup the rides of the city are sent to driver matching
+func (r Rules) ActivationWindowOf(city string) time.Duration {
+	if window, ok := r.CityActivationWindows[city]; ok {
+		return window
+	}
+	return r.ActivationWindow
+}
+
+// ActivationCutoffs returns the latest pickup time of the rides due for activation at now, by
+// city for the cities with their own window and for every other city
+func (r Rules) ActivationCutoffs(now time.Time) (map[string]time.Time, time.Time) {
+	byCity := make(map[string]time.Time, len(r.CityActivationWindows))
+	for city, window := range r.CityActivationWindows {
+		byCity[city] = now.Add(window)
+	}
+	return byCity, now.Add(r.ActivationWindow)
+}
@@ -0,0 +1,44 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRules() Rules {
+	return Rules{
+		MinLead:               20 * time.Minute,
+		MaxHorizon:            30 * 24 * time.Hour,
+		ActivationWindow:      15 * time.Minute,
+		CityActivationWindows: map[string]time.Duration{"Bengaluru": 30 * time.Minute},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	rules := testRules()
+	now := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+
+	require.NoError(t, rules.Validate(now.Add(20*time.Minute), now))
+	require.NoError(t, rules.Validate(now.Add(30*24*time.Hour), now))
+	require.ErrorIs(t, rules.Validate(now.Add(19*time.Minute), now), ErrTooSoon)
+	require.ErrorIs(t, rules.Validate(now.Add(-time.Hour), now), ErrTooSoon)
+	require.ErrorIs(t, rules.Validate(now.Add(31*24*time.Hour), now), ErrTooFar)
+}
+
+func TestActivationWindows(t *testing.T) {
+	t.Parallel()
+
+	rules := testRules()
+	require.Equal(t, 30*time.Minute, rules.ActivationWindowOf("Bengaluru"))
+	require.Equal(t, 15*time.Minute, rules.ActivationWindowOf("Mysuru"))
+	require.Equal(t, 15*time.Minute, rules.ActivationWindowOf(""))
+
+	now := time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)
+	byCity, others := rules.ActivationCutoffs(now)
+	require.Equal(t, map[string]time.Time{"Bengaluru": now.Add(30 * time.Minute)}, byCity)
+	require.Equal(t, now.Add(15*time.Minute), others)
+}
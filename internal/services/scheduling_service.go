@@ -2,44 +2,84 @@ package services
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"CabBookingService/internal/domain"
 	"CabBookingService/internal/models"
 	"CabBookingService/internal/repositories"
-	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/services/scheduling"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
 type SchedulingService interface {
-	Start(ctx context.Context)
+	// Start ticks until ctx is cancelled, the loop is counted in running so a tick can finish on shutdown
+	Start(ctx context.Context, running *sync.WaitGroup)
+}
+
+const (
+	// Name of the leader lock of the activation tick
+	schedulingLeaderLock = "scheduling-service"
+	// A scheduled booking whose activation failed this many times is cancelled
+	maxActivationAttempts = 5
+)
+
+// SchedulingSettings tune the activation of scheduled bookings
+type SchedulingSettings struct {
+	TickInterval time.Duration // How often due bookings are activated
+	BatchSize    int           // Bookings activated per transaction
+	// The activation windows, by city
+	Rules scheduling.Rules
 }
 
 type schedulingService struct {
-	bookingRepo   repositories.BookingRepository
-	messageQueue  queue.MessageQueue
-	checkInterval time.Duration
-	window        time.Duration
+	bookingRepo    repositories.BookingRepository
+	transactor     repositories.Transactor
+	outboxRepo     repositories.OutboxRepository
+	recurringRides RecurringRideService
+	reservations   ReservationService
+	leaderLock     repositories.LeaderLock // Optional, every instance runs the tick without it
+	tickInterval   time.Duration
+	rules          scheduling.Rules
+	batchSize      int
 }
 
 func NewSchedulingService(
 	bookingRepo repositories.BookingRepository,
-	messageQueue queue.MessageQueue,
+	transactor repositories.Transactor,
+	outboxRepo repositories.OutboxRepository,
+	recurringRides RecurringRideService,
+	reservations ReservationService,
+	leaderLock repositories.LeaderLock,
+	settings SchedulingSettings,
 ) SchedulingService {
 	return &schedulingService{
-		bookingRepo:   bookingRepo,
-		messageQueue:  messageQueue,
-		checkInterval: 1 * time.Minute,  // Run every minute
-		window:        15 * time.Minute, // Process rides 15 mins before time
+		bookingRepo:    bookingRepo,
+		transactor:     transactor,
+		outboxRepo:     outboxRepo,
+		recurringRides: recurringRides,
+		reservations:   reservations,
+		leaderLock:     leaderLock,
+		tickInterval:   settings.TickInterval,
+		rules:          settings.Rules,
+		batchSize:      settings.BatchSize,
 	}
 }
 
-func (s schedulingService) Start(ctx context.Context) {
-	ticker := time.NewTicker(s.checkInterval)
-	log.Info().Msg("Scheduling Service started")
+func (s schedulingService) Start(ctx context.Context, running *sync.WaitGroup) {
+	ticker := time.NewTicker(s.tickInterval)
+	log.Info().
+		Dur("tick_interval", s.tickInterval).
+		Dur("activation_window", s.rules.ActivationWindow).
+		Int("city_windows", len(s.rules.CityActivationWindows)).
+		Bool("leader_lock", s.leaderLock != nil).
+		Msg("Scheduling Service started")
 
+	running.Add(1)
 	go func() {
+		defer running.Done()
 		for {
 			select {
 			case <-ctx.Done():
@@ -47,35 +87,140 @@ func (s schedulingService) Start(ctx context.Context) {
 				log.Info().Msg("Scheduling Service stopped")
 				return
 			case <-ticker.C:
-				s.processScheduledBookings(ctx)
+				s.tick(ctx)
 			}
 		}
 	}()
 }
 
+// tick books the upcoming occurrences of recurring rides, sends the reminders of reserved rides and
+// activates the due bookings, if this instance is the leader
+func (s schedulingService) tick(ctx context.Context) {
+	if s.leaderLock != nil {
+		unlock, ok, err := s.leaderLock.TryLock(ctx, schedulingLeaderLock)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to take the scheduling leader lock")
+			return
+		}
+		if !ok {
+			log.Debug().Msg("Another instance is activating scheduled bookings")
+			return
+		}
+		defer unlock()
+	}
+	s.recurringRides.MaterializeDue(ctx)
+	s.reservations.SendReminders(ctx)
+	s.processScheduledBookings(ctx)
+}
+
 func (s schedulingService) processScheduledBookings(ctx context.Context) {
-	// 1. Find bookings that are SCHEDULED and due within the window
-	cutoff := time.Now().Add(s.window)
+	// Bookings due within the activation window of their city, a batch at a time until none are left.
+	// Bookings that failed are claimed again on the next tick, not in this one.
+	cityCutoffs, cutoff := s.rules.ActivationCutoffs(time.Now())
+	for ctx.Err() == nil {
+		claimed, failed, err := s.activateBatch(ctx, cityCutoffs, cutoff)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to activate scheduled bookings")
+			return
+		}
+		if failed > 0 || claimed < s.batchSize {
+			return
+		}
+	}
+}
+
+// activateBatch activates up to batchSize due bookings and returns how many it claimed and how many of those failed
+func (s schedulingService) activateBatch(ctx context.Context, cityCutoffs map[string]time.Time, cutoff time.Time) (int, int, error) {
+	var bookings []models.Booking
+	// Bookings activated, and the driver who reserved them if any
+	activated := make(map[uuid.UUID]*uuid.UUID)
+	err := s.transactor.InTx(ctx, func(ctx context.Context) error {
+		// 1. Claim the due bookings, moving them from SCHEDULED to REQUESTED. Other instances skip
+		// them, and don't see them once this commits.
+		var err error
+		bookings, err = s.bookingRepo.ClaimDueScheduledBookings(ctx, cityCutoffs, cutoff, s.batchSize)
+		if err != nil {
+			return err
+		}
 
-	bookings, err := s.bookingRepo.GetDueScheduledBookings(ctx, cutoff)
+		// 2. Activate each in its own savepoint, a failing booking doesn't hold up the rest of the batch
+		for i := range bookings {
+			booking := &bookings[i]
+			// Releasing an unavailable driver takes the reservation off the booking, they are told all the same
+			reservedDriverID := booking.ReservedDriverId
+			err := s.transactor.InTx(ctx, func(ctx context.Context) error {
+				return s.activate(ctx, booking)
+			})
+			if err == nil {
+				activated[booking.ID] = reservedDriverID
+				continue
+			}
+			if err := s.failActivation(ctx, booking, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch scheduled bookings")
-		return
+		return 0, 0, err
 	}
 
-	for _, booking := range bookings {
-		// 2. Update Status to REQUESTED
-		if err := s.bookingRepo.UpdateStatus(ctx, booking.ID, models.BookingStatusRequested); err != nil {
-			log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Failed to activate scheduled booking")
-			continue
+	for i := range bookings {
+		booking := &bookings[i]
+		driverID, ok := activated[booking.ID]
+		switch {
+		case !ok:
+		case driverID != nil:
+			s.reservations.NotifyActivated(ctx, booking, *driverID)
+		default:
+			log.Info().Str("booking_id", booking.ID.String()).Msg("Scheduled booking queued for driver matching")
 		}
+	}
+	return len(bookings), len(bookings) - len(activated), nil
+}
+
+// activate hands a claimed booking to its reserved driver, or to matching
+func (s schedulingService) activate(ctx context.Context, booking *models.Booking) error {
+	if err := addBookingEvent(ctx, s.outboxRepo, domain.BookingActivated{BookingEvent: newBookingEvent(booking)}); err != nil {
+		return err
+	}
 
-		// 3. Push to Matching Queue
-		log.Info().Str("booking_id", booking.ID.String()).Msg("Activating scheduled booking")
-		if err := s.messageQueue.Publish(ctx, domain.TopicDriverMatching, booking.ID); err != nil {
-			log.Error().Err(err).Str("booking_id", booking.ID.String()).Msg("Failed to publish to matching queue")
+	// 1. Reserved bookings go to their driver, if the driver can still take them
+	if booking.ReservedDriverId != nil {
+		assigned, err := s.reservations.AssignReservedDriver(ctx, booking)
+		if err != nil {
+			return err
+		}
+		if assigned {
+			return nil
 		}
+	}
+
+	// 2. Push the others to the Matching Queue, through the outbox so a booking can't be
+	// activated without being matched
+	return addOutboxEvent(ctx, s.outboxRepo, domain.TopicDriverMatching, domain.DriverMatchingRequested{BookingID: booking.ID})
+}
+
+// failActivation puts a booking whose activation failed back to SCHEDULED for the next tick,
+// or cancels it once it failed maxActivationAttempts times
+func (s schedulingService) failActivation(ctx context.Context, booking *models.Booking, cause error) error {
+	status := models.BookingStatusScheduled
+	if booking.ActivationAttempts+1 >= maxActivationAttempts {
+		status = models.BookingStatusCancelled
+	}
+	log.Error().Err(cause).
+		Str("booking_id", booking.ID.String()).
+		Int("attempts", booking.ActivationAttempts+1).
+		Str("status", status.String()).
+		Msg("Failed to activate scheduled booking")
 
-		log.Info().Str("booking_id", booking.ID.String()).Msg("Published scheduled booking to driver matching queue")
+	moved, err := s.bookingRepo.FailActivation(ctx, booking.ID, status)
+	if err != nil || !moved || status != models.BookingStatusCancelled {
+		return err
 	}
+	booking.Status = status
+	return addBookingEvent(ctx, s.outboxRepo, domain.BookingCancelled{
+		BookingEvent: newBookingEvent(booking),
+		CancelledBy:  domain.CancelledBySystem,
+	})
 }
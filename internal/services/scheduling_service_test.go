@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// activateDue runs one activation batch over the bookings picking up within the activation window
+func (p *testPlatform) activateDue(t *testing.T) (int, int) {
+	t.Helper()
+	cityCutoffs, cutoff := p.rules.ActivationCutoffs(time.Now())
+	claimed, failed, err := p.scheduler.activateBatch(context.Background(), cityCutoffs, cutoff)
+	require.NoError(t, err)
+	return claimed, failed
+}
+
+func TestActivateReservedBookings(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("The reserved driver is assigned", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		passenger := p.addPassenger(t, "passenger")
+		booking := p.addScheduledBooking(t, passenger, time.Now().Add(10*time.Minute), driver)
+
+		claimed, failed := p.activateDue(t)
+		require.Equal(t, 1, claimed)
+		require.Zero(t, failed)
+
+		activated := p.booking(t, booking.ID)
+		require.Equal(t, models.BookingStatusAccepted, activated.Status)
+		require.Equal(t, driver.ID, *activated.DriverId)
+		require.Equal(t, []DriverNotificationType{DriverNotificationNextRide}, p.notifications.driverTypes(driver.ID))
+	})
+
+	t.Run("A reserved driver who is offline is told the ride went to matching", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		require.NoError(t, p.driverRepo.UpdateAvailability(ctx, driver.ID, false))
+		passenger := p.addPassenger(t, "passenger")
+		booking := p.addScheduledBooking(t, passenger, time.Now().Add(10*time.Minute), driver)
+
+		claimed, failed := p.activateDue(t)
+		require.Equal(t, 1, claimed)
+		require.Zero(t, failed)
+
+		activated := p.booking(t, booking.ID)
+		require.Equal(t, models.BookingStatusRequested, activated.Status)
+		require.Nil(t, activated.ReservedDriverId)
+		require.Nil(t, activated.DriverId)
+		require.Equal(t, []DriverNotificationType{DriverNotificationReservationEnded}, p.notifications.driverTypes(driver.ID))
+		require.Equal(t, []PassengerNotificationType{PassengerNotificationDriverReleased}, p.notifications.passengerTypes(passenger.ID))
+	})
+}
+
+func TestActivationFailures(t *testing.T) {
+	t.Parallel()
+
+	// A reserved booking whose passenger is gone can't get the OTP for the ride start
+	addFailing := func(t *testing.T, p *testPlatform, driver *models.Driver) *models.Booking {
+		t.Helper()
+		gone := &models.Passenger{BaseModel: models.BaseModel{ID: uuid.New()}}
+		return p.addScheduledBooking(t, gone, time.Now().Add(5*time.Minute), driver)
+	}
+
+	t.Run("A failing booking doesn't hold up the rest of the batch", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		driver := p.addDriver(t, "driver", near(1))
+		failing := addFailing(t, p, driver)
+		booking := p.addScheduledBooking(t, p.addPassenger(t, "passenger"), time.Now().Add(10*time.Minute), nil)
+
+		claimed, failed := p.activateDue(t)
+		require.Equal(t, 2, claimed)
+		require.Equal(t, 1, failed)
+		require.Equal(t, models.BookingStatusRequested, p.booking(t, booking.ID).Status)
+
+		// Put back for the next tick
+		retried := p.booking(t, failing.ID)
+		require.Equal(t, models.BookingStatusScheduled, retried.Status)
+		require.Equal(t, 1, retried.ActivationAttempts)
+		require.Equal(t, driver.ID, *retried.ReservedDriverId)
+	})
+
+	t.Run("A booking is cancelled once it failed too often", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		tap := p.tapEvents(t)
+		failing := addFailing(t, p, p.addDriver(t, "driver", near(1)))
+
+		for attempt := 1; attempt < maxActivationAttempts; attempt++ {
+			claimed, failed := p.activateDue(t)
+			require.Equal(t, 1, claimed)
+			require.Equal(t, 1, failed)
+			require.Equal(t, models.BookingStatusScheduled, p.booking(t, failing.ID).Status)
+		}
+		require.NotContains(t, p.relayed(t, tap), "booking.cancelled")
+
+		claimed, failed := p.activateDue(t)
+		require.Equal(t, 1, claimed)
+		require.Equal(t, 1, failed)
+		cancelled := p.booking(t, failing.ID)
+		require.Equal(t, models.BookingStatusCancelled, cancelled.Status)
+		require.Equal(t, maxActivationAttempts, cancelled.ActivationAttempts)
+		require.Contains(t, p.relayed(t, tap), "booking.cancelled")
+
+		// Nothing is left to claim
+		claimed, _ = p.activateDue(t)
+		require.Zero(t, claimed)
+	})
+
+	t.Run("A tick goes on with full batches and stops after a failure", func(t *testing.T) {
+		t.Parallel()
+		p := newTestPlatform(t)
+		p.scheduler.batchSize = 1
+		passenger := p.addPassenger(t, "passenger")
+		first := p.addScheduledBooking(t, passenger, time.Now().Add(2*time.Minute), nil)
+		failing := addFailing(t, p, p.addDriver(t, "driver", near(1)))
+		last := p.addScheduledBooking(t, passenger, time.Now().Add(10*time.Minute), nil)
+
+		// The bookings are claimed in pickup order, the last one waits for the next tick
+		p.scheduler.processScheduledBookings(context.Background())
+		require.Equal(t, models.BookingStatusRequested, p.booking(t, first.ID).Status)
+		require.Equal(t, 1, p.booking(t, failing.ID).ActivationAttempts)
+		require.Equal(t, models.BookingStatusScheduled, p.booking(t, last.ID).Status)
+	})
+}
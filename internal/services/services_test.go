@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/repositories/memory"
+	"CabBookingService/internal/services/geocoding"
+	"CabBookingService/internal/services/pooling"
+	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/services/ranking"
+	"CabBookingService/internal/services/routing"
+	"CabBookingService/internal/services/scheduling"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Pickups and drivers of the tests are placed around this point
+var testPickup = models.ExactLocation{Latitude: 12.9716, Longitude: 77.5946}
+
+// testPlatform is the service stack on in-memory repositories, wired like the API
+type testPlatform struct {
+	store         *memory.Store
+	bookingRepo   repositories.BookingRepository
+	driverRepo    repositories.DriverRepository
+	passengerRepo repositories.PassengerRepository
+	otpRepo       repositories.OTPRepository
+	outboxRepo    repositories.OutboxRepository
+	transactor    repositories.Transactor
+
+	notifications *recordingNotifications
+	deadLetters   *recordingDeadLetters
+	queue         *queue.InMemoryQueue
+	relay         OutboxRelay
+
+	locationService LocationService
+	offerService    OfferService
+	poolingService  PoolingService
+	bookingService  BookingService
+	reservations    ReservationService
+	recurringRides  *recurringRideService
+	scheduler       *schedulingService
+	matching        MatchingSettings
+	rules           scheduling.Rules
+	routing         routing.RoutingProvider
+	geofence        GeofenceService
+	otpService      OTPService
+	tracking        RideTrackingService
+}
+
+type testOption func(*OfferSettings)
+
+// withOfferMode offers every ride in mode instead of broadcasting it
+func withOfferMode(mode OfferMode) testOption {
+	return func(settings *OfferSettings) {
+		settings.DefaultMode = mode
+	}
+}
+
+// withOfferTimeout replaces the one minute acceptance window of sequential offers
+func withOfferTimeout(timeout time.Duration) testOption {
+	return func(settings *OfferSettings) {
+		settings.Timeout = timeout
+	}
+}
+
+func newTestPlatform(t *testing.T, options ...testOption) *testPlatform {
+	t.Helper()
+	store := memory.NewStore()
+	p := &testPlatform{
+		store:         store,
+		bookingRepo:   memory.NewBookingRepository(store),
+		driverRepo:    memory.NewDriverRepository(store),
+		passengerRepo: memory.NewPassengerRepository(store),
+		otpRepo:       memory.NewOTPRepository(store),
+		outboxRepo:    memory.NewOutboxRepository(store),
+		transactor:    memory.NewTransactor(),
+		notifications: &recordingNotifications{},
+		deadLetters:   &recordingDeadLetters{},
+		routing:       routing.NewHaversineProvider(30),
+		rules: scheduling.Rules{
+			MinLead:          20 * time.Minute,
+			MaxHorizon:       7 * 24 * time.Hour,
+			ActivationWindow: 15 * time.Minute,
+		},
+	}
+	p.queue = queue.NewInMemoryQueue(p.deadLetters)
+	p.relay = NewOutboxRelay(p.outboxRepo, p.queue, OutboxSettings{
+		PollInterval: time.Second,
+		BatchSize:    100,
+		ClaimTimeout: time.Minute,
+		RetryBackoff: time.Second,
+		MaxBackoff:   time.Minute,
+		MaxAttempts:  3,
+	})
+
+	offerSettings := OfferSettings{
+		DefaultMode:   OfferModeBroadcast,
+		Timeout:       time.Minute,
+		TopK:          3,
+		SweepInterval: time.Second,
+		SweepBatch:    10,
+	}
+	for _, option := range options {
+		option(&offerSettings)
+	}
+
+	paymentRepo := memory.NewPaymentRepository(store)
+	tripRepo := memory.NewTripRepository(store)
+	p.otpService = NewOTPService(p.otpRepo)
+	p.locationService = NewNaiveLocationService(p.driverRepo)
+	p.geofence = NewGeofenceService(memory.NewGeofenceRepository(store))
+	fareService := NewFareService(p.geofence, p.locationService, p.routing, tripRepo)
+	p.tracking = NewRideTrackingService(p.bookingRepo, p.driverRepo, p.locationService, p.routing, p.transactor, p.outboxRepo, 50)
+	p.offerService = NewOfferService(p.bookingRepo, p.driverRepo, p.notifications, offerSettings)
+	p.poolingService = NewPoolingService(tripRepo, p.bookingRepo, p.locationService, p.routing, pooling.Limits{
+		Capacity:       3,
+		MaxDetourRatio: 1.5,
+		MaxDetour:      10 * time.Minute,
+	})
+	p.matching = MatchingSettings{
+		RankingWeights: ranking.Weights{ETA: 1},
+		Workers:        1,
+		MatchTimeout:   5 * time.Second,
+	}
+	p.bookingService = NewBookingService(p.bookingRepo, p.driverRepo, p.passengerRepo, memory.NewReviewRepository(store),
+		memory.NewSavedPlaceRepository(store), p.otpService, p.locationService, NewPaymentService(paymentRepo, fareService),
+		p.geofence, p.tracking, geocoding.NewNoopGeocoder(), p.notifications, p.offerService, p.poolingService,
+		p.transactor, p.outboxRepo, p.rules)
+	p.reservations = NewReservationService(p.bookingRepo, p.driverRepo, p.passengerRepo, p.otpService, p.routing,
+		p.tracking, p.notifications, p.transactor, p.outboxRepo, ReservationSettings{
+			BrowseHorizon: 24 * time.Hour,
+			Buffer:        15 * time.Minute,
+			ReminderLead:  30 * time.Minute,
+			BatchSize:     10,
+		})
+	p.recurringRides = NewRecurringRideService(memory.NewRecurringRideRepository(store), p.bookingRepo, p.passengerRepo,
+		memory.NewSavedPlaceRepository(store), p.geofence, geocoding.NewNoopGeocoder(), p.bookingService, p.transactor,
+		p.outboxRepo, RecurringRideSettings{
+			Horizon:   3 * 24 * time.Hour,
+			BatchSize: 10,
+			Rules:     p.rules,
+		}).(*recurringRideService)
+	p.scheduler = NewSchedulingService(p.bookingRepo, p.transactor, p.outboxRepo, p.recurringRides, p.reservations, nil, SchedulingSettings{
+		TickInterval: time.Second,
+		BatchSize:    10,
+		Rules:        p.rules,
+	}).(*schedulingService)
+	return p
+}
+
+// addDriver creates an available economy driver at the location
+func (p *testPlatform) addDriver(t *testing.T, name string, location models.ExactLocation) *models.Driver {
+	t.Helper()
+	ctx := context.Background()
+	driver := &models.Driver{
+		AccountId:     uuid.New(),
+		Name:          name,
+		IsAvailable:   true,
+		AverageRating: 4.5,
+		Car:           models.Car{PlateNumber: name, CarType: models.CarTypeEconomy},
+	}
+	require.NoError(t, p.driverRepo.Create(ctx, driver))
+	require.NoError(t, p.locationService.UpdateDriverLocation(ctx, driver.AccountId, location.Latitude, location.Longitude))
+	stored, err := p.driverRepo.GetByID(ctx, driver.ID)
+	require.NoError(t, err)
+	return stored
+}
+
+func (p *testPlatform) addPassenger(t *testing.T, name string) *models.Passenger {
+	t.Helper()
+	passenger := &models.Passenger{AccountId: uuid.New(), Name: name, PhoneNumber: "+910000000000"}
+	require.NoError(t, p.passengerRepo.Create(context.Background(), passenger))
+	return passenger
+}
+
+// addBooking stores a REQUESTED economy booking picking up at the location, without matching it
+func (p *testPlatform) addBooking(t *testing.T, passenger *models.Passenger, pickup models.ExactLocation) *models.Booking {
+	t.Helper()
+	booking := &models.Booking{
+		PassengerId:      passenger.ID,
+		Status:           models.BookingStatusRequested,
+		PickupLatitude:   pickup.Latitude,
+		PickupLongitude:  pickup.Longitude,
+		DropoffLatitude:  pickup.Latitude + 0.05,
+		DropoffLongitude: pickup.Longitude + 0.05,
+		CarType:          models.CarTypeEconomy,
+	}
+	require.NoError(t, p.bookingRepo.Create(context.Background(), booking))
+	return booking
+}
+
+// addScheduledBooking stores a SCHEDULED booking picking up at pickupAt, reserved by the driver unless nil
+func (p *testPlatform) addScheduledBooking(t *testing.T, passenger *models.Passenger, pickupAt time.Time, reservedBy *models.Driver) *models.Booking {
+	t.Helper()
+	booking := &models.Booking{
+		PassengerId:      passenger.ID,
+		Status:           models.BookingStatusScheduled,
+		ScheduledTime:    &pickupAt,
+		PickupLatitude:   testPickup.Latitude,
+		PickupLongitude:  testPickup.Longitude,
+		DropoffLatitude:  testPickup.Latitude + 0.05,
+		DropoffLongitude: testPickup.Longitude + 0.05,
+		CarType:          models.CarTypeEconomy,
+	}
+	if reservedBy != nil {
+		reservedAt := time.Now()
+		booking.ReservedDriverId = &reservedBy.ID
+		booking.ReservedAt = &reservedAt
+	}
+	require.NoError(t, p.bookingRepo.Create(context.Background(), booking))
+	return booking
+}
+
+func (p *testPlatform) booking(t *testing.T, id uuid.UUID) *models.Booking {
+	t.Helper()
+	booking, err := p.bookingRepo.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	return booking
+}
+
+// near returns a location about km kilometres north of testPickup
+func near(km float64) models.ExactLocation {
+	return models.ExactLocation{Latitude: testPickup.Latitude + km/111, Longitude: testPickup.Longitude}
+}
+
+// eventTap receives every event the outbox relay publishes
+type eventTap struct {
+	envelopes chan queue.Envelope
+}
+
+// tapEvents subscribes to the topics of the outbox events, before anything is relayed
+func (p *testPlatform) tapEvents(t *testing.T) *eventTap {
+	t.Helper()
+	tap := &eventTap{envelopes: make(chan queue.Envelope, 100)}
+	for _, topic := range append([]string{domain.TopicDriverMatching}, domain.BookingEventTopics...) {
+		sub, err := p.queue.Subscribe(topic, "test")
+		require.NoError(t, err)
+		t.Cleanup(sub.Unsubscribe)
+		go func() {
+			for delivery := range sub.Deliveries() {
+				tap.envelopes <- delivery.Envelope()
+			}
+		}()
+	}
+	return tap
+}
+
+// relayed relays the due outbox events and returns their event types, in no particular order
+func (p *testPlatform) relayed(t *testing.T, tap *eventTap) []string {
+	t.Helper()
+	delivered := p.relay.RelayDue(context.Background())
+	types := make([]string, 0, delivered)
+	for range delivered {
+		select {
+		case envelope := <-tap.envelopes:
+			types = append(types, envelope.Type)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d of the %d relayed events", len(types), delivered)
+		}
+	}
+	return types
+}
+
+// recordingNotifications keeps the notifications instead of sending them
+type recordingNotifications struct {
+	mu         sync.Mutex
+	drivers    []sentDriverNotification
+	passengers []sentPassengerNotification
+}
+
+type sentDriverNotification struct {
+	DriverID uuid.UUID
+	DriverNotification
+}
+
+type sentPassengerNotification struct {
+	PassengerID uuid.UUID
+	PassengerNotification
+}
+
+func (n *recordingNotifications) NotifyDriver(_ context.Context, driver *models.Driver, notification DriverNotification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.drivers = append(n.drivers, sentDriverNotification{DriverID: driver.ID, DriverNotification: notification})
+	return nil
+}
+
+func (n *recordingNotifications) NotifyPassenger(_ context.Context, passenger *models.Passenger, notification PassengerNotification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.passengers = append(n.passengers, sentPassengerNotification{PassengerID: passenger.ID, PassengerNotification: notification})
+	return nil
+}
+
+// offersTo returns the bookings the driver was offered, in order
+func (n *recordingNotifications) offersTo(driverID uuid.UUID) []uuid.UUID {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var bookingIDs []uuid.UUID
+	for _, sent := range n.drivers {
+		if sent.DriverID == driverID && sent.Type == DriverNotificationRideOffer {
+			bookingIDs = append(bookingIDs, sent.BookingID)
+		}
+	}
+	return bookingIDs
+}
+
+func (n *recordingNotifications) driverTypes(driverID uuid.UUID) []DriverNotificationType {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var types []DriverNotificationType
+	for _, sent := range n.drivers {
+		if sent.DriverID == driverID {
+			types = append(types, sent.Type)
+		}
+	}
+	return types
+}
+
+func (n *recordingNotifications) passengerTypes(passengerID uuid.UUID) []PassengerNotificationType {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var types []PassengerNotificationType
+	for _, sent := range n.passengers {
+		if sent.PassengerID == passengerID {
+			types = append(types, sent.Type)
+		}
+	}
+	return types
+}
+
+// recordingDeadLetters keeps what the in-memory queue gives up on
+type recordingDeadLetters struct {
+	mu      sync.Mutex
+	reasons []error
+}
+
+func (s *recordingDeadLetters) DeadLetter(_ context.Context, _ string, _ queue.Envelope, reason error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reasons = append(s.reasons, reason)
+	return nil
+}
+
+func (s *recordingDeadLetters) all() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.reasons...)
+}
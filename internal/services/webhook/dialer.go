@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned when a receiver resolves to an address partners may not reach
+var ErrForbiddenAddress = errors.New("webhook receiver address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range, not covered by netip's IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr reports whether addr may be dialled for a webhook delivery.
+// Loopback, private, link-local (which includes cloud metadata endpoints), multicast and unspecified addresses are not.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// guardPublic is a net.Dialer control function that refuses connections to non-public addresses.
+// It runs after DNS resolution, on the address actually dialled, so a host re-resolving to an internal
+// address between registration and delivery is still refused.
+func guardPublic(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if !IsPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newDialer returns the dialer of the delivery client, guarded unless allowPrivate is set
+func newDialer(allowPrivate bool) *net.Dialer {
+	dialer := &net.Dialer{}
+	if !allowPrivate {
+		dialer.Control = guardPublic
+	}
+	return dialer
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnexpectedStatus is returned when the receiver answers with anything but 2xx
+var ErrUnexpectedStatus = errors.New("webhook receiver returned an unexpected status")
+
+// Request is one attempt to deliver an event to an endpoint
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID uuid.UUID // Stays the same across retries, receivers use it to drop duplicates
+	EventType  string
+	Body       []byte
+}
+
+// Sender posts signed webhook requests
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender returns a sender that gives up on a receiver after timeout.
+// It only connects to public addresses and never goes through a proxy, see guardPublic.
+func NewSender(timeout time.Duration) *Sender {
+	return newSender(timeout, false)
+}
+
+func newSender(timeout time.Duration, allowPrivate bool) *Sender {
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext:         newDialer(allowPrivate).DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Send posts the request and returns the status code of the receiver, 0 if there was no answer.
+// Redirects are not followed, receivers have to answer on the registered URL.
+func (s *Sender) Send(ctx context.Context, req Request) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, fmt.Errorf("building webhook request: %w", err)
+	}
+	timestamp := s.now()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(HeaderEvent, req.EventType)
+	httpReq.Header.Set(HeaderDelivery, req.DeliveryID.String())
+	httpReq.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, timestamp, req.Body))
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"type":"booking.created"}`)
+	sentAt := time.Unix(1_700_000_000, 0)
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	signature := Sign("secret", sentAt, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		valid     bool
+	}{
+		{"Valid", "secret", timestamp, signature, body, sentAt.Add(time.Minute), true},
+		{"Wrong secret", "other", timestamp, signature, body, sentAt, false},
+		{"Tampered body", "secret", timestamp, signature, []byte(`{"type":"booking.paid"}`), sentAt, false},
+		{"Other timestamp", "secret", strconv.FormatInt(sentAt.Unix()+1, 10), signature, body, sentAt, false},
+		{"Too old", "secret", timestamp, signature, body, sentAt.Add(10 * time.Minute), false},
+		{"Missing prefix", "secret", timestamp, signature[len(signaturePrefix):], body, sentAt, false},
+		{"Broken timestamp", "secret", "yesterday", signature, body, sentAt, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.valid, Verify(tt.secret, tt.timestamp, tt.signature, tt.body, 5*time.Minute, tt.now))
+		})
+	}
+}
+
+func TestSender(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Posts a signed request", func(t *testing.T) {
+		t.Parallel()
+		received := make(chan *http.Request, 1)
+		bodies := make(chan []byte, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- r
+			bodies <- body
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer receiver.Close()
+
+		req := Request{
+			URL:        receiver.URL,
+			Secret:     "secret",
+			DeliveryID: uuid.New(),
+			EventType:  "booking.created",
+			Body:       []byte(`{"id":"1"}`),
+		}
+		status, err := newSender(time.Second, true).Send(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, status)
+
+		r := <-received
+		body := <-bodies
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "booking.created", r.Header.Get(HeaderEvent))
+		require.Equal(t, req.DeliveryID.String(), r.Header.Get(HeaderDelivery))
+		require.Equal(t, req.Body, body)
+		require.True(t, Verify("secret", r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute, time.Now()))
+	})
+
+	t.Run("Fails on error statuses and redirects", func(t *testing.T) {
+		t.Parallel()
+		for _, code := range []int{http.StatusInternalServerError, http.StatusGone, http.StatusFound} {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if code == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(code)
+			}))
+			status, err := newSender(time.Second, true).Send(context.Background(), Request{URL: receiver.URL, Secret: "secret"})
+			receiver.Close()
+			require.ErrorIs(t, err, ErrUnexpectedStatus)
+			require.Equal(t, code, status)
+		}
+	})
+
+	t.Run("Gives up on slow receivers", func(t *testing.T) {
+		t.Parallel()
+		release := make(chan struct{})
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer receiver.Close()
+		defer close(release)
+
+		status, err := newSender(50*time.Millisecond, true).Send(context.Background(), Request{URL: receiver.URL, Secret: "secret"})
+		require.Error(t, err)
+		require.Zero(t, status)
+	})
+	t.Run("Refuses non-public receivers", func(t *testing.T) {
+		t.Parallel()
+		called := false
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer receiver.Close()
+
+		status, err := NewSender(time.Second).Send(context.Background(), Request{URL: receiver.URL, Secret: "secret"})
+		require.ErrorIs(t, err, ErrForbiddenAddress)
+		require.Zero(t, status)
+		require.False(t, called)
+	})
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.public, IsPublicAddr(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Headers of every webhook request
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp" // Unix seconds, part of the signature
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" and the hex encoded HMAC
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature header of a request body sent at timestamp. The HMAC-SHA256 of the
+// secret covers "<timestamp>.<body>", so a captured request can't be replayed with a new timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac(secret, strconv.FormatInt(timestamp.Unix(), 10), body))
+}
+
+// Verify checks the signature and timestamp headers of a request body, as a receiver would.
+// Requests older than tolerance are rejected, a tolerance of zero accepts any age.
+func Verify(secret string, timestampHeader string, signatureHeader string, body []byte, tolerance time.Duration, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return false
+	}
+	if tolerance > 0 && now.Sub(time.Unix(seconds, 0)).Abs() > tolerance {
+		return false
+	}
+	if len(signatureHeader) <= len(signaturePrefix) || signatureHeader[:len(signaturePrefix)] != signaturePrefix {
+		return false
+	}
+	signature, err := hex.DecodeString(signatureHeader[len(signaturePrefix):])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, mac(secret, timestampHeader, body))
+}
+
+func mac(secret string, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
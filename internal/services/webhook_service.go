@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"CabBookingService/internal/domain"
+	"CabBookingService/internal/models"
+	"CabBookingService/internal/repositories"
+	"CabBookingService/internal/services/queue"
+	"CabBookingService/internal/services/webhook"
+	"CabBookingService/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Consumer group of the webhook service
+const groupWebhooks = "webhooks"
+
+var (
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrWebhookNotOwned  = errors.New("webhook does not belong to this partner")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// WebhookSettings tune how deliveries are sent and retried
+type WebhookSettings struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration // Per request to a partner
+	// A delivery claimed by a dispatcher that died is sent again after this long
+	ClaimTimeout time.Duration
+	// Failed deliveries are retried after RetryBackoff, doubling up to MaxBackoff, and marked
+	// failed after MaxAttempts attempts
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	MaxAttempts  int
+}
+
+type RegisterWebhookParams struct {
+	URL        string
+	EventTypes []string // Booking event types, e.g. booking.created
+}
+
+// WebhookService sends the lifecycle events of a partner's bookings to the endpoints it registered.
+// Events are turned into deliveries as they arrive on the queue, a dispatcher sends the deliveries
+// signed with the endpoint's secret and retries failed ones with backoff.
+type WebhookService interface {
+	// RegisterWebhook adds an endpoint for the bookings of the partner. The returned endpoint holds
+	// the signing secret, it isn't shown again.
+	RegisterWebhook(ctx context.Context, partnerAccountID uuid.UUID, params RegisterWebhookParams) (*models.WebhookEndpoint, error)
+	ListWebhooks(ctx context.Context, partnerAccountID uuid.UUID) ([]models.WebhookEndpoint, error)
+	DeleteWebhook(ctx context.Context, partnerAccountID, webhookID uuid.UUID) error
+	// ListDeliveries returns the delivery log of an endpoint, newest first
+	ListDeliveries(ctx context.Context, partnerAccountID, webhookID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error)
+	// ReplayDelivery sends a delivery again, also one that was delivered or ran out of attempts
+	ReplayDelivery(ctx context.Context, partnerAccountID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+
+	// StartConsuming turns booking events into deliveries until ctx is cancelled, counting its
+	// consumers in running
+	StartConsuming(ctx context.Context, running *sync.WaitGroup) error
+	// Start sends due deliveries until ctx is cancelled, counting the dispatcher in running
+	Start(ctx context.Context, running *sync.WaitGroup)
+	// DeliverDue sends the deliveries that are due now and returns how many were attempted
+	DeliverDue(ctx context.Context) int
+}
+
+type webhookService struct {
+	webhookRepo   repositories.WebhookRepository
+	passengerRepo repositories.PassengerRepository
+	queue         queue.MessageQueue
+	sender        *webhook.Sender
+	settings      WebhookSettings
+}
+
+func NewWebhookService(
+	webhookRepo repositories.WebhookRepository,
+	passengerRepo repositories.PassengerRepository,
+	messageQueue queue.MessageQueue,
+	settings WebhookSettings,
+) WebhookService {
+	return &webhookService{
+		webhookRepo:   webhookRepo,
+		passengerRepo: passengerRepo,
+		queue:         messageQueue,
+		sender:        webhook.NewSender(settings.Timeout),
+		settings:      settings,
+	}
+}
+
+func (s *webhookService) RegisterWebhook(ctx context.Context, partnerAccountID uuid.UUID, params RegisterWebhookParams) (*models.WebhookEndpoint, error) {
+	// 1. Validate
+	if err := validateWebhookParams(params); err != nil {
+		return nil, err
+	}
+
+	// 2. Get Partner Profile from Account ID, partners book rides with their passenger account
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, partnerAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Create Endpoint with a fresh signing secret
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	endpoint := &models.WebhookEndpoint{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		PassengerId: passenger.ID,
+		URL:         params.URL,
+		Secret:      secret,
+		EventTypes:  params.EventTypes,
+		IsActive:    true,
+	}
+	if err := s.webhookRepo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("webhook_id", endpoint.ID.String()).
+		Str("passenger_id", passenger.ID.String()).
+		Strs("event_types", endpoint.EventTypes).
+		Msg("Webhook registered")
+	return endpoint, nil
+}
+
+func (s *webhookService) ListWebhooks(ctx context.Context, partnerAccountID uuid.UUID) ([]models.WebhookEndpoint, error) {
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, partnerAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.ListEndpoints(ctx, passenger.ID)
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, partnerAccountID, webhookID uuid.UUID) error {
+	endpoint, err := s.getPartnerEndpoint(ctx, partnerAccountID, webhookID)
+	if err != nil {
+		return err
+	}
+	// Soft delete, the delivery log stays
+	return s.webhookRepo.DeleteEndpoint(ctx, endpoint.ID)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, partnerAccountID, webhookID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error) {
+	endpoint, err := s.getPartnerEndpoint(ctx, partnerAccountID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.ListDeliveries(ctx, endpoint.ID, limit, offset)
+}
+
+func (s *webhookService) ReplayDelivery(ctx context.Context, partnerAccountID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	// 1. Get the delivery and check the partner owns its endpoint
+	delivery, err := s.webhookRepo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+	if _, err := s.getPartnerEndpoint(ctx, partnerAccountID, delivery.EndpointId); err != nil {
+		return nil, err
+	}
+
+	// 2. Make it due now, the dispatcher picks it up on its next poll
+	now := time.Now()
+	if err := s.webhookRepo.Replay(ctx, delivery.ID, now); err != nil {
+		return nil, err
+	}
+	delivery.Status = models.WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+
+	log.Info().Str("delivery_id", delivery.ID.String()).Str("webhook_id", delivery.EndpointId.String()).Msg("Webhook delivery replayed")
+	return delivery, nil
+}
+
+// getPartnerEndpoint returns an endpoint of the partner, ErrWebhookNotOwned if it belongs to someone else
+func (s *webhookService) getPartnerEndpoint(ctx context.Context, partnerAccountID, webhookID uuid.UUID) (*models.WebhookEndpoint, error) {
+	passenger, err := s.passengerRepo.GetByAccountID(ctx, partnerAccountID)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := s.webhookRepo.GetEndpoint(ctx, webhookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	if endpoint.PassengerId != passenger.ID {
+		return nil, ErrWebhookNotOwned
+	}
+	return endpoint, nil
+}
+
+func (s *webhookService) StartConsuming(ctx context.Context, running *sync.WaitGroup) error {
+	for _, topic := range domain.BookingEventTopics {
+		sub, err := s.queue.Subscribe(topic, groupWebhooks)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to topic %s: %w", topic, err)
+		}
+		go func() {
+			<-ctx.Done()
+			sub.Unsubscribe()
+		}()
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			for delivery := range sub.Deliveries() {
+				s.consume(ctx, delivery)
+			}
+		}()
+	}
+	log.Info().Int("topics", len(domain.BookingEventTopics)).Msg("[Webhooks] Started consuming booking events")
+	return nil
+}
+
+// consume adds a delivery for every endpoint of the booking's partner that subscribed to the event.
+// A failure hands the event back to the queue, deliveries already added are skipped next time.
+func (s *webhookService) consume(ctx context.Context, delivery queue.Delivery) {
+	envelope := delivery.Envelope()
+	ackCtx := context.WithoutCancel(ctx)
+
+	if err := s.addDeliveries(ctx, envelope); err != nil {
+		log.Error().Err(err).Str("event_id", envelope.ID.String()).Str("event", envelope.Type).Msg("[Webhooks] Failed to add deliveries")
+		if err := delivery.Nack(ackCtx, err); err != nil {
+			log.Error().Err(err).Str("event_id", envelope.ID.String()).Msg("[Webhooks] Failed to nack event")
+		}
+		return
+	}
+	if err := delivery.Ack(ackCtx); err != nil {
+		log.Error().Err(err).Str("event_id", envelope.ID.String()).Msg("[Webhooks] Failed to ack event")
+	}
+}
+
+func (s *webhookService) addDeliveries(ctx context.Context, envelope queue.Envelope) error {
+	// 1. Every lifecycle event starts with the common booking fields
+	var event domain.BookingEvent
+	if err := json.Unmarshal(envelope.Payload, &event); err != nil {
+		return fmt.Errorf("decoding booking event: %w", err)
+	}
+
+	// 2. Find the endpoints that want it
+	endpoints, err := s.webhookRepo.ListActiveEndpoints(ctx, event.PassengerID)
+	if err != nil {
+		return err
+	}
+
+	// 3. Partners get the whole envelope, with its ID to drop duplicates
+	var payload []byte
+	now := time.Now()
+	for i := range endpoints {
+		if !endpoints[i].Subscribes(envelope.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = (queue.JSONCodec{}).Encode(envelope); err != nil {
+				return err
+			}
+		}
+		err := s.webhookRepo.AddDelivery(ctx, &models.WebhookDelivery{
+			ID:            uuid.New(),
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			EndpointId:    endpoints[i].ID,
+			EventID:       envelope.ID,
+			EventType:     envelope.Type,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) Start(ctx context.Context, running *sync.WaitGroup) {
+	ticker := time.NewTicker(s.settings.PollInterval)
+	log.Info().Dur("interval", s.settings.PollInterval).Msg("Webhook dispatcher started")
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info().Msg("Webhook dispatcher stopped")
+				return
+			case <-ticker.C:
+				// A full batch means there is a backlog, keep going instead of waiting for the next tick
+				for ctx.Err() == nil {
+					if s.DeliverDue(ctx) < s.settings.BatchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (s *webhookService) DeliverDue(ctx context.Context) int {
+	// 1. Claim the due deliveries, so other instances skip them
+	deliveries, err := s.webhookRepo.ClaimDueDeliveries(ctx, time.Now(), s.settings.ClaimTimeout, s.settings.BatchSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to claim webhook deliveries")
+		return 0
+	}
+
+	// 2. Send them in parallel, so one slow partner only holds up its own deliveries for up to the timeout
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			s.deliver(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+func (s *webhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	// 1. The endpoint may have been deleted or switched off since
+	endpoint, err := s.webhookRepo.GetEndpoint(ctx, delivery.EndpointId)
+	if err != nil || !endpoint.IsActive {
+		if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrWebhookNotFound
+		}
+		s.markFailed(ctx, delivery, models.WebhookDeliveryStatusFailed, 0, err)
+		return
+	}
+
+	// 2. Send
+	statusCode, err := s.sender.Send(ctx, webhook.Request{
+		URL:        endpoint.URL,
+		Secret:     endpoint.Secret,
+		DeliveryID: delivery.ID,
+		EventType:  delivery.EventType,
+		Body:       []byte(delivery.Payload),
+	})
+	if err != nil {
+		// 3. Retry with backoff, until the attempts run out
+		status := models.WebhookDeliveryStatusPending
+		if delivery.Attempts+1 >= s.settings.MaxAttempts {
+			status = models.WebhookDeliveryStatusFailed
+		}
+		s.markFailed(ctx, delivery, status, statusCode, err)
+		return
+	}
+
+	// 4. Mark delivered. If this fails the delivery is sent again once the claim times out.
+	if err := s.webhookRepo.MarkDelivered(ctx, delivery.ID, statusCode, time.Now()); err != nil {
+		log.Error().Err(err).Str("delivery_id", delivery.ID.String()).Msg("Failed to mark webhook delivered")
+	}
+}
+
+func (s *webhookService) markFailed(ctx context.Context, delivery *models.WebhookDelivery, status models.WebhookDeliveryStatus, statusCode int, reason error) {
+	nextAttemptAt := time.Now().Add(util.Backoff(s.settings.RetryBackoff, s.settings.MaxBackoff, delivery.Attempts+1))
+	log.Warn().Err(reason).
+		Str("delivery_id", delivery.ID.String()).
+		Str("webhook_id", delivery.EndpointId.String()).
+		Int("status_code", statusCode).
+		Int("attempts", delivery.Attempts+1).
+		Str("status", status.String()).
+		Msg("Webhook delivery failed")
+	if err := s.webhookRepo.MarkFailed(ctx, delivery.ID, status, nextAttemptAt, statusCode, reason.Error()); err != nil {
+		log.Error().Err(err).Str("delivery_id", delivery.ID.String()).Msg("Failed to record webhook delivery failure")
+	}
+}
+
+func validateWebhookParams(params RegisterWebhookParams) error {
+	u, err := url.Parse(params.URL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("%w: url must be an absolute https URL", ErrInvalidWebhook)
+	}
+	// The delivery client refuses non-public addresses when dialling, this only rejects the obvious ones early
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !webhook.IsPublicAddr(addr) {
+		return fmt.Errorf("%w: url must point to a public address", ErrInvalidWebhook)
+	}
+	if host := strings.ToLower(strings.TrimSuffix(u.Hostname(), ".")); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: url must point to a public address", ErrInvalidWebhook)
+	}
+	if len(params.EventTypes) == 0 {
+		return fmt.Errorf("%w: subscribe to at least one event type", ErrInvalidWebhook)
+	}
+	for _, eventType := range params.EventTypes {
+		if !slices.Contains(domain.BookingEventTypes, eventType) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+		}
+	}
+	return nil
+}
+
+// newWebhookSecret returns 32 random bytes, hex encoded
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,16 @@
+package util
+
+import "time"
+
+// Backoff is the wait before retrying something that failed attempts times before: base after the
+// first failure, doubling with every further failure, capped at max.
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	return min(backoff, max)
+}
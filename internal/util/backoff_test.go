@@ -0,0 +1,31 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		attempts int
+		expected time.Duration
+	}{
+		{"Not failed yet", 0, time.Second},
+		{"First failure", 1, time.Second},
+		{"Doubles with every failure", 3, 4 * time.Second},
+		{"Capped", 10, time.Minute},
+		{"Capped without overflowing", 200, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, Backoff(time.Second, time.Minute, tt.attempts))
+		})
+	}
+}
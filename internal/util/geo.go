@@ -22,3 +22,78 @@ func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
 
 	return R * c
 }
+
+// IsValidCoordinate checks that latitude and longitude are within range.
+// (0,0) is rejected as well since it is almost always an unset value rather than a real location.
+func IsValidCoordinate(lat, lon float64) bool {
+	if math.IsNaN(lat) || math.IsNaN(lon) {
+		return false
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return false
+	}
+	return !(lat == 0 && lon == 0)
+}
+
+// PointInPolygon uses the ray casting algorithm to check if a point lies inside a polygon.
+// Vertices are given as [latitude, longitude] pairs, the polygon is closed implicitly.
+// Polygons with less than 3 vertices never contain any point.
+func PointInPolygon(lat, lon float64, vertices [][2]float64) bool {
+	n := len(vertices)
+	if n < 3 {
+		return false
+	}
+
+	inside := false
+	// Cast a ray from the point towards increasing longitude and count edge crossings.
+	// An odd number of crossings means the point is inside.
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		latI, lonI := vertices[i][0], vertices[i][1]
+		latJ, lonJ := vertices[j][0], vertices[j][1]
+
+		if (latI > lat) != (latJ > lat) {
+			crossLon := (lonJ-lonI)*(lat-latI)/(latJ-latI) + lonI
+			if lon < crossLon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// BearingDegrees returns the initial compass bearing from the first point to the second,
+// in degrees clockwise from north within [0, 360)
+func BearingDegrees(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * (math.Pi / 180.0)
+	phi2 := lat2 * (math.Pi / 180.0)
+	dLon := (lon2 - lon1) * (math.Pi / 180.0)
+
+	y := math.Sin(dLon) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLon)
+
+	bearing := math.Atan2(y, x) * (180.0 / math.Pi)
+	return math.Mod(bearing+360, 360)
+}
+
+// AngleDiffDegrees returns the smallest difference between two bearings, within [0, 180]
+func AngleDiffDegrees(a, b float64) float64 {
+	diff := math.Mod(math.Abs(a-b), 360)
+	if diff > 180 {
+		diff = 360 - diff
+	}
+	return diff
+}
+
+// BoundingBox returns a box containing every point within radiusKm of the center. It is meant
+// to narrow down database queries, the exact distance still needs to be checked.
+func BoundingBox(lat, lon, radiusKm float64) (minLat, maxLat, minLon, maxLon float64) {
+	const kmPerDegree = 6371.0 * math.Pi / 180 // Same earth radius as DistanceKm
+
+	dLat := radiusKm / kmPerDegree
+	// Degrees of longitude shrink towards the poles
+	dLon := 180.0
+	if cos := math.Cos(lat * (math.Pi / 180.0)); cos > 1e-9 {
+		dLon = math.Min(180, radiusKm/(kmPerDegree*cos))
+	}
+	return lat - dLat, lat + dLat, lon - dLon, lon + dLon
+}
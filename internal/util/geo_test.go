@@ -0,0 +1,146 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointInPolygon(t *testing.T) {
+	t.Parallel()
+
+	// A square roughly covering central Bengaluru
+	square := [][2]float64{
+		{12.90, 77.50},
+		{12.90, 77.70},
+		{13.05, 77.70},
+		{13.05, 77.50},
+	}
+
+	// An L-shaped (concave) polygon
+	lShape := [][2]float64{
+		{0, 0},
+		{0, 2},
+		{1, 2},
+		{1, 1},
+		{2, 1},
+		{2, 0},
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		polygon  [][2]float64
+		expected bool
+	}{
+		{"Inside square", 12.97, 77.59, square, true},
+		{"Outside square (north)", 13.10, 77.59, square, false},
+		{"Outside square (east)", 12.97, 77.80, square, false},
+		{"Inside L-shape lower arm", 0.5, 1.5, lShape, true},
+		{"Inside L-shape upper arm", 1.5, 0.5, lShape, true},
+		{"In the notch of the L-shape", 1.5, 1.5, lShape, false},
+		{"Degenerate polygon", 0.5, 0.5, [][2]float64{{0, 0}, {1, 1}}, false},
+		{"Empty polygon", 0.5, 0.5, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, PointInPolygon(tt.lat, tt.lon, tt.polygon))
+		})
+	}
+}
+
+func TestIsValidCoordinate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected bool
+	}{
+		{"Valid", 12.97, 77.59, true},
+		{"Null island", 0, 0, false},
+		{"Latitude too large", 91, 10, false},
+		{"Longitude too small", 10, -181, false},
+		{"Equator is fine", 0, 77.59, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.expected, IsValidCoordinate(tt.lat, tt.lon))
+		})
+	}
+}
+
+func TestBearingDegrees(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		expected               float64
+	}{
+		{"North", 12.0, 77.0, 12.1, 77.0, 0},
+		{"East", 0.0, 77.0, 0.0, 77.1, 90},
+		{"South", 12.1, 77.0, 12.0, 77.0, 180},
+		{"West", 0.0, 77.1, 0.0, 77.0, 270},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.InDelta(t, tt.expected, BearingDegrees(tt.lat1, tt.lon1, tt.lat2, tt.lon2), 0.01)
+		})
+	}
+}
+
+func TestAngleDiffDegrees(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		a, b     float64
+		expected float64
+	}{
+		{"Same", 90, 90, 0},
+		{"Opposite", 0, 180, 180},
+		{"Across north", 350, 10, 20},
+		{"Order does not matter", 10, 350, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.InDelta(t, tt.expected, AngleDiffDegrees(tt.a, tt.b), 1e-9)
+		})
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		radiusKm float64
+	}{
+		{"Equator", 0, 10, 5},
+		{"Bengaluru", 12.97, 77.59, 2},
+		{"Far north", 78.2, 15.6, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			minLat, maxLat, minLon, maxLon := BoundingBox(tt.lat, tt.lon, tt.radiusKm)
+
+			// The edges are about radiusKm away from the center
+			require.InDelta(t, tt.radiusKm, DistanceKm(tt.lat, tt.lon, maxLat, tt.lon), 0.01)
+			require.InDelta(t, tt.radiusKm, DistanceKm(tt.lat, tt.lon, minLat, tt.lon), 0.01)
+			require.InDelta(t, tt.radiusKm, DistanceKm(tt.lat, tt.lon, tt.lat, maxLon), 0.05)
+			require.InDelta(t, tt.radiusKm, DistanceKm(tt.lat, tt.lon, tt.lat, minLon), 0.05)
+		})
+	}
+}